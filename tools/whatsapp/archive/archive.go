@@ -0,0 +1,138 @@
+// Package archive defines the chats and messages the logger archives, as
+// its store and APIs return them, for Go code that reads the archive.
+// Every type marshals to the JSON the REST API serves.
+package archive
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A stored message, as handed to post-store hooks and returned by searches
+type Message struct {
+	ID         string    `json:"id"`
+	ChatJID    string    `json:"chat_jid"`
+	ChatName   string    `json:"chat_name,omitempty"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"sender_name,omitempty"`
+	Content    string    `json:"content"`
+	Timestamp  time.Time `json:"timestamp"`
+	IsFromMe   bool      `json:"is_from_me"`
+	MediaType  string    `json:"media_type,omitempty"`
+	Filename   string    `json:"filename,omitempty"`
+	// ID and sender JID of the message this one replies to, in the same chat
+	ReplyTo       string `json:"reply_to,omitempty"`
+	ReplyToSender string `json:"reply_to_sender,omitempty"`
+	// Sender's number in E.164, when the sender is a phone-number JID
+	SenderPhone string `json:"sender_phone,omitempty"`
+	// Set for attachments once downloaded
+	MimeType  string `json:"mime_type,omitempty"`
+	LocalPath string `json:"local_path,omitempty"`
+	// Set instead of LocalPath when the attachment is kept in object storage
+	ObjectURL string `json:"object_url,omitempty"`
+	// Text read from a downloaded image
+	OCRText string `json:"ocr_text,omitempty"`
+	// When the sender last edited the message; nil if never
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// When and by whom the message was deleted for everyone. Content stays
+	// as it was, or is a placeholder when only the deletion was seen.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	RevokedBy string     `json:"revoked_by,omitempty"`
+	// Where a location message points; nil for other messages
+	Location *Location `json:"location,omitempty"`
+	// Pack of a downloaded sticker, when the sticker names one
+	Sticker *Sticker `json:"sticker,omitempty"`
+	// Sent as view-once media; kept only when view-once capture is on
+	IsViewOnce bool `json:"is_view_once,omitempty"`
+	// What changed, for a group change logged as a message; nil for
+	// messages people sent
+	System *SystemEvent `json:"system,omitempty"`
+	// Forwarded rather than written by the sender, and how many times it
+	// had been forwarded on the way
+	IsForwarded     bool `json:"is_forwarded,omitempty"`
+	ForwardingScore int  `json:"forwarding_score,omitempty"`
+	// A business message's buttons, list or template as sent, or the reply
+	// to one; Content holds it flattened to text
+	Interactive json.RawMessage `json:"interactive,omitempty"`
+}
+
+// Forwarding score from which WhatsApp labels a message "Forwarded many
+// times"
+const ForwardedManyTimes = 5
+
+// Kinds of group change logged as a system message
+const (
+	SystemSubject     = "subject"
+	SystemDescription = "description"
+	SystemIcon        = "icon"
+	SystemLocked      = "locked"
+	SystemAnnounce    = "announce"
+	SystemEphemeral   = "ephemeral"
+	SystemApproval    = "approval"
+	SystemInviteLink  = "invite_link"
+	SystemJoin        = "join"
+	SystemLeave       = "leave"
+	SystemPromote     = "promote"
+	SystemDemote      = "demote"
+)
+
+// A group change shown in the chat timeline
+type SystemEvent struct {
+	Kind string `json:"kind"`
+	// "subject" or "description" for the new text, "picture_id" or
+	// "removed" for icons, "state" ("on" or "off") for settings, "timer"
+	// in seconds for disappearing messages and "participants" (comma
+	// separated JIDs) for membership changes
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// The pack a sticker belongs to and the emojis it stands for, as embedded
+// in the sticker file
+type Sticker struct {
+	PackID    string   `json:"pack_id,omitempty"`
+	PackName  string   `json:"pack_name,omitempty"`
+	Publisher string   `json:"publisher,omitempty"`
+	Emojis    []string `json:"emojis,omitempty"`
+}
+
+// A place shared in a location or live-location message
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Radius of uncertainty in metres, 0 when not given
+	Accuracy int    `json:"accuracy,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Address  string `json:"address,omitempty"`
+	Live     bool   `json:"live,omitempty"`
+	// For live locations, seconds the share had been running at this update
+	LiveDuration int `json:"live_duration,omitempty"`
+}
+
+// Identifies a message; IDs are only unique within a chat
+type MessageKey struct {
+	ID      string `json:"id"`
+	ChatJID string `json:"chat_jid"`
+}
+
+// A stored chat
+type Chat struct {
+	JID             string    `json:"jid"`
+	Name            string    `json:"name"`
+	LastMessageTime time.Time `json:"last_message_time"`
+	// E.164 number of a direct chat
+	Phone string `json:"phone,omitempty"`
+	// Earlier JIDs of this conversation, linked with LinkChats
+	LinkedJIDs []string `json:"linked_jids,omitempty"`
+	// A WhatsApp Channel (newsletter) the account follows
+	Channel bool `json:"channel,omitempty"`
+	// Muted, archived or pinned on the phone. MutedUntil is nil for a chat
+	// muted until unmuted.
+	Muted      bool       `json:"muted,omitempty"`
+	MutedUntil *time.Time `json:"muted_until,omitempty"`
+	Archived   bool       `json:"archived,omitempty"`
+	Pinned     bool       `json:"pinned,omitempty"`
+	// The community a group chat belongs to, and whether it is that
+	// community's announcement group
+	Community    string `json:"community,omitempty"`
+	Announcement bool   `json:"announcement,omitempty"`
+}
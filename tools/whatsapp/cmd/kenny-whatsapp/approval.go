@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"whatsapp-logger/internal/outbox"
+	"whatsapp-logger/internal/store"
+)
+
+// Only text is held: files could change before approval, and polls are
+// rarely worth the round trip
+var (
+	errApprovalFile = errors.New("files cannot be held for approval; turn off sending.require_approval to send one")
+	errApprovalPoll = errors.New("polls cannot be held for approval; turn off sending.require_approval to start one")
+)
+
+// Hold a message for approval and say how to approve it
+func holdForApproval(st store.Store, p store.PendingSend) error {
+	id, err := st.AddPendingSend(p)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Held message %d for approval; send it with: kenny-whatsapp approve %d\n", id, id)
+	return nil
+}
+
+// List messages held for approval
+func cmdPending(args []string) error {
+	fs := flag.NewFlagSet("pending", flag.ContinueOnError)
+	all := fs.Bool("all", false, "include approved and rejected messages")
+	asJSON := fs.Bool("json", false, "print messages as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp pending [--all] [--json] [--tz zone]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+	sends, err := st.ListPendingSends(*all)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if sends == nil {
+			sends = []store.PendingSend{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sends)
+	}
+	for _, p := range sends {
+		fmt.Printf("%d  [%s] to %s  %s", p.ID, p.CreatedAt.In(loc).Format(timeLayout), p.ChatJID, p.Status)
+		if p.SendAt != nil {
+			fmt.Printf(", for %s", p.SendAt.In(loc).Format(timeLayout))
+		}
+		fmt.Printf("\n    %s\n", p.Text)
+	}
+	return nil
+}
+
+// Send a held message, or queue it when it is meant for later
+func cmdApprove(args []string) error {
+	id, err := pendingID("approve", args)
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	p, ok, err := st.GetPendingSend(id)
+	if err != nil {
+		st.Close()
+		return err
+	}
+	if !ok || p.Status != store.SendPending {
+		st.Close()
+		return outbox.ErrNotPending
+	}
+	if dryRun {
+		st.Close()
+		fmt.Printf("Would approve message %d to %s: %q\n", id, p.ChatJID, p.Text)
+		return nil
+	}
+	// Only messages due now need a connection
+	later := outbox.Later(p, time.Now())
+	var sender outbox.TextSender
+	if later {
+		defer st.Close()
+	} else {
+		logger, err := sendingLogger(st)
+		if err != nil {
+			return err
+		}
+		defer logger.Disconnect()
+		sender = logger
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if p, err = outbox.Approve(ctx, st, sender, id); err != nil {
+		return err
+	}
+	if later {
+		fmt.Printf("Approved message %d; queued in the outbox\n", id)
+	} else {
+		fmt.Printf("Approved and sent message %d to %s\n", id, p.ChatJID)
+	}
+	return nil
+}
+
+// Drop a held message without sending it
+func cmdReject(args []string) error {
+	id, err := pendingID("reject", args)
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+	if _, err := outbox.Reject(st, id); err != nil {
+		return err
+	}
+	fmt.Printf("Rejected message %d\n", id)
+	return nil
+}
+
+// Read the single held message ID argument of cmd
+func pendingID(cmd string, args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%w: kenny-whatsapp %s <id>", errUsage, cmd)
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid message ID %q", errUsage, args[0])
+	}
+	return id, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/cold"
+)
+
+// Move old messages out of the archive into compressed cold segments
+func cmdArchive(args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ContinueOnError)
+	olderThan := fs.String("older-than", "365d", "move messages older than this age (e.g. 365d, 720h)")
+	dir := fs.String("dir", coldDirPath, "cold storage directory")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	age, err := parseAge(*olderThan)
+	if err != nil || fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp archive [--older-than 365d] [--dir path]", errUsage)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	a, err := cold.Open(*dir)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-age)
+	seg, err := a.Move(st, cutoff)
+	if err != nil {
+		return err
+	}
+	if seg == nil {
+		fmt.Printf("No messages older than %s\n", cutoff.Format("2006-01-02"))
+		return nil
+	}
+	fmt.Printf("Moved %d messages from %d chats (%s to %s) into %s\n",
+		seg.Count, len(seg.Chats), seg.Since.Format("2006-01-02"), seg.Until.Format("2006-01-02"), seg.Name)
+	return nil
+}
+
+// Parse a duration that may also be given in whole days ("30d")
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Open the cold archive if one has been written, so searches can reach it
+func openCold() (*cold.Archive, error) {
+	if _, err := os.Stat(coldDirPath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return cold.Open(coldDirPath)
+}
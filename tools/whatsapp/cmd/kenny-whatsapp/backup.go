@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/backup"
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/schedule"
+	"whatsapp-logger/internal/store"
+)
+
+const backupUsage = "kenny-whatsapp backup [now|list|verify <snapshot>|restore [--dir path] <snapshot>|file [--gzip] [--no-media] <path>]"
+
+// Take, list, verify or restore encrypted snapshots on the configured
+// target, or write a snapshot to a local file
+func cmdBackup(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%w: %s", errUsage, backupUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if args[0] == "file" {
+		return backupFile(cfg, args[1:])
+	}
+	dbKey, err := databaseKey(cfg.Database)
+	if err != nil {
+		return err
+	}
+	b, err := backup.New(cfg.Backup, cfg.Media.Dir, dbKey, waLog.Stdout("Backup", "INFO", true))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "now":
+		st, err := openStore()
+		if err != nil {
+			return fmt.Errorf("failed to open database: %w", err)
+		}
+		defer st.Close()
+		name, err := b.Run(ctx, st)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot %s uploaded and verified\n", name)
+	case "list":
+		names, err := b.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			fmt.Println(n)
+		}
+	case "verify":
+		if len(args) != 2 {
+			return fmt.Errorf("%w: %s", errUsage, backupUsage)
+		}
+		m, err := b.Verify(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s OK: %d messages, %d chats, %d media files, taken %s\n",
+			args[1], m.Messages, m.Chats, len(m.Media), m.CreatedAt.Local().Format("2006-01-02 15:04"))
+	case "restore":
+		fs := flag.NewFlagSet("backup restore", flag.ContinueOnError)
+		dir := fs.String("dir", "restored", "directory to restore into")
+		if err := parseFlags(fs, args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("%w: %s", errUsage, backupUsage)
+		}
+		if err := os.MkdirAll(*dir, 0755); err != nil {
+			return err
+		}
+		m, err := b.Restore(ctx, fs.Arg(0), *dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored %d messages and %d media files into %s\n", m.Messages, len(m.Media), *dir)
+	default:
+		return fmt.Errorf("%w: %s", errUsage, backupUsage)
+	}
+	return nil
+}
+
+// Write a consistent snapshot of the running archive to a local file
+func backupFile(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("backup file", flag.ContinueOnError)
+	gz := fs.Bool("gzip", false, "gzip the snapshot")
+	noMedia := fs.Bool("no-media", false, "only list media files in the manifest instead of including them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: %s", errUsage, backupUsage)
+	}
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+	m, err := backup.WriteFile(st, cfg.Media.Dir, fs.Arg(0), backup.FileOptions{Gzip: *gz, WithMedia: !*noMedia})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s: %d messages, %d chats, %d media files\n", fs.Arg(0), m.Messages, m.Chats, len(m.Media))
+	return nil
+}
+
+// Unpack a snapshot written by `backup file` and check it
+func cmdRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dir := fs.String("dir", "restored", "directory to restore into")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: kenny-whatsapp restore [--dir path] <file>", errUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	dbKey, err := databaseKey(cfg.Database)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	m, err := backup.RestoreFile(fs.Arg(0), *dir, dbKey)
+	if err != nil {
+		return err
+	}
+	if m.MediaOmitted {
+		fmt.Printf("Restored %d messages into %s; the %d media files were not included\n", m.Messages, *dir, len(m.Media))
+		return nil
+	}
+	fmt.Printf("Restored %d messages and %d media files into %s\n", m.Messages, len(m.Media), *dir)
+	return nil
+}
+
+// Take snapshots on the configured schedule until ctx is cancelled
+func startBackups(ctx context.Context, cfg *config.Config, st *store.SQLiteStore) error {
+	sched, err := schedule.Parse(cfg.Backup.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid backup schedule: %w", err)
+	}
+	dbKey, err := databaseKey(cfg.Database)
+	if err != nil {
+		return err
+	}
+	log := waLog.Stdout("Backup", "INFO", true)
+	b, err := backup.New(cfg.Backup, cfg.Media.Dir, dbKey, log)
+	if err != nil {
+		return err
+	}
+	go schedule.Run(ctx, sched, func(ctx context.Context) {
+		if _, err := b.Run(ctx, st); err != nil {
+			log.Errorf("Backup failed: %v", err)
+		}
+	})
+	log.Infof("Backups scheduled (%s), next at %s", cfg.Backup.Schedule, sched.Next(time.Now()).Format("2006-01-02 15:04"))
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"whatsapp-logger/internal/config"
+)
+
+// Send one message to every chat listed in a file, pausing between sends
+func cmdBroadcast(args []string) error {
+	fs := flag.NewFlagSet("broadcast", flag.ContinueOnError)
+	to := fs.String("to", "", "file listing one chat JID or phone number per line (# starts a comment)")
+	delay := fs.Duration("delay", 15*time.Second, "pause between messages")
+	jitter := fs.Duration("jitter", 10*time.Second, "add up to this much at random to each pause")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	text := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if *to == "" || text == "" || *delay < 0 || *jitter < 0 {
+		return fmt.Errorf("%w: kenny-whatsapp broadcast --to file [--delay 15s] [--jitter 10s] <text>", errUsage)
+	}
+	recipients, err := readRecipients(*to)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Sending.RequireApproval && !dryRun {
+		return fmt.Errorf("broadcasts cannot be held for approval; turn off sending.require_approval to send one")
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("%w: %s lists no recipients", errUsage, *to)
+	}
+
+	if dryRun {
+		fmt.Printf("Would send to %d chats:\n", len(recipients))
+		for _, r := range recipients {
+			fmt.Println(r)
+		}
+		fmt.Printf("\n%s\n", text)
+		return nil
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	logger, err := sendingLogger(st)
+	if err != nil {
+		return err
+	}
+	defer logger.Disconnect()
+	if err := configureSending(logger, cfg.Sending); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sent := 0
+	for i, chat := range recipients {
+		if i > 0 {
+			pause := *delay
+			if *jitter > 0 {
+				pause += time.Duration(rand.Int63n(int64(*jitter)))
+			}
+			select {
+			case <-time.After(pause):
+			case <-ctx.Done():
+				return fmt.Errorf("stopped after %d of %d chats", sent, len(recipients))
+			}
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := logger.SendText(sendCtx, chat, text)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to send to %s: %v", chat, err)
+			continue
+		}
+		sent++
+		fmt.Printf("Sent to %s (%d/%d)\n", chat, i+1, len(recipients))
+	}
+	if sent < len(recipients) {
+		return fmt.Errorf("sent to %d of %d chats", sent, len(recipients))
+	}
+	return nil
+}
+
+// Read the chats listed in a file, one per line, skipping blank lines,
+// comments and repeats
+func readRecipients(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var chats []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		chat, err := resolveChat(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, n, err)
+		}
+		if !seen[chat] {
+			seen[chat] = true
+			chats = append(chats, chat)
+		}
+	}
+	return chats, scanner.Err()
+}
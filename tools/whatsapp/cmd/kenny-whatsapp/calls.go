@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Print calls across chats, or in one chat
+func cmdCalls(args []string) error {
+	fs := flag.NewFlagSet("calls", flag.ContinueOnError)
+	chat := fs.String("chat", "", "only calls in this chat")
+	asJSON := fs.Bool("json", false, "print calls as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp calls [--chat jid] [--json] [--tz zone]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	if *chat, err = resolveChat(*chat); err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	calls, err := st.ListCalls(*chat)
+	if err != nil {
+		return fmt.Errorf("failed to list calls: %w", err)
+	}
+	if *asJSON {
+		if calls == nil {
+			calls = []store.Call{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(calls)
+	}
+
+	for _, c := range calls {
+		kind, direction := "voice", "incoming"
+		if c.IsVideo {
+			kind = "video"
+		}
+		if c.IsFromMe {
+			direction = "outgoing"
+		}
+		fmt.Printf("[%s] %s %s call, %s: %s", c.StartedAt.In(loc).Format(timeLayout), direction, kind, c.ChatJID, c.Outcome)
+		if c.Outcome == store.CallAnswered && c.EndedAt != nil {
+			fmt.Printf(" (%s)", time.Duration(c.Duration)*time.Second)
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"whatsapp-logger/internal/stats"
+)
+
+// Pull the full contact list from the phone and store every name and
+// number
+func cmdSyncContacts(args []string) error {
+	fs := flag.NewFlagSet("sync-contacts", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp sync-contacts", errUsage)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	logger, err := connectLogger(st)
+	if err != nil {
+		return err
+	}
+	defer logger.Disconnect()
+
+	n, err := logger.SyncContacts(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Synced %d contacts\n", n)
+	return nil
+}
+
+// Rank the people and groups I talk with by how much, how lately, or how
+// conspicuously I've stopped writing to them
+func cmdTopContacts(args []string) error {
+	fs := flag.NewFlagSet("top-contacts", flag.ContinueOnError)
+	since := fs.String("since", "", "first day to include, YYYY-MM-DD (default: 90 days ago)")
+	until := fs.String("until", "", "day after the last one to include, YYYY-MM-DD (default: now)")
+	by := fs.String("sort", stats.ByVolume, "rank by \"volume\", \"recency\" or \"neglect\"")
+	limit := fs.Int("limit", 20, "contacts to list")
+	direct := fs.Bool("direct", false, "leave groups out")
+	minMessages := fs.Int("min-messages", 5, "messages a contact needs in the window to rank by neglect")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *limit <= 0 || (*by != stats.ByVolume && *by != stats.ByRecency && *by != stats.ByNeglect) {
+		return fmt.Errorf("%w: kenny-whatsapp top-contacts [--since DATE] [--until DATE] [--sort volume|recency|neglect] [--limit N] [--direct] [--min-messages N] [--json] [--tz zone]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	opts := stats.ContactOptions{
+		Location:    loc,
+		Sort:        *by,
+		Limit:       *limit,
+		DirectOnly:  *direct,
+		MinMessages: *minMessages,
+		Since:       time.Now().AddDate(0, 0, -90),
+	}
+	if *since != "" {
+		if opts.Since, err = time.ParseInLocation("2006-01-02", *since, loc); err != nil {
+			return fmt.Errorf("%w: invalid --since date %q", errUsage, *since)
+		}
+	}
+	if *until != "" {
+		if opts.Until, err = time.ParseInLocation("2006-01-02", *until, loc); err != nil {
+			return fmt.Errorf("%w: invalid --until date %q", errUsage, *until)
+		}
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	report, err := stats.Contacts(st, opts)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	if len(report.Contacts) == 0 {
+		fmt.Println("No contacts")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTACT\tMESSAGES\tSENT:RECEIVED\tPER WEEK\tLAST MESSAGE\tI LAST WROTE")
+	for _, c := range report.Contacts {
+		name := c.Name
+		if name == "" {
+			name = c.JID
+		}
+		if c.Group {
+			name += " (group)"
+		}
+		ratio := "-"
+		if c.SentRatio != nil {
+			ratio = fmt.Sprintf("%.2f", *c.SentRatio)
+		}
+		wrote := "not in range"
+		if c.LastSent != nil {
+			wrote = daysAgo(c.DaysSinceSent)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d:%d (%s)\t%.1f\t%s\t%s\n", name, c.Messages, c.Sent, c.Received, ratio, c.PerWeek, daysAgo(c.DaysQuiet), wrote)
+	}
+	return tw.Flush()
+}
+
+// "today", "1 day ago" or "N days ago"
+func daysAgo(days int) string {
+	switch days {
+	case 0:
+		return "today"
+	case 1:
+		return "1 day ago"
+	}
+	return fmt.Sprintf("%d days ago", days)
+}
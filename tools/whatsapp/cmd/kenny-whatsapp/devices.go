@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/store"
+	"whatsapp-logger/internal/wa"
+)
+
+// List the account's linked devices, or unlink a stale session
+func cmdDevices(args []string) error {
+	fs := flag.NewFlagSet("devices", flag.ContinueOnError)
+	unlink := fs.String("unlink", "", "unlink the device with this JID (must have its session in "+sessionDBPath+")")
+	asJSON := fs.Bool("json", false, "print devices as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp devices [--json] [--unlink device_jid]", errUsage)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	logger, err := connectLogger(st)
+	if err != nil {
+		return err
+	}
+	defer logger.Disconnect()
+
+	ctx := context.Background()
+	if *unlink != "" {
+		if err := logger.UnlinkDevice(ctx, *unlink); err != nil {
+			return err
+		}
+		fmt.Printf("Unlinked %s\n", *unlink)
+		return nil
+	}
+
+	devices, err := logger.Devices(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(devices)
+	}
+	for _, d := range devices {
+		var notes []string
+		switch {
+		case d.Primary:
+			notes = append(notes, "phone")
+		case d.Current:
+			notes = append(notes, "this logger")
+		case d.Local:
+			notes = append(notes, "older session of this logger")
+		}
+		if d.Platform != "" {
+			notes = append(notes, d.Platform)
+		}
+		fmt.Printf("%s  %s\n", d.JID, strings.Join(notes, ", "))
+	}
+	return nil
+}
+
+// Connect a logger on the existing session, refusing to start pairing.
+// Takes ownership of st.
+func connectLogger(st store.Store) (*wa.Logger, error) {
+	session, err := sessionDB()
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+	logger, err := wa.NewWithStore(session, st)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	if !logger.Paired() {
+		logger.Disconnect()
+		return nil, fmt.Errorf("%w; run start first", wa.ErrNotPaired)
+	}
+	if err := logger.Connect(); err != nil {
+		logger.Disconnect()
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	if err := logger.WaitForConnection(30 * time.Second); err != nil {
+		logger.Disconnect()
+		return nil, err
+	}
+	return logger, nil
+}
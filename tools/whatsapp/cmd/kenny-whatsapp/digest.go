@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/digest"
+	"whatsapp-logger/internal/notify"
+	"whatsapp-logger/internal/schedule"
+	"whatsapp-logger/internal/store"
+	"whatsapp-logger/internal/wa"
+)
+
+// Print a group digest for a recent period, as `start` would send it, or
+// with --unreplied the conversations waiting on my answer
+func cmdDigest(args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	since := fs.String("since", "", "period to cover, e.g. 24h or 7d (default: 24h, or 7d with --unreplied)")
+	top := fs.Int("top", 5, "most active participants to list")
+	unreplied := fs.Bool("unreplied", false, "list direct messages and mentions I haven't answered instead of a group digest")
+	hours := fs.Int("hours", 24, "with --unreplied, hours a message must have waited")
+	asJSON := fs.Bool("json", false, "print the digest as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *unreplied && (fs.NArg() != 0 || *hours < 0) || !*unreplied && fs.NArg() != 1 {
+		return fmt.Errorf("%w: kenny-whatsapp digest [--since 24h] [--top N] [--json] [--tz zone] <group_jid>, or digest --unreplied [--since 7d] [--hours N] [--json] [--tz zone]", errUsage)
+	}
+	if *since == "" {
+		*since = "24h"
+		if *unreplied {
+			*since = "7d"
+		}
+	}
+	age, err := parseAge(*since)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --since: %v", errUsage, err)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	now := time.Now()
+	var r interface{ Text(*time.Location) string }
+	if *unreplied {
+		r, err = digest.Unreplied(st, now.Add(-age), time.Duration(*hours)*time.Hour)
+	} else {
+		r, err = digest.Group(st, fs.Arg(0), now.Add(-age), now, *top)
+	}
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	fmt.Println(r.Text(loc))
+	return nil
+}
+
+// Send the configured group digests on their schedules until ctx is cancelled
+func startDigests(ctx context.Context, cfg *config.Config, st store.Store, logger *wa.Logger) error {
+	loc, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	log := waLog.Stdout("Digest", "INFO", true)
+	sinks := map[string]notify.Notifier{}
+	for _, s := range notify.SinksFromConfig(cfg.Notifications.Sinks) {
+		sinks[s.Name()] = s
+	}
+
+	for i, d := range cfg.Digests.Groups {
+		if d.SendTo == "" && len(d.Sinks) == 0 {
+			return fmt.Errorf("digest %d: set send_to or sinks", i+1)
+		}
+		sched, err := schedule.Parse(d.Schedule)
+		if err != nil {
+			return fmt.Errorf("digest %d: invalid schedule: %w", i+1, err)
+		}
+		go schedule.Run(ctx, sched, func(ctx context.Context) {
+			// Called just after the slot, so Prev gives the slot itself
+			until := sched.Prev(time.Now())
+			since := sched.Prev(until)
+			for _, chat := range d.Chats {
+				r, err := digest.Group(st, chat, since, until, d.Top)
+				if err != nil {
+					log.Errorf("Digest for %s failed: %v", chat, err)
+					continue
+				}
+				if r.Empty() && !d.SendEmpty {
+					continue
+				}
+				deliverDigest(ctx, log, logger, sinks, d, r.Name, r.Text(loc))
+			}
+		})
+		log.Infof("Digest of %d chats scheduled (%s), next at %s", len(d.Chats), d.Schedule, sched.Next(time.Now()).Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// Post one digest to its chat and sinks, logging failures
+func deliverDigest(ctx context.Context, log waLog.Logger, logger *wa.Logger, sinks map[string]notify.Notifier, d config.GroupDigest, title, text string) {
+	if d.SendTo != "" {
+		to := d.SendTo
+		if to == "self" {
+			to = logger.SelfChatJID()
+		}
+		if err := logger.SendText(ctx, to, text); err != nil {
+			log.Warnf("Failed to send digest to %s: %v", to, err)
+		}
+	}
+	for _, name := range d.Sinks {
+		sink, ok := sinks[name]
+		if !ok {
+			log.Warnf("Notification sink %q is not configured", name)
+			continue
+		}
+		if err := sink.Notify(ctx, notify.Notification{Title: "Digest: " + title, Body: text, Priority: 1}); err != nil {
+			log.Warnf("Failed to deliver digest to %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/bundle"
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/export"
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/store"
+)
+
+// Write the archive, or one chat of it, out in another format
+func cmdExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "bundle", "output format: bundle, txt, thread, jsonl, csv, parquet, html, pdf")
+	out := fs.String("out", "", "output file, or directory for parquet (default: kenny-whatsapp-<date> with the format's extension; - for stdout with jsonl, csv, html and pdf)")
+	chat := fs.String("chat", "", "only export this chat JID (required for txt, thread, html and pdf, which also take it as an argument)")
+	since := fs.String("since", "", "jsonl, csv, parquet, html and pdf: first day to include, YYYY-MM-DD")
+	until := fs.String("until", "", "jsonl, csv, parquet, html and pdf: day after the last one to include, YYYY-MM-DD")
+	columns := fs.String("columns", strings.Join(export.DefaultCSVColumns, ","), "csv columns, comma-separated, from: "+strings.Join(export.CSVColumns, ", "))
+	bom := fs.Bool("bom", false, "csv: start with a byte order mark so Excel reads it as UTF-8")
+	style := fs.String("style", export.StyleAndroid, "txt layout: android or ios")
+	me := fs.String("me", "Me", "txt, html and pdf name for my own messages")
+	font := fs.String("font", "", "pdf: TrueType font file for the text (default: a system font covering most scripts)")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || (fs.NArg() == 1 && *chat != "") {
+		return fmt.Errorf("%w: kenny-whatsapp export [--format bundle|txt|thread|jsonl|csv|parquet|html|pdf] [--out file] [--chat JID] [--since DATE] [--until DATE] [--columns list] [--tz zone] [chat_jid]", errUsage)
+	}
+	if fs.NArg() == 1 {
+		*chat = fs.Arg(0)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	ranged := export.RangeOptions{Location: loc}
+	if *since != "" {
+		if ranged.Since, err = time.ParseInLocation("2006-01-02", *since, loc); err != nil {
+			return fmt.Errorf("%w: invalid --since date %q", errUsage, *since)
+		}
+	}
+	if *until != "" {
+		if ranged.Until, err = time.ParseInLocation("2006-01-02", *until, loc); err != nil {
+			return fmt.Errorf("%w: invalid --until date %q", errUsage, *until)
+		}
+	}
+	if (*since != "" || *until != "") && !slices.Contains([]string{"jsonl", "csv", "parquet", "html", "pdf"}, *format) {
+		return fmt.Errorf("%w: --since and --until need --format jsonl, csv, parquet, html or pdf", errUsage)
+	}
+	csvColumns := splitList(*columns)
+	for _, col := range csvColumns {
+		if !slices.Contains(export.CSVColumns, col) {
+			return fmt.Errorf("%w: unknown CSV column %q, use: %s", errUsage, col, strings.Join(export.CSVColumns, ", "))
+		}
+	}
+	if *chat, err = resolveChat(*chat); err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	switch *format {
+	case "bundle":
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		return exportBundle(st, *out, bundle.Options{ChatJID: *chat, Location: loc, Storage: media.StorageFromConfig(cfg.Media)})
+	case "txt":
+		if *chat == "" {
+			return fmt.Errorf("%w: --format txt needs --chat", errUsage)
+		}
+		return exportText(st, *out, *chat, export.TextOptions{Style: *style, Me: *me, Location: loc})
+	case "thread":
+		if *chat == "" {
+			return fmt.Errorf("%w: --format thread needs --chat", errUsage)
+		}
+		return exportThreads(st, *out, *chat, export.ThreadOptions{Location: loc, Indent: true})
+	case "jsonl":
+		ranged.ChatJID = *chat
+		return exportRange(*out, datedName("jsonl"), func(w io.Writer) (int, error) {
+			return export.WriteJSONL(w, st, ranged)
+		})
+	case "csv":
+		ranged.ChatJID = *chat
+		return exportRange(*out, datedName("csv"), func(w io.Writer) (int, error) {
+			return export.WriteCSV(w, st, export.CSVOptions{RangeOptions: ranged, Columns: csvColumns, BOM: *bom})
+		})
+	case "parquet":
+		if *out == "-" {
+			return fmt.Errorf("%w: --format parquet writes a directory, not stdout", errUsage)
+		}
+		ranged.ChatJID = *chat
+		return exportParquet(st, *out, ranged)
+	case "html":
+		if *chat == "" {
+			return fmt.Errorf("%w: --format html needs a chat", errUsage)
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("kenny-whatsapp-%s.html", strings.SplitN(*chat, "@", 2)[0])
+		return exportRange(*out, name, func(w io.Writer) (int, error) {
+			return export.WriteHTML(w, st, *chat, export.HTMLOptions{RangeOptions: ranged, Me: *me, MediaDir: cfg.Media.Dir})
+		})
+	case "pdf":
+		if *chat == "" {
+			return fmt.Errorf("%w: --format pdf needs a chat", errUsage)
+		}
+		name := fmt.Sprintf("kenny-whatsapp-%s.pdf", strings.SplitN(*chat, "@", 2)[0])
+		return exportRange(*out, name, func(w io.Writer) (int, error) {
+			return export.WritePDF(w, st, *chat, export.PDFOptions{RangeOptions: ranged, Me: *me, Font: *font})
+		})
+	default:
+		return fmt.Errorf("%w: unknown export format %q", errUsage, *format)
+	}
+}
+
+// Write a portable single-file bundle another install can import
+func exportBundle(st store.Store, path string, opts bundle.Options) error {
+	if path == "" {
+		path = fmt.Sprintf("kenny-whatsapp-%s.zip", time.Now().Format("2006-01-02"))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	m, err := bundle.Write(context.Background(), st, f, opts)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	fmt.Printf("Exported %d chats, %d contacts, %d messages and %d media files to %s\n", m.Chats, m.Contacts, m.Messages, len(m.Media), path)
+	return nil
+}
+
+// Write one chat in WhatsApp's own "Export chat" text layout
+func exportText(st store.Store, path, chat string, opts export.TextOptions) error {
+	if path == "" {
+		path = fmt.Sprintf("WhatsApp Chat - %s.txt", strings.SplitN(chat, "@", 2)[0])
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create export: %w", err)
+	}
+	n, err := export.WriteText(f, st, chat, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	fmt.Printf("Exported %d messages to %s\n", n, path)
+	return nil
+}
+
+// Write one chat as a JSON tree following reply links
+func exportThreads(st store.Store, path, chat string, opts export.ThreadOptions) error {
+	if path == "" {
+		path = fmt.Sprintf("kenny-whatsapp-thread-%s.json", strings.SplitN(chat, "@", 2)[0])
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create export: %w", err)
+	}
+	n, err := export.WriteThreads(f, st, chat, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	fmt.Printf("Exported %d messages to %s\n", n, path)
+	return nil
+}
+
+// kenny-whatsapp-<date>.<ext>, for exports that may cover every chat
+func datedName(ext string) string {
+	return fmt.Sprintf("kenny-whatsapp-%s.%s", time.Now().Format("2006-01-02"), ext)
+}
+
+// Write an export of the messages in a range with write to path (or
+// defaultPath when empty), or to stdout for path "-"
+func exportRange(path, defaultPath string, write func(io.Writer) (int, error)) error {
+	if path == "-" {
+		_, err := write(os.Stdout)
+		return err
+	}
+	if path == "" {
+		path = defaultPath
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create export: %w", err)
+	}
+	n, err := write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	fmt.Printf("Exported %d messages to %s\n", n, path)
+	return nil
+}
+
+// Write messages as Parquet files partitioned by month under a directory
+func exportParquet(st store.Store, dir string, opts export.RangeOptions) error {
+	if dir == "" {
+		dir = fmt.Sprintf("kenny-whatsapp-parquet-%s", time.Now().Format("2006-01-02"))
+	}
+	res, err := export.WriteParquet(dir, st, opts)
+	if err != nil {
+		return fmt.Errorf("failed to write parquet export: %w", err)
+	}
+	fmt.Printf("Exported %d messages in %d monthly partitions to %s\n", res.Messages, len(res.Months), dir)
+	return nil
+}
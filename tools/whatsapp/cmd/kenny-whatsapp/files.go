@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/media"
+)
+
+// List the documents shared across chats, or in one chat
+func cmdFiles(args []string) error {
+	fs := flag.NewFlagSet("files", flag.ContinueOnError)
+	chat := fs.String("chat", "", "only documents in this chat")
+	asJSON := fs.Bool("json", false, "print documents as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp files [--chat jid] [--json] [--tz zone]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	if *chat, err = resolveChat(*chat); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	files, err := media.Documents(st, cfg.Media.Dir, *chat)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(files)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tFILE\tSIZE\tFROM\tCHAT\tLOCAL")
+	for _, f := range files {
+		name := f.Filename
+		if name == "" {
+			name = "(unnamed)"
+		}
+		sender := f.SenderName
+		if f.IsFromMe {
+			sender = "me"
+		} else if sender == "" {
+			sender = f.Sender
+		}
+		chatName := f.ChatName
+		if chatName == "" {
+			chatName = f.ChatJID
+		}
+		local := "-"
+		if f.LocalPath != "" {
+			local = f.LocalPath
+		} else if f.ObjectURL != "" {
+			local = f.ObjectURL
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", f.Timestamp.In(loc).Format("2006-01-02 15:04"), name,
+			formatSize(f.Size), sender, chatName, local)
+	}
+	return tw.Flush()
+}
+
+// Human-readable byte count, "?" when unknown
+func formatSize(n int64) string {
+	if n <= 0 {
+		return "?"
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
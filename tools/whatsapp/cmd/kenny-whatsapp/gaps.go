@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"whatsapp-logger/internal/gaps"
+	"whatsapp-logger/internal/store"
+)
+
+// Report suspicious gaps in chat history and optionally ask WhatsApp to fill them
+func cmdGaps(args []string) error {
+	fs := flag.NewFlagSet("gaps", flag.ContinueOnError)
+	chat := fs.String("chat", "", "only check this chat")
+	minGap := fs.String("min-gap", "3d", "shortest silence to consider")
+	minExpected := fs.Float64("min-expected", 20, "report silences in which the chat's usual rate predicts at least this many messages")
+	minMessages := fs.Int("min-messages", 50, "skip chats with fewer messages")
+	resync := fs.Bool("resync", false, "request the missing history from the phone for each gap")
+	count := fs.Int("count", 100, "messages to request per gap with --resync")
+	wait := fs.Duration("wait", 2*time.Minute, "how long to keep receiving history after --resync requests")
+	asJSON := fs.Bool("json", false, "print gaps as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp gaps [--chat jid] [--min-gap 3d] [--min-expected N] [--resync] [--json] [--tz zone]", errUsage)
+	}
+	age, err := parseAge(*minGap)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --min-gap: %v", errUsage, err)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	if *chat, err = resolveChat(*chat); err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	found, err := gaps.Find(st, gaps.Options{ChatJID: *chat, MinGap: age, MinExpected: *minExpected, MinMessages: *minMessages})
+	if err != nil {
+		st.Close()
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(found); err != nil {
+			st.Close()
+			return err
+		}
+	} else {
+		for _, g := range found {
+			name := g.ChatName
+			if name == "" {
+				name = g.ChatJID
+			}
+			fmt.Printf("%s: %.1f days silent, %s to %s (about %.0f messages expected)\n", name, g.Days,
+				g.Start.In(loc).Format("2006-01-02 15:04"), g.End.In(loc).Format("2006-01-02 15:04"), g.Expected)
+		}
+		if len(found) == 0 {
+			fmt.Println("No suspicious gaps found.")
+		}
+	}
+	if !*resync || len(found) == 0 {
+		return st.Close()
+	}
+	return resyncGaps(st, found, *count, *wait)
+}
+
+// Connect, request history before the end of each gap, and give the phone
+// time to answer. Takes ownership of st.
+func resyncGaps(st store.Store, found []gaps.Gap, count int, wait time.Duration) error {
+	before, err := st.MessageCount()
+	if err != nil {
+		st.Close()
+		return err
+	}
+	logger, err := connectLogger(st)
+	if err != nil {
+		return fmt.Errorf("cannot resync: %w", err)
+	}
+	defer logger.Disconnect()
+
+	ctx := context.Background()
+	for _, g := range found {
+		if err := logger.RequestHistoryBefore(ctx, g.After, count); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Requested history for %d gaps, waiting %s for it to arrive...\n", len(found), wait)
+	time.Sleep(wait)
+
+	after, err := st.MessageCount()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %d messages\n", after-before)
+	return nil
+}
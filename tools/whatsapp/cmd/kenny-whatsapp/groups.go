@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Print the synced groups, or one group with its members
+func cmdGroups(args []string) error {
+	fs := flag.NewFlagSet("groups", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print groups as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("%w: kenny-whatsapp groups [--json] [--tz zone] [group_jid]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if fs.NArg() == 0 {
+		groups, err := st.ListGroups()
+		if err != nil {
+			return fmt.Errorf("failed to list groups: %w", err)
+		}
+		if *asJSON {
+			if groups == nil {
+				groups = []store.Group{}
+			}
+			return enc.Encode(groups)
+		}
+		for _, g := range groups {
+			fmt.Printf("%s (%s): %d members%s\n", g.Subject, g.JID, g.Size, communityTag(g))
+		}
+		return nil
+	}
+
+	g, err := st.GetGroup(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return enc.Encode(g)
+	}
+	fmt.Printf("%s (%s)%s\n", g.Subject, g.JID, communityTag(g))
+	if !g.CreatedAt.IsZero() {
+		fmt.Printf("Created %s", g.CreatedAt.In(loc).Format(timeLayout))
+		if g.Owner != "" {
+			fmt.Printf(" by %s", g.Owner)
+		}
+		fmt.Println()
+	}
+	if g.Description != "" {
+		fmt.Printf("\n%s\n", g.Description)
+	}
+	if g.IsCommunity {
+		fmt.Printf("\n%d groups\n", len(g.Subgroups))
+		for _, sub := range g.Subgroups {
+			fmt.Printf("  %s (%s): %d members", sub.Subject, sub.JID, sub.Size)
+			if sub.IsAnnouncement {
+				fmt.Print(" [announcements]")
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Printf("\n%d members, synced %s\n", g.Size, g.SyncedAt.In(loc).Format(timeLayout))
+	for _, p := range g.Participants {
+		fmt.Printf("  %s", p.JID)
+		if p.PhoneJID != "" {
+			fmt.Printf(" (%s)", p.PhoneJID)
+		}
+		switch {
+		case p.IsSuperAdmin:
+			fmt.Print(" [owner]")
+		case p.IsAdmin:
+			fmt.Print(" [admin]")
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// Where a group sits in a community, as a suffix for its line
+func communityTag(g store.Group) string {
+	switch {
+	case g.IsCommunity:
+		return " [community]"
+	case g.IsAnnouncement:
+		return " [announcements of " + g.CommunityJID + "]"
+	case g.CommunityJID != "":
+		return " [in " + g.CommunityJID + "]"
+	}
+	return ""
+}
+
+// How membership changes read in the CLI
+var membershipVerbs = map[string]string{
+	store.EventJoin:    "joined",
+	store.EventLeave:   "left",
+	store.EventPromote: "became admin",
+	store.EventDemote:  "stopped being admin",
+}
+
+// Print who joined and left a group and when, or who was in it at a time
+func cmdMembership(args []string) error {
+	fs := flag.NewFlagSet("membership", flag.ContinueOnError)
+	at := fs.String("at", "", `list the members at this time, "YYYY-MM-DD HH:MM"`)
+	asJSON := fs.Bool("json", false, "print as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 || (*at != "" && fs.NArg() != 1) {
+		return fmt.Errorf("%w: kenny-whatsapp membership [--json] [--tz zone] <group_jid> [member_jid|phone]\n"+
+			"       kenny-whatsapp membership --at \"YYYY-MM-DD HH:MM\" [--json] [--tz zone] <group_jid>", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	member := ""
+	if fs.NArg() == 2 {
+		if member, err = resolveChat(fs.Arg(1)); err != nil {
+			return err
+		}
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if *at != "" {
+		when, err := time.ParseInLocation("2006-01-02 15:04", *at, loc)
+		if err != nil {
+			return fmt.Errorf("%w: invalid --at %q; use \"YYYY-MM-DD HH:MM\"", errUsage, *at)
+		}
+		members, err := st.GroupMembersAt(fs.Arg(0), when)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			if members == nil {
+				members = []string{}
+			}
+			return enc.Encode(members)
+		}
+		fmt.Printf("%d members at %s\n", len(members), when.Format(timeLayout))
+		for _, m := range members {
+			fmt.Printf("  %s\n", m)
+		}
+		return nil
+	}
+
+	changes, err := st.MembershipChanges(fs.Arg(0), member)
+	if err != nil {
+		return fmt.Errorf("failed to list membership changes: %w", err)
+	}
+	if *asJSON {
+		if changes == nil {
+			changes = []store.MembershipChange{}
+		}
+		return enc.Encode(changes)
+	}
+	for _, c := range changes {
+		who := c.Member
+		if c.Name != "" {
+			who = fmt.Sprintf("%s (%s)", c.Name, c.Member)
+		}
+		fmt.Printf("[%s] %s %s", c.At.In(loc).Format(timeLayout), who, membershipVerbs[c.Kind])
+		if c.Actor != "" {
+			fmt.Printf(", by %s", c.Actor)
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"whatsapp-logger/internal/bundle"
+	"whatsapp-logger/internal/chatimport"
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/mcpdb"
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/msgstore"
+	"whatsapp-logger/internal/store"
+)
+
+const importUsage = "kenny-whatsapp import (--from-bundle <file>|--from-mcp <messages.db>|--from-export <file> --chat <jid|phone> [--me name] [--tz zone]|--from-msgstore <msgstore.db> [--media-dir dir]) [--db path]"
+
+// Load messages from another source into the archive
+func cmdImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fromBundle := fs.String("from-bundle", "", "bundle written by `export --format bundle`")
+	fromMCP := fs.String("from-mcp", "", "messages.db of a whatsapp-mcp bridge")
+	fromExport := fs.String("from-export", "", "chat exported from the phone app, as .txt or .zip")
+	fromMsgstore := fs.String("from-msgstore", "", "decrypted msgstore.db of an Android backup")
+	mediaDir := fs.String("media-dir", "", "the phone's WhatsApp directory, holding Media/ (with --from-msgstore)")
+	chatArg := fs.String("chat", "", "chat JID or phone number the export is of (with --from-export)")
+	me := fs.String("me", "", "name the export gives you, e.g. \"You\" (with --from-export)")
+	tz := fs.String("tz", "", "time zone of the export's timestamps, the phone's (default: config timezone, else local)")
+	dbPath := fs.String("db", "", "database to import into (default: the configured archive)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	sources := 0
+	for _, from := range []string{*fromBundle, *fromMCP, *fromExport, *fromMsgstore} {
+		if from != "" {
+			sources++
+		}
+	}
+	if fs.NArg() != 0 || sources != 1 || (*fromExport != "") != (*chatArg != "") {
+		return fmt.Errorf("%w: %s", errUsage, importUsage)
+	}
+
+	var st *store.SQLiteStore
+	var err error
+	if *dbPath == "" {
+		st, err = openStore()
+	} else {
+		st, err = store.Open(*dbPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	if *fromExport != "" {
+		return importExport(st, *fromExport, *chatArg, *me, *tz)
+	}
+	if *fromMsgstore != "" {
+		return importMsgstore(st, *fromMsgstore, *mediaDir)
+	}
+	if *fromMCP != "" {
+		res, err := mcpdb.Import(st, *fromMCP)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d chats and %d messages from %s; %d messages were already stored\n",
+			res.Chats, res.Messages, *fromMCP, res.Skipped)
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	m, err := bundle.Read(context.Background(), st, *fromBundle, media.StorageFromConfig(cfg.Media))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d chats, %d contacts, %d messages and %d media files from %s (created %s)\n",
+		m.Chats, m.Contacts, m.Messages, len(m.Media), *fromBundle, m.CreatedAt.Local().Format("2006-01-02 15:04"))
+	return nil
+}
+
+func importExport(st store.Store, file, chatArg, me, tz string) error {
+	chatJID, err := resolveChat(chatArg)
+	if err != nil {
+		return err
+	}
+	loc, err := location(tz)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	res, err := chatimport.Import(context.Background(), st, file, chatimport.Options{
+		ChatJID:  chatJID,
+		Me:       me,
+		Location: loc,
+		Region:   cfg.Region,
+		Storage:  media.StorageFromConfig(cfg.Media),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d messages and %d media files from %s; %d were already stored\n",
+		res.Messages, res.Media, file, res.Skipped)
+	if len(res.Unresolved) > 0 {
+		fmt.Printf("Senders kept by name, matching no contact: %s\n", strings.Join(res.Unresolved, ", "))
+	}
+	return nil
+}
+
+func importMsgstore(st store.Store, file, mediaDir string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	res, err := msgstore.Import(context.Background(), st, file, msgstore.Options{
+		MediaDir: mediaDir,
+		Storage:  media.StorageFromConfig(cfg.Media),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d chats, %d messages and %d media files from %s; %d messages were already stored\n",
+		res.Chats, res.Messages, res.Media, file, res.Skipped)
+	return nil
+}
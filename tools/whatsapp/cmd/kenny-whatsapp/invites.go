@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Print group invites received across chats, or in one chat
+func cmdInvites(args []string) error {
+	fs := flag.NewFlagSet("invites", flag.ContinueOnError)
+	chat := fs.String("chat", "", "only invites sent in this chat")
+	asJSON := fs.Bool("json", false, "print invites as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp invites [--chat jid] [--json] [--tz zone]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	if *chat, err = resolveChat(*chat); err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	invites, err := st.ListGroupInvites(*chat)
+	if err != nil {
+		return fmt.Errorf("failed to list group invites: %w", err)
+	}
+	if *asJSON {
+		if invites == nil {
+			invites = []store.GroupInvite{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(invites)
+	}
+
+	for _, inv := range invites {
+		fmt.Printf("[%s] %s %s: %s (%s)", inv.Timestamp.In(loc).Format(timeLayout), inv.ChatJID, inv.Sender,
+			inv.GroupName, inv.GroupJID)
+		if inv.ExpiresAt != nil {
+			fmt.Printf(", expires %s", inv.ExpiresAt.In(loc).Format(timeLayout))
+		}
+		fmt.Printf("\n    message %s\n", inv.MessageID)
+	}
+	return nil
+}
+
+// Join the group a stored invite message invites to
+func cmdAcceptInvite(args []string) error {
+	fs := flag.NewFlagSet("accept-invite", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("%w: kenny-whatsapp accept-invite <chat_jid> <message_id>", errUsage)
+	}
+	chat, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	key := store.MessageKey{ChatJID: chat, ID: fs.Arg(1)}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	logger, err := sendingLogger(st)
+	if err != nil {
+		return err
+	}
+	defer logger.Disconnect()
+
+	inv, err := logger.AcceptInvite(key)
+	if err != nil {
+		return err
+	}
+	if !dryRun {
+		fmt.Printf("Joined %s (%s)\n", inv.GroupName, inv.GroupJID)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"whatsapp-logger/internal/reconcile"
+)
+
+// Record that a chat continues under a new JID, or undo it
+func cmdLinkChats(args []string) error {
+	fs := flag.NewFlagSet("link-chats", flag.ContinueOnError)
+	unlink := fs.Bool("unlink", false, "remove the link of the given JID")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if (*unlink && fs.NArg() != 1) || (!*unlink && fs.NArg() != 2) {
+		return fmt.Errorf("%w: kenny-whatsapp link-chats <old_jid> <new_jid> | --unlink <jid>", errUsage)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	if *unlink {
+		return st.UnlinkChat(fs.Arg(0))
+	}
+	// Both chats must exist, so typos do not create dangling links
+	for _, jid := range fs.Args() {
+		if _, err := st.QueryMessages(jid, 1); err != nil {
+			return err
+		}
+	}
+	if err := st.LinkChats(fs.Arg(0), fs.Arg(1)); err != nil {
+		return err
+	}
+	group, err := st.ChatGroup(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Linked %d chats as one conversation: %v\n", len(group), group)
+	return nil
+}
+
+// Merge chat rows that are the same conversation under different JID spellings
+func cmdReconcileChats(args []string) error {
+	fs := flag.NewFlagSet("reconcile-chats", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only print the merges that would happen")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp reconcile-chats [--dry-run]", errUsage)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	merges, err := reconcile.Plan(st)
+	if err != nil {
+		return err
+	}
+	for _, m := range merges {
+		fmt.Printf("%s (%s) <- %v\n", m.Into, m.Name, m.From)
+	}
+	if *dryRun || len(merges) == 0 {
+		fmt.Printf("%d chats to reconcile\n", len(merges))
+		return nil
+	}
+	removed, err := reconcile.Apply(st, merges)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Reconciled %d chats, removing %d duplicate rows\n", len(merges), removed)
+	return nil
+}
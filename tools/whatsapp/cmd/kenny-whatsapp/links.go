@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"whatsapp-logger/internal/links"
+	"whatsapp-logger/internal/store"
+)
+
+// List every URL shared in a chat, or each share matching the filters
+func cmdLinks(args []string) error {
+	fs := flag.NewFlagSet("links", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print links as JSON")
+	sender := fs.String("sender", "", "list links shared by this JID or phone number")
+	domain := fs.String("domain", "", "list links to this domain")
+	since := fs.String("since", "", "first day to include, YYYY-MM-DD")
+	until := fs.String("until", "", "day after the last one to include, YYYY-MM-DD")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	filtered := *sender != "" || *domain != "" || *since != "" || *until != ""
+	if fs.NArg() > 1 || (fs.NArg() == 0 && !filtered) {
+		return fmt.Errorf("%w: kenny-whatsapp links [--sender JID] [--domain D] [--since DATE] [--until DATE] [--json] [--tz zone] <chat_jid>", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	chat, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	if filtered {
+		f := store.LinkFilter{ChatJID: chat, Domain: *domain}
+		if f.Sender, err = resolveChat(*sender); err != nil {
+			return err
+		}
+		if *since != "" {
+			if f.Since, err = time.ParseInLocation("2006-01-02", *since, loc); err != nil {
+				return fmt.Errorf("%w: invalid --since date %q", errUsage, *since)
+			}
+		}
+		if *until != "" {
+			if f.Until, err = time.ParseInLocation("2006-01-02", *until, loc); err != nil {
+				return fmt.Errorf("%w: invalid --until date %q", errUsage, *until)
+			}
+		}
+		return printShares(st, f, loc, *asJSON)
+	}
+
+	found, err := links.Collect(st, chat)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found)
+	}
+
+	for _, l := range found {
+		fmt.Printf("[%s] %s", l.LastShared.In(loc).Format("2006-01-02"), l.URL)
+		if l.Count > 1 {
+			fmt.Printf(" (shared %d times)", l.Count)
+		}
+		fmt.Println()
+		if l.Title != "" {
+			fmt.Printf("    %s\n", l.Title)
+		}
+	}
+	return nil
+}
+
+// Print each share of a link matching f, newest first
+func printShares(st store.Store, f store.LinkFilter, loc *time.Location, asJSON bool) error {
+	found, err := st.ListLinks(f)
+	if err != nil {
+		return fmt.Errorf("failed to list links: %w", err)
+	}
+	if asJSON {
+		if found == nil {
+			found = []store.SharedLink{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found)
+	}
+
+	for _, l := range found {
+		fmt.Printf("[%s] %s %s: %s\n", l.Timestamp.In(loc).Format("2006-01-02"), l.ChatJID, l.Sender, l.URL)
+		if l.Title != "" {
+			fmt.Printf("    %s\n", l.Title)
+		}
+	}
+	return nil
+}
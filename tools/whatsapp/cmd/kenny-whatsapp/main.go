@@ -0,0 +1,519 @@
+// Command kenny-whatsapp logs WhatsApp messages into a local SQLite archive for Kenny.
+//
+// Build from tools/whatsapp with:
+//
+//	go build -tags sqlite_fts5 -o kenny_whatsapp_enhanced ./cmd/kenny-whatsapp
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	// Embedded zone data, so --tz works where the OS has none (Windows)
+	_ "time/tzdata"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/phone"
+	"whatsapp-logger/internal/store"
+	"whatsapp-logger/internal/wa"
+)
+
+const (
+	sessionDBPath  = "whatsapp_session.db"
+	messagesDBPath = "whatsapp_messages.db"
+	coldDirPath    = "whatsapp_cold"
+)
+
+// Process exit codes, so scripts driving the CLI can branch on failure kind
+const (
+	exitFailure      = 1
+	exitUsage        = 2
+	exitChatNotFound = 3
+	exitNotPaired    = 4
+	exitStoreClosed  = 5
+	exitNoMessage    = 6
+)
+
+// errUsage marks errors caused by bad command-line input
+var errUsage = errors.New("usage")
+
+// Set by the global --dry-run flag or KENNY_WA_DRY_RUN: outbound WhatsApp
+// messages are logged instead of sent
+var dryRun bool
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		log.Print(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// Map an error onto the process exit code for its kind
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, store.ErrChatNotFound):
+		return exitChatNotFound
+	case errors.Is(err, wa.ErrNotPaired):
+		return exitNotPaired
+	case errors.Is(err, store.ErrStoreClosed):
+		return exitStoreClosed
+	case errors.Is(err, store.ErrMessageNotFound):
+		return exitNoMessage
+	default:
+		return exitFailure
+	}
+}
+
+// Dispatch a command line to its command
+func run(args []string) error {
+	dryRun = os.Getenv("KENNY_WA_DRY_RUN") != ""
+	for len(args) > 0 && (args[0] == "--dry-run" || args[0] == "-dry-run") {
+		dryRun = true
+		args = args[1:]
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("%w: kenny-whatsapp [--dry-run] [start|status|query|search|replay|serve|mcp|export|import|archive|prune|reprocess|backup|restore|bookmark|link-chats|reconcile-chats|stats|words|links|files|whois|sync-contacts|top-contacts|presence|digest|gaps|devices|media-backfill|media-gc|ocr|polls|calls|groups|membership|invites|accept-invite|send|send-poll|react|mark-read|outbox|receipts|broadcast|pending|approve|reject]", errUsage)
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "start":
+		return cmdStart(args[1:])
+	case "status":
+		return cmdStatus()
+	case "query":
+		return cmdQuery(args[1:])
+	case "search":
+		return cmdSearch(args[1:])
+	case "replay":
+		return cmdReplay(args[1:])
+	case "serve":
+		return cmdServe(args[1:])
+	case "mcp":
+		return cmdMCP(args[1:])
+	case "export":
+		return cmdExport(args[1:])
+	case "import":
+		return cmdImport(args[1:])
+	case "archive":
+		return cmdArchive(args[1:])
+	case "prune":
+		return cmdPrune(args[1:])
+	case "reprocess":
+		return cmdReprocess(args[1:])
+	case "backup":
+		return cmdBackup(args[1:])
+	case "restore":
+		return cmdRestore(args[1:])
+	case "bookmark":
+		return cmdBookmark(args[1:])
+	case "link-chats":
+		return cmdLinkChats(args[1:])
+	case "reconcile-chats":
+		return cmdReconcileChats(args[1:])
+	case "stats":
+		return cmdStats(args[1:])
+	case "words":
+		return cmdWords(args[1:])
+	case "links":
+		return cmdLinks(args[1:])
+	case "files":
+		return cmdFiles(args[1:])
+	case "whois":
+		return cmdWhois(args[1:])
+	case "sync-contacts":
+		return cmdSyncContacts(args[1:])
+	case "top-contacts":
+		return cmdTopContacts(args[1:])
+	case "presence":
+		return cmdPresence(args[1:])
+	case "digest":
+		return cmdDigest(args[1:])
+	case "gaps":
+		return cmdGaps(args[1:])
+	case "devices":
+		return cmdDevices(args[1:])
+	case "media-backfill":
+		return cmdMediaBackfill(args[1:])
+	case "media-gc":
+		return cmdMediaGC(args[1:])
+	case "ocr":
+		return cmdOCR(args[1:])
+	case "polls":
+		return cmdPolls(args[1:])
+	case "calls":
+		return cmdCalls(args[1:])
+	case "groups":
+		return cmdGroups(args[1:])
+	case "membership":
+		return cmdMembership(args[1:])
+	case "invites":
+		return cmdInvites(args[1:])
+	case "accept-invite":
+		return cmdAcceptInvite(args[1:])
+	case "send":
+		return cmdSend(args[1:])
+	case "send-poll":
+		return cmdSendPoll(args[1:])
+	case "react":
+		return cmdReact(args[1:])
+	case "mark-read":
+		return cmdMarkRead(args[1:])
+	case "outbox":
+		return cmdOutbox(args[1:])
+	case "receipts":
+		return cmdReceipts(args[1:])
+	case "broadcast":
+		return cmdBroadcast(args[1:])
+	case "pending":
+		return cmdPending(args[1:])
+	case "approve":
+		return cmdApprove(args[1:])
+	case "reject":
+		return cmdReject(args[1:])
+	default:
+		return fmt.Errorf("%w: unknown command %q. Use: start, status, query, search, replay, serve, mcp, export, import, archive, prune, reprocess, backup, restore, bookmark, link-chats, reconcile-chats, stats, words, links, files, whois, sync-contacts, top-contacts, presence, digest, gaps, devices, media-backfill, media-gc, ocr, polls, calls, groups, membership, invites, accept-invite, send, send-poll, react, mark-read, outbox, receipts, broadcast, pending, approve, or reject", errUsage, args[0])
+	}
+}
+
+// Parse command flags, reporting bad input as a usage error
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return nil
+}
+
+// Layout for timestamps printed by the CLI
+const timeLayout = "2006-01-02 15:04:05 MST"
+
+// Register --tz on fs
+func addTZFlag(fs *flag.FlagSet) *string {
+	return fs.String("tz", "", "time zone for printed timestamps, e.g. Europe/Berlin (default: config timezone, else local)")
+}
+
+// Resolve a --tz value, falling back to the configured zone
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+		return cfg.Location()
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", errUsage, name)
+	}
+	return loc, nil
+}
+
+// Accept a phone number in any format wherever a chat JID is expected,
+// reading numbers without a country code in the configured region
+func resolveChat(arg string) (string, error) {
+	if arg == "" || strings.Contains(arg, "@") {
+		return arg, nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+	e164, err := phone.Normalize(arg, cfg.Region)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is neither a chat JID nor a phone number", errUsage, arg)
+	}
+	return phone.JID(e164), nil
+}
+
+// The configured database, or "" to use the SQLite files
+func databaseDSN() (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+	return cfg.Database.DSN, nil
+}
+
+// Open the message archive in the configured database, or else its SQLite
+// file
+func openStore() (*store.SQLiteStore, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	opts := store.Options{Synchronous: cfg.Database.Synchronous}
+	if opts.Key, err = databaseKey(cfg.Database); err != nil {
+		return nil, err
+	}
+	if cfg.Database.DSN != "" {
+		return store.OpenDSN(cfg.Database.DSN, opts)
+	}
+	return store.OpenSQLite(messagesDBPath, opts)
+}
+
+// The passphrase encrypting the archive, from the config, KENNY_WA_DB_KEY
+// or the output of the key command; "" when none is set
+func databaseKey(db config.Database) (string, error) {
+	if db.Key != "" || len(db.KeyCommand) == 0 {
+		return db.Key, nil
+	}
+	out, err := exec.Command(db.KeyCommand[0], db.KeyCommand[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run database key command: %w", err)
+	}
+	key := strings.TrimRight(string(out), "\r\n")
+	if key == "" {
+		return "", errors.New("database key command printed nothing")
+	}
+	return key, nil
+}
+
+// Where whatsmeow keeps the session: the configured database, or else its
+// SQLite file
+func sessionDB() (string, error) {
+	dsn, err := databaseDSN()
+	if err != nil || dsn == "" {
+		return sessionDBPath, err
+	}
+	return dsn, nil
+}
+
+// Print message and chat counts
+func cmdStatus() error {
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	// Count messages and chats
+	messageCount, err := st.MessageCount()
+	if err != nil {
+		return fmt.Errorf("failed to count messages: %w", err)
+	}
+	chatCount, err := st.ChatCount()
+	if err != nil {
+		return fmt.Errorf("failed to count chats: %w", err)
+	}
+
+	fmt.Printf("WhatsApp Logger Status:\n")
+	database := messagesDBPath
+	if dsn, _ := databaseDSN(); store.IsPostgres(dsn) {
+		database = "PostgreSQL"
+	}
+	fmt.Printf("Database: %s\n", database)
+	fmt.Printf("Messages: %d\n", messageCount)
+	fmt.Printf("Chats: %d\n", chatCount)
+	return nil
+}
+
+// Print the most recent messages in a chat or across a community, or
+// bookmarked messages, shared locations or shared contacts
+func cmdQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	community := fs.Bool("community", false, "treat the JID as a community and print messages across its groups")
+	bookmarked := fs.Bool("bookmarked", false, "list bookmarked messages, optionally only in the given chat")
+	locations := fs.Bool("locations", false, "list shared locations, optionally only in the given chat")
+	contacts := fs.Bool("contacts", false, "list shared contact cards, optionally only in the given chat")
+	since := fs.String("since", "", "first day to include, YYYY-MM-DD")
+	until := fs.String("until", "", "day after the last one to include, YYYY-MM-DD")
+	senders := fs.String("sender", "", "only messages from these JIDs or phone numbers, comma separated")
+	mediaTypes := fs.String("media", "", "only these media types, comma separated: image, video, audio, document, sticker")
+	mediaOnly := fs.Bool("media-only", false, "only messages with an attachment")
+	fromMe := fs.Bool("from-me", false, "only messages I sent")
+	toMe := fs.Bool("to-me", false, "only messages I received")
+	limit := fs.Int("limit", 10, "most messages to print, the newest; 0 for all")
+	asJSON := fs.Bool("json", false, "print messages as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	listings := 0
+	for _, set := range []bool{*bookmarked, *locations, *contacts} {
+		if set {
+			listings++
+		}
+	}
+	filtered := *since != "" || *until != "" || *senders != "" || *mediaTypes != "" || *mediaOnly || *fromMe || *toMe
+	if fs.NArg() > 1 || (fs.NArg() == 0 && listings == 0 && !filtered) || listings > 1 ||
+		(*community && (listings > 0 || fs.NArg() == 0 || filtered)) || (filtered && listings > 0) || (*fromMe && *toMe) {
+		return fmt.Errorf("%w: kenny-whatsapp query [--community | --bookmarked | --locations | --contacts] [--since DATE] [--until DATE] [--sender JIDS] [--media TYPES | --media-only] [--from-me | --to-me] [--limit N] [--json] [--tz zone] [<chat_jid>]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	f := store.MessageFilter{MediaOnly: *mediaOnly, Limit: *limit}
+	if *since != "" {
+		if f.Since, err = time.ParseInLocation("2006-01-02", *since, loc); err != nil {
+			return fmt.Errorf("%w: invalid --since date %q", errUsage, *since)
+		}
+	}
+	if *until != "" {
+		if f.Until, err = time.ParseInLocation("2006-01-02", *until, loc); err != nil {
+			return fmt.Errorf("%w: invalid --until date %q", errUsage, *until)
+		}
+	}
+	for _, sender := range splitList(*senders) {
+		jid, err := resolveChat(sender)
+		if err != nil {
+			return err
+		}
+		f.Senders = append(f.Senders, jid)
+	}
+	f.MediaTypes = splitList(*mediaTypes)
+	if *fromMe || *toMe {
+		f.FromMe = fromMe
+	}
+
+	chatJID, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	if *bookmarked {
+		bookmarks, err := st.ListBookmarks(chatJID)
+		if err != nil {
+			return fmt.Errorf("failed to list bookmarks: %w", err)
+		}
+		fmt.Printf("Bookmarked messages (%d):\n", len(bookmarks))
+		for _, b := range bookmarks {
+			fmt.Printf("[%s] %s %s (%s): %s\n", b.Timestamp.In(loc).Format(timeLayout), b.ChatJID, b.Sender, b.ID, b.Content)
+			if b.Note != "" {
+				fmt.Printf("    note: %s\n", b.Note)
+			}
+		}
+		return nil
+	}
+
+	if *locations {
+		found, err := st.ListLocations(chatJID)
+		if err != nil {
+			return fmt.Errorf("failed to list locations: %w", err)
+		}
+		fmt.Printf("Shared locations (%d):\n", len(found))
+		for _, m := range found {
+			l := m.Location
+			place := strings.TrimSpace(l.Name + " " + l.Address)
+			if l.Live {
+				place = strings.TrimSpace("live " + place)
+			}
+			fmt.Printf("[%s] %s %s: %.6f,%.6f %s\n", m.Timestamp.In(loc).Format(timeLayout), m.ChatJID, m.Sender,
+				l.Latitude, l.Longitude, place)
+		}
+		return nil
+	}
+
+	if *contacts {
+		found, err := st.ListSharedContacts(chatJID)
+		if err != nil {
+			return fmt.Errorf("failed to list shared contacts: %w", err)
+		}
+		fmt.Printf("Shared contacts (%d):\n", len(found))
+		for _, c := range found {
+			fmt.Printf("[%s] %s %s: %s %s\n", c.Timestamp.In(loc).Format(timeLayout), c.ChatJID, c.Sender,
+				c.Name, strings.Join(c.Phones, ", "))
+		}
+		return nil
+	}
+
+	if *community {
+		messages, err := st.QueryCommunity(chatJID, *limit)
+		if err != nil {
+			return fmt.Errorf("failed to query messages: %w", err)
+		}
+		fmt.Printf("Recent messages across community %s:\n", chatJID)
+		printMessages(messages, loc, true)
+		return nil
+	}
+
+	if chatJID != "" {
+		f.Chats = []string{chatJID}
+	}
+	found, err := st.ListMessages(f)
+	if err != nil {
+		return fmt.Errorf("failed to query messages: %w", err)
+	}
+	if *asJSON {
+		if found == nil {
+			found = []store.Message{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found)
+	}
+
+	if chatJID != "" {
+		fmt.Printf("Recent messages from %s:\n", chatJID)
+	} else {
+		fmt.Printf("Recent messages across chats:\n")
+	}
+	printMessages(found, loc, chatJID == "")
+	return nil
+}
+
+// Split a comma separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Print messages as query lists them, naming each one's chat when they
+// come from several
+func printMessages(messages []store.Message, loc *time.Location, withChat bool) {
+	for _, m := range messages {
+		note := ""
+		if m.DeletedAt != nil {
+			note = " (deleted)"
+		} else if m.EditedAt != nil {
+			note = " (edited)"
+		}
+		chat := ""
+		if withChat {
+			chat = m.ChatJID + " "
+		}
+		fmt.Printf("[%s] %s%s: %s%s\n", m.Timestamp.In(loc).Format(timeLayout), chat, m.Sender, m.Content, note)
+	}
+}
+
+// Bookmark a message with an optional note, or remove its bookmark
+func cmdBookmark(args []string) error {
+	fs := flag.NewFlagSet("bookmark", flag.ContinueOnError)
+	note := fs.String("note", "", "note to attach")
+	remove := fs.Bool("remove", false, "remove the bookmark instead")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("%w: kenny-whatsapp bookmark [--note text | --remove] <chat_jid> <message_id>", errUsage)
+	}
+	key := store.MessageKey{ChatJID: fs.Arg(0), ID: fs.Arg(1)}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	if *remove {
+		return st.RemoveBookmark(key)
+	}
+	return st.SetBookmark(key, *note)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/mcp"
+)
+
+// Serve the archive to AI assistants over the Model Context Protocol, on
+// stdin/stdout or, with --sse, over HTTP. Messages sent through it are
+// queued in the outbox for the logger to deliver.
+func cmdMCP(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	sseAddr := fs.String("sse", "", "serve over HTTP with server-sent events on this address (\"config\" for the API's) instead of stdin/stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	archive, err := openCold()
+	if err != nil {
+		return err
+	}
+	if *sseAddr == "" {
+		// stdout carries the protocol, so nothing else may be printed there
+		srv := mcp.New(st, waLog.Noop)
+		srv.SetCold(archive)
+		srv.SetRequireApproval(cfg.Sending.RequireApproval)
+		return srv.ServeStdio(ctx, os.Stdin, os.Stdout)
+	}
+
+	apiCfg := cfg.API
+	if *sseAddr != "config" {
+		apiCfg.Addr = *sseAddr
+	}
+	srv := mcp.New(st, waLog.Stdout("MCP", "INFO", true))
+	srv.SetCold(archive)
+	srv.SetRequireApproval(cfg.Sending.RequireApproval)
+	return srv.ListenAndServe(ctx, apiCfg)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/ocr"
+	"whatsapp-logger/internal/schedule"
+	"whatsapp-logger/internal/store"
+)
+
+// Download attachments of messages logged before downloads were enabled, or
+// ones the download policy skipped. Progress lives in the database: saved files get a local path and failures
+// are counted, so an interrupted run picks up where it stopped.
+func cmdMediaBackfill(args []string) error {
+	fs := flag.NewFlagSet("media-backfill", flag.ContinueOnError)
+	chat := fs.String("chat", "", "only download attachments in this chat")
+	types := fs.String("types", "", "comma-separated media types to download (default: what the media policy allows per chat)")
+	id := fs.String("id", "", "download just this message's attachment (with --chat), whatever the policy says")
+	limit := fs.Int("limit", 0, "stop after this many downloads (0: no limit)")
+	maxAttempts := fs.Int("max-attempts", 3, "skip attachments that already failed this many times (0: retry all)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp media-backfill [--chat jid [--id message_id]] [--types image,document] [--limit N] [--max-attempts N]", errUsage)
+	}
+	if *id != "" && *chat == "" {
+		return fmt.Errorf("%w: --id needs --chat", errUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if *chat, err = resolveChat(*chat); err != nil {
+		return err
+	}
+	policy := media.DownloadPolicyFromConfig(cfg.Media)
+	var wanted map[string]bool
+	if *types != "" {
+		wanted = map[string]bool{}
+		for _, t := range strings.Split(*types, ",") {
+			wanted[strings.TrimSpace(t)] = true
+		}
+	}
+	if *id != "" {
+		*maxAttempts = 0
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	pending, err := st.PendingMedia(*chat, *maxAttempts)
+	if err != nil {
+		st.Close()
+		return err
+	}
+	todo := pending[:0]
+	for _, p := range pending {
+		switch {
+		case *id != "":
+			if p.ID != *id {
+				continue
+			}
+		case p.IsViewOnce:
+			if !policy.ViewOnce() {
+				continue
+			}
+		case wanted != nil:
+			if !wanted[p.MediaType] {
+				continue
+			}
+		case !policy.Wanted(p.ChatJID, p.MediaType, p.Media.FileLength):
+			continue
+		}
+		todo = append(todo, p)
+	}
+	if *limit > 0 && len(todo) > *limit {
+		todo = todo[:*limit]
+	}
+	if len(todo) == 0 {
+		fmt.Println("No attachments left to download.")
+		return st.Close()
+	}
+
+	logger, err := connectLogger(st)
+	if err != nil {
+		return err
+	}
+	defer logger.Disconnect()
+	logger.SetMediaDir(cfg.Media.Dir)
+	logger.SetMediaStorage(media.StorageFromConfig(cfg.Media))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	saved, failed := 0, 0
+	for i, p := range todo {
+		if ctx.Err() != nil {
+			break
+		}
+		dctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		path, err := logger.DownloadMedia(dctx, p.Message, p.Media)
+		cancel()
+		key := store.MessageKey{ID: p.ID, ChatJID: p.ChatJID}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			failed++
+			fmt.Printf("[%d/%d] %s %s in %s: %v\n", i+1, len(todo), p.MediaType, p.ID, p.ChatJID, err)
+			if err := st.RecordMediaFailure(key, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		saved++
+		fmt.Printf("[%d/%d] saved %s\n", i+1, len(todo), path)
+	}
+	fmt.Printf("Saved %d, failed %d, %d left\n", saved, failed, len(todo)-saved-failed)
+	return nil
+}
+
+// Read text from downloaded images that have not been through OCR yet
+func cmdOCR(args []string) error {
+	fs := flag.NewFlagSet("ocr", flag.ContinueOnError)
+	chat := fs.String("chat", "", "only images in this chat")
+	limit := fs.Int("limit", 0, "stop after this many images (0: no limit)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp ocr [--chat jid] [--limit N]", errUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	engine, err := ocr.New(cfg.OCR)
+	if err != nil {
+		return err
+	}
+	storage := media.StorageFromConfig(cfg.Media)
+	if *chat, err = resolveChat(*chat); err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+	images, err := st.PendingOCR(*chat)
+	if err != nil {
+		return err
+	}
+	if *limit > 0 && len(images) > *limit {
+		images = images[:*limit]
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	read := 0
+	for i, m := range images {
+		if ctx.Err() != nil {
+			break
+		}
+		data, err := readMedia(ctx, storage, m)
+		if err != nil {
+			fmt.Printf("[%d/%d] %s: %v\n", i+1, len(images), m.ID, err)
+			continue
+		}
+		text, err := engine.Recognize(ctx, data, m.MimeType)
+		if err != nil {
+			fmt.Printf("[%d/%d] %s: %v\n", i+1, len(images), m.ID, err)
+			continue
+		}
+		if err := st.StoreOCRText(store.MessageKey{ID: m.ID, ChatJID: m.ChatJID}, text); err != nil {
+			return err
+		}
+		read++
+		fmt.Printf("[%d/%d] %s: %d characters\n", i+1, len(images), m.ID, len(text))
+	}
+	fmt.Printf("Read %d of %d images\n", read, len(images))
+	return nil
+}
+
+// Read a downloaded attachment from disk or object storage
+func readMedia(ctx context.Context, storage media.Storage, m store.Message) ([]byte, error) {
+	if m.ObjectURL == "" {
+		return os.ReadFile(m.LocalPath)
+	}
+	r, err := storage.Open(ctx, m.ObjectURL)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Delete downloaded files past the configured size or age limit
+func cmdMediaGC(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("media-gc", flag.ContinueOnError)
+	maxGB := fs.Float64("max-gb", cfg.Media.Retention.MaxGB, "keep at most this many gigabytes of files")
+	maxDays := fs.Int("max-days", cfg.Media.Retention.MaxDays, "delete files whose newest message is older than this many days")
+	preview := fs.Bool("dry-run", false, "report what would be deleted without deleting")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp media-gc [--max-gb N] [--max-days N] [--dry-run]", errUsage)
+	}
+	policy := retentionPolicy(config.MediaRetention{MaxGB: *maxGB, MaxDays: *maxDays})
+	if policy == (media.Policy{}) {
+		return fmt.Errorf("%w: no limit set; pass --max-gb or --max-days, or configure media.retention", errUsage)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	res, err := media.Evict(ctx, st, media.StorageFromConfig(cfg.Media), policy, time.Now(), *preview)
+	verb := "Deleted"
+	if *preview {
+		verb = "Would delete"
+	}
+	fmt.Printf("%s %d files (%s); %d files (%s) kept\n", verb, res.Files, formatSize(res.Bytes), res.KeptFiles, formatSize(res.KeptBytes))
+	return err
+}
+
+// Enforce media retention on its schedule until ctx is cancelled
+func startMediaGC(ctx context.Context, cfg config.Media, st store.Store) error {
+	sched, err := schedule.Parse(cfg.Retention.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid media retention schedule: %w", err)
+	}
+	log := waLog.Stdout("Media", "INFO", true)
+	storage := media.StorageFromConfig(cfg)
+	policy := retentionPolicy(cfg.Retention)
+	go schedule.Run(ctx, sched, func(ctx context.Context) {
+		res, err := media.Evict(ctx, st, storage, policy, time.Now(), false)
+		if err != nil {
+			log.Errorf("Media retention failed: %v", err)
+		}
+		if res.Files > 0 {
+			log.Infof("Deleted %d media files (%s), %s kept", res.Files, formatSize(res.Bytes), formatSize(res.KeptBytes))
+		}
+	})
+	log.Infof("Media retention scheduled (%s), next at %s", cfg.Retention.Schedule, sched.Next(time.Now()).Format("2006-01-02 15:04"))
+	return nil
+}
+
+// Translate configured retention limits into an eviction policy
+func retentionPolicy(r config.MediaRetention) media.Policy {
+	return media.Policy{
+		MaxBytes: int64(r.MaxGB * (1 << 30)),
+		MaxAge:   time.Duration(r.MaxDays) * 24 * time.Hour,
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/store"
+)
+
+const outboxUsage = "kenny-whatsapp outbox [add --at time <chat_jid|phone> <text>|list [--all] [--json]|cancel <id>]"
+
+// Queue messages for `start` to send later, list them or cancel one
+func cmdOutbox(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%w: %s", errUsage, outboxUsage)
+	}
+	switch args[0] {
+	case "add":
+		return cmdOutboxAdd(args[1:])
+	case "list":
+		return cmdOutboxList(args[1:])
+	case "cancel":
+		if len(args) != 2 {
+			return fmt.Errorf("%w: %s", errUsage, outboxUsage)
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid outbox ID %q", errUsage, args[1])
+		}
+		st, err := openStore()
+		if err != nil {
+			return fmt.Errorf("failed to open database: %w", err)
+		}
+		defer st.Close()
+		ok, err := st.CancelOutbox(id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("no pending outbox message %d", id)
+		}
+		fmt.Printf("Cancelled outbox message %d\n", id)
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", errUsage, outboxUsage)
+	}
+}
+
+func cmdOutboxAdd(args []string) error {
+	fs := flag.NewFlagSet("outbox add", flag.ContinueOnError)
+	at := fs.String("at", "", `when to send: "YYYY-MM-DD HH:MM" or a delay such as 2h`)
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *at == "" || fs.NArg() < 2 {
+		return fmt.Errorf("%w: %s", errUsage, outboxUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	sendAt, err := parseSendAt(*at, time.Now().In(loc))
+	if err != nil {
+		return err
+	}
+	chat, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	text := strings.TrimSpace(strings.Join(fs.Args()[1:], " "))
+	if text == "" {
+		return fmt.Errorf("%w: nothing to send", errUsage)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Sending.RequireApproval {
+		return holdForApproval(st, store.PendingSend{ChatJID: chat, Text: text, SendAt: &sendAt})
+	}
+	id, err := st.QueueOutbox(store.OutboxMessage{ChatJID: chat, Text: text, SendAt: sendAt})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Queued outbox message %d for %s\n", id, sendAt.Format(timeLayout))
+	return nil
+}
+
+func cmdOutboxList(args []string) error {
+	fs := flag.NewFlagSet("outbox list", flag.ContinueOnError)
+	all := fs.Bool("all", false, "include sent and failed messages")
+	asJSON := fs.Bool("json", false, "print messages as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+	messages, err := st.ListOutbox(*all)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if messages == nil {
+			messages = []store.OutboxMessage{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(messages)
+	}
+	for _, m := range messages {
+		fmt.Printf("%d  [%s] %s  %s", m.ID, m.SendAt.In(loc).Format(timeLayout), m.ChatJID, m.Status)
+		if m.Attempts > 0 {
+			fmt.Printf(" after %d failed attempts (%s)", m.Attempts, m.LastError)
+		}
+		fmt.Printf("\n    %s\n", m.Text)
+	}
+	return nil
+}
+
+// Read --at as a delay from now or a local date and time, which must be
+// in the future
+func parseSendAt(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(d), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid --at %q; use \"YYYY-MM-DD HH:MM\" or a delay such as 2h", errUsage, s)
+	}
+	if t.Before(now) {
+		return time.Time{}, fmt.Errorf("%w: %s is in the past", errUsage, t.Format(timeLayout))
+	}
+	return t, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"whatsapp-logger/internal/polls"
+)
+
+// Print poll results across chats, or in one chat
+func cmdPolls(args []string) error {
+	fs := flag.NewFlagSet("polls", flag.ContinueOnError)
+	chat := fs.String("chat", "", "only polls in this chat")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp polls [--chat jid] [--json] [--tz zone]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	if *chat, err = resolveChat(*chat); err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	results, err := polls.List(st, *chat)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+
+	for i, r := range results {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("[%s] %s (%s, %d voted)\n", r.CreatedAt.In(loc).Format(timeLayout), r.Question, r.ChatJID, r.Voters)
+		for _, o := range r.Tally {
+			fmt.Printf("  %3d  %s\n", o.Votes, o.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Print when a watched contact came online and went offline
+func cmdPresence(args []string) error {
+	fs := flag.NewFlagSet("presence", flag.ContinueOnError)
+	since := fs.String("since", "24h", "period to cover, e.g. 24h or 7d")
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: kenny-whatsapp presence [--since 24h] [--json] [--tz zone] <jid|phone>", errUsage)
+	}
+	age, err := parseAge(*since)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --since: %v", errUsage, err)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	jid, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	changes, err := st.PresenceLog(jid, time.Now().Add(-age))
+	if err != nil {
+		return fmt.Errorf("failed to read presence log: %w", err)
+	}
+	if *asJSON {
+		if changes == nil {
+			changes = []store.PresenceChange{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("No presence changes logged for %s in the last %s\n", jid, *since)
+		return nil
+	}
+	for _, p := range changes {
+		state := "offline"
+		if p.Online {
+			state = "online"
+		}
+		fmt.Printf("[%s] %s", p.At.In(loc).Format(timeLayout), state)
+		if p.LastSeen != nil {
+			fmt.Printf(" (last seen %s)", p.LastSeen.In(loc).Format(timeLayout))
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/retention"
+	"whatsapp-logger/internal/schedule"
+	"whatsapp-logger/internal/store"
+)
+
+// Delete messages past the configured retention limits
+func cmdPrune(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	preview := fs.Bool("dry-run", false, "list what would be deleted without deleting")
+	asJSON := fs.Bool("json", false, "print the affected chats as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp prune [--dry-run] [--json] [--tz zone]", errUsage)
+	}
+	if !cfg.Retention.Enabled() {
+		return fmt.Errorf("%w: no limit set; configure retention", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	pruned, err := retention.Prune(ctx, st, messageRetention(cfg.Retention), time.Now(), *preview)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if jerr := enc.Encode(pruned); jerr != nil {
+			return jerr
+		}
+		return err
+	}
+
+	verb := "Deleted"
+	if *preview {
+		verb = "Would delete"
+	}
+	total := 0
+	for _, p := range pruned {
+		name := p.Name
+		if name == "" {
+			name = p.ChatJID
+		}
+		fmt.Printf("%s %d messages before %s in %s\n", verb, p.Messages, p.Before.In(loc).Format("2006-01-02"), name)
+		total += p.Messages
+	}
+	fmt.Printf("%s %d messages in %d chats\n", verb, total, len(pruned))
+	return err
+}
+
+// Enforce message retention on its schedule until ctx is cancelled
+func startRetention(ctx context.Context, cfg config.Retention, st store.Store) error {
+	sched, err := schedule.Parse(cfg.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid retention schedule: %w", err)
+	}
+	log := waLog.Stdout("Retention", "INFO", true)
+	policy := messageRetention(cfg)
+	go schedule.Run(ctx, sched, func(ctx context.Context) {
+		pruned, err := retention.Prune(ctx, st, policy, time.Now(), false)
+		if err != nil {
+			log.Errorf("Message retention failed: %v", err)
+		}
+		total := 0
+		for _, p := range pruned {
+			total += p.Messages
+		}
+		if total > 0 {
+			log.Infof("Deleted %d old messages in %d chats", total, len(pruned))
+		}
+	})
+	log.Infof("Message retention scheduled (%s), next at %s", cfg.Schedule, sched.Next(time.Now()).Format("2006-01-02 15:04"))
+	return nil
+}
+
+// Translate configured retention limits into a pruning policy
+func messageRetention(r config.Retention) retention.Policy {
+	days := func(n int) time.Duration { return time.Duration(n) * 24 * time.Hour }
+	p := retention.Policy{MaxAge: days(r.Days), Chats: map[string]time.Duration{}}
+	if r.GroupDays != nil {
+		groups := days(*r.GroupDays)
+		p.Groups = &groups
+	}
+	if r.DirectDays != nil {
+		direct := days(*r.DirectDays)
+		p.Direct = &direct
+	}
+	for jid, n := range r.Chats {
+		p.Chats[jid] = days(n)
+	}
+	return p
+}
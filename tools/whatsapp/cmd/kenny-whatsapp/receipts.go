@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Show who got and read a message I sent
+func cmdReceipts(args []string) error {
+	fs := flag.NewFlagSet("receipts", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print receipts as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("%w: kenny-whatsapp receipts [--json] [--tz zone] <chat_jid|phone> <message_id>", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	chat, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	key := store.MessageKey{ChatJID: chat, ID: fs.Arg(1)}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	if _, err := st.GetMessage(key); err != nil {
+		return err
+	}
+	receipts, err := st.Receipts(key)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if receipts == nil {
+			receipts = []store.Receipt{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(receipts)
+	}
+	if len(receipts) == 0 {
+		fmt.Println("Not delivered yet")
+		return nil
+	}
+	for _, r := range receipts {
+		switch {
+		case r.ReadAt != nil:
+			fmt.Printf("%s  read %s\n", r.Recipient, r.ReadAt.In(loc).Format(timeLayout))
+		case r.DeliveredAt != nil:
+			fmt.Printf("%s  delivered %s\n", r.Recipient, r.DeliveredAt.In(loc).Format(timeLayout))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"whatsapp-logger/internal/journal"
+	"whatsapp-logger/internal/store"
+	"whatsapp-logger/internal/wa"
+)
+
+// Feed a journal recorded by `start --journal` through the handler pipeline
+// against a scratch database, leaving the real archive untouched
+func cmdReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	dbPath := fs.String("db", "", "scratch database to replay into (default: new file in the temp dir)")
+	ownUser := fs.String("own-user", "", "phone number of the recording account, used to attribute own history messages")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: kenny-whatsapp replay [--db scratch.db] [--own-user N] <journal>", errUsage)
+	}
+
+	target := *dbPath
+	if target == "" {
+		target = filepath.Join(os.TempDir(), fmt.Sprintf("kenny-replay-%d.db", time.Now().UnixNano()))
+	}
+	if same, _ := samePath(target, messagesDBPath); same {
+		return fmt.Errorf("%w: refusing to replay into the live archive %s", errUsage, messagesDBPath)
+	}
+
+	r, err := journal.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	st, err := store.Open(target)
+	if err != nil {
+		return fmt.Errorf("failed to open scratch database: %w", err)
+	}
+	defer st.Close()
+
+	logger := wa.NewOffline(st, *ownUser)
+	replayed := 0
+	for {
+		evt, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		logger.Replay(evt)
+		replayed++
+	}
+
+	messageCount, err := st.MessageCount()
+	if err != nil {
+		return fmt.Errorf("failed to count messages: %w", err)
+	}
+	chatCount, err := st.ChatCount()
+	if err != nil {
+		return fmt.Errorf("failed to count chats: %w", err)
+	}
+
+	fmt.Printf("Replayed %d events into %s\n", replayed, target)
+	fmt.Printf("Messages: %d\n", messageCount)
+	fmt.Printf("Chats: %d\n", chatCount)
+	return nil
+}
+
+// Report whether two paths name the same file, resolving relative paths
+func samePath(a, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	return absA == absB, nil
+}
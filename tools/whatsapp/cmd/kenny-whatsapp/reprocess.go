@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"whatsapp-logger/internal/store"
+	"whatsapp-logger/internal/wa"
+)
+
+// Re-run extraction over the raw payloads kept for stored messages
+func cmdReprocess(args []string) error {
+	fs := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	chat := fs.String("chat", "", "only reprocess this chat")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: kenny-whatsapp reprocess [--chat jid]", errUsage)
+	}
+	var err error
+	if *chat, err = resolveChat(*chat); err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	// Without connecting, the session still tells which account is mine, so
+	// mentions of me are recognized
+	logger := wa.NewOffline(st, "")
+	session, err := sessionDB()
+	if err != nil {
+		st.Close()
+		return err
+	}
+	if _, statErr := os.Stat(session); statErr == nil || store.IsPostgres(session) {
+		if logger, err = wa.NewWithStore(session, st); err != nil {
+			return fmt.Errorf("failed to create logger: %w", err)
+		}
+	}
+	defer logger.Disconnect()
+
+	n, err := logger.Reprocess(*chat)
+	fmt.Printf("Reprocessed %d messages\n", n)
+	return err
+}
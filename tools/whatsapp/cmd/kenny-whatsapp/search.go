@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Full-text search across every chat, best match first
+func cmdSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	chat := fs.String("chat", "", "only search this chat JID or phone number")
+	since := fs.String("since", "", "first day to search, YYYY-MM-DD")
+	until := fs.String("until", "", "day after the last one to search, YYYY-MM-DD")
+	senders := fs.String("sender", "", "only messages from these JIDs or phone numbers, comma separated")
+	limit := fs.Int("limit", 20, "maximum number of results")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	color := fs.String("color", "auto", "highlight matches: auto (when printing to a terminal), always or never")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	query := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if query == "" || *limit <= 0 || (*color != "auto" && *color != "always" && *color != "never") {
+		return fmt.Errorf("%w: kenny-whatsapp search [--chat JID] [--since DATE] [--until DATE] [--sender JIDS] [--limit N] [--json] [--color auto|always|never] [--tz zone] <query>", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	f := store.MessageFilter{Limit: *limit}
+	if *since != "" {
+		if f.Since, err = time.ParseInLocation("2006-01-02", *since, loc); err != nil {
+			return fmt.Errorf("%w: invalid --since date %q", errUsage, *since)
+		}
+	}
+	if *until != "" {
+		if f.Until, err = time.ParseInLocation("2006-01-02", *until, loc); err != nil {
+			return fmt.Errorf("%w: invalid --until date %q", errUsage, *until)
+		}
+	}
+	if *chat != "" {
+		jid, err := resolveChat(*chat)
+		if err != nil {
+			return err
+		}
+		f.Chats = []string{jid}
+	}
+	for _, sender := range splitList(*senders) {
+		jid, err := resolveChat(sender)
+		if err != nil {
+			return err
+		}
+		f.Senders = append(f.Senders, jid)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	results, err := st.Search(query, f)
+	if err != nil {
+		return fmt.Errorf("failed to search: %w", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	highlight := *color == "always" || (*color == "auto" && isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "")
+	for _, r := range results {
+		chat := r.ChatName
+		if chat == "" {
+			chat = r.ChatJID
+		}
+		sender := r.SenderName
+		switch {
+		case r.IsFromMe:
+			sender = "me"
+		case sender == "":
+			sender = r.Sender
+		}
+		snippet := strings.ReplaceAll(r.Snippet, "\n", " ")
+		if highlight {
+			snippet = highlightMatches(snippet, query)
+		}
+		fmt.Printf("[%s] %s / %s: %s\n", r.Timestamp.In(loc).Format(timeLayout), chat, sender, snippet)
+		fmt.Printf("    %s %s\n", r.ChatJID, r.ID)
+	}
+	if len(results) == 0 {
+		fmt.Println("No matches")
+	}
+	return nil
+}
+
+// Whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// A [bracketed] span of a snippet
+var snippetMatch = regexp.MustCompile(`\[([^\[\]]+)\]`)
+
+// Show a snippet's [bracketed] matches in bold yellow instead. Brackets
+// that were in the message itself, like those of "[Image]", are kept
+// unless their text is one of the query's words.
+func highlightMatches(snippet, query string) string {
+	var terms []string
+	for _, t := range strings.Fields(foldText(query)) {
+		if t = strings.Trim(t, `"*()`); t != "" && t != "or" && t != "and" && t != "not" {
+			terms = append(terms, t)
+		}
+	}
+	return snippetMatch.ReplaceAllStringFunc(snippet, func(span string) string {
+		text := span[1 : len(span)-1]
+		folded := foldText(text)
+		for _, t := range terms {
+			if strings.Contains(folded, t) || strings.Contains(t, folded) {
+				return "\x1b[1;33m" + text + "\x1b[0m"
+			}
+		}
+		return span
+	})
+}
+
+// Lower-case text without accents, as the search index compares words
+func foldText(text string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/store"
+	"whatsapp-logger/internal/wa"
+)
+
+// How long a send from the command line may take, uploads included
+const sendTimeout = 5 * time.Minute
+
+// Send a text message or a file to a chat and store it as mine
+func cmdSend(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	file := fs.String("file", "", "send this file, with the text as its caption")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || (*file == "" && fs.NArg() < 2) {
+		return fmt.Errorf("%w: kenny-whatsapp send [--file path] <chat_jid|phone> <text>", errUsage)
+	}
+	chat, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	text := strings.TrimSpace(strings.Join(fs.Args()[1:], " "))
+	if text == "" && *file == "" {
+		return fmt.Errorf("%w: nothing to send", errUsage)
+	}
+	var data []byte
+	if *file != "" {
+		if data, err = os.ReadFile(*file); err != nil {
+			return err
+		}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	if cfg.Sending.RequireApproval {
+		defer st.Close()
+		if *file != "" {
+			return errApprovalFile
+		}
+		return holdForApproval(st, store.PendingSend{ChatJID: chat, Text: text})
+	}
+	logger, err := sendingLogger(st)
+	if err != nil {
+		return err
+	}
+	defer logger.Disconnect()
+
+	if err := configureSending(logger, cfg.Sending); err != nil {
+		return err
+	}
+	if *file != "" {
+		logger.SetMediaDir(cfg.Media.Dir)
+		logger.SetMediaStorage(media.StorageFromConfig(cfg.Media))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if *file != "" {
+		return logger.SendMedia(ctx, chat, filepath.Base(*file), data, text)
+	}
+	return logger.SendText(ctx, chat, text)
+}
+
+// Start a poll in a chat
+func cmdSendPoll(args []string) error {
+	fs := flag.NewFlagSet("send-poll", flag.ContinueOnError)
+	choices := fs.Int("choices", 1, "options each person may pick, 0 for any number")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 4 {
+		return fmt.Errorf("%w: kenny-whatsapp send-poll [--choices N] <chat_jid|phone> <question> <option> <option>...", errUsage)
+	}
+	chat, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Sending.RequireApproval {
+		return errApprovalPoll
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	logger, err := sendingLogger(st)
+	if err != nil {
+		return err
+	}
+	defer logger.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return logger.SendPoll(ctx, chat, fs.Arg(1), fs.Args()[2:], *choices)
+}
+
+// React to a stored message, or remove my reaction when no emoji is given
+func cmdReact(args []string) error {
+	fs := flag.NewFlagSet("react", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 || fs.NArg() > 3 {
+		return fmt.Errorf("%w: kenny-whatsapp react <chat_jid|phone> <message_id> [emoji]", errUsage)
+	}
+	chat, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	key := store.MessageKey{ChatJID: chat, ID: fs.Arg(1)}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	logger, err := sendingLogger(st)
+	if err != nil {
+		return err
+	}
+	defer logger.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return logger.SendReaction(ctx, key, fs.Arg(2))
+}
+
+// Send read receipts for what came in to a chat since I last wrote there
+func cmdMarkRead(args []string) error {
+	fs := flag.NewFlagSet("mark-read", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: kenny-whatsapp mark-read <chat_jid|phone>", errUsage)
+	}
+	chat, err := resolveChat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	logger, err := sendingLogger(st)
+	if err != nil {
+		return err
+	}
+	defer logger.Disconnect()
+
+	n, err := logger.MarkRead(chat)
+	if err != nil {
+		return err
+	}
+	if !dryRun {
+		fmt.Printf("Marked %d messages read in %s\n", n, chat)
+	}
+	return nil
+}
+
+// Apply the typing and presence settings to logger
+func configureSending(logger *wa.Logger, cfg config.Sending) error {
+	if cfg.Typing {
+		logger.SetTyping(&wa.Typing{
+			CharsPerSecond: cfg.CharsPerSecond,
+			Min:            time.Duration(cfg.MinDelay * float64(time.Second)),
+			Max:            time.Duration(cfg.MaxDelay * float64(time.Second)),
+		})
+	}
+	return logger.SetPresence(cfg.Presence)
+}
+
+// Connect a logger to send with, or in dry-run mode one that only logs what
+// it would send. Takes ownership of st.
+func sendingLogger(st store.Store) (*wa.Logger, error) {
+	if dryRun {
+		logger := wa.NewOffline(st, "")
+		logger.SetDryRun(true)
+		return logger, nil
+	}
+	return connectLogger(st)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/api"
+	"whatsapp-logger/internal/config"
+)
+
+// Serve the REST API and web UI over the archive without connecting to WhatsApp
+func cmdServe(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", cfg.API.Addr, "listen address")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cfg.API.Addr = *addr
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	archive, err := openCold()
+	if err != nil {
+		return err
+	}
+	srv := api.New(st, cfg.API, waLog.Stdout("API", "INFO", true))
+	srv.SetCold(archive)
+	srv.SetMediaDir(cfg.Media.Dir)
+	srv.SetRequireApproval(cfg.Sending.RequireApproval)
+	return srv.ListenAndServe(ctx)
+}
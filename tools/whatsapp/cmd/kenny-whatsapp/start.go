@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/api"
+	"whatsapp-logger/internal/autoreply"
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/inbox"
+	"whatsapp-logger/internal/journal"
+	"whatsapp-logger/internal/live"
+	"whatsapp-logger/internal/mcp"
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/notify"
+	"whatsapp-logger/internal/ocr"
+	"whatsapp-logger/internal/outbox"
+	"whatsapp-logger/internal/quiet"
+	"whatsapp-logger/internal/rpc"
+	"whatsapp-logger/internal/wa"
+	"whatsapp-logger/internal/webhook"
+)
+
+// Start the WhatsApp logger and run until interrupted
+func cmdStart(args []string) error {
+	fs := flag.NewFlagSet("start", flag.ContinueOnError)
+	journalPath := fs.String("journal", "", "record received events to this file for later replay")
+	httpAddr := fs.String("http", "", "also serve the REST API and web UI on this address (\"config\" for the configured one)")
+	mcpAddr := fs.String("mcp", "", "also serve MCP over HTTP with server-sent events on this address, sending through the logger")
+	grpcAddr := fs.String("grpc", "", "also serve the gRPC API on this address, streaming messages as they arrive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to initialize message store: %w", err)
+	}
+
+	session, err := sessionDB()
+	if err != nil {
+		st.Close()
+		return err
+	}
+	logger, err := wa.NewWithStore(session, st)
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Disconnect()
+	logger.SetMediaDir(cfg.Media.Dir)
+	logger.SetMediaStorage(media.StorageFromConfig(cfg.Media))
+	if policy := media.DownloadPolicyFromConfig(cfg.Media); policy.Enabled() {
+		logger.SetMediaDownload(policy)
+	}
+	if cfg.OCR.Enabled {
+		engine, err := ocr.New(cfg.OCR)
+		if err != nil {
+			return err
+		}
+		logger.SetOCR(engine)
+	}
+	if err := configureSending(logger, cfg.Sending); err != nil {
+		return err
+	}
+	if err := logger.SetPresenceLog(cfg.PresenceLog.Contacts); err != nil {
+		return err
+	}
+	logger.SetDryRun(dryRun)
+	if dryRun {
+		log.Printf("Dry run: outbound messages are logged, not sent")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.Notifications.Enabled {
+		dispatcher := notify.NewDispatcher(cfg.Notifications, waLog.Stdout("Notify", "INFO", true), notify.SinksFromConfig(cfg.Notifications.Sinks)...)
+		loc, err := cfg.Location()
+		if err != nil {
+			return err
+		}
+		hours, err := quiet.New(cfg.QuietHours, loc)
+		if err != nil {
+			return err
+		}
+		dispatcher.SetQuietHours(hours)
+		if cfg.Notifications.FollowPhoneMutes {
+			dispatcher.SetPhoneMutes(func(chatJID string) bool {
+				muted, err := st.ChatMuted(chatJID, time.Now())
+				if err != nil {
+					log.Printf("Failed to read mute state of %s: %v", chatJID, err)
+				}
+				return muted
+			})
+		}
+		logger.AddMessageHook(dispatcher.HandleMessage)
+	}
+
+	if cfg.SelfCommands.Enabled {
+		loc, err := cfg.Location()
+		if err != nil {
+			return err
+		}
+		in := inbox.New(st, logger, loc, waLog.Stdout("Inbox", "INFO", true))
+		logger.AddMessageHook(in.HandleMessage)
+		go in.Run(ctx)
+	}
+
+	if len(cfg.AutoReplies.Rules) > 0 {
+		loc, err := cfg.Location()
+		if err != nil {
+			return err
+		}
+		responder, err := autoreply.New(cfg.AutoReplies, loc, logger, waLog.Stdout("AutoReply", "INFO", true))
+		if err != nil {
+			return err
+		}
+		logger.AddMessageHook(responder.HandleMessage)
+	}
+
+	if len(cfg.Webhooks.Endpoints) > 0 {
+		hooks, err := webhook.New(cfg.Webhooks, waLog.Stdout("Webhook", "INFO", true))
+		if err != nil {
+			return err
+		}
+		logger.AddMessageHook(hooks.HandleMessage)
+		go hooks.Run(ctx)
+	}
+
+	go logger.RunGroupSync(ctx, wa.GroupSyncInterval)
+
+	if dryRun {
+		log.Printf("Dry run: outbox messages stay queued")
+	} else {
+		go outbox.New(st, logger, waLog.Stdout("Outbox", "INFO", true)).Run(ctx)
+	}
+
+	if len(cfg.Digests.Groups) > 0 {
+		if err := startDigests(ctx, cfg, st, logger); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Media.Retention.Enabled() {
+		if err := startMediaGC(ctx, cfg.Media, st); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Retention.Enabled() {
+		if err := startRetention(ctx, cfg.Retention, st); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Backup.Enabled {
+		if err := startBackups(ctx, cfg, st); err != nil {
+			return err
+		}
+	}
+
+	if *journalPath != "" {
+		j, err := journal.Create(*journalPath)
+		if err != nil {
+			return err
+		}
+		defer j.Close()
+		logger.SetJournal(j)
+		log.Printf("Journaling events to %s", *journalPath)
+	}
+
+	// Live messages for the streaming endpoints
+	var hub *live.Hub
+	if *httpAddr != "" || *grpcAddr != "" {
+		hub = live.NewHub()
+		logger.AddMessageHook(hub.Publish)
+	}
+
+	if *grpcAddr != "" {
+		archive, err := openCold()
+		if err != nil {
+			return err
+		}
+		server := rpc.New(st, cfg.API, waLog.Stdout("gRPC", "INFO", true))
+		server.SetCold(archive)
+		server.SetSender(logger)
+		server.SetRequireApproval(cfg.Sending.RequireApproval)
+		server.SetHub(hub)
+		go func() {
+			if err := server.ListenAndServe(ctx, *grpcAddr); err != nil {
+				log.Printf("gRPC server stopped: %v", err)
+			}
+		}()
+	}
+
+	if err := logger.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	if *httpAddr != "" {
+		apiCfg := cfg.API
+		if *httpAddr != "config" {
+			apiCfg.Addr = *httpAddr
+		}
+		archive, err := openCold()
+		if err != nil {
+			return err
+		}
+		server := api.New(st, apiCfg, waLog.Stdout("API", "INFO", true))
+		server.SetCold(archive)
+		server.SetMediaDir(cfg.Media.Dir)
+		server.SetSender(logger)
+		server.SetRequireApproval(cfg.Sending.RequireApproval)
+		server.SetHub(hub)
+		go func() {
+			if err := server.ListenAndServe(ctx); err != nil {
+				log.Printf("API server stopped: %v", err)
+			}
+		}()
+	}
+
+	if *mcpAddr != "" {
+		mcpCfg := cfg.API
+		mcpCfg.Addr = *mcpAddr
+		archive, err := openCold()
+		if err != nil {
+			return err
+		}
+		server := mcp.New(st, waLog.Stdout("MCP", "INFO", true))
+		server.SetCold(archive)
+		server.SetSender(logger)
+		server.SetRequireApproval(cfg.Sending.RequireApproval)
+		go func() {
+			if err := server.ListenAndServe(ctx, mcpCfg); err != nil {
+				log.Printf("MCP server stopped: %v", err)
+			}
+		}()
+	}
+
+	log.Println("WhatsApp logger started. Press Ctrl+C to stop...")
+
+	// Wait for interrupt signal
+	<-ctx.Done()
+
+	log.Println("Shutting down...")
+	return nil
+}
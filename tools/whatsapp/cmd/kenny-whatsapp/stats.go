@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"whatsapp-logger/internal/stats"
+)
+
+// Print an activity report: volume by day and week, top chats and
+// senders, busiest hours, media and how quickly I reply
+func cmdStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	since := fs.String("since", "", "first day to include, YYYY-MM-DD (default: 7 days ago)")
+	until := fs.String("until", "", "day after the last one to include, YYYY-MM-DD (default: now)")
+	chat := fs.String("chat", "", "only messages in this chat JID or phone number")
+	top := fs.Int("top", 10, "chats and senders to rank")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *top <= 0 {
+		return fmt.Errorf("%w: kenny-whatsapp stats [--since DATE] [--until DATE] [--chat JID] [--top N] [--json] [--tz zone]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+	opts := stats.Options{Location: loc, TopChats: *top, TopSenders: *top}
+	y, m, d := time.Now().In(loc).Date()
+	opts.Since = time.Date(y, m, d, 0, 0, 0, 0, loc).AddDate(0, 0, -6)
+	if *since != "" {
+		if opts.Since, err = time.ParseInLocation("2006-01-02", *since, loc); err != nil {
+			return fmt.Errorf("%w: invalid --since date %q", errUsage, *since)
+		}
+	}
+	if *until != "" {
+		if opts.Until, err = time.ParseInLocation("2006-01-02", *until, loc); err != nil {
+			return fmt.Errorf("%w: invalid --until date %q", errUsage, *until)
+		}
+	}
+	if opts.ChatJID, err = resolveChat(*chat); err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	report, err := stats.Compute(st, opts)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printStats(report, loc)
+	return nil
+}
+
+// Days listed one by one; longer windows are shown by week only
+const maxDailyRows = 31
+
+func printStats(r *stats.Report, loc *time.Location) {
+	fmt.Printf("%s to %s: %d messages, %d sent, %d received\n",
+		r.Since.In(loc).Format("2006-01-02"), r.Until.In(loc).Format("2006-01-02"), r.Total, r.Sent, r.Received)
+	if r.Total == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	if len(r.Daily) <= maxDailyRows {
+		busiest := 0
+		for _, d := range r.Daily {
+			busiest = max(busiest, d.Sent+d.Received)
+		}
+		fmt.Fprintln(tw, "\nDAY\tSENT\tRECEIVED\t")
+		for _, d := range r.Daily {
+			day, _ := time.ParseInLocation("2006-01-02", d.Day, loc)
+			fmt.Fprintf(tw, "%s %s\t%d\t%d\t%s\n", day.Format("Mon"), d.Day, d.Sent, d.Received, bar(d.Sent+d.Received, busiest))
+		}
+	}
+	if len(r.Weekly) > 1 {
+		busiest := 0
+		for _, w := range r.Weekly {
+			busiest = max(busiest, w.Sent+w.Received)
+		}
+		fmt.Fprintln(tw, "\nWEEK OF\tSENT\tRECEIVED\t")
+		for _, w := range r.Weekly {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", w.Week, w.Sent, w.Received, bar(w.Sent+w.Received, busiest))
+		}
+	}
+
+	fmt.Fprintln(tw, "\nCHAT\tMESSAGES\tSENT\tRECEIVED")
+	for _, c := range r.TopChats {
+		name := c.Name
+		if name == "" {
+			name = c.JID
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", name, c.Count, c.Sent, c.Received)
+	}
+	if len(r.TopSenders) > 0 {
+		fmt.Fprintln(tw, "\nSENDER\tMESSAGES\tCHATS\t")
+		for _, s := range r.TopSenders {
+			name := s.Name
+			if name == "" {
+				name = s.JID
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t\n", name, s.Count, s.Chats)
+		}
+	}
+
+	fmt.Fprintln(tw, "\nHOUR\tMESSAGES\t")
+	busiest := 0
+	for _, n := range r.Hours {
+		busiest = max(busiest, n)
+	}
+	for hour, n := range r.Hours {
+		if n > 0 {
+			fmt.Fprintf(tw, "%02d:00\t%d\t%s\n", hour, n, bar(n, busiest))
+		}
+	}
+
+	if len(r.Media) > 0 {
+		types := make([]string, 0, len(r.Media))
+		for t := range r.Media {
+			types = append(types, t)
+		}
+		sort.Slice(types, func(i, j int) bool { return r.Media[types[i]] > r.Media[types[j]] })
+		fmt.Fprintln(tw, "\nMEDIA\tMESSAGES\t")
+		for _, t := range types {
+			fmt.Fprintf(tw, "%s\t%d\t\n", t, r.Media[t])
+		}
+	}
+
+	replies := 0
+	for _, b := range r.ResponseTimes {
+		replies += b.Count
+	}
+	if replies > 0 {
+		fmt.Fprintf(tw, "\nMY REPLY TIME\tREPLIES\t(median %s)\n", (time.Duration(r.MedianResponseSeconds) * time.Second).String())
+		for _, b := range r.ResponseTimes {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", b.Label, b.Count, bar(b.Count, replies))
+		}
+	}
+	tw.Flush()
+	if r.Forwarded > 0 {
+		fmt.Printf("\n%d forwarded, %d of them forwarded many times\n", r.Forwarded, r.ForwardedManyTimes)
+	}
+}
+
+// A bar of up to 30 blocks for n against the largest value shown
+func bar(n, largest int) string {
+	if largest == 0 || n == 0 {
+		return ""
+	}
+	return strings.Repeat("█", max(1, n*30/largest))
+}
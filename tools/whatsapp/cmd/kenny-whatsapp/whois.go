@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/whois"
+)
+
+// Print everything known about a contact
+func cmdWhois(args []string) error {
+	fs := flag.NewFlagSet("whois", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the profile as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: kenny-whatsapp whois [--json] [--tz zone] <jid|phone>", errUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if _, err := whois.Resolve(fs.Arg(0), cfg.Region); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	p, err := whois.Lookup(st, fs.Arg(0), cfg.Region, cfg.Media.Dir)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	}
+
+	day := func(t time.Time) string { return t.In(loc).Format("2006-01-02") }
+	fmt.Printf("JID:        %s\n", p.JID)
+	if p.Phone != "" {
+		fmt.Printf("Phone:      %s\n", p.Phone)
+	}
+	if p.ChatName != "" && p.ChatName != p.JID {
+		fmt.Printf("Chat name:  %s\n", p.ChatName)
+	}
+	if len(p.Names) > 0 {
+		fmt.Println("Names:")
+		for _, n := range p.Names {
+			fmt.Printf("  %s (%s to %s)\n", n.Name, day(n.FirstSeen), day(n.LastSeen))
+		}
+	}
+	if len(p.LinkedJIDs) > 0 {
+		fmt.Printf("Linked:     %s\n", strings.Join(p.LinkedJIDs, ", "))
+	}
+	if p.Messages > 0 {
+		fmt.Printf("Messages:   %d, first %s, last %s\n", p.Messages, p.FirstMessage.In(loc).Format(timeLayout), p.LastMessage.In(loc).Format(timeLayout))
+	}
+	if p.Direct != nil {
+		fmt.Printf("Direct:     %d from them, %d from me, %s to %s\n", p.Direct.Theirs, p.Direct.Mine, day(p.Direct.First), day(p.Direct.Last))
+	}
+	if len(p.Groups) > 0 {
+		fmt.Println("Groups:")
+		for _, g := range p.Groups {
+			name := g.Name
+			if name == "" {
+				name = g.JID
+			}
+			fmt.Printf("  %s: %d messages, %s to %s\n", name, g.Theirs, day(g.First), day(g.Last))
+		}
+	}
+	if p.Avatar != "" {
+		fmt.Printf("Avatar:     %s\n", p.Avatar)
+	}
+	return nil
+}
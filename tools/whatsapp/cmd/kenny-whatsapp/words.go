@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/stats"
+)
+
+// Print the most used words and phrases per chat or per contact
+func cmdWords(args []string) error {
+	fs := flag.NewFlagSet("words", flag.ContinueOnError)
+	since := fs.String("since", "", "first day to include, YYYY-MM-DD (default: one year ago)")
+	until := fs.String("until", "", "day after the last one to include, YYYY-MM-DD (default: now)")
+	by := fs.String("by", "", "group by \"chat\" or \"sender\" (default: one group)")
+	chat := fs.String("chat", "", "only messages in this chat JID")
+	top := fs.Int("top", 20, "words and phrases to list per group")
+	minPhrase := fs.Int("min-phrase", 3, "minimum occurrences for a phrase")
+	stopFile := fs.String("stopwords", "", "file of extra words to ignore, whitespace separated")
+	received := fs.Bool("received", false, "only count messages sent to me")
+	original := fs.Bool("original", false, "skip forwarded messages")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	tz := addTZFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || (*by != "" && *by != stats.ByChat && *by != stats.BySender) {
+		return fmt.Errorf("%w: kenny-whatsapp words [--since DATE] [--until DATE] [--by chat|sender] [--chat JID] [--top N] [--json]", errUsage)
+	}
+	loc, err := location(*tz)
+	if err != nil {
+		return err
+	}
+
+	opts := stats.WordOptions{
+		By:             *by,
+		ChatJID:        *chat,
+		Top:            *top,
+		MinPhraseCount: *minPhrase,
+		ReceivedOnly:   *received,
+		OriginalOnly:   *original,
+		Since:          time.Now().AddDate(-1, 0, 0),
+	}
+	if *since != "" {
+		if opts.Since, err = time.ParseInLocation("2006-01-02", *since, loc); err != nil {
+			return fmt.Errorf("%w: invalid --since date %q", errUsage, *since)
+		}
+	}
+	if *until != "" {
+		if opts.Until, err = time.ParseInLocation("2006-01-02", *until, loc); err != nil {
+			return fmt.Errorf("%w: invalid --until date %q", errUsage, *until)
+		}
+	}
+	if *stopFile != "" {
+		data, err := os.ReadFile(*stopFile)
+		if err != nil {
+			return fmt.Errorf("failed to read stopwords: %w", err)
+		}
+		opts.Stopwords = strings.Fields(string(data))
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer st.Close()
+
+	report, err := stats.Words(st, opts)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	for _, g := range report.Groups {
+		fmt.Printf("%s (%d messages)\n", g.Name, g.Messages)
+		fmt.Printf("  words:   %s\n", formatTerms(g.Words))
+		if len(g.Phrases) > 0 {
+			fmt.Printf("  phrases: %s\n", formatTerms(g.Phrases))
+		}
+	}
+	return nil
+}
+
+// Render terms as "term (n), term (n)"
+func formatTerms(terms []stats.TermCount) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = fmt.Sprintf("%s (%d)", t.Term, t.Count)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,1046 @@
+// Package api serves the message archive over HTTP: a JSON REST API plus the
+// embedded web UI for browsing it.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/cold"
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/digest"
+	"whatsapp-logger/internal/links"
+	"whatsapp-logger/internal/live"
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/outbox"
+	"whatsapp-logger/internal/polls"
+	"whatsapp-logger/internal/stats"
+	"whatsapp-logger/internal/store"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// Server exposes a store over HTTP
+type Server struct {
+	store store.Store
+	cfg   config.API
+	log   waLog.Logger
+	mux   *http.ServeMux
+	// Optional archive of messages moved out of the store
+	cold *cold.Archive
+	// Where downloaded attachments live
+	mediaDir string
+	// Sends messages for POST requests; nil when not connected to WhatsApp
+	sender Sender
+	// Hold text messages for approval instead of sending or queueing them
+	requireApproval bool
+	// Live messages for the stream endpoint; nil when not connected
+	hub *live.Hub
+}
+
+// Sender delivers messages to WhatsApp; *wa.Logger implements it
+type Sender interface {
+	SendText(ctx context.Context, chatJID, text string) error
+	SendMedia(ctx context.Context, chatJID, name string, data []byte, caption string) error
+	SendReaction(ctx context.Context, key store.MessageKey, emoji string) error
+	SendPoll(ctx context.Context, chatJID, question string, options []string, selectable int) error
+	MarkRead(chatJID string) (int, error)
+}
+
+// Largest file accepted for sending
+const maxUpload = 64 << 20
+
+// Create a server reading from st
+func New(st store.Store, cfg config.API, log waLog.Logger) *Server {
+	s := &Server{store: st, cfg: cfg, log: log, mux: http.NewServeMux()}
+	s.routes()
+	return s
+}
+
+// Also search messages moved to cold storage; nil disables it
+func (s *Server) SetCold(a *cold.Archive) {
+	s.cold = a
+}
+
+// Look for downloaded attachments under dir
+func (s *Server) SetMediaDir(dir string) {
+	s.mediaDir = dir
+}
+
+// Accept messages to send through sender; nil disables sending
+func (s *Server) SetSender(sender Sender) {
+	s.sender = sender
+}
+
+// Hold messages sent or queued through the API until approved
+func (s *Server) SetRequireApproval(on bool) {
+	s.requireApproval = on
+}
+
+// Stream messages published to hub from GET /api/stream
+func (s *Server) SetHub(hub *live.Hub) {
+	s.hub = hub
+}
+
+// Register every endpoint
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /api/chats", s.handleChats)
+	s.mux.HandleFunc("GET /api/chats/{jid}/messages", s.handleChatMessages)
+	s.mux.HandleFunc("POST /api/chats/{jid}/messages", s.handleSend)
+	s.mux.HandleFunc("POST /api/chats/{jid}/media", s.handleSendMedia)
+	s.mux.HandleFunc("POST /api/chats/{jid}/polls", s.handleSendPoll)
+	s.mux.HandleFunc("POST /api/chats/{jid}/read", s.handleMarkRead)
+	s.mux.HandleFunc("GET /api/chats/{jid}/links", s.handleLinks)
+	s.mux.HandleFunc("GET /api/chats/{jid}/avatar", s.handleAvatar)
+	s.mux.HandleFunc("GET /api/chats/{jid}/messages/{id}", s.handleMessage)
+	s.mux.HandleFunc("GET /api/chats/{jid}/messages/{id}/thread", s.handleThread)
+	s.mux.HandleFunc("GET /api/chats/{jid}/messages/{id}/reactions", s.handleReactions)
+	s.mux.HandleFunc("GET /api/chats/{jid}/messages/{id}/revisions", s.handleRevisions)
+	s.mux.HandleFunc("GET /api/chats/{jid}/messages/{id}/receipts", s.handleReceipts)
+	s.mux.HandleFunc("GET /api/files", s.handleFiles)
+	s.mux.HandleFunc("GET /api/polls", s.handlePolls)
+	s.mux.HandleFunc("GET /api/locations", s.handleLocations)
+	s.mux.HandleFunc("GET /api/contacts", s.handleSharedContacts)
+	s.mux.HandleFunc("GET /api/links", s.handleSharedLinks)
+	s.mux.HandleFunc("GET /api/calls", s.handleCalls)
+	s.mux.HandleFunc("GET /api/groups", s.handleGroups)
+	s.mux.HandleFunc("GET /api/groups/{jid}", s.handleGroup)
+	s.mux.HandleFunc("GET /api/groups/{jid}/membership", s.handleMembership)
+	s.mux.HandleFunc("GET /api/groups/{jid}/members", s.handleMembersAt)
+	s.mux.HandleFunc("GET /api/communities/{jid}/messages", s.handleCommunityMessages)
+	s.mux.HandleFunc("GET /api/invites", s.handleInvites)
+	s.mux.HandleFunc("GET /api/presence/{jid}", s.handlePresence)
+	s.mux.HandleFunc("GET /api/outbox", s.handleOutbox)
+	s.mux.HandleFunc("POST /api/outbox", s.handleQueueOutbox)
+	s.mux.HandleFunc("DELETE /api/outbox/{id}", s.handleCancelOutbox)
+	s.mux.HandleFunc("GET /api/pending", s.handlePending)
+	s.mux.HandleFunc("POST /api/pending/{id}/approve", s.handleApprove)
+	s.mux.HandleFunc("POST /api/pending/{id}/reject", s.handleReject)
+	s.mux.HandleFunc("GET /api/search", s.handleSearch)
+	s.mux.HandleFunc("GET /api/stream", s.handleStream)
+	s.mux.HandleFunc("GET /api/stats", s.handleStats)
+	s.mux.HandleFunc("GET /api/stats/words", s.handleWords)
+	s.mux.HandleFunc("GET /api/stats/contacts", s.handleContactStats)
+	s.mux.HandleFunc("GET /api/unreplied", s.handleUnreplied)
+	s.mux.HandleFunc("GET /api/bookmarks", s.handleBookmarks)
+	s.mux.HandleFunc("PUT /api/chats/{jid}/messages/{id}/bookmark", s.handleSetBookmark)
+	s.mux.HandleFunc("DELETE /api/chats/{jid}/messages/{id}/bookmark", s.handleRemoveBookmark)
+	s.mux.HandleFunc("PUT /api/chats/{jid}/messages/{id}/reaction", s.handleReact)
+	s.mux.HandleFunc("DELETE /api/chats/{jid}/messages/{id}/reaction", s.handleReact)
+
+	web, _ := fs.Sub(webFiles, "web")
+	s.mux.Handle("GET /", http.FileServerFS(web))
+}
+
+// The full handler, with authentication applied when configured and
+// requests that change state guarded against cross-site forgery
+func (s *Server) Handler() http.Handler {
+	h := guardWrites(s.mux)
+	if s.cfg.Username == "" || s.cfg.Password == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(s.cfg.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(s.cfg.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Kenny WhatsApp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Refuse POST, PUT and DELETE requests a browser sends from another site,
+// and those not declaring a JSON body (a multipart one for file uploads).
+// A page elsewhere can make the browser submit a form to the API, with the
+// user's saved credentials, but can neither set a JSON content type without
+// a CORS preflight, which the API never grants, nor hide where it came from.
+func guardWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if crossOrigin(r) {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		want := "application/json"
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/media") {
+			want = "multipart/form-data"
+		}
+		if kind, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || kind != want {
+			http.Error(w, "Content-Type must be "+want, http.StatusUnsupportedMediaType)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Whether a browser sent r from a page of another origin. Requests from
+// other clients carry neither header.
+func crossOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
+// Serve on the configured address until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.cfg.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	s.log.Infof("Serving archive on http://%s", s.cfg.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleChats(w http.ResponseWriter, r *http.Request) {
+	chats, err := s.store.ListChats()
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, chats)
+}
+
+func (s *Server) handleChatMessages(w http.ResponseWriter, r *http.Request) {
+	messages, err := s.store.QueryMessages(r.PathValue("jid"), limitParam(r))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// Send a text message to the chat, body {"text": "..."}
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	if s.sender == nil && !s.requireApproval {
+		http.Error(w, "sending needs the logger running (start --http)", http.StatusServiceUnavailable)
+		return
+	}
+	var body struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(body.Text) == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	if s.requireApproval {
+		s.hold(w, store.PendingSend{ChatJID: r.PathValue("jid"), Text: body.Text})
+		return
+	}
+	if err := s.sender.SendText(r.Context(), r.PathValue("jid"), body.Text); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Send a file to the chat, as multipart form fields file and caption
+func (s *Server) handleSendMedia(w http.ResponseWriter, r *http.Request) {
+	if s.requireApproval {
+		http.Error(w, "files cannot be held for approval", http.StatusForbidden)
+		return
+	}
+	if s.sender == nil {
+		http.Error(w, "sending needs the logger running (start --http)", http.StatusServiceUnavailable)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "a file form field is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := s.sender.SendMedia(r.Context(), r.PathValue("jid"), header.Filename, data, r.FormValue("caption")); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Start a poll, body {"question": "...", "options": [...], "selectable": N};
+// selectable defaults to 1, 0 allows any number of picks
+func (s *Server) handleSendPoll(w http.ResponseWriter, r *http.Request) {
+	if s.requireApproval {
+		http.Error(w, "polls cannot be held for approval", http.StatusForbidden)
+		return
+	}
+	if s.sender == nil {
+		http.Error(w, "sending needs the logger running (start --http)", http.StatusServiceUnavailable)
+		return
+	}
+	body := struct {
+		Question   string   `json:"question"`
+		Options    []string `json:"options"`
+		Selectable int      `json:"selectable"`
+	}{Selectable: 1}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := s.sender.SendPoll(r.Context(), r.PathValue("jid"), body.Question, body.Options, body.Selectable); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The saved profile picture of a contact or group, as a JPEG
+func (s *Server) handleAvatar(w http.ResponseWriter, r *http.Request) {
+	a, ok, err := s.store.GetAvatar(r.PathValue("jid"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if !ok || a.Path == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	http.ServeFile(w, r, a.Path)
+}
+
+// Send read receipts for what came in since I last wrote in the chat
+func (s *Server) handleMarkRead(w http.ResponseWriter, r *http.Request) {
+	if s.sender == nil {
+		http.Error(w, "sending needs the logger running (start --http)", http.StatusServiceUnavailable)
+		return
+	}
+	n, err := s.sender.MarkRead(r.PathValue("jid"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"marked": n})
+}
+
+// Every URL shared in the chat, most recently shared first
+func (s *Server) handleLinks(w http.ResponseWriter, r *http.Request) {
+	found, err := links.Collect(s.store, r.PathValue("jid"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+// One message, with the message it replies to when that is stored
+func (s *Server) handleMessage(w http.ResponseWriter, r *http.Request) {
+	m, parent, err := s.store.GetMessageWithParent(store.MessageKey{ChatJID: r.PathValue("jid"), ID: r.PathValue("id")})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Message store.Message  `json:"message"`
+		ReplyTo *store.Message `json:"reply_to,omitempty"`
+	}{m, parent})
+}
+
+// The reply chain around a message, for following one discussion in a busy
+// group
+func (s *Server) handleThread(w http.ResponseWriter, r *http.Request) {
+	thread, err := s.store.Thread(store.MessageKey{ChatJID: r.PathValue("jid"), ID: r.PathValue("id")})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, thread)
+}
+
+func (s *Server) handleReactions(w http.ResponseWriter, r *http.Request) {
+	reactions, err := s.store.Reactions(store.MessageKey{ChatJID: r.PathValue("jid"), ID: r.PathValue("id")})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if reactions == nil {
+		reactions = []store.Reaction{}
+	}
+	writeJSON(w, http.StatusOK, reactions)
+}
+
+func (s *Server) handleRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := s.store.Revisions(store.MessageKey{ChatJID: r.PathValue("jid"), ID: r.PathValue("id")})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if revisions == nil {
+		revisions = []store.Revision{}
+	}
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// Who got and read a message I sent
+func (s *Server) handleReceipts(w http.ResponseWriter, r *http.Request) {
+	receipts, err := s.store.Receipts(store.MessageKey{ChatJID: r.PathValue("jid"), ID: r.PathValue("id")})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if receipts == nil {
+		receipts = []store.Receipt{}
+	}
+	writeJSON(w, http.StatusOK, receipts)
+}
+
+// Document messages across chats, or in ?chat=
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	files, err := media.Documents(s.store, s.mediaDir, r.URL.Query().Get("chat"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, files)
+}
+
+// Poll results across chats, or in ?chat=
+func (s *Server) handlePolls(w http.ResponseWriter, r *http.Request) {
+	results, err := polls.List(s.store, r.URL.Query().Get("chat"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// Location messages across chats, or in ?chat=
+func (s *Server) handleLocations(w http.ResponseWriter, r *http.Request) {
+	found, err := s.store.ListLocations(r.URL.Query().Get("chat"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if found == nil {
+		found = []store.Message{}
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+// Contact cards shared across chats, or in ?chat=
+func (s *Server) handleSharedContacts(w http.ResponseWriter, r *http.Request) {
+	found, err := s.store.ListSharedContacts(r.URL.Query().Get("chat"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if found == nil {
+		found = []store.SharedContact{}
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+// Each share of a link, newest first, narrowed with ?chat=, ?sender=,
+// ?domain= and ?since= / ?until= days (YYYY-MM-DD, UTC)
+func (s *Server) handleSharedLinks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.LinkFilter{ChatJID: q.Get("chat"), Sender: q.Get("sender"), Domain: q.Get("domain")}
+	for _, bound := range []struct {
+		param string
+		into  *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := q.Get(bound.param); v != "" {
+			day, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				http.Error(w, bound.param+" must be a YYYY-MM-DD date", http.StatusBadRequest)
+				return
+			}
+			*bound.into = day
+		}
+	}
+	found, err := s.store.ListLinks(f)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if found == nil {
+		found = []store.SharedLink{}
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+// Calls across chats, or in ?chat=
+func (s *Server) handleCalls(w http.ResponseWriter, r *http.Request) {
+	calls, err := s.store.ListCalls(r.URL.Query().Get("chat"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if calls == nil {
+		calls = []store.Call{}
+	}
+	writeJSON(w, http.StatusOK, calls)
+}
+
+// Synced groups, by subject
+func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := s.store.ListGroups()
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if groups == nil {
+		groups = []store.Group{}
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// One synced group with its members
+func (s *Server) handleGroup(w http.ResponseWriter, r *http.Request) {
+	g, err := s.store.GetGroup(r.PathValue("jid"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+// The latest messages across a community's groups
+func (s *Server) handleCommunityMessages(w http.ResponseWriter, r *http.Request) {
+	messages, err := s.store.QueryCommunity(r.PathValue("jid"), limitParam(r))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if messages == nil {
+		messages = []store.Message{}
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// Joins, leaves, promotions and demotions in a group, oldest first, or
+// only those of ?member=
+func (s *Server) handleMembership(w http.ResponseWriter, r *http.Request) {
+	changes, err := s.store.MembershipChanges(r.PathValue("jid"), r.URL.Query().Get("member"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if changes == nil {
+		changes = []store.MembershipChange{}
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// Who was in a group at ?at= (RFC 3339), default now
+func (s *Server) handleMembersAt(w http.ResponseWriter, r *http.Request) {
+	at := time.Now()
+	if v := r.URL.Query().Get("at"); v != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "at must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	members, err := s.store.GroupMembersAt(r.PathValue("jid"), at)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if members == nil {
+		members = []string{}
+	}
+	writeJSON(w, http.StatusOK, members)
+}
+
+// A watched contact's presence changes since ?since= (RFC 3339), default
+// the last 24 hours
+func (s *Server) handlePresence(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().Add(-24 * time.Hour)
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	changes, err := s.store.PresenceLog(r.PathValue("jid"), since)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if changes == nil {
+		changes = []store.PresenceChange{}
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// Group invites across chats, or in ?chat=
+func (s *Server) handleInvites(w http.ResponseWriter, r *http.Request) {
+	invites, err := s.store.ListGroupInvites(r.URL.Query().Get("chat"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if invites == nil {
+		invites = []store.GroupInvite{}
+	}
+	writeJSON(w, http.StatusOK, invites)
+}
+
+// Pending outbox messages, soonest first; ?all=1 adds sent and failed ones
+func (s *Server) handleOutbox(w http.ResponseWriter, r *http.Request) {
+	messages, err := s.store.ListOutbox(r.URL.Query().Get("all") != "")
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if messages == nil {
+		messages = []store.OutboxMessage{}
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// Queue a message, body {"chat_jid": "...", "text": "...", "send_at": RFC 3339}
+func (s *Server) handleQueueOutbox(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ChatJID string    `json:"chat_jid"`
+		Text    string    `json:"text"`
+		SendAt  time.Time `json:"send_at"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if body.ChatJID == "" || strings.TrimSpace(body.Text) == "" || body.SendAt.IsZero() {
+		http.Error(w, "chat_jid, text and send_at are required", http.StatusBadRequest)
+		return
+	}
+	if s.requireApproval {
+		s.hold(w, store.PendingSend{ChatJID: body.ChatJID, Text: body.Text, SendAt: &body.SendAt})
+		return
+	}
+	m := store.OutboxMessage{ChatJID: body.ChatJID, Text: body.Text, SendAt: body.SendAt}
+	id, err := s.store.QueueOutbox(m)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]int64{"id": id})
+}
+
+func (s *Server) handleCancelOutbox(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid outbox ID", http.StatusBadRequest)
+		return
+	}
+	ok, err := s.store.CancelOutbox(id)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if !ok {
+		http.Error(w, "no pending outbox message with this ID", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Hold a message for approval, answering with its ID
+func (s *Server) hold(w http.ResponseWriter, p store.PendingSend) {
+	id, err := s.store.AddPendingSend(p)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]int64{"pending_id": id})
+}
+
+// Messages held for approval, oldest first; ?all=1 adds decided ones
+func (s *Server) handlePending(w http.ResponseWriter, r *http.Request) {
+	sends, err := s.store.ListPendingSends(r.URL.Query().Get("all") != "")
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if sends == nil {
+		sends = []store.PendingSend{}
+	}
+	writeJSON(w, http.StatusOK, sends)
+}
+
+// Send a held message, or queue it when it is meant for later
+func (s *Server) handleApprove(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid message ID", http.StatusBadRequest)
+		return
+	}
+	p, err := outbox.Approve(r.Context(), s.store, s.sender, id)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) handleReject(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid message ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := outbox.Reject(s.store, id); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		http.Error(w, "missing q parameter", http.StatusBadRequest)
+		return
+	}
+	limit := limitParam(r)
+	messages, err := s.store.SearchMessages(q, limit)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	// Cold messages are all older than hot ones, so only fill the remainder
+	if s.cold != nil && len(messages) < limit {
+		older, err := s.cold.Search(q, limit-len(messages))
+		if err != nil {
+			s.writeError(w, err)
+			return
+		}
+		messages = append(messages, older...)
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// Activity report over the last `days` days (default 365)
+// Push messages as they are stored, as server-sent "message" events, until
+// the client goes away. Repeated chat parameters select chats (with the
+// chats linked to them), media_type parameters media types ("text" for
+// none), and from_me=true or false the direction.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if s.hub == nil {
+		http.Error(w, "streaming needs the logger running (start --http)", http.StatusServiceUnavailable)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	q := r.URL.Query()
+	var chats map[string]bool
+	if len(q["chat"]) > 0 {
+		chats = map[string]bool{}
+		for _, jid := range q["chat"] {
+			group, err := s.store.ChatGroup(jid)
+			if err != nil {
+				s.writeError(w, err)
+				return
+			}
+			for _, linked := range group {
+				chats[linked] = true
+			}
+		}
+	}
+	var mediaTypes map[string]bool
+	if len(q["media_type"]) > 0 {
+		mediaTypes = map[string]bool{}
+		for _, t := range q["media_type"] {
+			if t == "text" {
+				t = ""
+			}
+			mediaTypes[t] = true
+		}
+	}
+	var fromMe *bool
+	if v := q.Get("from_me"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "from_me must be true or false", http.StatusBadRequest)
+			return
+		}
+		fromMe = &b
+	}
+
+	messages, cancel := s.hub.Subscribe()
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case m := <-messages:
+			if (chats != nil && !chats[m.ChatJID]) || (mediaTypes != nil && !mediaTypes[m.MediaType]) ||
+				(fromMe != nil && m.IsFromMe != *fromMe) {
+				continue
+			}
+			data, err := json.Marshal(m)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// Activity report over the last `days` days, optionally for one ?chat=,
+// ranking ?top= chats and senders
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	days, err := strconv.Atoi(q.Get("days"))
+	if err != nil || days <= 0 {
+		days = 365
+	}
+	top, _ := strconv.Atoi(q.Get("top"))
+	report, err := stats.Compute(s.store, stats.Options{
+		Since:      time.Now().AddDate(0, 0, -days),
+		ChatJID:    q.Get("chat"),
+		TopChats:   top,
+		TopSenders: top,
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Contacts and groups over the last `days` days, ranked by ?sort=volume,
+// recency or neglect; ?direct=true leaves groups out
+func (s *Server) handleContactStats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	days, err := strconv.Atoi(q.Get("days"))
+	if err != nil || days <= 0 {
+		days = 90
+	}
+	by := q.Get("sort")
+	if by != "" && by != stats.ByVolume && by != stats.ByRecency && by != stats.ByNeglect {
+		http.Error(w, "sort must be volume, recency or neglect", http.StatusBadRequest)
+		return
+	}
+	var direct bool
+	if v := q.Get("direct"); v != "" {
+		if direct, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "direct must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	minMessages, _ := strconv.Atoi(q.Get("min_messages"))
+	report, err := stats.Contacts(s.store, stats.ContactOptions{
+		Since:       time.Now().AddDate(0, 0, -days),
+		Sort:        by,
+		Limit:       limitParam(r),
+		DirectOnly:  direct,
+		MinMessages: minMessages,
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Chats where messages to me from the last `days` days (default 7) have
+// waited over ?hours= (default 24) for my answer
+func (s *Server) handleUnreplied(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	days, err := strconv.Atoi(q.Get("days"))
+	if err != nil || days <= 0 {
+		days = 7
+	}
+	hours, err := strconv.Atoi(q.Get("hours"))
+	if err != nil || hours < 0 {
+		hours = 24
+	}
+	report, err := digest.Unreplied(s.store, time.Now().AddDate(0, 0, -days), time.Duration(hours)*time.Hour)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Word and phrase report over the last `days` days, grouped by ?by=chat|sender
+func (s *Server) handleWords(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	days, err := strconv.Atoi(q.Get("days"))
+	if err != nil || days <= 0 {
+		days = 365
+	}
+	top, _ := strconv.Atoi(q.Get("top"))
+	by := q.Get("by")
+	if by != "" && by != stats.ByChat && by != stats.BySender {
+		http.Error(w, "by must be chat or sender", http.StatusBadRequest)
+		return
+	}
+	report, err := stats.Words(s.store, stats.WordOptions{
+		Since:   time.Now().AddDate(0, 0, -days),
+		By:      by,
+		ChatJID: q.Get("chat"),
+		Top:     top,
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Bookmarked messages, optionally narrowed with ?chat=
+func (s *Server) handleBookmarks(w http.ResponseWriter, r *http.Request) {
+	bookmarks, err := s.store.ListBookmarks(r.URL.Query().Get("chat"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if bookmarks == nil {
+		bookmarks = []store.Bookmark{}
+	}
+	writeJSON(w, http.StatusOK, bookmarks)
+}
+
+// Bookmark a message; the optional JSON body {"note": "..."} sets its note
+func (s *Server) handleSetBookmark(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+	key := store.MessageKey{ChatJID: r.PathValue("jid"), ID: r.PathValue("id")}
+	if err := s.store.SetBookmark(key, body.Note); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	key := store.MessageKey{ChatJID: r.PathValue("jid"), ID: r.PathValue("id")}
+	if err := s.store.RemoveBookmark(key); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// React to a message, body {"emoji": "..."}; DELETE takes my reaction back
+func (s *Server) handleReact(w http.ResponseWriter, r *http.Request) {
+	if s.sender == nil {
+		http.Error(w, "sending needs the logger running (start --http)", http.StatusServiceUnavailable)
+		return
+	}
+	var body struct {
+		Emoji string `json:"emoji"`
+	}
+	if r.Method == http.MethodPut {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil || body.Emoji == "" {
+			http.Error(w, "body must be {\"emoji\": \"...\"}", http.StatusBadRequest)
+			return
+		}
+	}
+	key := store.MessageKey{ChatJID: r.PathValue("jid"), ID: r.PathValue("id")}
+	if err := s.sender.SendReaction(r.Context(), key, body.Emoji); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Read the limit query parameter, clamped to a sane range
+func limitParam(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return defaultLimit
+	}
+	if limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
+
+// Map a store error onto an HTTP status
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, store.ErrChatNotFound), errors.Is(err, store.ErrMessageNotFound), errors.Is(err, outbox.ErrNotPending):
+		status = http.StatusNotFound
+	case errors.Is(err, store.ErrStoreClosed):
+		status = http.StatusServiceUnavailable
+	default:
+		s.log.Errorf("API request failed: %v", err)
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,87 @@
+"use strict";
+
+const chatList = document.getElementById("chats");
+const messagesEl = document.getElementById("messages");
+const titleEl = document.getElementById("title");
+let chatNames = {};
+
+async function getJSON(url) {
+  const res = await fetch(url);
+  const body = await res.json();
+  if (!res.ok) throw new Error(body.error || res.statusText);
+  return body || [];
+}
+
+function fmtTime(ts) {
+  const d = new Date(ts);
+  return isNaN(d) || d.getFullYear() < 2000 ? "" : d.toLocaleString();
+}
+
+function el(tag, cls, text) {
+  const e = document.createElement(tag);
+  if (cls) e.className = cls;
+  if (text !== undefined) e.textContent = text;
+  return e;
+}
+
+async function loadChats() {
+  const chats = await getJSON("api/chats");
+  chatList.replaceChildren();
+  for (const c of chats) {
+    chatNames[c.jid] = c.name || c.jid;
+    const li = el("li");
+    li.dataset.jid = c.jid;
+    li.append(el("div", "name", c.name || c.jid), el("div", "when", fmtTime(c.last_message_time)));
+    li.onclick = () => openChat(c.jid);
+    chatList.append(li);
+  }
+}
+
+function renderMessages(messages, withChat) {
+  messagesEl.replaceChildren();
+  if (messages.length === 0) {
+    messagesEl.append(el("div", "empty", "No messages"));
+    return;
+  }
+  for (const m of messages) {
+    const b = el("div", m.is_from_me ? "bubble me" : "bubble");
+    if (withChat) {
+      const c = el("div", "chat", chatNames[m.chat_jid] || m.chat_jid);
+      c.onclick = () => openChat(m.chat_jid);
+      b.append(c);
+    }
+    if (!m.is_from_me) b.append(el("div", "sender", m.sender_name || m.sender));
+    if (m.media_type) b.append(el("span", "media", m.media_type + (m.filename ? ": " + m.filename : "")));
+    b.append(el("div", "text", m.content));
+    b.append(el("div", "meta", fmtTime(m.timestamp)));
+    messagesEl.append(b);
+  }
+}
+
+async function openChat(jid) {
+  for (const li of chatList.children) li.classList.toggle("active", li.dataset.jid === jid);
+  titleEl.textContent = chatNames[jid] || jid;
+  try {
+    const messages = await getJSON("api/chats/" + encodeURIComponent(jid) + "/messages?limit=200");
+    // API returns newest first; show oldest at the top like a chat
+    renderMessages(messages.reverse(), false);
+    messagesEl.scrollTop = messagesEl.scrollHeight;
+  } catch (err) {
+    messagesEl.replaceChildren(el("div", "empty", err.message));
+  }
+}
+
+document.getElementById("search").onsubmit = async (e) => {
+  e.preventDefault();
+  const q = document.getElementById("q").value.trim();
+  if (!q) return;
+  titleEl.textContent = "Search: " + q;
+  for (const li of chatList.children) li.classList.remove("active");
+  try {
+    renderMessages(await getJSON("api/search?limit=200&q=" + encodeURIComponent(q)), true);
+  } catch (err) {
+    messagesEl.replaceChildren(el("div", "empty", err.message));
+  }
+};
+
+loadChats().catch((err) => chatList.replaceChildren(el("li", "", err.message)));
@@ -0,0 +1,89 @@
+"use strict";
+
+const SVG = "http://www.w3.org/2000/svg";
+
+function svgEl(tag, attrs) {
+  const e = document.createElementNS(SVG, tag);
+  for (const [k, v] of Object.entries(attrs)) e.setAttribute(k, v);
+  return e;
+}
+
+// Draw vertical bars for values into an SVG, with an optional label row
+function bars(svg, values, labels, titles) {
+  svg.replaceChildren();
+  const width = Math.max(values.length * 10, 300);
+  const height = 160;
+  const labelSpace = labels ? 18 : 0;
+  svg.setAttribute("viewBox", `0 0 ${width} ${height + labelSpace}`);
+  const max = Math.max(1, ...values);
+  const w = width / Math.max(values.length, 1);
+  values.forEach((v, i) => {
+    const h = (v / max) * height;
+    const rect = svgEl("rect", { x: i * w + w * 0.1, y: height - h, width: w * 0.8, height: h, class: "bar" });
+    const t = svgEl("title", {});
+    t.textContent = titles ? titles[i] : String(v);
+    rect.append(t);
+    svg.append(rect);
+    if (labels) {
+      const text = svgEl("text", { x: i * w + w / 2, y: height + 13, "text-anchor": "middle", class: "label" });
+      text.textContent = labels[i];
+      svg.append(text);
+    }
+  });
+}
+
+function fmtDuration(seconds) {
+  if (seconds < 60) return Math.round(seconds) + "s";
+  if (seconds < 3600) return Math.round(seconds / 60) + "m";
+  return (seconds / 3600).toFixed(1) + "h";
+}
+
+function row(table, cells) {
+  const tr = document.createElement("tr");
+  for (const c of cells) {
+    const td = document.createElement("td");
+    td.textContent = c;
+    tr.append(td);
+  }
+  table.append(tr);
+}
+
+async function load() {
+  const days = document.getElementById("days").value;
+  const res = await fetch("api/stats?days=" + days);
+  const r = await res.json();
+  if (!res.ok) throw new Error(r.error || res.statusText);
+
+  const totals = document.getElementById("totals");
+  totals.replaceChildren();
+  for (const [label, value] of [["Messages", r.total], ["Sent", r.sent], ["Received", r.received]]) {
+    const card = document.createElement("div");
+    card.className = "card";
+    card.innerHTML = "<div class=value></div><div class=label></div>";
+    card.querySelector(".value").textContent = value.toLocaleString();
+    card.querySelector(".label").textContent = label;
+    totals.append(card);
+  }
+
+  bars(document.getElementById("volume"),
+    r.daily.map((d) => d.sent + d.received), null,
+    r.daily.map((d) => `${d.day}: ${d.received} received, ${d.sent} sent`));
+
+  const top = document.getElementById("top");
+  top.replaceChildren();
+  row(top, ["Chat", "Messages", "Sent"]);
+  for (const c of r.top_chats) row(top, [c.name || c.jid, c.count, c.sent]);
+
+  bars(document.getElementById("responses"),
+    r.response_times.map((b) => b.count), r.response_times.map((b) => b.label), null);
+  document.getElementById("median").textContent =
+    r.median_response_seconds ? "Median reply: " + fmtDuration(r.median_response_seconds) : "No replies in this window";
+
+  const media = document.getElementById("media");
+  media.replaceChildren();
+  row(media, ["Type", "Messages"]);
+  for (const [type, count] of Object.entries(r.media).sort((a, b) => b[1] - a[1])) row(media, [type, count]);
+}
+
+document.getElementById("days").onchange = () => load().catch(alert);
+load().catch((err) => (document.getElementById("report").textContent = err.message));
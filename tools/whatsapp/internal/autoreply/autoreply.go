@@ -0,0 +1,154 @@
+// Package autoreply answers live messages with the canned replies of
+// config-defined rules.
+package autoreply
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/quiet"
+	"whatsapp-logger/internal/store"
+)
+
+// How long a reply may take to send
+const sendTimeout = 30 * time.Second
+
+// Messages older than this on arrival were missed while offline and
+// delivered on reconnecting; their conversations have moved on
+const maxAge = 2 * time.Minute
+
+// Sender delivers replies; *wa.Logger implements it
+type Sender interface {
+	SendText(ctx context.Context, chatJID, text string) error
+}
+
+// A configured rule with its windows parsed
+type rule struct {
+	config.AutoReply
+	// When the rule applies; nil means always
+	hours    *quiet.Hours
+	cooldown time.Duration
+}
+
+// Responder replies to matching messages, at most once per rule and chat
+// within the rule's cooldown
+type Responder struct {
+	rules []rule
+	send  Sender
+	log   waLog.Logger
+	now   func() time.Time
+	// Messages sent before the responder started are never answered
+	started time.Time
+
+	mu sync.Mutex
+	// Last reply per rule name and chat
+	last map[string]time.Time
+}
+
+// Create a responder for the configured rules, reading their windows in
+// loc and replying through send
+func New(cfg config.AutoReplies, loc *time.Location, send Sender, log waLog.Logger) (*Responder, error) {
+	r := &Responder{send: send, log: log, now: time.Now, started: time.Now(), last: map[string]time.Time{}}
+	for i, c := range cfg.Rules {
+		if strings.TrimSpace(c.Reply) == "" {
+			return nil, fmt.Errorf("auto-reply rule %d: reply is empty", i+1)
+		}
+		hours, err := quiet.New(config.QuietHours{Windows: c.Windows}, loc)
+		if err != nil {
+			return nil, fmt.Errorf("auto-reply rule %d: %w", i+1, err)
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		r.rules = append(r.rules, rule{AutoReply: c, hours: hours, cooldown: time.Duration(c.CooldownMinutes) * time.Minute})
+	}
+	return r, nil
+}
+
+// Message hook: send the reply of the first rule msg matches
+func (r *Responder) HandleMessage(msg store.Message) {
+	ru, ok := r.Match(msg)
+	if !ok {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := r.send.SendText(ctx, msg.ChatJID, ru.Reply); err != nil {
+			r.log.Warnf("Failed to send auto-reply %q to %s: %v", ru.Name, msg.ChatJID, err)
+			return
+		}
+		r.log.Infof("Sent auto-reply %q to %s", ru.Name, msg.ChatJID)
+	}()
+}
+
+// The first rule that replies to msg now, claiming its cooldown for the
+// chat. Only messages from others in direct chats are answered, and group
+// chats only by rules that list them. Messages sent before the responder
+// started, or more than maxAge ago, are history catching up and get no
+// reply.
+func (r *Responder) Match(msg store.Message) (config.AutoReply, bool) {
+	if msg.IsFromMe || msg.System != nil || !answerable(msg.ChatJID) {
+		return config.AutoReply{}, false
+	}
+	now := r.now()
+	if msg.Timestamp.Before(r.started) || now.Sub(msg.Timestamp) > maxAge {
+		return config.AutoReply{}, false
+	}
+	for _, ru := range r.rules {
+		if isGroup(msg.ChatJID) && len(ru.Chats) == 0 {
+			continue
+		}
+		if !ru.Match(msg) {
+			continue
+		}
+		if ru.hours != nil {
+			if active, _ := ru.hours.Active(now); !active {
+				continue
+			}
+		}
+		if !r.claim(ru, msg.ChatJID, now) {
+			// Replied to this chat recently; later rules stay quiet too
+			return config.AutoReply{}, false
+		}
+		return ru.AutoReply, true
+	}
+	return config.AutoReply{}, false
+}
+
+// Record a reply by ru to chat at now, unless one was sent within the
+// cooldown
+func (r *Responder) claim(ru rule, chat string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := ru.Name + "\x00" + chat
+	if last, ok := r.last[key]; ok && now.Sub(last) < ru.cooldown {
+		return false
+	}
+	r.last[key] = now
+	return true
+}
+
+// Whether chat is a person or group rather than a channel or broadcast
+func answerable(chat string) bool {
+	jid, err := types.ParseJID(chat)
+	if err != nil {
+		return false
+	}
+	switch jid.Server {
+	case types.DefaultUserServer, types.HiddenUserServer, types.GroupServer:
+		return true
+	}
+	return false
+}
+
+func isGroup(chat string) bool {
+	return strings.HasSuffix(chat, "@"+types.GroupServer)
+}
@@ -0,0 +1,41 @@
+package autoreply
+
+import (
+	"testing"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/store"
+)
+
+func TestMatchSkipsOldMessages(t *testing.T) {
+	cfg := config.AutoReplies{Rules: []config.AutoReply{{Name: "away", Reply: "Back soon"}}}
+	started := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	now := started.Add(time.Hour)
+	for _, tc := range []struct {
+		name string
+		sent time.Time
+		want bool
+	}{
+		{"live", now.Add(-10 * time.Second), true},
+		{"sent before starting", started.Add(-time.Minute), false},
+		{"delivered late after a reconnect", now.Add(-30 * time.Minute), false},
+		{"no timestamp", time.Time{}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := New(cfg, time.UTC, nil, waLog.Noop)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.started = started
+			r.now = func() time.Time { return now }
+			msg := store.Message{ID: "MSG1", ChatJID: "447700900123@s.whatsapp.net", Sender: "447700900123@s.whatsapp.net",
+				Content: "Are you there?", Timestamp: tc.sent}
+			if _, ok := r.Match(msg); ok != tc.want {
+				t.Errorf("Match = %v, want %v", ok, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+// Package backup ships encrypted snapshots of the archive to remote storage,
+// or writes them to local files, and restores them.
+//
+// A snapshot is a gzip-compressed tar holding manifest.json, the messages
+// database and the media directory, encrypted as described in crypt.go.
+// Local files are the same tar, unencrypted and optionally uncompressed.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/store"
+)
+
+const (
+	namePrefix = "kenny-whatsapp-"
+	nameSuffix = ".tar.gz.enc"
+	// Name of the database inside a snapshot
+	dbEntry = "whatsapp_messages.db"
+)
+
+// Snapshotter is a store that can write a consistent copy of itself
+type Snapshotter interface {
+	store.Store
+	Snapshot(path string) error
+}
+
+// Manifest records what a snapshot holds, for verification
+type Manifest struct {
+	CreatedAt time.Time `json:"created_at"`
+	Messages  int       `json:"messages"`
+	Chats     int       `json:"chats"`
+	// SHA-256 of every media file, keyed by slash-separated relative path
+	Media map[string]string `json:"media"`
+	// Set when the snapshot lists media files without holding them
+	MediaOmitted bool `json:"media_omitted,omitempty"`
+}
+
+// Backer takes, prunes and verifies snapshots on one target
+type Backer struct {
+	target     Target
+	passphrase string
+	keep       int
+	mediaDir   string
+	dbKey      string
+	log        waLog.Logger
+}
+
+// Create a Backer from the backup configuration. mediaDir is included in
+// snapshots when it exists; dbKey is the passphrase of an encrypted
+// archive, needed to verify its snapshots.
+func New(cfg config.Backup, mediaDir, dbKey string, log waLog.Logger) (*Backer, error) {
+	if cfg.Passphrase == "" {
+		return nil, errors.New("backup passphrase not set: set backup.passphrase or KENNY_WA_BACKUP_PASSPHRASE")
+	}
+	target, err := TargetFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Backer{target: target, passphrase: cfg.Passphrase, keep: cfg.Keep, mediaDir: mediaDir, dbKey: dbKey, log: log}, nil
+}
+
+// Take a snapshot of st, upload it, verify the uploaded copy restores, and
+// prune old snapshots. Returns the new snapshot's name.
+func (b *Backer) Run(ctx context.Context, st Snapshotter) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "kenny-backup-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbCopy := filepath.Join(tmpDir, dbEntry)
+	if err := st.Snapshot(dbCopy); err != nil {
+		return "", fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	m := &Manifest{CreatedAt: time.Now().UTC(), Media: map[string]string{}}
+	if err := m.count(dbCopy); err != nil {
+		return "", err
+	}
+
+	name := namePrefix + m.CreatedAt.Format("20060102T150405Z") + nameSuffix
+	encPath := filepath.Join(tmpDir, name)
+	if err := b.pack(encPath, dbCopy, m); err != nil {
+		return "", fmt.Errorf("failed to build snapshot: %w", err)
+	}
+
+	f, err := os.Open(encPath)
+	if err != nil {
+		return "", err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	err = b.target.Put(ctx, name, f, info.Size())
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to upload snapshot to %s: %w", b.target, err)
+	}
+	b.log.Infof("Uploaded snapshot %s (%d bytes, %d messages) to %s", name, info.Size(), m.Messages, b.target)
+
+	if _, err := b.Verify(ctx, name); err != nil {
+		return name, fmt.Errorf("uploaded snapshot %s failed verification: %w", name, err)
+	}
+	if err := b.prune(ctx); err != nil {
+		return name, fmt.Errorf("failed to prune old snapshots: %w", err)
+	}
+	return name, nil
+}
+
+// Count the messages and chats in a database copy. The copy is counted
+// rather than the store, which may have taken writes since.
+func (m *Manifest) count(dbCopy string) error {
+	db, err := sql.Open("sqlite3", "file:"+dbCopy+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := db.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&m.Messages); err != nil {
+		return fmt.Errorf("failed to count snapshot messages: %w", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM chats`).Scan(&m.Chats); err != nil {
+		return fmt.Errorf("failed to count snapshot chats: %w", err)
+	}
+	return nil
+}
+
+// Write the encrypted tarball
+func (b *Backer) pack(encPath, dbCopy string, m *Manifest) error {
+	out, err := os.Create(encPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	enc, err := newEncryptWriter(out, b.passphrase)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(enc)
+	tw := tar.NewWriter(zw)
+	err = writeEntries(tw, dbCopy, b.mediaDir, true, m)
+	for _, c := range []io.Closer{tw, zw, enc} {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// Add the database copy, the media directory and the manifest to a
+// snapshot. Media files are hashed into the manifest either way, and only
+// added when withMedia is set.
+func writeEntries(tw *tar.Writer, dbCopy, mediaDir string, withMedia bool, m *Manifest) error {
+	// Hash media while adding it, then write the manifest last; restores
+	// read the whole archive before checking it anyway
+	if err := addFile(tw, dbEntry, dbCopy, nil); err != nil {
+		return err
+	}
+	m.MediaOmitted = !withMedia
+	if mediaDir != "" {
+		err := filepath.WalkDir(mediaDir, func(p string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && p == mediaDir {
+				return fs.SkipDir
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(mediaDir, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			h := sha256.New()
+			if withMedia {
+				err = addFile(tw, path.Join("media", rel), p, h)
+			} else {
+				err = hashFile(h, p)
+			}
+			if err != nil {
+				return err
+			}
+			m.Media[rel] = hex.EncodeToString(h.Sum(nil))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add media: %w", err)
+		}
+	}
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0644, Size: int64(len(manifest)), ModTime: m.CreatedAt}); err != nil {
+		return err
+	}
+	_, err = tw.Write(manifest)
+	return err
+}
+
+// Add a file to the tarball, also feeding it to h when given
+func addFile(tw *tar.Writer, name, src string, h io.Writer) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+		return err
+	}
+	var r io.Reader = f
+	if h != nil {
+		r = io.TeeReader(f, h)
+	}
+	_, err = io.CopyN(tw, r, info.Size())
+	return err
+}
+
+func hashFile(h io.Writer, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+// Snapshot names on the target, oldest first
+func (b *Backer) List(ctx context.Context) ([]string, error) {
+	names, err := b.target.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []string
+	for _, n := range names {
+		if strings.HasPrefix(n, namePrefix) && strings.HasSuffix(n, nameSuffix) {
+			snapshots = append(snapshots, n)
+		}
+	}
+	// Names embed a sortable UTC timestamp
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// Delete all but the newest keep snapshots
+func (b *Backer) prune(ctx context.Context) error {
+	if b.keep <= 0 {
+		return nil
+	}
+	snapshots, err := b.List(ctx)
+	if err != nil {
+		return err
+	}
+	for len(snapshots) > b.keep {
+		if err := b.target.Delete(ctx, snapshots[0]); err != nil {
+			return err
+		}
+		b.log.Infof("Pruned snapshot %s", snapshots[0])
+		snapshots = snapshots[1:]
+	}
+	return nil
+}
+
+// Download a snapshot, restore it to a scratch directory and check it
+// against its manifest
+func (b *Backer) Verify(ctx context.Context, name string) (*Manifest, error) {
+	tmpDir, err := os.MkdirTemp("", "kenny-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	return b.Restore(ctx, name, tmpDir)
+}
+
+// Download a snapshot and unpack it into dir, which must not already hold a
+// messages database. The restored database and media are checked against the
+// manifest before returning.
+func (b *Backer) Restore(ctx context.Context, name, dir string) (*Manifest, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	dbPath := filepath.Join(dir, dbEntry)
+	if _, err := os.Stat(dbPath); err == nil {
+		return nil, fmt.Errorf("refusing to overwrite %s", dbPath)
+	}
+
+	rc, err := b.target.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	m, err := b.unpack(rc, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := check(m, dir, b.dbKey); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Decrypt and extract a snapshot stream into dir
+func (b *Backer) unpack(r io.Reader, dir string) (*Manifest, error) {
+	dec, err := newDecryptReader(r, b.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(dec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	m, err := extract(tar.NewReader(zr), dir)
+	if err != nil {
+		return nil, err
+	}
+	// Drain to the end so decryption authenticates the final chunk
+	if _, err := io.Copy(io.Discard, dec); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Extract a snapshot's entries into dir, returning its manifest
+func extract(tr *tar.Reader, dir string) (*Manifest, error) {
+	var m *Manifest
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if hdr.Name == "manifest.json" {
+			m = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return nil, fmt.Errorf("failed to read manifest: %w", err)
+			}
+			continue
+		}
+		// Reject entries that would escape dir
+		clean := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("unexpected snapshot entry %q", hdr.Name)
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(clean))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.Create(dst)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", hdr.Name, err)
+		}
+	}
+	if m == nil {
+		return nil, errors.New("snapshot has no manifest")
+	}
+	return m, nil
+}
+
+// Check restored files against the manifest. key unlocks an encrypted
+// archive.
+func check(m *Manifest, dir, key string) error {
+	st, err := store.OpenSQLite(filepath.Join(dir, dbEntry), store.Options{Key: key})
+	if err != nil {
+		return fmt.Errorf("restored database does not open: %w", err)
+	}
+	defer st.Close()
+	if err := st.IntegrityCheck(); err != nil {
+		return fmt.Errorf("restored database is corrupt: %w", err)
+	}
+	messages, err := st.MessageCount()
+	if err != nil {
+		return err
+	}
+	chats, err := st.ChatCount()
+	if err != nil {
+		return err
+	}
+	if messages != m.Messages || chats != m.Chats {
+		return fmt.Errorf("restored database has %d messages and %d chats, manifest says %d and %d",
+			messages, chats, m.Messages, m.Chats)
+	}
+
+	if m.MediaOmitted {
+		return nil
+	}
+	for rel, want := range m.Media {
+		f, err := os.Open(filepath.Join(dir, "media", filepath.FromSlash(rel)))
+		if err != nil {
+			return fmt.Errorf("restored media missing: %w", err)
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != want {
+			return fmt.Errorf("restored media %s does not match its checksum", rel)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted snapshots are a header followed by AES-256-GCM sealed chunks:
+//
+//	"KWB1" | salt (16) | nonce prefix (7) | chunk...
+//
+// Each chunk seals up to chunkSize bytes under the nonce
+// prefix | big-endian chunk counter (4) | final flag (1), so chunks cannot be
+// reordered, dropped or truncated without failing authentication.
+const (
+	magic       = "KWB1"
+	saltSize    = 16
+	prefixSize  = 7
+	chunkSize   = 64 * 1024
+	headerSize  = len(magic) + saltSize + prefixSize
+	scryptN     = 1 << 15
+	scryptR     = 8
+	scryptP     = 1
+	keySize     = 32
+	sealedChunk = chunkSize + 16
+)
+
+// ErrBadPassphrase is returned when a snapshot fails to decrypt
+var ErrBadPassphrase = errors.New("wrong passphrase or corrupted snapshot")
+
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], counter)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// Encrypting writer; Close must be called to write the final chunk
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+// Wrap w so everything written is encrypted under passphrase
+func newEncryptWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return nil, err
+	}
+	aead, err := deriveKey(passphrase, header[len(magic):len(magic)+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		prefix: header[len(magic)+saltSize:],
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// Only seal a full buffer once more data arrives, so the last chunk
+		// is always the one sealed as final by Close
+		if len(e.buf) == chunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) seal(final bool) error {
+	if e.counter == ^uint32(0) {
+		return errors.New("snapshot too large")
+	}
+	sealed := e.aead.Seal(nil, chunkNonce(e.prefix, e.counter, final), e.buf, nil)
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+// Decrypting reader over a snapshot written by encryptWriter
+type decryptReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   []byte
+	done    bool
+}
+
+// Wrap r, reading and checking the header
+func newDecryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errors.New("not an encrypted kenny-whatsapp snapshot")
+	}
+	aead, err := deriveKey(passphrase, header[len(magic):len(magic)+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: r, aead: aead, prefix: header[len(magic)+saltSize:]}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// Read and open the next chunk
+func (d *decryptReader) next() error {
+	sealed := make([]byte, sealedChunk)
+	n, err := io.ReadFull(d.r, sealed)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("snapshot truncated: %w", io.ErrUnexpectedEOF)
+		}
+		return err
+	}
+	sealed = sealed[:n]
+
+	// A short chunk must be the final one; a full one may be either
+	if n == sealedChunk {
+		if plain, err := d.aead.Open(nil, chunkNonce(d.prefix, d.counter, false), sealed, nil); err == nil {
+			d.plain = plain
+			d.counter++
+			return nil
+		}
+	}
+	plain, err := d.aead.Open(nil, chunkNonce(d.prefix, d.counter, true), sealed, nil)
+	if err != nil {
+		return ErrBadPassphrase
+	}
+	if n, _ := d.r.Read(make([]byte, 1)); n > 0 {
+		return errors.New("unexpected data after final snapshot chunk")
+	}
+	d.plain = plain
+	d.done = true
+	return nil
+}
@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileOptions shape a snapshot written to a local file
+type FileOptions struct {
+	// Gzip the tar
+	Gzip bool
+	// Hold media files, not only list them in the manifest
+	WithMedia bool
+}
+
+// Write a snapshot of st and mediaDir to path, a plain tar unlike the
+// encrypted snapshots shipped to targets. st keeps taking writes meanwhile.
+// path must not exist.
+func WriteFile(st Snapshotter, mediaDir, path string, opts FileOptions) (*Manifest, error) {
+	tmpDir, err := os.MkdirTemp("", "kenny-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbCopy := filepath.Join(tmpDir, dbEntry)
+	if err := st.Snapshot(dbCopy); err != nil {
+		return nil, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	m := &Manifest{CreatedAt: time.Now().UTC(), Media: map[string]string{}}
+	if err := m.count(dbCopy); err != nil {
+		return nil, err
+	}
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(out, dbCopy, mediaDir, opts, m); err != nil {
+		out.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return m, out.Close()
+}
+
+func writeFile(out *os.File, dbCopy, mediaDir string, opts FileOptions, m *Manifest) error {
+	var w io.Writer = out
+	var zw *gzip.Writer
+	if opts.Gzip {
+		zw = gzip.NewWriter(out)
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	err := writeEntries(tw, dbCopy, mediaDir, opts.WithMedia, m)
+	if cerr := tw.Close(); err == nil {
+		err = cerr
+	}
+	if zw != nil {
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// Unpack a snapshot written by WriteFile, gzipped or not, into dir, which
+// must not already hold a messages database, and check it against its
+// manifest. key unlocks an encrypted archive.
+func RestoreFile(path, dir, key string) (*Manifest, error) {
+	dbPath := filepath.Join(dir, dbEntry)
+	if _, err := os.Stat(dbPath); err == nil {
+		return nil, fmt.Errorf("refusing to overwrite %s", dbPath)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	m, err := extract(tar.NewReader(r), dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := check(m, dir, key); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
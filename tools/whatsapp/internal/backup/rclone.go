@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Any remote rclone is configured for, e.g. "gdrive:kenny-backups"
+type rcloneTarget struct {
+	remote string
+}
+
+func (t *rcloneTarget) String() string { return "rclone " + t.remote }
+
+func (t *rcloneTarget) path(name string) string {
+	return t.remote + "/" + name
+}
+
+func (t *rcloneTarget) Put(ctx context.Context, name string, r io.ReadSeeker, size int64) error {
+	cmd := exec.CommandContext(ctx, "rclone", "rcat", "--size", fmt.Sprint(size), t.path(name))
+	cmd.Stdin = r
+	return run(cmd)
+}
+
+func (t *rcloneTarget) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "rclone", "cat", t.path(name))
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run rclone: %w", err)
+	}
+	return &cmdReader{ReadCloser: out, cmd: cmd, stderr: &stderr}, nil
+}
+
+func (t *rcloneTarget) List(ctx context.Context) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "rclone", "lsf", "--files-only", t.remote)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := run(cmd); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
+
+func (t *rcloneTarget) Delete(ctx context.Context, name string) error {
+	return run(exec.CommandContext(ctx, "rclone", "deletefile", t.path(name)))
+}
+
+// Run a command, folding its stderr into the error
+func run(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", strings.Join(cmd.Args[:2], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Stdout of a running command; Close reports how the command exited
+type cmdReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (c *cmdReader) Close() error {
+	c.ReadCloser.Close()
+	if err := c.cmd.Wait(); err != nil {
+		return fmt.Errorf("rclone cat failed: %v: %s", err, strings.TrimSpace(c.stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package backup
+
+import (
+	"context"
+	"io"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/s3"
+)
+
+// Snapshots stored as objects in an S3 or S3-compatible bucket
+type s3Target struct {
+	*s3.Client
+}
+
+func newS3(cfg config.S3Target) *s3Target {
+	return &s3Target{s3.New(cfg)}
+}
+
+func (t *s3Target) Put(ctx context.Context, name string, r io.ReadSeeker, size int64) error {
+	return t.Client.Put(ctx, name, r, size, "")
+}
@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"whatsapp-logger/internal/config"
+)
+
+// Target is remote storage for snapshot files
+type Target interface {
+	// Short description for logs
+	String() string
+	// Upload a snapshot of the given size
+	Put(ctx context.Context, name string, r io.ReadSeeker, size int64) error
+	// Download a snapshot
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// Names of every stored object, in any order
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Build the target configured in cfg
+func TargetFromConfig(cfg config.Backup) (Target, error) {
+	configured := 0
+	var t Target
+	if cfg.S3 != nil {
+		configured++
+		t = newS3(*cfg.S3)
+	}
+	if cfg.Rclone != nil {
+		configured++
+		t = &rcloneTarget{remote: strings.TrimSuffix(cfg.Rclone.Remote, "/")}
+	}
+	if cfg.Dir != "" {
+		configured++
+		t = dirTarget(cfg.Dir)
+	}
+	switch configured {
+	case 0:
+		return nil, errors.New("no backup target configured: set backup.s3, backup.rclone or backup.dir")
+	case 1:
+		return t, nil
+	default:
+		return nil, errors.New("more than one backup target configured")
+	}
+}
+
+// Local or mounted directory, e.g. a NAS share
+type dirTarget string
+
+func (d dirTarget) String() string { return string(d) }
+
+func (d dirTarget) Put(ctx context.Context, name string, r io.ReadSeeker, size int64) error {
+	if err := os.MkdirAll(string(d), 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(string(d), name)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path + ".tmp")
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (d dirTarget) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), name))
+}
+
+func (d dirTarget) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (d dirTarget) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(string(d), name))
+}
+
+// Check an object name stays inside its target
+func validName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return nil
+}
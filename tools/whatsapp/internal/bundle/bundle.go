@@ -0,0 +1,510 @@
+// Package bundle writes and reads portable single-file copies of the archive.
+//
+// A bundle is a zip file:
+//
+//	manifest.json     format marker, counts and media index
+//	chats.jsonl       one store.Chat per line
+//	contacts.jsonl    one store.Contact per line
+//	messages.jsonl    one store.Message per line, with its attachment's hash
+//	revisions.jsonl   earlier contents of edited messages
+//	media/<sha256>    referenced media, content-addressed
+package bundle
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/store"
+)
+
+// Format identifies bundle files in their manifest
+const (
+	Format = "kenny-whatsapp-bundle"
+	// Version 2 added contacts, revisions and media; a version 1 bundle
+	// reads as one without them
+	Version = 2
+)
+
+// ErrNotBundle is returned when a zip file lacks a bundle manifest
+var ErrNotBundle = errors.New("not a kenny-whatsapp bundle")
+
+// Manifest describes a bundle's contents
+type Manifest struct {
+	Format    string      `json:"format"`
+	Version   int         `json:"version"`
+	CreatedAt time.Time   `json:"created_at"`
+	Chats     int         `json:"chats"`
+	Contacts  int         `json:"contacts"`
+	Messages  int         `json:"messages"`
+	Media     []MediaFile `json:"media"`
+}
+
+// A media blob stored in the bundle as media/<SHA256>. Only attachments the
+// archive holds a file for are bundled.
+type MediaFile struct {
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type,omitempty"`
+	// Original filename, for display
+	Name string `json:"name,omitempty"`
+}
+
+// One line of messages.jsonl
+type message struct {
+	store.Message
+	// Hex SHA-256 of the attachment under media/, when bundled
+	MediaSHA256 string `json:"media_sha256,omitempty"`
+}
+
+// One line of revisions.jsonl
+type revisions struct {
+	store.MessageKey
+	// Oldest first
+	Revisions []store.Revision `json:"revisions"`
+}
+
+// Options narrow what is bundled
+type Options struct {
+	// Only this chat when set
+	ChatJID string
+	Since   time.Time
+	Until   time.Time
+	// Zone timestamps are written in; UTC when nil
+	Location *time.Location
+	// Where attachment files are read from; nil leaves media out
+	Storage media.Storage
+}
+
+// Write a bundle of the store's contents to w
+func Write(ctx context.Context, st store.Store, w io.Writer, opts Options) (*Manifest, error) {
+	zw := zip.NewWriter(w)
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	m := &Manifest{Format: Format, Version: Version, CreatedAt: time.Now().UTC(), Media: []MediaFile{}}
+
+	chats, err := st.ListChats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chats: %w", err)
+	}
+	// A chat filter covers the chats linked to it too
+	var only map[string]bool
+	if opts.ChatJID != "" {
+		group, err := st.ChatGroup(opts.ChatJID)
+		if err != nil {
+			return nil, err
+		}
+		only = map[string]bool{}
+		for _, jid := range group {
+			only[jid] = true
+		}
+	}
+	cw, err := create(zw, "chats.jsonl", m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(cw)
+	for _, c := range chats {
+		if only != nil && !only[c.JID] {
+			continue
+		}
+		c.LastMessageTime = c.LastMessageTime.In(loc)
+		if err := enc.Encode(c); err != nil {
+			return nil, err
+		}
+		m.Chats++
+	}
+
+	// Attachment files by the location messages record them at
+	blobs := map[string]store.MediaBlob{}
+	if opts.Storage != nil {
+		stored, err := st.MediaBlobs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list media: %w", err)
+		}
+		for _, b := range stored {
+			blobs[b.Location] = b
+		}
+	}
+	var (
+		bundled = map[string]bool{}
+		pending []store.MediaBlob
+		names   = map[string]string{}
+		edited  []store.MessageKey
+		// JIDs whose contact entries travel with a single chat
+		people = map[string]bool{}
+	)
+	mw, err := create(zw, "messages.jsonl", m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	enc = json.NewEncoder(mw)
+	err = st.ForEachMessage(opts.Since, opts.Until, func(msg store.Message) error {
+		if only != nil && !only[msg.ChatJID] {
+			return nil
+		}
+		m.Messages++
+		people[msg.ChatJID], people[msg.Sender] = true, true
+		if msg.EditedAt != nil {
+			edited = append(edited, store.MessageKey{ID: msg.ID, ChatJID: msg.ChatJID})
+		}
+		line := message{Message: msg}
+		location := msg.LocalPath
+		if location == "" {
+			location = msg.ObjectURL
+		}
+		if b, ok := blobs[location]; ok && location != "" {
+			line.MediaSHA256 = b.SHA256
+			if !bundled[b.SHA256] {
+				bundled[b.SHA256] = true
+				pending = append(pending, b)
+				names[b.SHA256] = msg.Filename
+			}
+		}
+		line.Timestamp = msg.Timestamp.In(loc)
+		return enc.Encode(line)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export messages: %w", err)
+	}
+
+	rw, err := create(zw, "revisions.jsonl", m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	enc = json.NewEncoder(rw)
+	for _, key := range edited {
+		revs, err := st.Revisions(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export revisions: %w", err)
+		}
+		if len(revs) == 0 {
+			continue
+		}
+		if err := enc.Encode(revisions{MessageKey: key, Revisions: revs}); err != nil {
+			return nil, err
+		}
+	}
+
+	contacts, err := st.ListContacts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contacts: %w", err)
+	}
+	kw, err := create(zw, "contacts.jsonl", m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	enc = json.NewEncoder(kw)
+	for _, c := range contacts {
+		if only != nil && !people[c.JID] {
+			continue
+		}
+		if err := enc.Encode(c); err != nil {
+			return nil, err
+		}
+		m.Contacts++
+	}
+
+	for _, b := range pending {
+		f, ok, err := writeMedia(ctx, zw, opts.Storage, b, m.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to bundle media %s: %w", b.SHA256, err)
+		}
+		if ok {
+			f.Name = names[b.SHA256]
+			m.Media = append(m.Media, f)
+		}
+	}
+
+	if err := writeManifest(zw, m); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Copy an attachment file into media/; ok is false when the file is gone
+func writeMedia(ctx context.Context, zw *zip.Writer, storage media.Storage, b store.MediaBlob, modified time.Time) (f MediaFile, ok bool, err error) {
+	r, err := storage.Open(ctx, b.Location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, false, nil
+	}
+	if err != nil {
+		return f, false, err
+	}
+	defer r.Close()
+	// Attachments are compressed already
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "media/" + b.SHA256, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return f, false, err
+	}
+	size, err := io.Copy(w, r)
+	if err != nil {
+		return f, false, err
+	}
+	return MediaFile{SHA256: b.SHA256, Size: size, MimeType: b.MimeType}, true, nil
+}
+
+// Add a deflated entry stamped with the bundle's creation time
+func create(zw *zip.Writer, name string, modified time.Time) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+}
+
+func writeManifest(zw *zip.Writer, m *Manifest) error {
+	fw, err := create(zw, "manifest.json", m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// Read a bundle's manifest and load its chats, contacts and messages into
+// st, saving its media to storage; a nil storage leaves media out
+func Read(ctx context.Context, st store.Store, path string, storage media.Storage) (*Manifest, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer zr.Close()
+
+	var m Manifest
+	if err := decodeFile(&zr.Reader, "manifest.json", func(dec *json.Decoder) error { return dec.Decode(&m) }); err != nil {
+		return nil, err
+	}
+	if m.Format != Format {
+		return nil, ErrNotBundle
+	}
+	if m.Version > Version {
+		return nil, fmt.Errorf("bundle version %d is newer than supported version %d", m.Version, Version)
+	}
+
+	err = decodeFile(&zr.Reader, "chats.jsonl", func(dec *json.Decoder) error {
+		for dec.More() {
+			var c store.Chat
+			if err := dec.Decode(&c); err != nil {
+				return err
+			}
+			if err := st.StoreChat(c.JID, c.Name, c.LastMessageTime); err != nil {
+				return err
+			}
+			// Linked chats travel only as their JIDs, under the chat they continue
+			for _, linked := range c.LinkedJIDs {
+				if err := st.StoreChat(linked, c.Name, time.Time{}); err != nil {
+					return err
+				}
+				if err := st.LinkChats(linked, c.JID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import chats: %w", err)
+	}
+
+	if m.Version >= 2 {
+		err = decodeFile(&zr.Reader, "contacts.jsonl", func(dec *json.Decoder) error {
+			for dec.More() {
+				var c store.Contact
+				if err := dec.Decode(&c); err != nil {
+					return err
+				}
+				if err := st.StoreContact(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to import contacts: %w", err)
+		}
+	}
+
+	edits := map[store.MessageKey][]store.Revision{}
+	if m.Version >= 2 {
+		err = decodeFile(&zr.Reader, "revisions.jsonl", func(dec *json.Decoder) error {
+			for dec.More() {
+				var r revisions
+				if err := dec.Decode(&r); err != nil {
+					return err
+				}
+				edits[r.MessageKey] = r.Revisions
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to import revisions: %w", err)
+		}
+	}
+
+	types := map[string]string{}
+	for _, f := range m.Media {
+		types[f.SHA256] = f.MimeType
+	}
+	r := restorer{ctx: ctx, st: st, zr: &zr.Reader, storage: storage, types: types, saved: map[string]store.MediaBlob{}}
+	err = decodeFile(&zr.Reader, "messages.jsonl", func(dec *json.Decoder) error {
+		for dec.More() {
+			var msg message
+			if err := dec.Decode(&msg); err != nil {
+				return err
+			}
+			key := store.MessageKey{ID: msg.ID, ChatJID: msg.ChatJID}
+			if err := r.restore(msg, edits[key]); err != nil {
+				return fmt.Errorf("message %s in %s: %w", msg.ID, msg.ChatJID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import messages: %w", err)
+	}
+	return &m, nil
+}
+
+// Stores bundled messages with what the archive keeps beside them
+type restorer struct {
+	ctx     context.Context
+	st      store.Store
+	zr      *zip.Reader
+	storage media.Storage
+	// MIME types of bundled media, and the files already saved, by hash
+	types map[string]string
+	saved map[string]store.MediaBlob
+}
+
+// Store a bundled message as it was: with its edit history, metadata and
+// attachment
+func (r *restorer) restore(msg message, revs []store.Revision) error {
+	key := store.MessageKey{ID: msg.ID, ChatJID: msg.ChatJID}
+	// Store the original text, then replay each edit over it
+	content := msg.Content
+	if len(revs) > 0 {
+		content = revs[0].Content
+	}
+	if msg.System != nil {
+		system := msg.Message
+		system.Content = content
+		if err := r.st.StoreSystemMessage(system); err != nil {
+			return err
+		}
+	} else if err := r.st.StoreMessage(msg.ID, msg.ChatJID, msg.Sender, content, msg.Timestamp, msg.IsFromMe, msg.MediaType, msg.Filename, ""); err != nil {
+		return err
+	}
+	for i, rev := range revs {
+		next := msg.Content
+		if i+1 < len(revs) {
+			next = revs[i+1].Content
+		}
+		if err := r.st.EditMessage(key, next, rev.ReplacedAt); err != nil {
+			return err
+		}
+	}
+
+	if msg.ReplyTo != "" {
+		if err := r.st.StoreReply(key, msg.ReplyTo, msg.ReplyToSender); err != nil {
+			return err
+		}
+	}
+	if msg.IsForwarded {
+		if err := r.st.StoreForwarded(key, msg.ForwardingScore); err != nil {
+			return err
+		}
+	}
+	if len(msg.Interactive) > 0 {
+		if err := r.st.StoreInteractive(key, msg.Interactive); err != nil {
+			return err
+		}
+	}
+	if msg.Location != nil {
+		if err := r.st.StoreLocation(key, *msg.Location); err != nil {
+			return err
+		}
+	}
+	if msg.MimeType != "" || msg.IsViewOnce {
+		if err := r.st.StoreMedia(key, store.Media{MimeType: msg.MimeType, ViewOnce: msg.IsViewOnce}); err != nil {
+			return err
+		}
+	}
+	if msg.OCRText != "" {
+		if err := r.st.StoreOCRText(key, msg.OCRText); err != nil {
+			return err
+		}
+	}
+	if msg.DeletedAt != nil {
+		tombstone := msg.Message
+		tombstone.Timestamp = *msg.DeletedAt
+		if err := r.st.RevokeMessage(tombstone, msg.RevokedBy); err != nil {
+			return err
+		}
+	}
+	if msg.MediaSHA256 != "" && r.storage != nil {
+		return r.attach(key, msg)
+	}
+	return nil
+}
+
+// Save a message's bundled attachment to storage, once per file, and point
+// the message at it. Attachments missing from the bundle are left out.
+func (r *restorer) attach(key store.MessageKey, msg message) error {
+	b, ok := r.saved[msg.MediaSHA256]
+	if !ok {
+		f, err := r.zr.Open("media/" + msg.MediaSHA256)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != msg.MediaSHA256 {
+			return fmt.Errorf("media/%s does not match its hash", msg.MediaSHA256)
+		}
+		b = store.MediaBlob{SHA256: msg.MediaSHA256, Size: int64(len(data)), MimeType: r.types[msg.MediaSHA256]}
+		if b.Location, err = r.storage.Put(r.ctx, media.BlobKey(b.SHA256, media.FileExtension(msg.Message)), data, b.MimeType); err != nil {
+			return err
+		}
+		r.saved[b.SHA256] = b
+	}
+	sum, err := hex.DecodeString(b.SHA256)
+	if err != nil {
+		return err
+	}
+	if err := r.st.StoreMedia(key, store.Media{FileSHA256: sum, FileLength: b.Size}); err != nil {
+		return err
+	}
+	return r.st.SetMediaFile(key, b)
+}
+
+// Open a named entry and hand a JSON decoder over it to fn
+func decodeFile(zr *zip.Reader, name string, fn func(*json.Decoder) error) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		if name == "manifest.json" {
+			return ErrNotBundle
+		}
+		return fmt.Errorf("bundle is missing %s: %w", name, err)
+	}
+	defer f.Close()
+	return fn(json.NewDecoder(bufio.NewReader(f)))
+}
@@ -0,0 +1,124 @@
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/store"
+)
+
+const (
+	chatJID = "447700900123@s.whatsapp.net"
+	photo   = "fake jpeg bytes"
+)
+
+func openStore(t *testing.T) *store.SQLiteStore {
+	t.Helper()
+	st, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := openStore(t)
+	srcMedia := media.Local(t.TempDir())
+	must(src.StoreChat(chatJID, "Alice", at))
+	contact := store.Contact{JID: chatJID, FullName: "Alice Smith", PushName: "Ally", Phone: "+447700900123"}
+	must(src.StoreContact(contact))
+
+	image := store.MessageKey{ID: "IMG1", ChatJID: chatJID}
+	must(src.StoreMessage(image.ID, image.ChatJID, chatJID, "[Image] receipt", at, false, "image", "receipt.jpg", ""))
+	sum := sha256.Sum256([]byte(photo))
+	blob := store.MediaBlob{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(photo)), MimeType: "image/jpeg"}
+	var err error
+	blob.Location, err = srcMedia.Put(ctx, media.BlobKey(blob.SHA256, ".jpg"), []byte(photo), blob.MimeType)
+	must(err)
+	must(src.StoreMedia(image, store.Media{FileSHA256: sum[:], FileLength: blob.Size, MimeType: blob.MimeType}))
+	must(src.SetMediaFile(image, blob))
+
+	edited := store.MessageKey{ID: "TXT1", ChatJID: chatJID}
+	must(src.StoreMessage(edited.ID, edited.ChatJID, "", "see you at 5", at, true, "", "", ""))
+	must(src.EditMessage(edited, "see you at 6", at.Add(time.Minute)))
+	must(src.EditMessage(edited, "see you at 7", at.Add(2*time.Minute)))
+	wantRevisions, err := src.Revisions(edited)
+	must(err)
+
+	path := filepath.Join(t.TempDir(), "bundle.zip")
+	f, err := os.Create(path)
+	must(err)
+	written, err := Write(ctx, src, f, Options{Storage: srcMedia})
+	must(err)
+	must(f.Close())
+	if written.Contacts != 1 || written.Messages != 2 || len(written.Media) != 1 {
+		t.Fatalf("wrote %d contacts, %d messages and %d media files, want 1, 2 and 1",
+			written.Contacts, written.Messages, len(written.Media))
+	}
+	if got := written.Media[0]; got.SHA256 != blob.SHA256 || got.Size != blob.Size || got.Name != "receipt.jpg" {
+		t.Errorf("manifest media %+v", got)
+	}
+
+	dst := openStore(t)
+	dstDir := t.TempDir()
+	if _, err := Read(ctx, dst, path, media.Local(dstDir)); err != nil {
+		t.Fatal(err)
+	}
+
+	contacts, err := dst.ListContacts()
+	must(err)
+	if len(contacts) != 1 || contacts[0] != contact {
+		t.Errorf("contacts %+v, want %+v", contacts, contact)
+	}
+
+	m, err := dst.GetMessage(image)
+	must(err)
+	if !strings.HasPrefix(m.LocalPath, dstDir) || m.MimeType != "image/jpeg" {
+		t.Errorf("image at %q of type %q, want it under %s", m.LocalPath, m.MimeType, dstDir)
+	}
+	data, err := os.ReadFile(m.LocalPath)
+	must(err)
+	if string(data) != photo {
+		t.Errorf("image file holds %q", data)
+	}
+	if b, ok, err := dst.FindMediaBlob(blob.SHA256); err != nil || !ok || b.Location != m.LocalPath {
+		t.Errorf("media blob %+v, %v, %v", b, ok, err)
+	}
+
+	m, err = dst.GetMessage(edited)
+	must(err)
+	if m.Content != "see you at 7" || m.EditedAt == nil || !m.EditedAt.Equal(at.Add(2*time.Minute)) {
+		t.Errorf("edited message %q edited at %v", m.Content, m.EditedAt)
+	}
+	revisions, err := dst.Revisions(edited)
+	must(err)
+	if !reflect.DeepEqual(revisions, wantRevisions) {
+		t.Errorf("revisions %+v, want %+v", revisions, wantRevisions)
+	}
+
+	// Importing again changes nothing
+	if _, err := Read(ctx, dst, path, media.Local(dstDir)); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, err = dst.Revisions(edited); err != nil || len(revisions) != len(wantRevisions) {
+		t.Errorf("%d revisions after importing twice, want %d (%v)", len(revisions), len(wantRevisions), err)
+	}
+}
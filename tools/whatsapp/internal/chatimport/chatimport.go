@@ -0,0 +1,324 @@
+package chatimport
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/phone"
+	"whatsapp-logger/internal/store"
+)
+
+// Options shape an import
+type Options struct {
+	// Chat the export is of; exports don't name its JID
+	ChatJID string
+	// Name the export gives the exporting account, whose entries are
+	// stored as sent by me
+	Me string
+	// Zone the export's times are in, the phone's; local when nil
+	Location *time.Location
+	// Region of phone numbers written without a country code
+	Region string
+	// Where attached files are saved; nil leaves them out
+	Storage media.Storage
+}
+
+// Result counts what an import did
+type Result struct {
+	Messages int `json:"messages"`
+	// Entries already in the archive, from the logger or an earlier import
+	Skipped int `json:"skipped"`
+	// System lines, which are not imported
+	System int `json:"system"`
+	// Attached files saved
+	Media int `json:"media"`
+	// Senders no contact matched, stored under the name the export gives
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// Placeholders for media, as the logger writes them
+var placeholders = map[string]string{
+	"image":    "[Image]",
+	"video":    "[Video]",
+	"audio":    "[Audio]",
+	"document": "[Document]",
+	"sticker":  "[Sticker]",
+}
+
+// Import the export at file, a .txt or the .zip the app shares, into
+// opts.ChatJID. Entries are stored under IDs derived from their content, so
+// importing an export again adds nothing, and entries matching a stored
+// message of the same minute are skipped, so exports may overlap what the
+// logger recorded.
+func Import(ctx context.Context, st store.Store, file string, opts Options) (Result, error) {
+	var res Result
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	text, files, closeFiles, err := openExport(file)
+	if err != nil {
+		return res, err
+	}
+	defer closeFiles()
+	entries, err := Parse(text, opts.Location)
+	text.Close()
+	if err != nil {
+		return res, err
+	}
+
+	if err := ensureChat(st, opts.ChatJID, entries[len(entries)-1].Time); err != nil {
+		return res, err
+	}
+	stored, err := storedMessages(st, opts.ChatJID, entries[0].Time, entries[len(entries)-1].Time)
+	if err != nil {
+		return res, err
+	}
+
+	r := resolver{st: st, opts: opts, jids: map[string]string{}}
+	repeats := map[string]int{}
+	for _, e := range entries {
+		if e.Sender == "" {
+			res.System++
+			continue
+		}
+		content, filename := messageContent(e)
+		if stored[matchKey(e.Time, content)] {
+			res.Skipped++
+			continue
+		}
+		fromMe := opts.Me != "" && strings.EqualFold(e.Sender, opts.Me)
+		sender := ""
+		if !fromMe {
+			if sender, err = r.jid(e.Sender); err != nil {
+				return res, err
+			}
+		}
+
+		id := entryID(opts.ChatJID, e, repeats)
+		err := st.StoreMessage(id, opts.ChatJID, sender, content, e.Time, fromMe, e.MediaType, filename, "")
+		if err != nil {
+			return res, err
+		}
+		res.Messages++
+		if e.Attachment != "" && opts.Storage != nil {
+			saved, err := saveAttachment(ctx, st, opts.Storage, files, store.MessageKey{ID: id, ChatJID: opts.ChatJID}, e)
+			if err != nil {
+				return res, fmt.Errorf("failed to save %s: %w", e.Attachment, err)
+			}
+			if saved {
+				res.Media++
+			}
+		}
+	}
+	res.Unresolved = r.unresolved
+	sort.Strings(res.Unresolved)
+	return res, nil
+}
+
+// Open an export's text, and a way to open the files it attaches, from a
+// zip or from the directory holding a .txt
+func openExport(file string) (text io.ReadCloser, files func(string) (io.ReadCloser, error), closeFn func() error, err error) {
+	if !strings.EqualFold(filepath.Ext(file), ".zip") {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		dir := filepath.Dir(file)
+		files = func(name string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(dir, filepath.Base(name)))
+		}
+		return f, files, func() error { return nil }, nil
+	}
+
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	byName := map[string]*zip.File{}
+	var chat *zip.File
+	for _, f := range zr.File {
+		name := path.Base(f.Name)
+		byName[name] = f
+		// iOS calls it _chat.txt, Android "WhatsApp Chat with Alice.txt"
+		if strings.EqualFold(path.Ext(name), ".txt") && (chat == nil || name == "_chat.txt") {
+			chat = f
+		}
+	}
+	if chat == nil {
+		zr.Close()
+		return nil, nil, nil, fmt.Errorf("%w: no .txt in %s", ErrNotExport, file)
+	}
+	if text, err = chat.Open(); err != nil {
+		zr.Close()
+		return nil, nil, nil, err
+	}
+	files = func(name string) (io.ReadCloser, error) {
+		f, ok := byName[path.Base(name)]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return f.Open()
+	}
+	return text, files, zr.Close, nil
+}
+
+// Store the chat when the archive doesn't have it, leaving known chats as
+// they are
+func ensureChat(st store.Store, chatJID string, last time.Time) error {
+	chats, err := st.ListChats()
+	if err != nil {
+		return err
+	}
+	for _, c := range chats {
+		if c.JID == chatJID {
+			return nil
+		}
+		for _, linked := range c.LinkedJIDs {
+			if linked == chatJID {
+				return nil
+			}
+		}
+	}
+	return st.StoreChat(chatJID, chatJID, last)
+}
+
+// The messages stored in a chat and the chats linked to it between two
+// times, by matchKey
+func storedMessages(st store.Store, chatJID string, first, last time.Time) (map[string]bool, error) {
+	group, err := st.ChatGroup(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	inChat := map[string]bool{}
+	for _, jid := range group {
+		inChat[jid] = true
+	}
+	stored := map[string]bool{}
+	err = st.ForEachMessage(first.Truncate(time.Minute), last.Truncate(time.Minute).Add(time.Minute), func(m store.Message) error {
+		if inChat[m.ChatJID] {
+			stored[matchKey(m.Timestamp, m.Content)] = true
+		}
+		return nil
+	})
+	return stored, err
+}
+
+// Messages match when their text is the same within a minute, the
+// precision of Android exports
+func matchKey(t time.Time, content string) string {
+	return fmt.Sprintf("%d\x00%s", t.Truncate(time.Minute).Unix(), strings.TrimSpace(content))
+}
+
+// The content and filename to store for an entry, with media placeholders
+// as the logger writes them
+func messageContent(e Entry) (content, filename string) {
+	if e.Attachment == "" && !e.MediaOmitted {
+		return e.Text, ""
+	}
+	placeholder, ok := placeholders[e.MediaType]
+	if !ok {
+		placeholder = "[Media omitted]"
+	}
+	if e.MediaType == "document" && e.Attachment != "" {
+		filename = e.Attachment
+		return withCaption(withCaption(placeholder, filename), e.Text), filename
+	}
+	return withCaption(placeholder, e.Text), e.Attachment
+}
+
+func withCaption(placeholder, caption string) string {
+	if caption == "" {
+		return placeholder
+	}
+	return placeholder + " " + caption
+}
+
+// A stable ID for an entry, so importing the same export again replaces
+// rather than duplicates it. repeats tells identical entries apart.
+func entryID(chatJID string, e Entry, repeats map[string]int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%s\x00%s\x00%s", chatJID, e.Time.Unix(), e.Sender, e.Text, e.Attachment)
+	sum := hex.EncodeToString(h.Sum(nil))
+	n := repeats[sum]
+	repeats[sum]++
+	return fmt.Sprintf("EXPORT-%s-%d", strings.ToUpper(sum[:20]), n)
+}
+
+// Save an attached file to media storage and point the message at it.
+// Reports false when the export doesn't hold the file.
+func saveAttachment(ctx context.Context, st store.Store, storage media.Storage, files func(string) (io.ReadCloser, error), key store.MessageKey, e Entry) (bool, error) {
+	f, err := files(e.Attachment)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return false, err
+	}
+
+	ext := strings.ToLower(path.Ext(e.Attachment))
+	mimeType := mime.TypeByExtension(ext)
+	sum := sha256.Sum256(data)
+	b := store.MediaBlob{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data)), MimeType: mimeType}
+	if b.Location, err = storage.Put(ctx, media.BlobKey(b.SHA256, ext), data, mimeType); err != nil {
+		return false, err
+	}
+	if err := st.StoreMedia(key, store.Media{FileSHA256: sum[:], FileLength: b.Size, MimeType: mimeType}); err != nil {
+		return false, err
+	}
+	return true, st.SetMediaFile(key, b)
+}
+
+// Maps the names an export gives senders to JIDs
+type resolver struct {
+	st         store.Store
+	opts       Options
+	jids       map[string]string
+	unresolved []string
+}
+
+// The JID of the sender an export names, or the name itself when none
+// matches. In a direct chat every other sender is the contact; elsewhere
+// phone numbers map directly and names are looked up among contacts.
+func (r *resolver) jid(name string) (string, error) {
+	if !strings.HasSuffix(r.opts.ChatJID, "@g.us") {
+		return r.opts.ChatJID, nil
+	}
+	if jid, ok := r.jids[name]; ok {
+		return jid, nil
+	}
+	jid := name
+	if e164, err := phone.Normalize(name, r.opts.Region); err == nil && strings.Trim(name, "+0123456789 -()") == "" {
+		jid = phone.JID(e164)
+	} else {
+		jids, err := r.st.ContactJIDs(name)
+		if err != nil {
+			return "", err
+		}
+		if len(jids) > 0 {
+			jid = jids[0]
+		} else {
+			r.unresolved = append(r.unresolved, name)
+		}
+	}
+	r.jids[name] = jid
+	return jid, nil
+}
@@ -0,0 +1,240 @@
+// Package chatimport backfills the archive from chats exported with the
+// phone app's "Export chat", covering history from before the logger ran.
+//
+// An export is a text file with one entry per message, continued on the
+// following lines for multi-line text, or a zip holding that file and the
+// attached media. Its layout depends on the platform and the phone's
+// locale:
+//
+//	[15/01/2024, 14:23:45] Alice: Hello     iOS
+//	15/01/2024, 14:23 - Alice: Hello        Android
+//	1/15/24, 2:23 PM - Alice: Hello         Android, US
+package chatimport
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Left-to-right mark iOS puts before system text and placeholders
+const lrm = "\u200e"
+
+// ErrNotExport is returned for text with no line in an export's layout
+var ErrNotExport = errors.New("not a WhatsApp chat export")
+
+// Entry is one message of an export
+type Entry struct {
+	Time time.Time
+	// Sender as the export names them: a saved name, a push name or a
+	// phone number. Empty for system lines.
+	Sender string
+	Text   string
+	// File the entry attaches, as named in the export
+	Attachment string
+	// Media type of the attachment, or of media left out of the export;
+	// empty when unknown
+	MediaType string
+	// The export was made without media and leaves this entry's out
+	MediaOmitted bool
+}
+
+// The start of an entry: date, time, then "] " (iOS) or " - " (Android)
+var entryStart = regexp.MustCompile(`^\[?(\d{1,4})[./-](\d{1,2})[./-](\d{1,4}),?\s+(\d{1,2})[:.](\d{2})(?:[:.](\d{2}))?\s*([AaPp])?\.?\s?(?:[Mm]\.?)?(?:\]\s*|\s+[-\x{2013}]\s+)(.*)$`)
+
+// Attachments: "<attached: NAME>" on iOS, "NAME (file attached)" on Android
+var (
+	iosAttachment     = regexp.MustCompile(`<attached: ([^>]+)>`)
+	androidAttachment = regexp.MustCompile(`^(.+?) \(file attached\)$`)
+	iosOmitted        = regexp.MustCompile(`^(?:.* )?(image|video|audio|sticker|GIF|document) omitted$`)
+)
+
+// An entry before its date is read, which needs every line to tell the
+// day from the month
+type rawEntry struct {
+	date [3]int
+	// Dates read year first, as 2024-01-15
+	yearFirst bool
+	clock     [3]int
+	pm        string
+	// iOS marks system lines and placeholders with a left-to-right mark
+	marked bool
+	Entry
+}
+
+// Read an export's entries, oldest first, with times in loc. System lines,
+// like the encryption notice or group changes, come back with no sender.
+func Parse(r io.Reader, loc *time.Location) ([]Entry, error) {
+	var raws []*rawEntry
+	br := bufio.NewReader(r)
+	for first := true; ; first = false {
+		line, err := br.ReadString('\n')
+		if line == "" && err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if e := parseStart(line); e != nil {
+			raws = append(raws, e)
+		} else if len(raws) > 0 {
+			last := raws[len(raws)-1]
+			last.Text += "\n" + clean(line)
+		}
+	}
+	if len(raws) == 0 {
+		return nil, ErrNotExport
+	}
+
+	dayFirst := dayFirst(raws)
+	entries := make([]Entry, 0, len(raws))
+	for _, e := range raws {
+		e.Time = e.time(dayFirst, loc)
+		e.attachment()
+		entries = append(entries, e.Entry)
+	}
+	return entries, nil
+}
+
+// Parse the first line of an entry, or return nil for a continuation line
+func parseStart(line string) *rawEntry {
+	line = strings.NewReplacer("\u202f", " ", "\u00a0", " ").Replace(line)
+	m := entryStart.FindStringSubmatch(strings.TrimPrefix(line, lrm))
+	if m == nil {
+		return nil
+	}
+	e := &rawEntry{yearFirst: len(m[1]) == 4, pm: strings.ToLower(m[7])}
+	for i := 0; i < 3; i++ {
+		e.date[i], _ = strconv.Atoi(m[1+i])
+		e.clock[i], _ = strconv.Atoi(m[4+i])
+	}
+	body := m[8]
+	if sender, text, ok := strings.Cut(body, ": "); ok && !strings.HasPrefix(body, lrm) {
+		e.Sender, body = clean(sender), text
+	}
+	e.marked = strings.HasPrefix(body, lrm)
+	e.Text = clean(body)
+	return e
+}
+
+// Drop the direction marks exports put around names and placeholders
+func clean(s string) string {
+	return strings.NewReplacer("\u200e", "", "\u200f", "", "\u202a", "", "\u202c", "").Replace(s)
+}
+
+// Whether dates put the day before the month. A day or month over 12
+// settles it; otherwise 12-hour clocks suggest the US layout.
+func dayFirst(raws []*rawEntry) bool {
+	twelveHour := false
+	for _, e := range raws {
+		if e.yearFirst {
+			continue
+		}
+		switch {
+		case e.date[0] > 12:
+			return true
+		case e.date[1] > 12:
+			return false
+		}
+		twelveHour = twelveHour || e.pm != ""
+	}
+	return !twelveHour
+}
+
+func (e *rawEntry) time(dayFirst bool, loc *time.Location) time.Time {
+	var year, month, day int
+	switch {
+	case e.yearFirst:
+		year, month, day = e.date[0], e.date[1], e.date[2]
+	case dayFirst:
+		day, month, year = e.date[0], e.date[1], e.date[2]
+	default:
+		month, day, year = e.date[0], e.date[1], e.date[2]
+	}
+	if year < 100 {
+		year += 2000
+	}
+	hour := e.clock[0]
+	switch {
+	case e.pm == "p" && hour < 12:
+		hour += 12
+	case e.pm == "a" && hour == 12:
+		hour = 0
+	}
+	return time.Date(year, time.Month(month), day, hour, e.clock[1], e.clock[2], 0, loc)
+}
+
+// Move an attachment reference out of the text into Attachment and
+// MediaType, leaving the caption, and mark iOS system lines as such
+func (e *rawEntry) attachment() {
+	first, rest, _ := strings.Cut(e.Text, "\n")
+	switch {
+	case iosAttachment.MatchString(e.Text):
+		e.Attachment = iosAttachment.FindStringSubmatch(e.Text)[1]
+		e.Text = strings.TrimSpace(iosAttachment.ReplaceAllString(e.Text, ""))
+	case androidAttachment.MatchString(first):
+		e.Attachment = androidAttachment.FindStringSubmatch(first)[1]
+		e.Text = strings.TrimSpace(rest)
+	case first == "<Media omitted>":
+		e.MediaOmitted = true
+		e.Text = strings.TrimSpace(rest)
+	case e.marked && iosOmitted.MatchString(first):
+		e.MediaOmitted = true
+		e.MediaType = omittedType(iosOmitted.FindStringSubmatch(first)[1])
+		e.Text = strings.TrimSpace(rest)
+	case e.marked && e.Sender != "":
+		// Group changes and notices iOS writes under the chat's name
+		e.Sender = ""
+	}
+	if e.Attachment != "" {
+		e.MediaType = attachmentType(e.Attachment)
+	}
+}
+
+func omittedType(word string) string {
+	switch word {
+	case "GIF":
+		return "video"
+	default:
+		return word
+	}
+}
+
+// The media type of an exported file, from the names the apps give them:
+// IMG-20240115-WA0001.jpg on Android, 00000012-PHOTO-2024-01-15-14-23-45.jpg
+// on iOS. Files kept under their original name go by extension, and are
+// documents when that doesn't tell.
+func attachmentType(name string) string {
+	upper := strings.ToUpper(name)
+	for _, t := range []struct{ marker, mediaType string }{
+		{"DOC-", "document"},
+		{"IMG-", "image"}, {"-PHOTO-", "image"},
+		{"VID-", "video"}, {"-VIDEO-", "video"}, {"-GIF-", "video"},
+		{"PTT-", "audio"}, {"AUD-", "audio"}, {"-AUDIO-", "audio"},
+		{"STK-", "sticker"}, {"-STICKER-", "sticker"},
+	} {
+		if strings.HasPrefix(upper, t.marker) || (strings.HasPrefix(t.marker, "-") && strings.Contains(upper, t.marker)) {
+			return t.mediaType
+		}
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".heic":
+		return "image"
+	case ".webp":
+		return "sticker"
+	case ".mp4", ".mov", ".3gp":
+		return "video"
+	case ".opus", ".ogg", ".m4a", ".mp3", ".aac":
+		return "audio"
+	}
+	return "document"
+}
@@ -0,0 +1,196 @@
+package chatimport
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+	for _, tc := range []struct {
+		name   string
+		export string
+		want   []Entry
+	}{
+		{
+			"ios",
+			"[15/01/2024, 14:23:45] Alice: Hello",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 45), Sender: "Alice", Text: "Hello"}},
+		},
+		{
+			"android",
+			"15/01/2024, 14:23 - Alice: Hello",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice", Text: "Hello"}},
+		},
+		{
+			"android us",
+			"1/15/24, 2:23 PM - Alice: Hello",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice", Text: "Hello"}},
+		},
+		{
+			"ios us with a narrow space before the meridiem",
+			"[1/15/24, 2:23:45\u202fPM] Alice: Hello",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 45), Sender: "Alice", Text: "Hello"}},
+		},
+		{
+			"midnight on a 12-hour clock",
+			"1/15/24, 12:05 AM - Alice: Up late",
+			[]Entry{{Time: at(2024, 1, 15, 0, 5, 0), Sender: "Alice", Text: "Up late"}},
+		},
+		{
+			"ambiguous dates on a 12-hour clock read month first",
+			"2/3/24, 9:00 AM - Alice: Hi",
+			[]Entry{{Time: at(2024, 2, 3, 9, 0, 0), Sender: "Alice", Text: "Hi"}},
+		},
+		{
+			"ambiguous dates on a 24-hour clock read day first",
+			"2/3/24, 09:00 - Alice: Hi",
+			[]Entry{{Time: at(2024, 3, 2, 9, 0, 0), Sender: "Alice", Text: "Hi"}},
+		},
+		{
+			"a later day over 12 settles an ambiguous 12-hour export",
+			"2/3/24, 9:00 AM - Alice: Hi\n13/3/24, 9:01 AM - Bob: Hey",
+			[]Entry{
+				{Time: at(2024, 3, 2, 9, 0, 0), Sender: "Alice", Text: "Hi"},
+				{Time: at(2024, 3, 13, 9, 1, 0), Sender: "Bob", Text: "Hey"},
+			},
+		},
+		{
+			"a later month-first date settles an ambiguous 24-hour export",
+			"2/3/24, 09:00 - Alice: Hi\n3/13/24, 09:01 - Bob: Hey",
+			[]Entry{
+				{Time: at(2024, 2, 3, 9, 0, 0), Sender: "Alice", Text: "Hi"},
+				{Time: at(2024, 3, 13, 9, 1, 0), Sender: "Bob", Text: "Hey"},
+			},
+		},
+		{
+			"year first",
+			"2024-01-15, 14:23 - Alice: Hello",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice", Text: "Hello"}},
+		},
+		{
+			"dotted dates",
+			"15.01.24, 14:23 - Alice: Hallo",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice", Text: "Hallo"}},
+		},
+		{
+			"multi-line messages",
+			"15/01/2024, 14:23 - Alice: Line one\nline two\n\nline four\n15/01/2024, 14:24 - Bob: Next",
+			[]Entry{
+				{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice", Text: "Line one\nline two\n\nline four"},
+				{Time: at(2024, 1, 15, 14, 24, 0), Sender: "Bob", Text: "Next"},
+			},
+		},
+		{
+			"byte order mark and crlf line endings",
+			"\ufeff15/01/2024, 14:23 - Alice: Hello\r\nthere\r\n",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice", Text: "Hello\nthere"}},
+		},
+		{
+			"android attachment with caption",
+			"15/01/2024, 14:23 - Alice: IMG-20240115-WA0001.jpg (file attached)\nThe receipt",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice", Text: "The receipt",
+				Attachment: "IMG-20240115-WA0001.jpg", MediaType: "image"}},
+		},
+		{
+			"android document under its own name",
+			"15/01/2024, 14:23 - Alice: Minutes March.pdf (file attached)",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice",
+				Attachment: "Minutes March.pdf", MediaType: "document"}},
+		},
+		{
+			"ios attachment",
+			"[15/01/2024, 14:23:45] Alice: \u200e<attached: 00000012-PHOTO-2024-01-15-14-23-45.jpg>",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 45), Sender: "Alice",
+				Attachment: "00000012-PHOTO-2024-01-15-14-23-45.jpg", MediaType: "image"}},
+		},
+		{
+			"ios voice note",
+			"[15/01/2024, 14:23:45] Alice: \u200e<attached: 00000013-AUDIO-2024-01-15-14-23-45.opus>",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 45), Sender: "Alice",
+				Attachment: "00000013-AUDIO-2024-01-15-14-23-45.opus", MediaType: "audio"}},
+		},
+		{
+			"android media omitted",
+			"15/01/2024, 14:23 - Alice: <Media omitted>",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice", MediaOmitted: true}},
+		},
+		{
+			"ios media omitted",
+			"[15/01/2024, 14:23:45] Alice: \u200eimage omitted\n[15/01/2024, 14:23:50] Alice: \u200eGIF omitted",
+			[]Entry{
+				{Time: at(2024, 1, 15, 14, 23, 45), Sender: "Alice", MediaType: "image", MediaOmitted: true},
+				{Time: at(2024, 1, 15, 14, 23, 50), Sender: "Alice", MediaType: "video", MediaOmitted: true},
+			},
+		},
+		{
+			"ios text that only mentions omitted media",
+			"[15/01/2024, 14:23:45] Alice: the image omitted from the report",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 45), Sender: "Alice", Text: "the image omitted from the report"}},
+		},
+		{
+			"android system lines",
+			"15/01/2024, 14:20 - Messages and calls are end-to-end encrypted. No one outside of this chat can read them.\n" +
+				"15/01/2024, 14:21 - Alice added Bob",
+			[]Entry{
+				{Time: at(2024, 1, 15, 14, 20, 0), Text: "Messages and calls are end-to-end encrypted. No one outside of this chat can read them."},
+				{Time: at(2024, 1, 15, 14, 21, 0), Text: "Alice added Bob"},
+			},
+		},
+		{
+			"ios system lines",
+			"[15/01/2024, 14:20:00] \u200eMessages and calls are end-to-end encrypted.\n" +
+				"[15/01/2024, 14:21:00] Book club: \u200eAlice added Bob",
+			[]Entry{
+				{Time: at(2024, 1, 15, 14, 20, 0), Text: "Messages and calls are end-to-end encrypted."},
+				{Time: at(2024, 1, 15, 14, 21, 0), Text: "Alice added Bob"},
+			},
+		},
+		{
+			"text with a colon",
+			"15/01/2024, 14:23 - Alice: Note: bring snacks",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "Alice", Text: "Note: bring snacks"}},
+		},
+		{
+			"sender given as a phone number",
+			"15/01/2024, 14:23 - +44 7700 900123: Hello",
+			[]Entry{{Time: at(2024, 1, 15, 14, 23, 0), Sender: "+44 7700 900123", Text: "Hello"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tc.export), time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Parse\n got %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseInLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip(err)
+	}
+	entries, err := Parse(strings.NewReader("15/07/2024, 14:23 - Alice: Hello"), loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 7, 15, 13, 23, 0, 0, time.UTC); !entries[0].Time.Equal(want) {
+		t.Errorf("time = %v, want %v", entries[0].Time.UTC(), want)
+	}
+}
+
+func TestParseRejectsOtherText(t *testing.T) {
+	_, err := Parse(strings.NewReader("Dear Alice,\nthis is a letter.\n"), time.UTC)
+	if !errors.Is(err, ErrNotExport) {
+		t.Errorf("err = %v, want ErrNotExport", err)
+	}
+}
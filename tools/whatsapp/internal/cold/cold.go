@@ -0,0 +1,242 @@
+// Package cold moves old messages out of the hot database into compressed,
+// append-only segment files, and searches them on demand.
+//
+// A cold directory holds:
+//
+//	index.jsonl                one Segment per line, appended as segments are written
+//	segment-<unixnano>.jsonl.gz  gzip-compressed store.Message lines, oldest first
+//
+// Segments are never rewritten. Messages are only deleted from the hot
+// database after their segment and index entry are safely on disk, so a crash
+// mid-move leaves duplicates rather than losses; searches drop the duplicates.
+package cold
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+const indexFile = "index.jsonl"
+
+// Messages deleted from the hot store per transaction
+const deleteBatch = 500
+
+// Index entry describing one segment file
+type Segment struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// Timestamp range of the messages inside
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	Count int       `json:"count"`
+	// Messages per chat JID
+	Chats map[string]int `json:"chats"`
+}
+
+// Archive is a directory of cold segments
+type Archive struct {
+	dir string
+	// Guards index appends
+	mu sync.Mutex
+}
+
+// Open the cold archive in dir, creating it if needed
+func Open(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cold archive: %w", err)
+	}
+	return &Archive{dir: dir}, nil
+}
+
+// Move every message older than before out of st into a new segment. Returns
+// nil when there was nothing to move.
+func (a *Archive) Move(st store.Store, before time.Time) (*Segment, error) {
+	seg := &Segment{
+		Name:      fmt.Sprintf("segment-%d.jsonl.gz", time.Now().UnixNano()),
+		CreatedAt: time.Now().UTC(),
+		Chats:     map[string]int{},
+	}
+	final := filepath.Join(a.dir, seg.Name)
+	tmp := final + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	var keys []store.MessageKey
+	err = st.ForEachMessage(time.Time{}, before, func(m store.Message) error {
+		if seg.Count == 0 {
+			seg.Since = m.Timestamp
+		}
+		seg.Until = m.Timestamp
+		seg.Count++
+		seg.Chats[m.ChatJID]++
+		keys = append(keys, store.MessageKey{ID: m.ID, ChatJID: m.ChatJID})
+		return enc.Encode(m)
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write segment: %w", err)
+	}
+	if seg.Count == 0 {
+		return nil, nil
+	}
+
+	if err := os.Rename(tmp, final); err != nil {
+		return nil, fmt.Errorf("failed to finalize segment: %w", err)
+	}
+	if err := a.appendIndex(seg); err != nil {
+		return nil, err
+	}
+
+	for len(keys) > 0 {
+		n := min(deleteBatch, len(keys))
+		if _, err := st.DeleteMessages(keys[:n]); err != nil {
+			return seg, fmt.Errorf("segment %s written but removing messages from the database failed: %w", seg.Name, err)
+		}
+		keys = keys[n:]
+	}
+	return seg, nil
+}
+
+// Record a finished segment in the index
+func (a *Archive) appendIndex(seg *Segment) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(a.dir, indexFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open cold index: %w", err)
+	}
+	if err := json.NewEncoder(f).Encode(seg); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write cold index: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// List segments in the order they were written
+func (a *Archive) Segments() ([]Segment, error) {
+	f, err := os.Open(filepath.Join(a.dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cold index: %w", err)
+	}
+	defer f.Close()
+
+	var segs []Segment
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var seg Segment
+		err := dec.Decode(&seg)
+		if errors.Is(err, io.EOF) {
+			return segs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cold index: %w", err)
+		}
+		segs = append(segs, seg)
+	}
+}
+
+// Find cold messages whose content or image text contains query (case-insensitive), newest
+// first. Segments are loaded newest first, and only until no remaining
+// segment can hold a message newer than the limit-th match.
+func (a *Archive) Search(query string, limit int) ([]store.Message, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	segs, err := a.Segments()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].Until.After(segs[j].Until) })
+
+	needle := strings.ToLower(query)
+	seen := map[store.MessageKey]bool{}
+	var matches []store.Message
+	for _, seg := range segs {
+		if len(matches) >= limit && !seg.Until.After(matches[limit-1].Timestamp) {
+			break
+		}
+		err := a.scan(seg.Name, func(m store.Message) {
+			key := store.MessageKey{ID: m.ID, ChatJID: m.ChatJID}
+			if seen[key] || !containsText(m, needle) {
+				return
+			}
+			seen[key] = true
+			matches = append(matches, m)
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].Timestamp.After(matches[j].Timestamp) })
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// Whether a message's content or image text contains the lowercase needle
+func containsText(m store.Message, needle string) bool {
+	return strings.Contains(strings.ToLower(m.Content), needle) || strings.Contains(strings.ToLower(m.OCRText), needle)
+}
+
+// Decode every message in a segment
+func (a *Archive) scan(name string, fn func(store.Message)) error {
+	f, err := os.Open(filepath.Join(a.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("failed to read segment %s: %w", name, err)
+	}
+	defer zr.Close()
+
+	dec := json.NewDecoder(zr)
+	for {
+		var m store.Message
+		err := dec.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read segment %s: %w", name, err)
+		}
+		fn(m)
+	}
+}
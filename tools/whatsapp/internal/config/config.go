@@ -0,0 +1,493 @@
+// Package config loads the logger's optional JSON configuration file.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/phone"
+	"whatsapp-logger/internal/rules"
+)
+
+// DefaultPath is used when KENNY_WA_CONFIG is unset
+const DefaultPath = "kenny_whatsapp.json"
+
+// Config is the whole configuration file. Every section is optional.
+type Config struct {
+	// IANA zone used to print timestamps, e.g. "Europe/Berlin" (default: local)
+	Timezone string `json:"timezone"`
+	// ISO 3166 region for phone numbers written without a country code,
+	// e.g. "GB" (default: none, so bare digits must include the country code)
+	Region        string        `json:"region"`
+	Notifications Notifications `json:"notifications"`
+	API           API           `json:"api"`
+	Backup        Backup        `json:"backup"`
+	QuietHours    QuietHours    `json:"quiet_hours"`
+	SelfCommands  SelfCommands  `json:"self_commands"`
+	Digests       Digests       `json:"digests"`
+	Media         Media         `json:"media"`
+	OCR           OCR           `json:"ocr"`
+	Sending       Sending       `json:"sending"`
+	AutoReplies   AutoReplies   `json:"auto_replies"`
+	PresenceLog   PresenceLog   `json:"presence_log"`
+	Database      Database      `json:"database"`
+	Retention     Retention     `json:"retention"`
+	Webhooks      Webhooks      `json:"webhooks"`
+}
+
+// Retention deletes messages older than their chat's limit, sparing
+// bookmarked ones. `start` enforces it when any limit is set.
+type Retention struct {
+	// Days to keep messages in every chat (0: forever)
+	Days int `json:"days"`
+	// Days to keep messages in group chats and channels, and in direct
+	// chats, overriding Days; 0 keeps them forever
+	GroupDays  *int `json:"group_days"`
+	DirectDays *int `json:"direct_days"`
+	// Days per chat JID, overriding the limits above; 0 keeps a chat forever
+	Chats map[string]int `json:"chats"`
+	// Cron expression in local time (default "30 4 * * *", daily at 04:30)
+	Schedule string `json:"schedule"`
+}
+
+// Whether any chat has a limit
+func (r Retention) Enabled() bool {
+	if r.Days > 0 || (r.GroupDays != nil && *r.GroupDays > 0) || (r.DirectDays != nil && *r.DirectDays > 0) {
+		return true
+	}
+	for _, days := range r.Chats {
+		if days > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Database moves the archive and the WhatsApp session out of their SQLite
+// files, e.g. into PostgreSQL to share them between machines
+type Database struct {
+	// A postgres:// URL; the messages and the session live side by side in
+	// it (default: whatsapp_messages.db and whatsapp_session.db)
+	DSN string `json:"dsn"`
+	// PRAGMA synchronous for the SQLite archive: "OFF", "NORMAL", "FULL"
+	// or "EXTRA" (default "NORMAL")
+	Synchronous string `json:"synchronous"`
+	// Passphrase encrypting message text in the archive; may come from
+	// KENNY_WA_DB_KEY instead of the file
+	Key string `json:"key"`
+	// Command printing the passphrase, e.g. a keyring lookup, run when Key
+	// is unset
+	KeyCommand []string `json:"key_command"`
+}
+
+// PresenceLog opts in to logging when chosen contacts come online and go
+// offline. WhatsApp only reports presence to accounts that are online
+// themselves, so sending.presence should be "available".
+type PresenceLog struct {
+	// Contacts to watch, as JIDs or phone numbers
+	Contacts []string `json:"contacts"`
+}
+
+// Webhooks lets `start` POST live messages to HTTP endpoints as they are
+// stored
+type Webhooks struct {
+	Endpoints []Webhook `json:"endpoints"`
+}
+
+// Webhook delivers the messages its matcher selects to URL, in order,
+// retrying failed deliveries with backoff
+type Webhook struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	rules.Matcher
+	// Key for the HMAC-SHA256 signature of each body, sent in the
+	// X-Kenny-Signature header; empty sends bodies unsigned
+	Secret string `json:"secret"`
+	// Extra request headers, e.g. for authorization
+	Headers map[string]string `json:"headers"`
+	// Attempts per message before it is dropped (default 5)
+	MaxAttempts int `json:"max_attempts"`
+}
+
+// AutoReplies lets `start` answer matching messages with canned replies
+type AutoReplies struct {
+	Rules []AutoReply `json:"rules"`
+}
+
+// AutoReply sends Reply to messages its matcher selects, during its windows
+type AutoReply struct {
+	Name string `json:"name"`
+	rules.Matcher
+	// When the rule applies, in the configured time zone; empty means always
+	Windows []QuietWindow `json:"windows"`
+	Reply   string        `json:"reply"`
+	// Minutes before the rule answers the same chat again (default 60)
+	CooldownMinutes int `json:"cooldown_minutes"`
+}
+
+// Sending controls how messages the logger sends look to the other side
+type Sending struct {
+	// Show "typing…" before each text message, for about as long as typing
+	// it would take
+	Typing bool `json:"typing"`
+	// Typing speed in characters per second (default 10)
+	CharsPerSecond float64 `json:"chars_per_second"`
+	// Shortest and longest typing delay in seconds (default 1 and 8)
+	MinDelay float64 `json:"min_delay"`
+	MaxDelay float64 `json:"max_delay"`
+	// Presence set on connect: "available", "unavailable", or "" to leave it
+	Presence string `json:"presence"`
+	// Hold text messages from `send`, `outbox add` and the API until
+	// approved with `approve`; files, polls and broadcasts are refused
+	RequireApproval bool `json:"require_approval"`
+}
+
+// OCR extracts text from downloaded images so searches find it
+type OCR struct {
+	Enabled bool `json:"enabled"`
+	// "tesseract" (default) or "http"
+	Engine string `json:"engine"`
+	// Tesseract binary (default "tesseract") and languages, e.g. "eng+deu"
+	Command   string `json:"command"`
+	Languages string `json:"languages"`
+	// HTTP service that receives the image as the request body and answers
+	// with the text, plain or as {"text": "..."}
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+// Media controls where attachments are kept and which ones `start` downloads
+type Media struct {
+	// Download attachments of live messages as they arrive
+	Download bool `json:"download"`
+	// Directory holding attachments and profile pictures (default "whatsapp_media")
+	Dir string `json:"dir"`
+	// Media types to download: "image", "video", "audio", "document",
+	// "sticker" (default all)
+	Types []string `json:"types"`
+	// Skip attachments larger than this many megabytes (0: no limit)
+	MaxMB int64 `json:"max_mb"`
+	// Per-chat overrides keyed by chat JID
+	Chats map[string]ChatMedia `json:"chats"`
+	// Download view-once photos and videos as they arrive, whatever the rules
+	// above say; they are never downloaded otherwise
+	ViewOnce bool `json:"view_once"`
+	// Keep attachments in this bucket instead of Dir; profile pictures stay in Dir
+	S3        *S3Target      `json:"s3"`
+	Retention MediaRetention `json:"retention"`
+}
+
+// ChatMedia overrides the download settings for one chat; unset fields
+// keep the global value
+type ChatMedia struct {
+	Download *bool    `json:"download"`
+	Types    []string `json:"types"`
+	MaxMB    *int64   `json:"max_mb"`
+}
+
+// MediaRetention deletes downloaded files past a size or age limit, keeping
+// their messages. `start` enforces it when either limit is set.
+type MediaRetention struct {
+	// Total size of downloaded files in gigabytes (0: no limit)
+	MaxGB float64 `json:"max_gb"`
+	// Delete files whose newest message is older than this many days (0: no limit)
+	MaxDays int `json:"max_days"`
+	// Cron expression in local time (default "0 4 * * *", daily at 04:00)
+	Schedule string `json:"schedule"`
+}
+
+// Whether a limit is set
+func (r MediaRetention) Enabled() bool {
+	return r.MaxGB > 0 || r.MaxDays > 0
+}
+
+// Digests configures scheduled summaries sent by `start`
+type Digests struct {
+	Groups []GroupDigest `json:"groups"`
+}
+
+// GroupDigest summarizes each of Chats on a schedule. Every digest covers
+// the time since the previous scheduled run.
+type GroupDigest struct {
+	Chats []string `json:"chats"`
+	// Cron expression in local time (default "0 8 * * *", daily at 08:00)
+	Schedule string `json:"schedule"`
+	// Chat JID to post the digest in; "self" is my own chat
+	SendTo string `json:"send_to"`
+	// Notification sinks to deliver it to as well, e.g. ["telegram"]
+	Sinks []string `json:"sinks"`
+	// Most active participants listed (default 5)
+	Top int `json:"top"`
+	// Also send digests for periods with no activity
+	SendEmpty bool `json:"send_empty"`
+}
+
+// SelfCommands lets `start` act on commands I send to my own chat
+type SelfCommands struct {
+	Enabled bool `json:"enabled"`
+}
+
+// QuietHours holds back notifications during the given windows, in the
+// configured time zone
+type QuietHours struct {
+	Windows []QuietWindow `json:"windows"`
+}
+
+// QuietWindow is quiet from Start to End ("HH:MM") on each of Days. A window
+// ending before it starts runs past midnight into the next day.
+type QuietWindow struct {
+	// Weekdays the window starts on ("mon".."sun"); empty means every day
+	Days  []string `json:"days"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+// Backup configures scheduled encrypted snapshots taken by `start`
+type Backup struct {
+	Enabled bool `json:"enabled"`
+	// Cron expression in local time (default "0 3 * * *", daily at 03:00)
+	Schedule string `json:"schedule"`
+	// Snapshots to retain on the target (default 7); older ones are deleted
+	Keep int `json:"keep"`
+	// Encryption passphrase; KENNY_WA_BACKUP_PASSPHRASE is used when unset
+	Passphrase string `json:"passphrase"`
+	// Exactly one target must be set
+	S3     *S3Target     `json:"s3"`
+	Rclone *RcloneTarget `json:"rclone"`
+	// Local or mounted directory
+	Dir string `json:"dir"`
+}
+
+// S3Target stores snapshots in an S3 or S3-compatible bucket
+type S3Target struct {
+	// Defaults to AWS for Region; set for MinIO, B2, R2 and the like
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// RcloneTarget stores snapshots through the rclone CLI
+type RcloneTarget struct {
+	// Remote and path, e.g. "gdrive:kenny-backups"
+	Remote string `json:"remote"`
+}
+
+// API configures the HTTP server behind `serve` and `start --http`
+type API struct {
+	// Listen address (default 127.0.0.1:8787); use 0.0.0.0:8787 to reach it from the LAN
+	Addr string `json:"addr"`
+	// When both are set, every request needs HTTP basic auth
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Notifications controls which live messages raise a notification
+type Notifications struct {
+	Enabled bool `json:"enabled"`
+	// Messages notify when their priority reaches this threshold (default 1)
+	MinPriority int `json:"min_priority"`
+	// Watch rules assign a priority to matching messages
+	Rules []NotifyRule `json:"rules"`
+	// Per-chat overrides keyed by chat JID
+	Chats map[string]ChatNotify `json:"chats"`
+	// Treat chats muted on the phone as muted here, unless Chats has an
+	// entry for them
+	FollowPhoneMutes bool `json:"follow_phone_mutes"`
+	// Sinks used when a rule names none (default ["desktop"])
+	DefaultSinks []string `json:"default_sinks"`
+	// Credentials for push sinks
+	Sinks Sinks `json:"sinks"`
+	// Notifications at or above this priority ignore quiet hours (0: none do)
+	BreakThroughPriority int `json:"break_through_priority"`
+}
+
+// NotifyRule raises a message to Priority when its matcher applies
+type NotifyRule struct {
+	Name string `json:"name"`
+	rules.Matcher
+	Priority int `json:"priority"`
+	// Sinks to deliver to: "desktop", "ntfy", "pushover", "telegram"
+	Sinks []string `json:"sinks"`
+}
+
+// Sinks configures the push notification destinations
+type Sinks struct {
+	Ntfy     *NtfySink     `json:"ntfy"`
+	Pushover *PushoverSink `json:"pushover"`
+	Telegram *TelegramSink `json:"telegram"`
+}
+
+// NtfySink publishes to an ntfy topic
+type NtfySink struct {
+	// Server base URL (default https://ntfy.sh)
+	URL   string `json:"url"`
+	Topic string `json:"topic"`
+	// Optional access token for protected topics
+	Token string `json:"token"`
+}
+
+// PushoverSink sends through the Pushover API
+type PushoverSink struct {
+	Token string `json:"token"`
+	User  string `json:"user"`
+}
+
+// TelegramSink sends through a Telegram bot
+type TelegramSink struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+}
+
+// ChatNotify overrides notification behaviour for a single chat
+type ChatNotify struct {
+	// Never notify for this chat
+	Mute bool `json:"mute"`
+	// Notify for every message in this chat regardless of rules
+	Always bool `json:"always"`
+	// Replaces the global threshold for this chat when set
+	MinPriority int `json:"min_priority"`
+}
+
+// Load the file named by KENNY_WA_CONFIG, or DefaultPath. A missing file yields defaults.
+func Load() (*Config, error) {
+	path := os.Getenv("KENNY_WA_CONFIG")
+	if path == "" {
+		path = DefaultPath
+	}
+	return LoadFile(path)
+}
+
+// Load a specific configuration file. A missing file yields defaults.
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg.withDefaults(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return cfg.withDefaults(), nil
+}
+
+// The configured display time zone, or time.Local when unset
+func (c *Config) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+	}
+	return loc, nil
+}
+
+// Fill in defaults for unset values
+func (c *Config) withDefaults() *Config {
+	if c.Notifications.MinPriority == 0 {
+		c.Notifications.MinPriority = 1
+	}
+	if c.API.Addr == "" {
+		c.API.Addr = "127.0.0.1:8787"
+	}
+	if len(c.Notifications.DefaultSinks) == 0 {
+		c.Notifications.DefaultSinks = []string{"desktop"}
+	}
+	if c.Media.Dir == "" {
+		c.Media.Dir = "whatsapp_media"
+	}
+	if c.Media.Retention.Schedule == "" {
+		c.Media.Retention.Schedule = "0 4 * * *"
+	}
+	if c.Sending.CharsPerSecond <= 0 {
+		c.Sending.CharsPerSecond = 10
+	}
+	if c.Sending.MinDelay == 0 {
+		c.Sending.MinDelay = 1
+	}
+	if c.Sending.MaxDelay == 0 {
+		c.Sending.MaxDelay = 8
+	}
+	if c.Retention.Schedule == "" {
+		c.Retention.Schedule = "30 4 * * *"
+	}
+	if c.Backup.Schedule == "" {
+		c.Backup.Schedule = "0 3 * * *"
+	}
+	if c.Backup.Keep == 0 {
+		c.Backup.Keep = 7
+	}
+	if c.Backup.Passphrase == "" {
+		c.Backup.Passphrase = os.Getenv("KENNY_WA_BACKUP_PASSPHRASE")
+	}
+	if c.Database.Key == "" {
+		c.Database.Key = os.Getenv("KENNY_WA_DB_KEY")
+	}
+	for i := range c.Digests.Groups {
+		if c.Digests.Groups[i].Schedule == "" {
+			c.Digests.Groups[i].Schedule = "0 8 * * *"
+		}
+	}
+	for i := range c.AutoReplies.Rules {
+		if c.AutoReplies.Rules[i].CooldownMinutes == 0 {
+			c.AutoReplies.Rules[i].CooldownMinutes = 60
+		}
+		c.AutoReplies.Rules[i].Senders = c.normalizeSenders(c.AutoReplies.Rules[i].Senders)
+	}
+	for i := range c.Webhooks.Endpoints {
+		if c.Webhooks.Endpoints[i].MaxAttempts == 0 {
+			c.Webhooks.Endpoints[i].MaxAttempts = 5
+		}
+		c.Webhooks.Endpoints[i].Senders = c.normalizeSenders(c.Webhooks.Endpoints[i].Senders)
+	}
+	c.PresenceLog.Contacts = c.normalizeContacts(c.PresenceLog.Contacts)
+	for i := range c.Notifications.Rules {
+		if c.Notifications.Rules[i].Priority == 0 {
+			c.Notifications.Rules[i].Priority = 1
+		}
+		c.Notifications.Rules[i].Senders = c.normalizeSenders(c.Notifications.Rules[i].Senders)
+	}
+	return c
+}
+
+// Rewrite phone numbers written in any format to JIDs; JIDs and numbers
+// that cannot be read pass through
+func (c *Config) normalizeContacts(contacts []string) []string {
+	out := make([]string, len(contacts))
+	for i, s := range contacts {
+		out[i] = s
+		if strings.Contains(s, "@") {
+			continue
+		}
+		if e164, err := phone.Normalize(s, c.Region); err == nil {
+			out[i] = phone.JID(e164)
+		}
+	}
+	return out
+}
+
+// Rewrite phone numbers written in any format to the digits of a JID user
+// part, which is what matchers compare against; JIDs pass through
+func (c *Config) normalizeSenders(senders []string) []string {
+	out := make([]string, len(senders))
+	for i, s := range senders {
+		out[i] = s
+		if strings.Contains(s, "@") {
+			continue
+		}
+		if e164, err := phone.Normalize(s, c.Region); err == nil {
+			out[i] = strings.TrimPrefix(e164, "+")
+		}
+	}
+	return out
+}
@@ -0,0 +1,234 @@
+// Package digest builds scheduled summaries of chat activity.
+package digest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Participants listed when no other number is asked for
+const defaultTop = 5
+
+// Activity in one group over a period
+type GroupReport struct {
+	ChatJID  string    `json:"chat_jid"`
+	Name     string    `json:"name"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Messages int       `json:"messages"`
+	// Most active senders, busiest first
+	Participants []Participant `json:"participants"`
+	// Members at the end of the period; 0 until the group is synced
+	Members int      `json:"members,omitempty"`
+	Joined  []string `json:"joined"`
+	Left    []string `json:"left"`
+	// Messages pinned during the period that are still pinned
+	Pinned []store.Message `json:"pinned"`
+	// Description changes, oldest first
+	Topics []string `json:"topics"`
+	// "on" or "off" when only-admins-can-send was switched during the period
+	Announce string `json:"announce,omitempty"`
+}
+
+// A sender and how many messages they sent
+type Participant struct {
+	JID      string `json:"jid"`
+	Name     string `json:"name"`
+	Messages int    `json:"messages"`
+}
+
+// Summarize a group's messages and events with since <= time < until,
+// listing the top most active participants (default 5)
+func Group(st store.Store, chatJID string, since, until time.Time, top int) (*GroupReport, error) {
+	if top <= 0 {
+		top = defaultTop
+	}
+	r := &GroupReport{
+		ChatJID: chatJID, Name: chatJID, Since: since, Until: until,
+		Participants: []Participant{}, Joined: []string{}, Left: []string{}, Pinned: []store.Message{}, Topics: []string{},
+	}
+
+	chats, err := st.ListChats()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range chats {
+		if c.JID == chatJID && c.Name != "" {
+			r.Name = c.Name
+		}
+	}
+
+	counts := map[string]int{}
+	err = st.ForEachMessage(since, until, func(m store.Message) error {
+		// Group changes are reported from chat events instead
+		if m.ChatJID != chatJID || m.System != nil {
+			return nil
+		}
+		r.Messages++
+		if !m.IsFromMe {
+			counts[userJID(m.Sender)]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for jid, n := range counts {
+		r.Participants = append(r.Participants, Participant{JID: jid, Messages: n})
+	}
+	sort.Slice(r.Participants, func(i, j int) bool {
+		if r.Participants[i].Messages != r.Participants[j].Messages {
+			return r.Participants[i].Messages > r.Participants[j].Messages
+		}
+		return r.Participants[i].JID < r.Participants[j].JID
+	})
+	if len(r.Participants) > top {
+		r.Participants = r.Participants[:top]
+	}
+	for i := range r.Participants {
+		if r.Participants[i].Name, err = displayName(st, r.Participants[i].JID); err != nil {
+			return nil, err
+		}
+	}
+
+	events, err := st.ChatEvents(chatJID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	var pinned []string
+	for _, e := range events {
+		switch e.Kind {
+		case store.EventJoin, store.EventLeave:
+			name, err := displayName(st, e.Subject)
+			if err != nil {
+				return nil, err
+			}
+			if e.Kind == store.EventJoin {
+				r.Joined = append(r.Joined, name)
+			} else {
+				r.Left = append(r.Left, name)
+			}
+		case store.EventPin:
+			pinned = append(pinned, e.Subject)
+		case store.EventUnpin:
+			pinned = remove(pinned, e.Subject)
+		case store.EventTopic:
+			r.Topics = append(r.Topics, e.Subject)
+		case store.EventAnnounce:
+			r.Announce = e.Subject
+		}
+	}
+	for _, id := range pinned {
+		m, err := st.GetMessage(store.MessageKey{ID: id, ChatJID: chatJID})
+		if errors.Is(err, store.ErrMessageNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.Pinned = append(r.Pinned, m)
+	}
+
+	members, err := st.GroupMembersAt(chatJID, until)
+	if err != nil && !errors.Is(err, store.ErrChatNotFound) {
+		return nil, err
+	}
+	r.Members = len(members)
+
+	if r.Messages == 0 && len(events) == 0 {
+		if _, err := st.QueryMessages(chatJID, 1); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Whether nothing happened in the period
+func (r *GroupReport) Empty() bool {
+	return r.Messages == 0 && len(r.Joined) == 0 && len(r.Left) == 0 && len(r.Pinned) == 0 &&
+		len(r.Topics) == 0 && r.Announce == ""
+}
+
+// Render the report as a short plain-text message
+func (r *GroupReport) Text(loc *time.Location) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d messages since %s\n", r.Name, r.Messages, r.Since.In(loc).Format("Mon 02 Jan 15:04"))
+	if r.Members > 0 {
+		fmt.Fprintf(&b, "Members: %d\n", r.Members)
+	}
+	if len(r.Participants) > 0 {
+		parts := make([]string, len(r.Participants))
+		for i, p := range r.Participants {
+			parts[i] = fmt.Sprintf("%s (%d)", p.Name, p.Messages)
+		}
+		fmt.Fprintf(&b, "Most active: %s\n", strings.Join(parts, ", "))
+	}
+	if len(r.Joined) > 0 {
+		fmt.Fprintf(&b, "Joined: %s\n", strings.Join(r.Joined, ", "))
+	}
+	if len(r.Left) > 0 {
+		fmt.Fprintf(&b, "Left: %s\n", strings.Join(r.Left, ", "))
+	}
+	for _, m := range r.Pinned {
+		fmt.Fprintf(&b, "Pinned: %s\n", strings.TrimSpace(m.Content))
+	}
+	for _, t := range r.Topics {
+		fmt.Fprintf(&b, "New description: %s\n", t)
+	}
+	switch r.Announce {
+	case "on":
+		b.WriteString("Only admins can send messages now\n")
+	case "off":
+		b.WriteString("Everyone can send messages again\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// The latest push name seen for jid, or its phone number
+func displayName(st store.Store, jid string) (string, error) {
+	names, err := st.ContactNames(jid)
+	if err != nil {
+		return "", err
+	}
+	latest := ""
+	var seen time.Time
+	for _, n := range names {
+		if n.LastSeen.After(seen) || latest == "" {
+			latest, seen = n.Name, n.LastSeen
+		}
+	}
+	if latest != "" {
+		return latest, nil
+	}
+	user, _, _ := strings.Cut(jid, "@")
+	if user != "" && strings.Trim(user, "0123456789") == "" {
+		return "+" + user, nil
+	}
+	return jid, nil
+}
+
+// Sender JID without device suffix; history-synced senders may be stored
+// as the bare user
+func userJID(sender string) string {
+	user, server, found := strings.Cut(sender, "@")
+	user, _, _ = strings.Cut(user, ":")
+	if !found {
+		server = "s.whatsapp.net"
+	}
+	return user + "@" + server
+}
+
+func remove(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package digest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Characters of the newest waiting message shown per chat
+const previewLength = 80
+
+// Conversations I've left hanging
+type UnrepliedReport struct {
+	Since time.Time `json:"since"`
+	// Only chats waiting on me since before this are listed
+	Before time.Time `json:"before"`
+	// Waiting longest first
+	Chats []store.UnrepliedChat `json:"chats"`
+}
+
+// Find the chats where messages to me since since, in direct chats or
+// mentioning or replying to me in groups, have gone unanswered for longer
+// than wait
+func Unreplied(st store.Store, since time.Time, wait time.Duration) (*UnrepliedReport, error) {
+	before := time.Now().Add(-wait)
+	chats, err := st.Unreplied(since, before)
+	if err != nil {
+		return nil, err
+	}
+	return &UnrepliedReport{Since: since, Before: before, Chats: chats}, nil
+}
+
+// Whether nothing is waiting
+func (r *UnrepliedReport) Empty() bool {
+	return len(r.Chats) == 0
+}
+
+// Render the report as a short plain-text message
+func (r *UnrepliedReport) Text(loc *time.Location) string {
+	if r.Empty() {
+		return "Nothing waiting on a reply"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d conversations waiting on a reply:\n", len(r.Chats))
+	for _, c := range r.Chats {
+		name := c.ChatName
+		if name == "" {
+			name = c.ChatJID
+		}
+		who := c.First.SenderName
+		if who == "" {
+			who = c.First.SenderPhone
+		}
+		if who == "" {
+			who = userJID(c.First.Sender)
+		}
+		why := ""
+		switch c.Reason {
+		case store.AwaitingMention:
+			why = who + " mentioned you, "
+		case store.AwaitingReply:
+			why = who + " replied to you, "
+		}
+		count := fmt.Sprintf("%d messages", c.Count)
+		if c.Count == 1 {
+			count = "1 message"
+		}
+		fmt.Fprintf(&b, "- %s: %s%s since %s\n", name, why, count, c.First.Timestamp.In(loc).Format("Mon 02 Jan 15:04"))
+		if text := preview(c.Latest.Content); text != "" {
+			fmt.Fprintf(&b, "  %q\n", text)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Text on one line, cut to previewLength
+func preview(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > previewLength {
+		text = string(r[:previewLength]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Layout of timestamps in CSV cells, which spreadsheets read as dates
+const csvTime = "2006-01-02 15:04:05"
+
+// Value of each column a CSV export can have
+var csvColumns = map[string]func(m store.Message) string{
+	"id":               func(m store.Message) string { return m.ID },
+	"chat_jid":         func(m store.Message) string { return m.ChatJID },
+	"chat_name":        func(m store.Message) string { return m.ChatName },
+	"timestamp":        func(m store.Message) string { return m.Timestamp.Format(csvTime) },
+	"sender":           func(m store.Message) string { return m.Sender },
+	"sender_name":      func(m store.Message) string { return m.SenderName },
+	"sender_phone":     func(m store.Message) string { return m.SenderPhone },
+	"is_from_me":       func(m store.Message) string { return strconv.FormatBool(m.IsFromMe) },
+	"content":          func(m store.Message) string { return m.Content },
+	"media_type":       func(m store.Message) string { return m.MediaType },
+	"filename":         func(m store.Message) string { return m.Filename },
+	"mime_type":        func(m store.Message) string { return m.MimeType },
+	"local_path":       func(m store.Message) string { return m.LocalPath },
+	"reply_to":         func(m store.Message) string { return m.ReplyTo },
+	"is_forwarded":     func(m store.Message) string { return strconv.FormatBool(m.IsForwarded) },
+	"forwarding_score": func(m store.Message) string { return strconv.Itoa(m.ForwardingScore) },
+	"edited_at":        func(m store.Message) string { return csvOptionalTime(m.EditedAt, m.Timestamp.Location()) },
+	"deleted_at":       func(m store.Message) string { return csvOptionalTime(m.DeletedAt, m.Timestamp.Location()) },
+	"ocr_text":         func(m store.Message) string { return m.OCRText },
+	"latitude": func(m store.Message) string {
+		if m.Location == nil {
+			return ""
+		}
+		return strconv.FormatFloat(m.Location.Latitude, 'f', -1, 64)
+	},
+	"longitude": func(m store.Message) string {
+		if m.Location == nil {
+			return ""
+		}
+		return strconv.FormatFloat(m.Location.Longitude, 'f', -1, 64)
+	},
+}
+
+// Columns of a CSV export when none are chosen
+var DefaultCSVColumns = []string{"timestamp", "chat_jid", "chat_name", "sender", "sender_name", "is_from_me", "content", "media_type"}
+
+// CSVColumns lists every column a CSV export can have, in the order they
+// are documented
+var CSVColumns = []string{
+	"id", "chat_jid", "chat_name", "timestamp", "sender", "sender_name", "sender_phone", "is_from_me",
+	"content", "media_type", "filename", "mime_type", "local_path", "reply_to", "is_forwarded",
+	"forwarding_score", "edited_at", "deleted_at", "ocr_text", "latitude", "longitude",
+}
+
+// CSVOptions shape a CSV export
+type CSVOptions struct {
+	RangeOptions
+	// Columns in order, from CSVColumns (default DefaultCSVColumns)
+	Columns []string
+	// Start with a UTF-8 byte order mark, so Excel doesn't misread
+	// non-ASCII text
+	BOM bool
+}
+
+func csvOptionalTime(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(loc).Format(csvTime)
+}
+
+// Write the messages opts selects as CSV with a header row, oldest first.
+// Fields holding commas, quotes or line breaks are quoted as RFC 4180
+// requires. Returns the number of messages written.
+func WriteCSV(w io.Writer, st store.Store, opts CSVOptions) (int, error) {
+	if len(opts.Columns) == 0 {
+		opts.Columns = DefaultCSVColumns
+	}
+	values := make([]func(store.Message) string, len(opts.Columns))
+	for i, col := range opts.Columns {
+		if values[i] = csvColumns[col]; values[i] == nil {
+			return 0, fmt.Errorf("unknown CSV column %q", col)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if opts.BOM {
+		bw.WriteString("\ufeff")
+	}
+	cw := csv.NewWriter(bw)
+	cw.UseCRLF = true
+	if err := cw.Write(opts.Columns); err != nil {
+		return 0, err
+	}
+	record := make([]string, len(values))
+	n, err := eachMessage(st, opts.RangeOptions, func(m store.Message) error {
+		for i, value := range values {
+			record[i] = value(m)
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return n, err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
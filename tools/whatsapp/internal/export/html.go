@@ -0,0 +1,303 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/store"
+)
+
+// HTMLOptions shape an HTML transcript
+type HTMLOptions struct {
+	RangeOptions
+	// Name shown for my own messages (default "Me")
+	Me string
+	// Where downloaded media is kept; images found there are shown inline
+	MediaDir string
+}
+
+const (
+	// Longest side of an inline thumbnail, in pixels
+	thumbnailSize = 320
+	// Images larger than this are not decoded for thumbnails
+	maxThumbnailSource = 20 << 20
+	// Characters of a quoted message shown above a reply
+	quoteLength = 160
+)
+
+// A transcript page
+type htmlPage struct {
+	ChatJID   string
+	ChatName  string
+	Group     bool
+	Messages  int
+	From, To  string
+	Generated string
+	Days      []*htmlDay
+}
+
+// A day's messages, under a date heading
+type htmlDay struct {
+	Date     string
+	Messages []htmlMessage
+}
+
+type htmlMessage struct {
+	ID        string
+	Time      string
+	Sender    string
+	FromMe    bool
+	System    bool
+	Text      string
+	MediaType string
+	Filename  string
+	// data: URL of an inline thumbnail
+	Thumbnail template.URL
+	Quote     *htmlQuote
+	Forwarded bool
+	Edited    bool
+	Deleted   bool
+}
+
+// The message a reply quotes
+type htmlQuote struct {
+	ID     string
+	Sender string
+	Text   string
+	// The quoted message is not in the archive
+	Missing bool
+}
+
+// Write a chat, including chats linked to it, as a self-contained HTML page
+// of message bubbles, with thumbnails of downloaded images embedded so the
+// file can be archived or shared on its own. Returns the number of messages
+// written.
+func WriteHTML(w io.Writer, st store.Store, chatJID string, opts HTMLOptions) (int, error) {
+	if opts.Me == "" {
+		opts.Me = "Me"
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	opts.ChatJID = chatJID
+
+	page := &htmlPage{Group: strings.HasSuffix(chatJID, "@g.us")}
+	quotes := map[string]htmlQuote{}
+	var day *htmlDay
+	n, err := eachMessage(st, opts.RangeOptions, func(m store.Message) error {
+		if page.ChatJID == "" || m.ChatJID == chatJID {
+			page.ChatJID, page.ChatName = m.ChatJID, m.ChatName
+		}
+		date := m.Timestamp.Format("Monday, 2 January 2006")
+		if day == nil || day.Date != date {
+			day = &htmlDay{Date: date}
+			page.Days = append(page.Days, day)
+		}
+		hm := htmlMessage{
+			ID:        m.ID,
+			Time:      m.Timestamp.Format("15:04"),
+			Sender:    senderName(m, opts.Me),
+			FromMe:    m.IsFromMe,
+			System:    m.System != nil,
+			Text:      m.Content,
+			MediaType: m.MediaType,
+			Filename:  m.Filename,
+			Forwarded: m.IsForwarded,
+			Edited:    m.EditedAt != nil,
+			Deleted:   m.DeletedAt != nil,
+		}
+		switch {
+		case hm.System:
+			hm.Text = systemText(m.Content)
+		case m.MediaType != "":
+			hm.Text = mediaCaption(m)
+			if m.MediaType == "image" || m.MediaType == "sticker" {
+				hm.Thumbnail = thumbnail(media.Locate(opts.MediaDir, m))
+			}
+		}
+		if m.ReplyTo != "" {
+			q, ok := quotes[m.ReplyTo]
+			if !ok {
+				q = htmlQuote{ID: m.ReplyTo, Missing: true}
+			}
+			hm.Quote = &q
+		}
+		quotes[m.ID] = htmlQuote{ID: m.ID, Sender: hm.Sender, Text: quoteText(m)}
+		day.Messages = append(day.Messages, hm)
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+	if page.ChatJID == "" {
+		page.ChatJID = chatJID
+	}
+	if page.ChatName == "" {
+		page.ChatName = page.ChatJID
+	}
+	page.Messages = n
+	if !opts.Since.IsZero() {
+		page.From = opts.Since.In(opts.Location).Format("2 January 2006")
+	}
+	if !opts.Until.IsZero() {
+		page.To = opts.Until.In(opts.Location).Format("2 January 2006")
+	}
+	page.Generated = time.Now().In(opts.Location).Format("2 January 2006 15:04 MST")
+
+	bw := bufio.NewWriter(w)
+	if err := htmlTemplate.Execute(bw, page); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// A message shortened to a line for quoting above a reply
+func quoteText(m store.Message) string {
+	text := m.Content
+	if m.System != nil {
+		text = systemText(text)
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > quoteLength {
+		text = string(r[:quoteLength]) + "…"
+	}
+	return text
+}
+
+// A JPEG data URL of the image at path, scaled to fit thumbnailSize, or ""
+// when it is remote, missing or not an image Go can decode
+func thumbnail(path string) template.URL {
+	if path == "" || media.IsRemote(path) {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() > maxThumbnailSource {
+		return ""
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(img, thumbnailSize), &jpeg.Options{Quality: 75}); err != nil {
+		return ""
+	}
+	return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+// Shrink img to fit a size×size square by averaging the pixels each
+// output pixel covers. Images already small enough are returned as is.
+func scaleDown(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	out := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+			i := out.PixOffset(x, y)
+			out.Pix[i] = uint8(r / count >> 8)
+			out.Pix[i+1] = uint8(g / count >> 8)
+			out.Pix[i+2] = uint8(bl / count >> 8)
+			out.Pix[i+3] = uint8(a / count >> 8)
+		}
+	}
+	return out
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="kenny-whatsapp">
+<title>{{.ChatName}} – WhatsApp transcript</title>
+<style>
+body { margin: 0; background: #efeae2; color: #111b21; font: 14px/1.4 -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; }
+header { background: #075e54; color: #fff; padding: 16px 24px; }
+header h1 { margin: 0 0 4px; font-size: 20px; }
+header p { margin: 0; opacity: .85; font-size: 13px; }
+main { max-width: 820px; margin: 0 auto; padding: 12px 16px 32px; }
+.day { text-align: center; margin: 16px 0 8px; }
+.day span, .system { display: inline-block; background: #fff; border-radius: 8px; padding: 4px 12px; font-size: 12px; color: #54656f; box-shadow: 0 1px .5px rgba(0,0,0,.13); }
+.row { display: flex; margin: 2px 0; }
+.row.me { justify-content: flex-end; }
+.row.center { justify-content: center; }
+.bubble { max-width: 75%; background: #fff; border-radius: 8px; padding: 6px 8px 4px; box-shadow: 0 1px .5px rgba(0,0,0,.13); overflow-wrap: anywhere; }
+.me .bubble { background: #d9fdd3; }
+.sender { font-weight: 600; font-size: 13px; color: #1f7aad; }
+.forwarded { font-style: italic; font-size: 12px; color: #667781; }
+.quote { border-left: 4px solid #06cf9c; background: rgba(0,0,0,.05); border-radius: 4px; padding: 4px 8px; margin: 2px 0 4px; font-size: 13px; color: #54656f; text-decoration: none; display: block; }
+.quote b { color: #06a67e; display: block; }
+.text { white-space: pre-wrap; }
+.deleted .text { color: #667781; font-style: italic; }
+.media { display: block; max-width: 100%; border-radius: 6px; margin: 2px 0; }
+.placeholder { color: #54656f; font-style: italic; }
+.meta { text-align: right; font-size: 11px; color: #667781; margin-top: 2px; }
+footer { text-align: center; font-size: 12px; color: #667781; padding-bottom: 24px; }
+@media print { body { background: #fff; } header { background: none; color: #000; } .bubble, .day span, .system { box-shadow: none; border: 1px solid #ccc; } }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.ChatName}}</h1>
+<p>{{.ChatJID}} · {{.Messages}} messages{{if .From}} · from {{.From}}{{end}}{{if .To}} · before {{.To}}{{end}}</p>
+</header>
+<main>
+{{- range .Days}}
+<div class="day"><span>{{.Date}}</span></div>
+{{- range .Messages}}
+{{- if .System}}
+<div class="row center" id="m-{{.ID}}"><span class="system">{{.Text}} · {{.Time}}</span></div>
+{{- else}}
+<div class="row{{if .FromMe}} me{{end}}{{if .Deleted}} deleted{{end}}" id="m-{{.ID}}">
+<div class="bubble">
+{{- if and $.Group (not .FromMe)}}<div class="sender">{{.Sender}}</div>{{end}}
+{{- if .Forwarded}}<div class="forwarded">Forwarded</div>{{end}}
+{{- with .Quote}}{{if .Missing}}<div class="quote">Replying to a message not in this archive</div>{{else}}<a class="quote" href="#m-{{.ID}}"><b>{{.Sender}}</b>{{.Text}}</a>{{end}}{{end}}
+{{- if .Thumbnail}}<img class="media" src="{{.Thumbnail}}" alt="{{.MediaType}}">
+{{- else if .MediaType}}<div class="placeholder">{{.MediaType}}{{with .Filename}}: {{.}}{{end}}</div>{{end}}
+{{- if .Text}}<div class="text">{{.Text}}</div>{{end}}
+<div class="meta">{{if .Edited}}edited · {{end}}{{if .Deleted}}deleted · {{end}}{{.Time}}</div>
+</div>
+</div>
+{{- end}}
+{{- end}}
+{{- end}}
+</main>
+<footer>Exported by kenny-whatsapp on {{.Generated}}</footer>
+</body>
+</html>
+`))
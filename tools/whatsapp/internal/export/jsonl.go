@@ -0,0 +1,24 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Write the messages opts selects as JSON Lines, one message with all its
+// metadata per line, oldest first. Returns the number of messages written.
+func WriteJSONL(w io.Writer, st store.Store, opts RangeOptions) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	n, err := eachMessage(st, opts, func(m store.Message) error {
+		return enc.Encode(m)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
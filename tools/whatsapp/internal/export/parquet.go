@@ -0,0 +1,204 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Rows buffered before they are handed to the Parquet writer
+const parquetBatch = 1024
+
+// One message as a Parquet row. Timestamps are stored as UTC instants;
+// optional strings holding "" and nil pointers are written as null.
+type parquetRow struct {
+	ID              string     `parquet:"id"`
+	ChatJID         string     `parquet:"chat_jid"`
+	ChatName        string     `parquet:"chat_name,optional"`
+	Timestamp       time.Time  `parquet:"timestamp,timestamp(millisecond)"`
+	Sender          string     `parquet:"sender,optional"`
+	SenderName      string     `parquet:"sender_name,optional"`
+	SenderPhone     string     `parquet:"sender_phone,optional"`
+	IsFromMe        bool       `parquet:"is_from_me"`
+	Content         string     `parquet:"content,optional"`
+	MediaType       string     `parquet:"media_type,optional"`
+	Filename        string     `parquet:"filename,optional"`
+	MimeType        string     `parquet:"mime_type,optional"`
+	LocalPath       string     `parquet:"local_path,optional"`
+	ReplyTo         string     `parquet:"reply_to,optional"`
+	IsForwarded     bool       `parquet:"is_forwarded"`
+	ForwardingScore int32      `parquet:"forwarding_score"`
+	EditedAt        *time.Time `parquet:"edited_at,optional"`
+	DeletedAt       *time.Time `parquet:"deleted_at,optional"`
+	OCRText         string     `parquet:"ocr_text,optional"`
+	Latitude        *float64   `parquet:"latitude,optional"`
+	Longitude       *float64   `parquet:"longitude,optional"`
+}
+
+func newParquetRow(m store.Message) parquetRow {
+	row := parquetRow{
+		ID:              m.ID,
+		ChatJID:         m.ChatJID,
+		ChatName:        m.ChatName,
+		Timestamp:       m.Timestamp.UTC(),
+		Sender:          m.Sender,
+		SenderName:      m.SenderName,
+		SenderPhone:     m.SenderPhone,
+		IsFromMe:        m.IsFromMe,
+		Content:         m.Content,
+		MediaType:       m.MediaType,
+		Filename:        m.Filename,
+		MimeType:        m.MimeType,
+		LocalPath:       m.LocalPath,
+		ReplyTo:         m.ReplyTo,
+		IsForwarded:     m.IsForwarded,
+		ForwardingScore: int32(m.ForwardingScore),
+		EditedAt:        m.EditedAt,
+		DeletedAt:       m.DeletedAt,
+		OCRText:         m.OCRText,
+	}
+	if m.Location != nil {
+		row.Latitude = &m.Location.Latitude
+		row.Longitude = &m.Location.Longitude
+	}
+	return row
+}
+
+// ParquetResult describes a finished Parquet export
+type ParquetResult struct {
+	Messages int
+	// Partitions written, as YYYY-MM
+	Months []string
+}
+
+// One month's file being written
+type parquetPartition struct {
+	month  string
+	path   string
+	file   *os.File
+	writer *parquet.GenericWriter[parquetRow]
+	rows   []parquetRow
+}
+
+func (p *parquetPartition) flush() error {
+	if len(p.rows) == 0 {
+		return nil
+	}
+	_, err := p.writer.Write(p.rows)
+	p.rows = p.rows[:0]
+	return err
+}
+
+// Finish the file and move it into place, replacing an earlier export of
+// the month
+func (p *parquetPartition) close() error {
+	err := p.flush()
+	if cerr := p.writer.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := p.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(p.file.Name(), p.path)
+	}
+	if err != nil {
+		os.Remove(p.file.Name())
+	}
+	return err
+}
+
+// Drop the unfinished file, leaving any earlier export of the month as it
+// was
+func (p *parquetPartition) abort() {
+	p.file.Close()
+	os.Remove(p.file.Name())
+}
+
+// Write the messages opts selects under dir as Parquet, one zstd-compressed
+// file per calendar month in opts.Location, in Hive-style partitions:
+//
+//	dir/month=2024-03/messages.parquet
+//
+// DuckDB reads them with read_parquet('dir/*/*.parquet', hive_partitioning
+// = true), and Spark with spark.read.parquet(dir). Since and Until are
+// widened to whole months, so each partition written holds its month
+// complete and a later export can refresh single months. Messages are read
+// oldest first in one pass, so only one month is held open at a time.
+func WriteParquet(dir string, st store.Store, opts RangeOptions) (ParquetResult, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if !opts.Since.IsZero() {
+		opts.Since = monthStart(opts.Since.In(opts.Location))
+	}
+	if !opts.Until.IsZero() {
+		if until := opts.Until.In(opts.Location); !until.Equal(monthStart(until)) {
+			opts.Until = monthStart(until).AddDate(0, 1, 0)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return ParquetResult{}, err
+	}
+
+	var result ParquetResult
+	var part *parquetPartition
+	n, err := eachMessage(st, opts, func(m store.Message) error {
+		month := m.Timestamp.Format("2006-01")
+		if part == nil || part.month != month {
+			if part != nil {
+				err := part.close()
+				part = nil
+				if err != nil {
+					return err
+				}
+			}
+			var err error
+			if part, err = openParquetPartition(dir, month); err != nil {
+				return err
+			}
+			result.Months = append(result.Months, month)
+		}
+		part.rows = append(part.rows, newParquetRow(m))
+		if len(part.rows) == parquetBatch {
+			return part.flush()
+		}
+		return nil
+	})
+	result.Messages = n
+	if part != nil {
+		if err != nil {
+			part.abort()
+			return result, err
+		}
+		err = part.close()
+	}
+	return result, err
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func openParquetPartition(dir, month string) (*parquetPartition, error) {
+	partDir := filepath.Join(dir, "month="+month)
+	if err := os.MkdirAll(partDir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(partDir, ".messages-*.parquet")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create partition %s: %w", month, err)
+	}
+	return &parquetPartition{
+		month:  month,
+		path:   filepath.Join(partDir, "messages.parquet"),
+		file:   f,
+		writer: parquet.NewGenericWriter[parquetRow](f, parquet.Compression(&parquet.Zstd)),
+		rows:   make([]parquetRow, 0, parquetBatch),
+	}, nil
+}
@@ -0,0 +1,262 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+
+	"whatsapp-logger/internal/store"
+)
+
+// PDFOptions shape a PDF transcript
+type PDFOptions struct {
+	RangeOptions
+	// Name shown for my own messages (default "Me")
+	Me string
+	// TrueType font to write text in. Empty looks for a system font with
+	// wide Unicode coverage, falling back to Helvetica, which only has
+	// Western European letters.
+	Font string
+}
+
+// Fonts tried, in order, when PDFOptions.Font is empty
+var pdfFonts = []string{
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+	"/System/Library/Fonts/Supplemental/Arial Unicode.ttf",
+	"/Library/Fonts/Arial Unicode.ttf",
+	`C:\Windows\Fonts\arial.ttf`,
+}
+
+const (
+	pdfFamily   = "body"
+	pdfTextSize = 10
+	pdfLine     = 4.6
+	// Indent of message text under its heading line, in mm
+	pdfIndent = 4
+)
+
+// Write a chat, including chats linked to it, as a paginated PDF record:
+// a cover block naming the chat and the range covered, then every message
+// with its sender, full date and time and the text of the message it
+// replies to. Returns the number of messages written.
+func WritePDF(w io.Writer, st store.Store, chatJID string, opts PDFOptions) (int, error) {
+	if opts.Me == "" {
+		opts.Me = "Me"
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	opts.ChatJID = chatJID
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 18, 15)
+	pdf.SetAutoPageBreak(true, 16)
+	pdf.SetCreator("kenny-whatsapp", true)
+	pdf.AliasNbPages("")
+	text, family, err := pdfFont(pdf, opts.Font)
+	if err != nil {
+		return 0, err
+	}
+
+	chatName := chatJID
+	pdf.SetHeaderFuncMode(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetFont(family, "", 8)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.CellFormat(0, 5, text(chatName), "B", 1, "L", false, 0, "")
+		pdf.Ln(3)
+	}, false)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(family, "", 8)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	type quoted struct{ sender, text string }
+	quotes := map[string]quoted{}
+	width, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	body := width - left - right - pdfIndent
+
+	var messages []store.Message
+	n, err := eachMessage(st, opts.RangeOptions, func(m store.Message) error {
+		if len(messages) == 0 || m.ChatJID == chatJID {
+			chatName = m.ChatName
+		}
+		messages = append(messages, m)
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+	if chatName == "" {
+		chatName = chatJID
+	}
+
+	pdf.AddPage()
+	pdf.SetTitle(chatName+" – WhatsApp transcript", true)
+	pdf.SetFont(family, "B", 16)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.MultiCell(0, 8, text(chatName), "", "L", false)
+	pdf.SetFont(family, "", 9)
+	pdf.SetTextColor(70, 70, 70)
+	covered := "all messages"
+	switch {
+	case !opts.Since.IsZero() && !opts.Until.IsZero():
+		covered = fmt.Sprintf("messages from %s up to %s", opts.Since.In(opts.Location).Format("2 January 2006"), opts.Until.In(opts.Location).Format("2 January 2006"))
+	case !opts.Since.IsZero():
+		covered = "messages from " + opts.Since.In(opts.Location).Format("2 January 2006")
+	case !opts.Until.IsZero():
+		covered = "messages before " + opts.Until.In(opts.Location).Format("2 January 2006")
+	}
+	zone := opts.Location.String()
+	if zone == "Local" {
+		zone, _ = time.Now().In(opts.Location).Zone()
+	}
+	for _, line := range []string{
+		"Chat: " + chatJID,
+		fmt.Sprintf("Covers: %s (%d)", covered, n),
+		"Times in: " + zone,
+		"Exported: " + time.Now().In(opts.Location).Format(timeLayoutPDF),
+	} {
+		pdf.MultiCell(0, pdfLine, text(line), "", "L", false)
+	}
+	pdf.Ln(4)
+
+	day := ""
+	for _, m := range messages {
+		if d := m.Timestamp.Format("Monday, 2 January 2006"); d != day {
+			day = d
+			pdf.Ln(2)
+			pdf.SetFont(family, "B", 9)
+			pdf.SetTextColor(60, 60, 60)
+			pdf.CellFormat(0, 6, text(day), "B", 1, "L", false, 0, "")
+			pdf.Ln(1.5)
+		}
+
+		sender := senderName(m, opts.Me)
+		heading := m.Timestamp.Format("15:04:05") + "  " + sender
+		if m.System != nil {
+			heading = m.Timestamp.Format("15:04:05")
+		}
+		var notes []string
+		if m.IsForwarded {
+			notes = append(notes, "forwarded")
+		}
+		if m.EditedAt != nil {
+			notes = append(notes, "edited "+m.EditedAt.In(opts.Location).Format(timeLayoutPDF))
+		}
+		if m.DeletedAt != nil {
+			notes = append(notes, "deleted "+m.DeletedAt.In(opts.Location).Format(timeLayoutPDF))
+		}
+		if len(notes) > 0 {
+			heading += "  (" + strings.Join(notes, ", ") + ")"
+		}
+		pdf.SetFont(family, "B", pdfTextSize)
+		pdf.SetTextColor(20, 20, 20)
+		pdf.MultiCell(0, pdfLine+0.4, text(heading), "", "L", false)
+
+		pdf.SetLeftMargin(left + pdfIndent)
+		if m.ReplyTo != "" {
+			quote := "Reply to a message not in this archive"
+			if q, ok := quotes[m.ReplyTo]; ok {
+				quote = "Reply to " + q.sender + ": " + q.text
+			}
+			pdf.SetFont(family, "", pdfTextSize-1)
+			pdf.SetTextColor(100, 100, 100)
+			pdf.SetFillColor(238, 238, 238)
+			pdf.MultiCell(body, pdfLine, text(quote), "L", "L", true)
+		}
+		content := m.Content
+		switch {
+		case m.System != nil:
+			content = systemText(content)
+		case m.MediaType != "":
+			placeholder := "[" + m.MediaType
+			if m.Filename != "" {
+				placeholder += ": " + m.Filename
+			}
+			content = placeholder + "]"
+			if caption := mediaCaption(m); caption != "" {
+				content += "\n" + caption
+			}
+		}
+		pdf.SetFont(family, "", pdfTextSize)
+		pdf.SetTextColor(0, 0, 0)
+		if m.System != nil {
+			pdf.SetTextColor(100, 100, 100)
+		}
+		pdf.MultiCell(body, pdfLine, text(content), "", "L", false)
+		pdf.SetLeftMargin(left)
+		pdf.SetX(left)
+		pdf.Ln(1.8)
+
+		quotes[m.ID] = quoted{sender: sender, text: quoteText(m)}
+		if pdf.Err() {
+			return n, pdf.Error()
+		}
+	}
+	return n, pdf.Output(w)
+}
+
+// Layout of full timestamps in a PDF transcript
+const timeLayoutPDF = "2 January 2006 15:04:05 MST"
+
+// Register the font text is written in. Returns how to prepare text for
+// it and the family to select: Helvetica needs text in its own encoding,
+// which drops what that can't hold.
+func pdfFont(pdf *fpdf.Fpdf, font string) (func(string) string, string, error) {
+	if font == "" {
+		for _, candidate := range pdfFonts {
+			if _, err := os.Stat(candidate); err == nil {
+				font = candidate
+				break
+			}
+		}
+	}
+	if font == "" {
+		return pdf.UnicodeTranslatorFromDescriptor(""), "Helvetica", nil
+	}
+
+	regular, err := os.ReadFile(font)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load font: %w", err)
+	}
+	// A bold face beside the font, as DejaVuSans-Bold.ttf is, else the
+	// regular one for headings too
+	bold, err := os.ReadFile(strings.TrimSuffix(font, filepath.Ext(font)) + "-Bold" + filepath.Ext(font))
+	if err != nil {
+		bold = regular
+	}
+	pdf.AddUTF8FontFromBytes(pdfFamily, "", regular)
+	pdf.AddUTF8FontFromBytes(pdfFamily, "B", bold)
+	if pdf.Err() {
+		return nil, "", fmt.Errorf("failed to load font %s: %w", font, pdf.Error())
+	}
+	return pdfText, pdfFamily, nil
+}
+
+// Text prepared for a TrueType font. The PDF writer only handles the Basic
+// Multilingual Plane, so characters beyond it, most emoji among them, are
+// written as their code points, like [U+1F389], rather than lost.
+func pdfText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xFFFF {
+			fmt.Fprintf(&b, "[U+%X]", r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package export
+
+import (
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// RangeOptions select the messages of an export that covers the whole
+// archive or one chat
+type RangeOptions struct {
+	// Chat JID, including the chats linked to it; empty for every chat
+	ChatJID string
+	// since <= timestamp < until; zero values leave that end open
+	Since, Until time.Time
+	// Zone timestamps are written in (default UTC)
+	Location *time.Location
+}
+
+// Call fn for each message opts selects, oldest first, and check an empty
+// single-chat export is of a known chat. Returns the number of messages.
+func eachMessage(st store.Store, opts RangeOptions, fn func(store.Message) error) (int, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	var inChat map[string]bool
+	if opts.ChatJID != "" {
+		group, err := st.ChatGroup(opts.ChatJID)
+		if err != nil {
+			return 0, err
+		}
+		inChat = map[string]bool{}
+		for _, jid := range group {
+			inChat[jid] = true
+		}
+	}
+
+	count := 0
+	err := st.ForEachMessage(opts.Since, opts.Until, func(m store.Message) error {
+		if inChat != nil && !inChat[m.ChatJID] {
+			return nil
+		}
+		m.Timestamp = m.Timestamp.In(opts.Location)
+		count++
+		return fn(m)
+	})
+	if err != nil {
+		return count, err
+	}
+	if count == 0 && opts.ChatJID != "" {
+		if _, err := st.QueryMessages(opts.ChatJID, 1); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
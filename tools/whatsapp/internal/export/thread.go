@@ -0,0 +1,98 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// ThreadOptions shape a threaded JSON export
+type ThreadOptions struct {
+	// Zone timestamps are written in (default UTC)
+	Location *time.Location
+	// Indent nested output for reading
+	Indent bool
+}
+
+// A chat's messages arranged by reply links
+type ThreadExport struct {
+	ChatJID  string `json:"chat_jid"`
+	ChatName string `json:"chat_name,omitempty"`
+	Messages int    `json:"messages"`
+	// Messages that reply to nothing stored, oldest first
+	Threads []*ThreadNode `json:"threads"`
+}
+
+// A message and the replies to it, oldest first
+type ThreadNode struct {
+	store.Message
+	// Set on a reply whose quoted message is not in the archive, which
+	// therefore starts its own thread
+	ParentMissing bool          `json:"parent_missing,omitempty"`
+	Replies       []*ThreadNode `json:"replies"`
+}
+
+// Build the reply tree of a chat, including chats linked to it
+func Threads(st store.Store, chatJID string, opts ThreadOptions) (*ThreadExport, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	group, err := st.ChatGroup(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	inChat := map[string]bool{}
+	for _, jid := range group {
+		inChat[jid] = true
+	}
+
+	out := &ThreadExport{ChatJID: group[0], Threads: []*ThreadNode{}}
+	byID := map[string]*ThreadNode{}
+	err = st.ForEachMessage(time.Time{}, time.Time{}, func(m store.Message) error {
+		if !inChat[m.ChatJID] {
+			return nil
+		}
+		if out.ChatName == "" || m.ChatJID == out.ChatJID {
+			out.ChatName = m.ChatName
+		}
+		m.Timestamp = m.Timestamp.In(opts.Location)
+		n := &ThreadNode{Message: m, Replies: []*ThreadNode{}}
+		// Messages arrive oldest first, so a reply can only attach to a
+		// message already seen; this also keeps corrupt links from looping
+		if parent := byID[m.ReplyTo]; m.ReplyTo != "" && parent != nil {
+			parent.Replies = append(parent.Replies, n)
+		} else {
+			n.ParentMissing = m.ReplyTo != ""
+			out.Threads = append(out.Threads, n)
+		}
+		if _, dup := byID[m.ID]; !dup {
+			byID[m.ID] = n
+		}
+		out.Messages++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Messages == 0 {
+		if _, err := st.QueryMessages(chatJID, 1); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Write a chat's reply tree as JSON. Returns the number of messages written.
+func WriteThreads(w io.Writer, st store.Store, chatJID string, opts ThreadOptions) (int, error) {
+	t, err := Threads(st, chatJID, opts)
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(w)
+	if opts.Indent {
+		enc.SetIndent("", "  ")
+	}
+	return t.Messages, enc.Encode(t)
+}
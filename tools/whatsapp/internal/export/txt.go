@@ -0,0 +1,187 @@
+// Package export renders archived chats in formats other tools consume.
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Layouts of WhatsApp's own "Export chat" text file
+const (
+	StyleAndroid = "android"
+	StyleIOS     = "ios"
+)
+
+// TextOptions shape a WhatsApp-style text export
+type TextOptions struct {
+	// StyleAndroid (default) or StyleIOS
+	Style string
+	// Name shown for my own messages (default "Me")
+	Me string
+	// Zone timestamps are written in (default local)
+	Location *time.Location
+}
+
+// WhatsApp opens every exported chat with this notice
+const encryptionNotice = "Messages and calls are end-to-end encrypted. No one outside of this chat, not even WhatsApp, can read or listen to them."
+
+// Left-to-right mark iOS puts before system text and placeholders
+const lrm = "\u200e"
+
+// Write a chat, including chats linked to it, in WhatsApp's text export
+// layout. Returns the number of messages written.
+func WriteText(w io.Writer, st store.Store, chatJID string, opts TextOptions) (int, error) {
+	if opts.Style == "" {
+		opts.Style = StyleAndroid
+	}
+	if opts.Style != StyleAndroid && opts.Style != StyleIOS {
+		return 0, fmt.Errorf("unknown text export style %q", opts.Style)
+	}
+	if opts.Me == "" {
+		opts.Me = "Me"
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+
+	group, err := st.ChatGroup(chatJID)
+	if err != nil {
+		return 0, err
+	}
+	inChat := map[string]bool{}
+	for _, jid := range group {
+		inChat[jid] = true
+	}
+
+	bw := bufio.NewWriter(w)
+	count := 0
+	err = st.ForEachMessage(time.Time{}, time.Time{}, func(m store.Message) error {
+		if !inChat[m.ChatJID] {
+			return nil
+		}
+		if count == 0 {
+			writeLine(bw, opts, m.Timestamp, "", encryptionNotice)
+		}
+		if m.System != nil {
+			writeLine(bw, opts, m.Timestamp, "", systemText(m.Content))
+		} else {
+			writeLine(bw, opts, m.Timestamp, senderName(m, opts.Me), textBody(m, opts.Style))
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	if count == 0 {
+		if _, err := st.QueryMessages(chatJID, 1); err != nil {
+			return 0, err
+		}
+	}
+	return count, bw.Flush()
+}
+
+// Write one entry; an empty sender makes it a system line
+func writeLine(w *bufio.Writer, opts TextOptions, ts time.Time, sender, body string) {
+	ts = ts.In(opts.Location)
+	switch opts.Style {
+	case StyleIOS:
+		fmt.Fprintf(w, "[%s] ", ts.Format("02/01/2006, 15:04:05"))
+		if sender == "" {
+			body = lrm + body
+		} else {
+			fmt.Fprintf(w, "%s: ", sender)
+		}
+	default:
+		fmt.Fprintf(w, "%s - ", ts.Format("02/01/2006, 15:04"))
+		if sender != "" {
+			fmt.Fprintf(w, "%s: ", sender)
+		}
+	}
+	// Continuation lines carry no prefix, as in WhatsApp's own exports
+	w.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\r", "\n"))
+	w.WriteString("\n")
+}
+
+// A group change placeholder like "[Group subject changed] Trip" without
+// its brackets, as WhatsApp writes system lines
+func systemText(content string) string {
+	if end := strings.Index(content, "]"); strings.HasPrefix(content, "[") && end > 0 {
+		return content[1:end] + content[end+1:]
+	}
+	return content
+}
+
+// The name WhatsApp would show for the sender
+func senderName(m store.Message, me string) string {
+	if m.IsFromMe {
+		return me
+	}
+	if m.SenderName != "" {
+		return m.SenderName
+	}
+	user, _, _ := strings.Cut(m.Sender, "@")
+	user, _, _ = strings.Cut(user, ":")
+	// In a direct chat the chat is named after the contact
+	if m.Sender == m.ChatJID && m.ChatName != "" && m.ChatName != user && m.ChatName != m.ChatJID {
+		return m.ChatName
+	}
+	// Unsaved numbers appear as international phone numbers
+	if user != "" && strings.Trim(user, "0123456789") == "" {
+		return "+" + user
+	}
+	return m.Sender
+}
+
+// Placeholders WhatsApp writes when exporting without media
+var iosPlaceholders = map[string]string{
+	"image":    "image omitted",
+	"video":    "video omitted",
+	"audio":    "audio omitted",
+	"document": "document omitted",
+	"sticker":  "sticker omitted",
+}
+
+// Message text with this archive's "[Image] caption" placeholders rewritten
+// the way WhatsApp exports media; captions follow on their own line
+func textBody(m store.Message, style string) string {
+	if m.MediaType == "" {
+		return m.Content
+	}
+	caption := mediaCaption(m)
+	placeholder := "<Media omitted>"
+	if style == StyleIOS {
+		placeholder = lrm + iosPlaceholders[m.MediaType]
+		if iosPlaceholders[m.MediaType] == "" {
+			placeholder = lrm + "media omitted"
+		}
+		if m.MediaType == "document" && m.Filename != "" {
+			placeholder = lrm + m.Filename + " " + lrm + "document omitted"
+		}
+	}
+	if caption != "" {
+		return placeholder + "\n" + caption
+	}
+	return placeholder
+}
+
+// The caption of a media message, without the "[Image]" placeholder this
+// archive stores it behind
+func mediaCaption(m store.Message) string {
+	caption := ""
+	if strings.HasPrefix(m.Content, "[") {
+		if end := strings.Index(m.Content, "]"); end >= 0 {
+			caption = strings.TrimSpace(m.Content[end+1:])
+		}
+	}
+	// Documents carry their filename as the caption
+	if m.MediaType == "document" && caption == m.Filename {
+		caption = ""
+	}
+	return caption
+}
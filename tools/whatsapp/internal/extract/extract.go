@@ -0,0 +1,365 @@
+// Package extract turns raw whatsmeow payloads into the flat fields Kenny stores.
+package extract
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-logger/internal/links"
+	"whatsapp-logger/internal/store"
+)
+
+// Extract plain text from a conversation or extended text message
+func Text(m *waE2E.Message) string {
+	if conv := m.GetConversation(); conv != "" {
+		return conv
+	}
+	return m.GetExtendedTextMessage().GetText()
+}
+
+// The context (quote, forwarding) of a text or attachment message, nil for
+// other kinds
+func contextInfo(m *waE2E.Message) *waE2E.ContextInfo {
+	switch {
+	case m.GetExtendedTextMessage() != nil:
+		return m.GetExtendedTextMessage().GetContextInfo()
+	case m.GetImageMessage() != nil:
+		return m.GetImageMessage().GetContextInfo()
+	case m.GetVideoMessage() != nil:
+		return m.GetVideoMessage().GetContextInfo()
+	case m.GetAudioMessage() != nil:
+		return m.GetAudioMessage().GetContextInfo()
+	case m.GetDocumentMessage() != nil:
+		return m.GetDocumentMessage().GetContextInfo()
+	case m.GetStickerMessage() != nil:
+		return m.GetStickerMessage().GetContextInfo()
+	case m.GetLocationMessage() != nil:
+		return m.GetLocationMessage().GetContextInfo()
+	case m.GetContactMessage() != nil:
+		return m.GetContactMessage().GetContextInfo()
+	case m.GetGroupInviteMessage() != nil:
+		return m.GetGroupInviteMessage().GetContextInfo()
+	}
+	return nil
+}
+
+// Extract the ID and sender JID of the message a reply quotes, empty when it
+// quotes none
+func ReplyTo(m *waE2E.Message) (id, sender string) {
+	ctx := contextInfo(m)
+	if ctx.GetStanzaID() == "" {
+		return "", ""
+	}
+	sender = ctx.GetParticipant()
+	if jid, err := types.ParseJID(sender); err == nil && sender != "" {
+		sender = jid.ToNonAD().String()
+	}
+	return ctx.GetStanzaID(), sender
+}
+
+// Extract the JIDs a text or attachment message mentions, without device
+// suffixes
+func Mentions(m *waE2E.Message) []types.JID {
+	var jids []types.JID
+	for _, s := range contextInfo(m).GetMentionedJID() {
+		if jid, err := types.ParseJID(s); err == nil && jid.User != "" {
+			jids = append(jids, jid.ToNonAD())
+		}
+	}
+	return jids
+}
+
+// Report whether a message was forwarded and how many times it had been
+// forwarded before; WhatsApp shows "Forwarded many times" from a score of
+// store.ForwardedManyTimes
+func Forwarded(m *waE2E.Message) (forwarded bool, score int) {
+	ctx := contextInfo(m)
+	return ctx.GetIsForwarded(), int(ctx.GetForwardingScore())
+}
+
+// Extract the target message ID and emoji of a reaction; an empty emoji
+// removes an earlier reaction. ok is false for other messages.
+func Reaction(m *waE2E.Message) (target, emoji string, ok bool) {
+	r := m.GetReactionMessage()
+	if r == nil || r.GetKey().GetID() == "" {
+		return "", "", false
+	}
+	return r.GetKey().GetID(), r.GetText(), true
+}
+
+// Extract the target message ID, new content and edit time (zero when not
+// given) of an edit. ok is false for other messages.
+func Edit(m *waE2E.Message) (target string, edited *waE2E.Message, at time.Time, ok bool) {
+	if inner := m.GetEditedMessage().GetMessage(); inner != nil {
+		m = inner
+	}
+	p := m.GetProtocolMessage()
+	if p.GetType() != waE2E.ProtocolMessage_MESSAGE_EDIT || p.GetEditedMessage() == nil || p.GetKey().GetID() == "" {
+		return "", nil, time.Time{}, false
+	}
+	if ms := p.GetTimestampMS(); ms > 0 {
+		at = time.UnixMilli(ms)
+	}
+	return p.GetKey().GetID(), p.GetEditedMessage(), at, true
+}
+
+// Extract the key of the message a delete-for-everyone removes. ok is false
+// for other messages.
+func Revoke(m *waE2E.Message) (target *waCommon.MessageKey, ok bool) {
+	p := m.GetProtocolMessage()
+	if p.GetType() != waE2E.ProtocolMessage_REVOKE || p.GetKey().GetID() == "" {
+		return nil, false
+	}
+	return p.GetKey(), true
+}
+
+// Extract the link preview WhatsApp attached to a text message, if any
+func LinkPreview(m *waE2E.Message) (url, title, description string) {
+	ext := m.GetExtendedTextMessage()
+	if ext.GetMatchedText() == "" || ext.GetTitle() == "" {
+		return "", "", ""
+	}
+	return ext.GetMatchedText(), ext.GetTitle(), ext.GetDescription()
+}
+
+// Extract the URLs a text message shares, each once, with the link preview
+// attached to the one it describes. Only the link fields are set.
+func Links(m *waE2E.Message) []store.SharedLink {
+	urls := links.Find(Text(m))
+	previewURL, title, description := LinkPreview(m)
+	if previewURL != "" {
+		// WhatsApp previews bare hosts like "example.com/a" that Find skips
+		if !strings.Contains(previewURL, "://") {
+			previewURL = "https://" + previewURL
+		}
+		urls = append(urls, previewURL)
+	}
+
+	var found []store.SharedLink
+	seen := map[string]int{}
+	for _, u := range urls {
+		domain := links.Domain(u)
+		if domain == "" {
+			continue
+		}
+		i, dup := seen[u]
+		if !dup {
+			i = len(found)
+			seen[u] = i
+			found = append(found, store.SharedLink{URL: u, Domain: domain})
+		}
+		if u == previewURL {
+			found[i].Title, found[i].Description = title, description
+		}
+	}
+	return found
+}
+
+// Extract display content, media type and filename from a message.
+// Uses the generated nil-safe getters throughout, so a payload with any
+// optional field missing degrades to a placeholder instead of panicking.
+func Content(m *waE2E.Message) (content, mediaType, filename string) {
+	switch {
+	case m.GetConversation() != "":
+		content = m.GetConversation()
+	case m.GetExtendedTextMessage() != nil:
+		content = m.GetExtendedTextMessage().GetText()
+	case m.GetImageMessage() != nil:
+		content = withCaption("[Image]", m.GetImageMessage().GetCaption())
+		mediaType = "image"
+	case m.GetVideoMessage() != nil:
+		content = withCaption("[Video]", m.GetVideoMessage().GetCaption())
+		mediaType = "video"
+	case m.GetAudioMessage() != nil:
+		content = "[Audio]"
+		mediaType = "audio"
+	case m.GetDocumentMessage() != nil:
+		filename = m.GetDocumentMessage().GetFileName()
+		content = withCaption("[Document]", filename)
+		mediaType = "document"
+	case m.GetStickerMessage() != nil:
+		content = "[Sticker]"
+		mediaType = "sticker"
+	case m.GetLocationMessage() != nil:
+		content = withCaption("[Location]", m.GetLocationMessage().GetName())
+	case m.GetLiveLocationMessage() != nil:
+		content = withCaption("[Live location]", m.GetLiveLocationMessage().GetCaption())
+	case m.GetContactMessage() != nil:
+		content = withCaption("[Contact]", m.GetContactMessage().GetDisplayName())
+	case m.GetContactsArrayMessage() != nil:
+		content = withCaption("[Contacts]", m.GetContactsArrayMessage().GetDisplayName())
+	case pollCreation(m) != nil:
+		content = withCaption("[Poll]", pollCreation(m).GetName())
+	case m.GetGroupInviteMessage() != nil:
+		content = withCaption("[Group invite]", m.GetGroupInviteMessage().GetGroupName())
+	default:
+		var ok bool
+		if content, _, ok = Interactive(m); !ok {
+			content = "[Unknown message type]"
+		}
+	}
+	return content, mediaType, filename
+}
+
+// Extract the place a location or live-location message shares; ok is false
+// for other messages
+func Location(m *waE2E.Message) (l store.Location, ok bool) {
+	if loc := m.GetLocationMessage(); loc != nil {
+		return store.Location{
+			Latitude:  loc.GetDegreesLatitude(),
+			Longitude: loc.GetDegreesLongitude(),
+			Accuracy:  int(loc.GetAccuracyInMeters()),
+			Name:      loc.GetName(),
+			Address:   loc.GetAddress(),
+			Live:      loc.GetIsLive(),
+		}, true
+	}
+	if live := m.GetLiveLocationMessage(); live != nil {
+		return store.Location{
+			Latitude:     live.GetDegreesLatitude(),
+			Longitude:    live.GetDegreesLongitude(),
+			Accuracy:     int(live.GetAccuracyInMeters()),
+			Name:         live.GetCaption(),
+			Live:         true,
+			LiveDuration: int(live.GetTimeOffset()),
+		}, true
+	}
+	return store.Location{}, false
+}
+
+// Extract the group invite a message carries; ok is false for other
+// messages. Only the invite fields are set.
+func GroupInvite(m *waE2E.Message) (inv store.GroupInvite, ok bool) {
+	g := m.GetGroupInviteMessage()
+	if g == nil || g.GetGroupJID() == "" || g.GetInviteCode() == "" {
+		return store.GroupInvite{}, false
+	}
+	inv = store.GroupInvite{
+		GroupJID:  g.GetGroupJID(),
+		GroupName: g.GetGroupName(),
+		Code:      g.GetInviteCode(),
+		Caption:   g.GetCaption(),
+	}
+	if exp := g.GetInviteExpiration(); exp > 0 {
+		at := time.Unix(exp, 0)
+		inv.ExpiresAt = &at
+	}
+	return inv, true
+}
+
+// The poll a message starts, in whichever version of the message it came
+func pollCreation(m *waE2E.Message) *waE2E.PollCreationMessage {
+	switch {
+	case m.GetPollCreationMessage() != nil:
+		return m.GetPollCreationMessage()
+	case m.GetPollCreationMessageV2() != nil:
+		return m.GetPollCreationMessageV2()
+	default:
+		return m.GetPollCreationMessageV3()
+	}
+}
+
+// Extract the question, option texts and how many options a voter may pick
+// (0: any) of a poll. ok is false for other messages.
+func Poll(m *waE2E.Message) (question string, options []string, selectable int, ok bool) {
+	p := pollCreation(m)
+	if p == nil {
+		return "", nil, 0, false
+	}
+	for _, o := range p.GetOptions() {
+		options = append(options, o.GetOptionName())
+	}
+	return p.GetName(), options, int(p.GetSelectableOptionsCount()), true
+}
+
+// Convert the option hashes of a decrypted vote to hex
+func VoteHashes(v *waE2E.PollVoteMessage) []string {
+	hashes := []string{}
+	for _, h := range v.GetSelectedOptions() {
+		hashes = append(hashes, hex.EncodeToString(h))
+	}
+	return hashes
+}
+
+// The getters every downloadable attachment message shares
+type attachment interface {
+	GetURL() string
+	GetDirectPath() string
+	GetMediaKey() []byte
+	GetFileSHA256() []byte
+	GetFileEncSHA256() []byte
+	GetFileLength() uint64
+	GetMimetype() string
+}
+
+// Extract the download metadata of an attachment; ok is false for messages
+// without one
+func Media(m *waE2E.Message) (media store.Media, ok bool) {
+	var a attachment
+	switch {
+	case m.GetImageMessage() != nil:
+		a = m.GetImageMessage()
+	case m.GetVideoMessage() != nil:
+		a = m.GetVideoMessage()
+	case m.GetAudioMessage() != nil:
+		a = m.GetAudioMessage()
+	case m.GetDocumentMessage() != nil:
+		a = m.GetDocumentMessage()
+	case m.GetStickerMessage() != nil:
+		a = m.GetStickerMessage()
+	default:
+		return store.Media{}, false
+	}
+	// Only photos, videos and voice notes can be view-once
+	viewOnce, _ := a.(interface{ GetViewOnce() bool })
+	return store.Media{
+		URL:           a.GetURL(),
+		DirectPath:    a.GetDirectPath(),
+		MediaKey:      a.GetMediaKey(),
+		FileSHA256:    a.GetFileSHA256(),
+		FileEncSHA256: a.GetFileEncSHA256(),
+		FileLength:    int64(a.GetFileLength()),
+		MimeType:      a.GetMimetype(),
+		ViewOnce:      viewOnce != nil && viewOnce.GetViewOnce(),
+	}, true
+}
+
+// Append an optional caption to a media placeholder
+func withCaption(placeholder, caption string) string {
+	if caption == "" {
+		return placeholder
+	}
+	return placeholder + " " + caption
+}
+
+// Determine sender and direction of a history sync message from its key
+func HistorySender(key *waCommon.MessageKey, chat types.JID, ownUser string) (sender string, isFromMe bool) {
+	isFromMe = key.GetFromMe()
+	switch {
+	case !isFromMe && key.GetParticipant() != "":
+		sender = key.GetParticipant()
+	case isFromMe:
+		sender = ownUser
+	default:
+		sender = chat.User
+	}
+	return sender, isFromMe
+}
+
+// Derive a readable chat name from a JID
+func ChatName(jid types.JID) string {
+	if jid.Server == types.GroupServer {
+		user := jid.User
+		if len(user) > 8 {
+			user = user[:8]
+		}
+		return fmt.Sprintf("Group %s", user) // Shortened group name
+	}
+	return jid.User // Individual chat
+}
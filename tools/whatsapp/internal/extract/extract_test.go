@@ -0,0 +1,172 @@
+package extract
+
+import (
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+var knownMediaTypes = map[string]bool{"": true, "image": true, "video": true, "audio": true, "document": true, "sticker": true}
+
+// Arbitrary wire bytes decoded as a message must never crash extraction
+func FuzzExtractContentWire(f *testing.F) {
+	seeds := []*waE2E.Message{
+		{Conversation: proto.String("hello")},
+		{ExtendedTextMessage: &waE2E.ExtendedTextMessage{Text: proto.String("link https://example.com")}},
+		{ExtendedTextMessage: &waE2E.ExtendedTextMessage{}},
+		{ImageMessage: &waE2E.ImageMessage{Caption: proto.String("receipt")}},
+		{VideoMessage: &waE2E.VideoMessage{}},
+		{AudioMessage: &waE2E.AudioMessage{}},
+		{DocumentMessage: &waE2E.DocumentMessage{FileName: proto.String("report.pdf")}},
+		{},
+	}
+	for _, m := range seeds {
+		data, err := proto.Marshal(m)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var m waE2E.Message
+		if err := proto.Unmarshal(data, &m); err != nil {
+			return
+		}
+		content, mediaType, _ := Content(&m)
+		if !knownMediaTypes[mediaType] {
+			t.Fatalf("unexpected media type %q", mediaType)
+		}
+		if mediaType != "" && !strings.HasPrefix(content, "[") {
+			t.Fatalf("media content %q is missing its placeholder", content)
+		}
+		Text(&m)
+	})
+}
+
+// Every combination of present/absent optional pointers must be handled
+func FuzzExtractContentFields(f *testing.F) {
+	f.Add("hi", "caption", "file.pdf", uint16(0))
+	f.Add("", "", "", uint16(0xffff))
+	f.Add("text", "", "notes.txt", uint16(0x0155))
+
+	f.Fuzz(func(t *testing.T, text, caption, filename string, mask uint16) {
+		opt := func(bit uint, v string) *string {
+			if mask&(1<<bit) == 0 {
+				return nil
+			}
+			return proto.String(v)
+		}
+		present := func(bit uint) bool { return mask&(1<<bit) != 0 }
+
+		m := &waE2E.Message{Conversation: opt(0, text)}
+		if present(1) {
+			m.ExtendedTextMessage = &waE2E.ExtendedTextMessage{Text: opt(2, text)}
+		}
+		if present(3) {
+			m.ImageMessage = &waE2E.ImageMessage{Caption: opt(4, caption)}
+		}
+		if present(5) {
+			m.VideoMessage = &waE2E.VideoMessage{Caption: opt(6, caption)}
+		}
+		if present(7) {
+			m.AudioMessage = &waE2E.AudioMessage{}
+		}
+		if present(8) {
+			m.DocumentMessage = &waE2E.DocumentMessage{FileName: opt(9, filename)}
+		}
+
+		content, mediaType, gotFilename := Content(m)
+		if !knownMediaTypes[mediaType] {
+			t.Fatalf("unexpected media type %q", mediaType)
+		}
+		if mediaType != "document" && gotFilename != "" {
+			t.Fatalf("filename %q set for media type %q", gotFilename, mediaType)
+		}
+		if present(0) && text != "" && content != text {
+			t.Fatalf("conversation text %q not preferred, got %q", text, content)
+		}
+		Text(m)
+		Text(nil)
+	})
+}
+
+// Parsed JIDs of any shape must produce a chat name without slicing past the user part
+func FuzzChatName(f *testing.F) {
+	for _, s := range []string{
+		"1234567890@s.whatsapp.net",
+		"120363012345678901@g.us",
+		"1@g.us",
+		"@g.us",
+		"status@broadcast",
+		"123.0:1@s.whatsapp.net",
+		"12345@lid",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		jid, err := types.ParseJID(s)
+		if err != nil {
+			return
+		}
+		name := ChatName(jid)
+		if jid.Server == types.GroupServer && !strings.HasPrefix(name, "Group ") {
+			t.Fatalf("group %q named %q", s, name)
+		}
+	})
+}
+
+// Message keys with any subset of fields set must resolve a sender
+func FuzzHistorySender(f *testing.F) {
+	f.Add("111@s.whatsapp.net", "222@s.whatsapp.net", "me", uint8(0))
+	f.Add("120363012345678901@g.us", "333@s.whatsapp.net", "", uint8(7))
+
+	f.Fuzz(func(t *testing.T, chat, participant, ownUser string, mask uint8) {
+		jid, err := types.ParseJID(chat)
+		if err != nil {
+			return
+		}
+		var key *waCommon.MessageKey
+		if mask&1 != 0 {
+			key = &waCommon.MessageKey{}
+			if mask&2 != 0 {
+				key.FromMe = proto.Bool(mask&4 != 0)
+			}
+			if mask&8 != 0 {
+				key.Participant = proto.String(participant)
+			}
+		}
+		sender, isFromMe := HistorySender(key, jid, ownUser)
+		if isFromMe && sender != ownUser {
+			t.Fatalf("own message attributed to %q", sender)
+		}
+	})
+}
+
+// Mentioned JIDs of any shape must come back without devices or empty users
+func FuzzMentions(f *testing.F) {
+	f.Add("15551234567@s.whatsapp.net", "")
+	f.Add("15551234567:12@s.whatsapp.net", "12345@lid")
+	f.Add("@s.whatsapp.net", "not a jid")
+
+	f.Fuzz(func(t *testing.T, a, b string) {
+		m := &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+			Text:        proto.String("@a @b"),
+			ContextInfo: &waE2E.ContextInfo{MentionedJID: []string{a, b}},
+		}}
+		jids := Mentions(m)
+		if len(jids) > 2 {
+			t.Fatalf("%d mentions from 2 JIDs", len(jids))
+		}
+		for _, jid := range jids {
+			if jid.User == "" || jid.Device != 0 {
+				t.Fatalf("mention %s of %q, %q kept a device or lost its user", jid, a, b)
+			}
+		}
+	})
+}
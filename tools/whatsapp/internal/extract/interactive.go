@@ -0,0 +1,121 @@
+package extract
+
+import (
+	"encoding/json"
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Extract a business message with buttons, a list or a template, or a reply
+// to one: readable text (header, body and footer, then one "- " line per
+// option) and the payload as JSON, keyed by its proto field name. ok is
+// false for other messages.
+func Interactive(m *waE2E.Message) (text string, payload []byte, ok bool) {
+	var only *waE2E.Message
+	switch {
+	case m.GetButtonsMessage() != nil:
+		b := m.GetButtonsMessage()
+		var options []string
+		for _, button := range b.GetButtons() {
+			options = append(options, button.GetButtonText().GetDisplayText())
+		}
+		text = interactiveText("[Buttons]", options, b.GetText(), b.GetContentText(), b.GetFooterText())
+		only = &waE2E.Message{ButtonsMessage: b}
+	case m.GetListMessage() != nil:
+		l := m.GetListMessage()
+		var options []string
+		for _, section := range l.GetSections() {
+			for _, row := range section.GetRows() {
+				options = append(options, withCaption(row.GetTitle(), dash(row.GetDescription())))
+			}
+		}
+		text = interactiveText("[List]", options, l.GetTitle(), l.GetDescription(), l.GetFooterText())
+		only = &waE2E.Message{ListMessage: l}
+	case m.GetTemplateMessage() != nil:
+		t := m.GetTemplateMessage().GetHydratedTemplate()
+		if t == nil {
+			t = m.GetTemplateMessage().GetHydratedFourRowTemplate()
+		}
+		var options []string
+		for _, button := range t.GetHydratedButtons() {
+			switch {
+			case button.GetQuickReplyButton() != nil:
+				options = append(options, button.GetQuickReplyButton().GetDisplayText())
+			case button.GetUrlButton() != nil:
+				options = append(options, withCaption(button.GetUrlButton().GetDisplayText(), dash(button.GetUrlButton().GetURL())))
+			case button.GetCallButton() != nil:
+				options = append(options, withCaption(button.GetCallButton().GetDisplayText(), dash(button.GetCallButton().GetPhoneNumber())))
+			}
+		}
+		text = interactiveText("[Template]", options, t.GetHydratedTitleText(), t.GetHydratedContentText(), t.GetHydratedFooterText())
+		only = &waE2E.Message{TemplateMessage: m.GetTemplateMessage()}
+	case m.GetInteractiveMessage() != nil:
+		i := m.GetInteractiveMessage()
+		var options []string
+		for _, button := range i.GetNativeFlowMessage().GetButtons() {
+			options = append(options, nativeFlowLabel(button))
+		}
+		text = interactiveText("[Interactive]", options, i.GetHeader().GetTitle(), i.GetBody().GetText(), i.GetFooter().GetText())
+		only = &waE2E.Message{InteractiveMessage: i}
+	case m.GetButtonsResponseMessage() != nil:
+		text = withCaption("[Selected]", m.GetButtonsResponseMessage().GetSelectedDisplayText())
+		only = &waE2E.Message{ButtonsResponseMessage: m.GetButtonsResponseMessage()}
+	case m.GetListResponseMessage() != nil:
+		text = withCaption("[Selected]", m.GetListResponseMessage().GetTitle())
+		only = &waE2E.Message{ListResponseMessage: m.GetListResponseMessage()}
+	case m.GetTemplateButtonReplyMessage() != nil:
+		text = withCaption("[Selected]", m.GetTemplateButtonReplyMessage().GetSelectedDisplayText())
+		only = &waE2E.Message{TemplateButtonReplyMessage: m.GetTemplateButtonReplyMessage()}
+	case m.GetInteractiveResponseMessage() != nil:
+		text = withCaption("[Selected]", m.GetInteractiveResponseMessage().GetBody().GetText())
+		only = &waE2E.Message{InteractiveResponseMessage: m.GetInteractiveResponseMessage()}
+	default:
+		return "", nil, false
+	}
+	payload, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(only)
+	if err != nil {
+		payload = nil
+	}
+	return text, payload, true
+}
+
+// Join the non-empty parts of a message on their own lines, then its
+// options; placeholder stands in when there is nothing else
+func interactiveText(placeholder string, options []string, parts ...string) string {
+	var lines []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			lines = append(lines, p)
+		}
+	}
+	for _, o := range options {
+		if o = strings.TrimSpace(o); o != "" {
+			lines = append(lines, "- "+o)
+		}
+	}
+	if len(lines) == 0 {
+		return placeholder
+	}
+	return strings.Join(lines, "\n")
+}
+
+// The label of a native flow button, read from its JSON parameters
+func nativeFlowLabel(b *waE2E.InteractiveMessage_NativeFlowMessage_NativeFlowButton) string {
+	var params struct {
+		DisplayText string `json:"display_text"`
+	}
+	if json.Unmarshal([]byte(b.GetButtonParamsJSON()), &params) == nil && params.DisplayText != "" {
+		return params.DisplayText
+	}
+	return b.GetName()
+}
+
+// s prefixed with a dash, or "" when empty
+func dash(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "- " + s
+}
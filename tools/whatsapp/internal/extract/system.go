@@ -0,0 +1,133 @@
+package extract
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waWeb"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-logger/internal/store"
+)
+
+// System events for the group stub types history sync carries in place of
+// a message
+var stubKinds = map[waWeb.WebMessageInfo_StubType]string{
+	waWeb.WebMessageInfo_GROUP_CHANGE_SUBJECT:                store.SystemSubject,
+	waWeb.WebMessageInfo_GROUP_CHANGE_DESCRIPTION:            store.SystemDescription,
+	waWeb.WebMessageInfo_GROUP_CHANGE_ICON:                   store.SystemIcon,
+	waWeb.WebMessageInfo_GROUP_CHANGE_RESTRICT:               store.SystemLocked,
+	waWeb.WebMessageInfo_GROUP_CHANGE_ANNOUNCE:               store.SystemAnnounce,
+	waWeb.WebMessageInfo_CHANGE_EPHEMERAL_SETTING:            store.SystemEphemeral,
+	waWeb.WebMessageInfo_GROUP_MEMBERSHIP_JOIN_APPROVAL_MODE: store.SystemApproval,
+	waWeb.WebMessageInfo_GROUP_CHANGE_INVITE_LINK:            store.SystemInviteLink,
+	waWeb.WebMessageInfo_GROUP_PARTICIPANT_ADD:               store.SystemJoin,
+	waWeb.WebMessageInfo_GROUP_PARTICIPANT_INVITE:            store.SystemJoin,
+	waWeb.WebMessageInfo_GROUP_PARTICIPANT_REMOVE:            store.SystemLeave,
+	waWeb.WebMessageInfo_GROUP_PARTICIPANT_LEAVE:             store.SystemLeave,
+	waWeb.WebMessageInfo_GROUP_PARTICIPANT_PROMOTE:           store.SystemPromote,
+	waWeb.WebMessageInfo_GROUP_PARTICIPANT_DEMOTE:            store.SystemDemote,
+}
+
+// Extract the group change a history stub records; ok is false for other
+// messages. Stub parameters are positional and undocumented; a missing one
+// leaves the data empty.
+func Stub(info *waWeb.WebMessageInfo) (e store.SystemEvent, ok bool) {
+	kind, ok := stubKinds[info.GetMessageStubType()]
+	if !ok {
+		return store.SystemEvent{}, false
+	}
+	e.Kind = kind
+	params := info.GetMessageStubParameters()
+	first := ""
+	if len(params) > 0 {
+		first = params[0]
+	}
+	switch kind {
+	case store.SystemSubject, store.SystemDescription:
+		e.Data = nonEmpty(kind, first)
+	case store.SystemLocked, store.SystemAnnounce, store.SystemApproval:
+		e.Data = nonEmpty("state", first)
+	case store.SystemEphemeral:
+		e.Data = nonEmpty("timer", first)
+	case store.SystemJoin, store.SystemLeave, store.SystemPromote, store.SystemDemote:
+		var jids []string
+		for _, p := range params {
+			if jid, err := types.ParseJID(p); err == nil {
+				p = jid.ToNonAD().String()
+			}
+			jids = append(jids, p)
+		}
+		e.Data = nonEmpty("participants", strings.Join(jids, ","))
+	}
+	return e, true
+}
+
+// Data holding key, or nil when value is empty
+func nonEmpty(key, value string) map[string]string {
+	if value == "" {
+		return nil
+	}
+	return map[string]string{key: value}
+}
+
+// A placeholder describing a group change, in the style of Content
+func SystemContent(e store.SystemEvent) string {
+	on := e.Data["state"] == "on"
+	switch e.Kind {
+	case store.SystemSubject:
+		return withCaption("[Group subject changed]", e.Data["subject"])
+	case store.SystemDescription:
+		return withCaption("[Group description changed]", e.Data["description"])
+	case store.SystemIcon:
+		if e.Data["removed"] == "true" {
+			return "[Group icon removed]"
+		}
+		return "[Group icon changed]"
+	case store.SystemLocked:
+		if on {
+			return "[Only admins can edit group info]"
+		}
+		return "[All members can edit group info]"
+	case store.SystemAnnounce:
+		if on {
+			return "[Only admins can send messages]"
+		}
+		return "[All members can send messages]"
+	case store.SystemApproval:
+		if on {
+			return "[Admins approve new members]"
+		}
+		return "[Anyone with the link can join]"
+	case store.SystemEphemeral:
+		secs, _ := strconv.Atoi(e.Data["timer"])
+		if secs <= 0 {
+			return "[Disappearing messages off]"
+		}
+		return fmt.Sprintf("[Disappearing messages set to %s]", timerText(time.Duration(secs)*time.Second))
+	case store.SystemInviteLink:
+		return "[Group invite link reset]"
+	case store.SystemJoin:
+		return withCaption("[Joined]", e.Data["participants"])
+	case store.SystemLeave:
+		return withCaption("[Left]", e.Data["participants"])
+	case store.SystemPromote:
+		return withCaption("[Made admin]", e.Data["participants"])
+	case store.SystemDemote:
+		return withCaption("[No longer admin]", e.Data["participants"])
+	}
+	return "[Group changed]"
+}
+
+// Disappearing message timers as WhatsApp names them
+func timerText(d time.Duration) string {
+	switch {
+	case d > 24*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	return d.String()
+}
@@ -0,0 +1,105 @@
+package extract
+
+import (
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+
+	"whatsapp-logger/internal/phone"
+	"whatsapp-logger/internal/store"
+)
+
+// Extract the contact cards a contact or contacts-array message shares; ok
+// is false for other messages. Only the card fields are set.
+func Contacts(m *waE2E.Message) (contacts []store.SharedContact, ok bool) {
+	var cards []*waE2E.ContactMessage
+	switch {
+	case m.GetContactMessage() != nil:
+		cards = []*waE2E.ContactMessage{m.GetContactMessage()}
+	case m.GetContactsArrayMessage() != nil:
+		cards = m.GetContactsArrayMessage().GetContacts()
+	default:
+		return nil, false
+	}
+	for _, card := range cards {
+		name, phones := parseVCard(card.GetVcard())
+		if card.GetDisplayName() != "" {
+			name = card.GetDisplayName()
+		}
+		contacts = append(contacts, store.SharedContact{Name: name, Phones: phones, VCard: card.GetVcard()})
+	}
+	return contacts, true
+}
+
+// Read the formatted name and phone numbers from vCard text. Numbers are
+// E.164 where the card carries a WhatsApp ID or an international number,
+// else as written.
+func parseVCard(vcard string) (name string, phones []string) {
+	var family, given string
+	for _, line := range unfoldVCard(vcard) {
+		prop, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		params := strings.Split(prop, ";")
+		// Drop the "item1." group prefix Apple and WhatsApp put on some lines
+		_, field, grouped := strings.Cut(params[0], ".")
+		if !grouped {
+			field = params[0]
+		}
+		switch strings.ToUpper(field) {
+		case "FN":
+			name = unescapeVCard(value)
+		case "N":
+			parts := strings.Split(value, ";")
+			family = unescapeVCard(parts[0])
+			if len(parts) > 1 {
+				given = unescapeVCard(parts[1])
+			}
+		case "TEL":
+			if number := vcardPhone(params[1:], value); number != "" {
+				phones = append(phones, number)
+			}
+		}
+	}
+	if name == "" {
+		name = strings.TrimSpace(given + " " + family)
+	}
+	return name, phones
+}
+
+// The number of a TEL line, preferring its waid parameter
+func vcardPhone(params []string, value string) string {
+	for _, p := range params {
+		key, waid, _ := strings.Cut(p, "=")
+		if strings.EqualFold(key, "waid") && waid != "" {
+			if e164, err := phone.Normalize("+"+waid, ""); err == nil {
+				return e164
+			}
+		}
+	}
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "tel:"))
+	if strings.HasPrefix(value, "+") {
+		if e164, err := phone.Normalize(value, ""); err == nil {
+			return e164
+		}
+	}
+	return value
+}
+
+// Split vCard text into logical lines, joining folded continuations
+func unfoldVCard(vcard string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(vcard, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func unescapeVCard(s string) string {
+	return strings.TrimSpace(strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s))
+}
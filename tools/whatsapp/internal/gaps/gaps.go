@@ -0,0 +1,117 @@
+// Package gaps finds stretches of silence in otherwise active chats that
+// suggest messages missing from the archive.
+package gaps
+
+import (
+	"sort"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Options tune what counts as a suspicious gap
+type Options struct {
+	// Only this chat when set
+	ChatJID string
+	// Silences shorter than this are never reported (default 72h)
+	MinGap time.Duration
+	// Report a silence only if the chat's average rate predicts at least
+	// this many messages in it (default 20)
+	MinExpected float64
+	// Chats with fewer messages are too sparse to judge (default 50)
+	MinMessages int
+}
+
+// A suspicious silence in a chat
+type Gap struct {
+	ChatJID  string    `json:"chat_jid"`
+	ChatName string    `json:"chat_name,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Days     float64   `json:"days"`
+	// Messages the chat's average rate predicts for the gap
+	Expected float64 `json:"expected"`
+	// First message after the gap, the anchor for a history request
+	After store.Message `json:"after"`
+}
+
+// Running state of one chat during the scan
+type chatScan struct {
+	name        string
+	count       int
+	first, last time.Time
+	prev        time.Time
+	candidates  []Gap
+}
+
+// Scan the archive for suspicious gaps, longest first
+func Find(st store.Store, opts Options) ([]Gap, error) {
+	if opts.MinGap <= 0 {
+		opts.MinGap = 72 * time.Hour
+	}
+	if opts.MinExpected <= 0 {
+		opts.MinExpected = 20
+	}
+	if opts.MinMessages <= 0 {
+		opts.MinMessages = 50
+	}
+
+	chats := map[string]*chatScan{}
+	err := st.ForEachMessage(time.Time{}, time.Time{}, func(m store.Message) error {
+		if opts.ChatJID != "" && m.ChatJID != opts.ChatJID {
+			return nil
+		}
+		c := chats[m.ChatJID]
+		if c == nil {
+			c = &chatScan{first: m.Timestamp}
+			chats[m.ChatJID] = c
+		}
+		if m.ChatName != "" {
+			c.name = m.ChatName
+		}
+		if c.count > 0 && m.Timestamp.Sub(c.prev) >= opts.MinGap {
+			c.candidates = append(c.candidates, Gap{ChatJID: m.ChatJID, Start: c.prev, End: m.Timestamp, After: m})
+		}
+		c.count++
+		c.prev, c.last = m.Timestamp, m.Timestamp
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := []Gap{}
+	for _, c := range chats {
+		if c.count < opts.MinMessages {
+			continue
+		}
+		span := c.last.Sub(c.first)
+		if span <= 0 {
+			continue
+		}
+		// Judge each gap against the rate outside it, so one long silence
+		// does not lower the bar for itself
+		for _, g := range c.candidates {
+			gap := g.End.Sub(g.Start)
+			active := span - gap
+			if active <= 0 {
+				continue
+			}
+			rate := float64(c.count) / active.Hours()
+			g.Expected = rate * gap.Hours()
+			if g.Expected < opts.MinExpected {
+				continue
+			}
+			g.ChatName = c.name
+			g.Days = gap.Hours() / 24
+			found = append(found, g)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Expected != found[j].Expected {
+			return found[i].Expected > found[j].Expected
+		}
+		return found[i].ChatJID < found[j].ChatJID
+	})
+	return found, nil
+}
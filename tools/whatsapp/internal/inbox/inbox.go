@@ -0,0 +1,291 @@
+// Package inbox turns messages I send to my own chat into commands.
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/store"
+)
+
+// How often due reminders are checked for
+const pollInterval = 30 * time.Second
+
+// How long a reply may take to send
+const sendTimeout = 30 * time.Second
+
+// Results listed by /search and /notes
+const listLimit = 5
+
+// Sender delivers replies; *wa.Logger implements it
+type Sender interface {
+	SendText(ctx context.Context, chatJID, text string) error
+	IsSelfChat(chatJID string) bool
+}
+
+// Inbox parses self-chat commands and answers them in the same chat
+type Inbox struct {
+	st   store.Store
+	send Sender
+	loc  *time.Location
+	log  waLog.Logger
+	now  func() time.Time
+}
+
+// Create an inbox that reads and writes st, replying through send, and
+// reads and prints times in loc
+func New(st store.Store, send Sender, loc *time.Location, log waLog.Logger) *Inbox {
+	return &Inbox{st: st, send: send, loc: loc, log: log, now: time.Now}
+}
+
+// Message hook: act on commands I sent to myself
+func (in *Inbox) HandleMessage(m store.Message) {
+	if !m.IsFromMe || !strings.HasPrefix(m.Content, "/") || !in.send.IsSelfChat(m.ChatJID) {
+		return
+	}
+	go func() {
+		reply := in.Execute(m)
+		if err := in.reply(m.ChatJID, reply); err != nil {
+			in.log.Warnf("Failed to answer self-chat command: %v", err)
+		}
+	}()
+}
+
+// Run one command message, returning the reply text
+func (in *Inbox) Execute(m store.Message) string {
+	name, args, _ := strings.Cut(strings.TrimSpace(m.Content), " ")
+	args = strings.TrimSpace(args)
+	var reply string
+	var err error
+	switch strings.ToLower(name) {
+	case "/help":
+		reply = help
+	case "/note":
+		reply, err = in.note(m, args)
+	case "/notes":
+		reply, err = in.notes(m.ChatJID)
+	case "/remind":
+		reply, err = in.remind(m.ChatJID, args)
+	case "/reminders":
+		reply, err = in.reminders()
+	case "/cancel":
+		reply, err = in.cancel(args)
+	case "/search":
+		reply, err = in.search(args)
+	default:
+		reply = fmt.Sprintf("Unknown command %s. Send /help for the list.", name)
+	}
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	return reply
+}
+
+const help = `Commands:
+/note TEXT - save a note
+/notes - latest notes
+/remind WHEN TEXT - WHEN is 20m, 2h, 18:30, tomorrow 9:00 or 2025-06-01 9:00
+/reminders - pending reminders
+/cancel ID - cancel a reminder
+/search TEXT - search the archive`
+
+// Notes are bookmarks on the command message itself
+func (in *Inbox) note(m store.Message, text string) (string, error) {
+	if text == "" {
+		return "Usage: /note TEXT", nil
+	}
+	if err := in.st.SetBookmark(store.MessageKey{ID: m.ID, ChatJID: m.ChatJID}, text); err != nil {
+		return "", err
+	}
+	return "Noted.", nil
+}
+
+func (in *Inbox) notes(chatJID string) (string, error) {
+	bookmarks, err := in.st.ListBookmarks(chatJID)
+	if err != nil {
+		return "", err
+	}
+	if len(bookmarks) == 0 {
+		return "No notes yet.", nil
+	}
+	var b strings.Builder
+	for i, bm := range bookmarks {
+		if i == listLimit {
+			break
+		}
+		fmt.Fprintf(&b, "%s %s\n", bm.BookmarkedAt.In(in.loc).Format("02 Jan 15:04"), bm.Note)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (in *Inbox) remind(chatJID, args string) (string, error) {
+	due, text, err := parseWhen(args, in.now().In(in.loc))
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		return "Usage: /remind WHEN TEXT", nil
+	}
+	id, err := in.st.AddReminder(store.Reminder{ChatJID: chatJID, Text: text, DueAt: due, CreatedAt: in.now()})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Reminder %d set for %s.", id, due.In(in.loc).Format("Mon 02 Jan 15:04")), nil
+}
+
+func (in *Inbox) reminders() (string, error) {
+	pending, err := in.st.ListReminders()
+	if err != nil {
+		return "", err
+	}
+	if len(pending) == 0 {
+		return "No reminders pending.", nil
+	}
+	var b strings.Builder
+	for _, r := range pending {
+		fmt.Fprintf(&b, "%d. %s %s\n", r.ID, r.DueAt.In(in.loc).Format("Mon 02 Jan 15:04"), r.Text)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (in *Inbox) cancel(args string) (string, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(args, "#"), 10, 64)
+	if err != nil {
+		return "Usage: /cancel ID", nil
+	}
+	found, err := in.st.DeleteReminder(id)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return fmt.Sprintf("No reminder %d.", id), nil
+	}
+	return fmt.Sprintf("Reminder %d cancelled.", id), nil
+}
+
+func (in *Inbox) search(query string) (string, error) {
+	if query == "" {
+		return "Usage: /search TEXT", nil
+	}
+	// Ask for extra so the commands themselves can be skipped
+	found, err := in.st.SearchMessages(query, listLimit*2)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	n := 0
+	for _, m := range found {
+		if n == listLimit {
+			break
+		}
+		if strings.HasPrefix(m.Content, "/") && in.send.IsSelfChat(m.ChatJID) {
+			continue
+		}
+		chat := m.ChatName
+		if chat == "" {
+			chat = m.ChatJID
+		}
+		fmt.Fprintf(&b, "%s %s: %s\n", m.Timestamp.In(in.loc).Format("02 Jan 2006"), chat, m.Content)
+		n++
+	}
+	if n == 0 {
+		return fmt.Sprintf("Nothing found for %q.", query), nil
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Send due reminders until ctx is cancelled
+func (in *Inbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		in.sendDue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver and retire every reminder that is due; failed ones stay pending
+func (in *Inbox) sendDue() {
+	pending, err := in.st.ListReminders()
+	if err != nil {
+		in.log.Warnf("Failed to list reminders: %v", err)
+		return
+	}
+	now := in.now()
+	for _, r := range pending {
+		if r.DueAt.After(now) {
+			break
+		}
+		if err := in.reply(r.ChatJID, "Reminder: "+r.Text); err != nil {
+			in.log.Warnf("Failed to send reminder %d: %v", r.ID, err)
+			continue
+		}
+		if _, err := in.st.DeleteReminder(r.ID); err != nil {
+			in.log.Warnf("Failed to retire reminder %d: %v", r.ID, err)
+		}
+	}
+}
+
+func (in *Inbox) reply(chatJID, text string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return in.send.SendText(ctx, chatJID, text)
+}
+
+// Split "WHEN TEXT" into the due time and the text. WHEN is a duration
+// ("20m", "1h30m", optionally after "in"), a clock time today or, once
+// passed, tomorrow ("18:30"), "tomorrow" with a clock time, or a date and
+// clock time ("2025-06-01 9:00").
+func parseWhen(args string, now time.Time) (time.Time, string, error) {
+	fields := strings.Fields(args)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "in") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return time.Time{}, "", fmt.Errorf("missing time; send /help for formats")
+	}
+	rest := func(n int) string { return strings.Join(fields[n:], " ") }
+
+	if d, err := time.ParseDuration(fields[0]); err == nil && d > 0 {
+		return now.Add(d), rest(1), nil
+	}
+	if h, m, ok := clock(fields[0]); ok {
+		due := time.Date(now.Year(), now.Month(), now.Day(), h, m, 0, 0, now.Location())
+		if !due.After(now) {
+			due = due.AddDate(0, 0, 1)
+		}
+		return due, rest(1), nil
+	}
+	if len(fields) >= 2 {
+		day, err := time.ParseInLocation("2006-01-02", fields[0], now.Location())
+		if strings.EqualFold(fields[0], "tomorrow") {
+			day, err = now.AddDate(0, 0, 1), nil
+		}
+		if h, m, ok := clock(fields[1]); ok && err == nil {
+			due := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, now.Location())
+			if !due.After(now) {
+				return time.Time{}, "", fmt.Errorf("%s is in the past", due.Format("2006-01-02 15:04"))
+			}
+			return due, rest(2), nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("cannot read time %q; send /help for formats", fields[0])
+}
+
+// Parse "H:MM" or "HH:MM"
+func clock(s string) (hour, minute int, ok bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, false
+	}
+	return t.Hour(), t.Minute(), true
+}
@@ -0,0 +1,141 @@
+// Package journal records whatsmeow events to disk and reads them back for replay.
+//
+// A journal is a JSON Lines file. Message payloads are kept as raw protobuf
+// bytes (base64 in JSON) so replays see exactly what the handlers saw live.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Entry kinds
+const (
+	KindMessage     = "message"
+	KindHistorySync = "history_sync"
+)
+
+// One journaled event
+type entry struct {
+	Kind       string             `json:"kind"`
+	RecordedAt time.Time          `json:"recorded_at"`
+	Info       *types.MessageInfo `json:"info,omitempty"`
+	Payload    []byte             `json:"payload"`
+}
+
+// Writer appends events to a journal file. Safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// Open a journal for appending, creating it if needed
+func Create(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	return &Writer{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record an event. Event types the replayer does not understand are skipped.
+func (w *Writer) Record(evt interface{}) error {
+	var e entry
+	var err error
+	switch v := evt.(type) {
+	case *events.Message:
+		raw := v.RawMessage
+		if raw == nil {
+			raw = v.Message
+		}
+		e = entry{Kind: KindMessage, Info: &v.Info}
+		e.Payload, err = proto.Marshal(raw)
+	case *events.HistorySync:
+		e = entry{Kind: KindHistorySync}
+		e.Payload, err = proto.Marshal(v.Data)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", e.Kind, err)
+	}
+	e.RecordedAt = time.Now().UTC()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(&e)
+}
+
+// Close the journal file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// Reader yields events from a journal in recorded order
+type Reader struct {
+	f    *os.File
+	dec  *json.Decoder
+	line int
+}
+
+// Open a journal for reading
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	return &Reader{f: f, dec: json.NewDecoder(f)}, nil
+}
+
+// Next returns the next event as the same type whatsmeow emitted it
+// (*events.Message or *events.HistorySync), or io.EOF at the end.
+func (r *Reader) Next() (interface{}, error) {
+	var e entry
+	if err := r.dec.Decode(&e); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("journal entry %d: %w", r.line+1, err)
+	}
+	r.line++
+
+	switch e.Kind {
+	case KindMessage:
+		var raw waE2E.Message
+		if err := proto.Unmarshal(e.Payload, &raw); err != nil {
+			return nil, fmt.Errorf("journal entry %d: %w", r.line, err)
+		}
+		evt := &events.Message{RawMessage: &raw}
+		if e.Info != nil {
+			evt.Info = *e.Info
+		}
+		return evt.UnwrapRaw(), nil
+	case KindHistorySync:
+		var data waHistorySync.HistorySync
+		if err := proto.Unmarshal(e.Payload, &data); err != nil {
+			return nil, fmt.Errorf("journal entry %d: %w", r.line, err)
+		}
+		return &events.HistorySync{Data: &data}, nil
+	default:
+		return nil, fmt.Errorf("journal entry %d: unknown kind %q", r.line, e.Kind)
+	}
+}
+
+// Close the journal file
+func (r *Reader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,131 @@
+// Package links collects the URLs shared in a chat.
+package links
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// A URL and where it was shared
+type Link struct {
+	URL         string    `json:"url"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Count       int       `json:"count"`
+	FirstShared time.Time `json:"first_shared"`
+	LastShared  time.Time `json:"last_shared"`
+	// Who shared it first, and in which message
+	Sender    string `json:"sender"`
+	MessageID string `json:"message_id"`
+	ChatJID   string `json:"chat_jid"`
+}
+
+// http(s) URLs and bare www. hosts
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// Find the URLs in text, trimmed of trailing punctuation
+func Find(text string) []string {
+	var urls []string
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = trimURL(u)
+		if strings.HasPrefix(strings.ToLower(u), "www.") {
+			u = "https://" + u
+		}
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// The host of a URL in lower case without a leading "www.", or "" if it has
+// none
+func Domain(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+// Drop sentence punctuation and unbalanced closing brackets from the end
+func trimURL(u string) string {
+	for {
+		trimmed := strings.TrimRight(u, ".,;:!?'*")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+		if trimmed == u {
+			return u
+		}
+		u = trimmed
+	}
+}
+
+// Every distinct URL shared in a chat and the chats linked to it, most
+// recently shared first. An empty chatJID collects across all chats.
+func Collect(st store.Store, chatJID string) ([]Link, error) {
+	var inChat map[string]bool
+	if chatJID != "" {
+		group, err := st.ChatGroup(chatJID)
+		if err != nil {
+			return nil, err
+		}
+		inChat = map[string]bool{}
+		for _, jid := range group {
+			inChat[jid] = true
+		}
+	}
+
+	byURL := map[string]*Link{}
+	err := st.ForEachMessage(time.Time{}, time.Time{}, func(m store.Message) error {
+		if inChat != nil && !inChat[m.ChatJID] {
+			return nil
+		}
+		for _, u := range Find(m.Content) {
+			l := byURL[u]
+			if l == nil {
+				l = &Link{URL: u, FirstShared: m.Timestamp, Sender: m.Sender, MessageID: m.ID, ChatJID: m.ChatJID}
+				byURL[u] = l
+			}
+			l.Count++
+			l.LastShared = m.Timestamp
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(byURL) == 0 && chatJID != "" {
+		if _, err := st.QueryMessages(chatJID, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	urls := make([]string, 0, len(byURL))
+	for u := range byURL {
+		urls = append(urls, u)
+	}
+	previews, err := st.LinkPreviews(urls)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Link, 0, len(byURL))
+	for u, l := range byURL {
+		if p, ok := previews[u]; ok {
+			l.Title, l.Description = p.Title, p.Description
+		}
+		result = append(result, *l)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastShared.Equal(result[j].LastShared) {
+			return result[i].LastShared.After(result[j].LastShared)
+		}
+		return result[i].URL < result[j].URL
+	})
+	return result, nil
+}
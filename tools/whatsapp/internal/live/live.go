@@ -0,0 +1,48 @@
+// Package live fans messages out to subscribers as the logger stores them,
+// for the streaming endpoints of the REST and gRPC APIs.
+package live
+
+import (
+	"sync"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Messages buffered per subscriber before a slow one starts missing them
+const buffer = 256
+
+// Hub hands each published message to every subscriber
+type Hub struct {
+	mu   sync.Mutex
+	subs map[chan store.Message]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[chan store.Message]struct{}{}}
+}
+
+// Message hook: pass m to subscribers. Never blocks; a subscriber that
+// falls behind misses messages rather than stalling the logger.
+func (h *Hub) Publish(m store.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- m:
+		default:
+		}
+	}
+}
+
+// Receive messages published from now on, until cancel is called
+func (h *Hub) Subscribe() (messages <-chan store.Message, cancel func()) {
+	ch := make(chan store.Message, buffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
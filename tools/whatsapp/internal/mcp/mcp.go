@@ -0,0 +1,421 @@
+// Package mcp serves the message archive to AI assistants over the Model
+// Context Protocol: JSON-RPC 2.0 on stdin/stdout, for assistants that launch
+// the server themselves, or over HTTP with server-sent events. It offers
+// tools to list chats, read a chat's history, search messages and send a
+// message.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/cold"
+	"whatsapp-logger/internal/store"
+)
+
+// Protocol revisions the server speaks, newest first
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// JSON-RPC error codes
+const (
+	codeParse          = -32700
+	codeInvalidRequest = -32600
+	codeNoMethod       = -32601
+	codeInvalidParams  = -32602
+)
+
+// Server answers MCP requests from a store
+type Server struct {
+	store store.Store
+	log   waLog.Logger
+	// Optional archive of messages moved out of the store
+	cold *cold.Archive
+	// Sends messages right away; nil queues them in the outbox instead
+	sender Sender
+	// Hold messages for approval instead of sending or queueing them
+	requireApproval bool
+}
+
+// Sender delivers text messages to WhatsApp; *wa.Logger implements it
+type Sender interface {
+	SendText(ctx context.Context, chatJID, text string) error
+}
+
+// Create a server reading from st
+func New(st store.Store, log waLog.Logger) *Server {
+	return &Server{store: st, log: log}
+}
+
+// Also search messages moved to cold storage; nil disables it
+func (s *Server) SetCold(a *cold.Archive) {
+	s.cold = a
+}
+
+// Send messages through sender; nil queues them for the logger to send
+func (s *Server) SetSender(sender Sender) {
+	s.sender = sender
+}
+
+// Hold messages sent through send_message until approved
+func (s *Server) SetRequireApproval(on bool) {
+	s.requireApproval = on
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Answer one JSON-RPC message. Returns nil for notifications, which get no
+// reply.
+func (s *Server) handle(ctx context.Context, data []byte) []byte {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return reply(nil, nil, &rpcError{codeParse, "invalid JSON"})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return reply(req.ID, nil, &rpcError{codeInvalidRequest, "not a JSON-RPC 2.0 request"})
+	}
+	result, err := s.call(ctx, req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	var rerr *rpcError
+	if err != nil && !errors.As(err, &rerr) {
+		s.log.Errorf("MCP %s failed: %v", req.Method, err)
+		rerr = &rpcError{codeInvalidRequest, err.Error()}
+	}
+	return reply(req.ID, result, rerr)
+}
+
+func reply(id json.RawMessage, result interface{}, err *rpcError) []byte {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	if result == nil && err == nil {
+		result = struct{}{}
+	}
+	data, _ := json.Marshal(response{JSONRPC: "2.0", ID: id, Result: result, Error: err})
+	return data
+}
+
+func (s *Server) call(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(params, &p)
+		version := protocolVersions[0]
+		for _, v := range protocolVersions {
+			if v == p.ProtocolVersion {
+				version = v
+			}
+		}
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]bool{"listChanged": false}},
+			"serverInfo":      map[string]string{"name": "kenny-whatsapp", "version": "1.0.0"},
+			"instructions": "Tools over a personal WhatsApp archive. Chats are named by JID; " +
+				"list_chats finds them, get_chat_history reads one and search_messages searches all.",
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": tools}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{codeInvalidParams, "invalid tool call"}
+		}
+		return s.callTool(ctx, p.Name, p.Arguments)
+	}
+	if strings.HasPrefix(method, "notifications/") {
+		return nil, nil
+	}
+	return nil, &rpcError{codeNoMethod, fmt.Sprintf("unknown method %q", method)}
+}
+
+// A tool as tools/list describes it
+type tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+func schema(required []string, properties map[string]interface{}) map[string]interface{} {
+	s := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func property(typ, description string) map[string]string {
+	return map[string]string{"type": typ, "description": description}
+}
+
+var tools = []tool{
+	{
+		Name:        "list_chats",
+		Description: "List chats, most recently active first, with their JID and name.",
+		InputSchema: schema(nil, map[string]interface{}{
+			"query": property("string", "Only chats whose name, JID or phone number contains this"),
+			"limit": property("integer", "Most chats to return (default 50)"),
+		}),
+	},
+	{
+		Name:        "get_chat_history",
+		Description: "Read a chat's messages, oldest first, ending with the most recent ones in the range.",
+		InputSchema: schema([]string{"chat_jid"}, map[string]interface{}{
+			"chat_jid": property("string", "JID of the chat, as list_chats returns it"),
+			"since":    property("string", "Only messages at or after this RFC 3339 time"),
+			"until":    property("string", "Only messages before this RFC 3339 time"),
+			"limit":    property("integer", "Most messages to return (default 50)"),
+		}),
+	},
+	{
+		Name:        "search_messages",
+		Description: "Search message text and text read from images in every chat, newest first.",
+		InputSchema: schema([]string{"query"}, map[string]interface{}{
+			"query": property("string", "Text to look for"),
+			"limit": property("integer", "Most messages to return (default 50)"),
+		}),
+	},
+	{
+		Name: "send_message",
+		Description: "Send a text message to a chat. Without a connected logger it is queued and " +
+			"sent once the logger runs; when approval is required it is held until approved.",
+		InputSchema: schema([]string{"chat_jid", "text"}, map[string]interface{}{
+			"chat_jid": property("string", "JID of the chat, as list_chats returns it"),
+			"text":     property("string", "Message text"),
+			"send_at":  property("string", "Send later, at this RFC 3339 time"),
+		}),
+	},
+}
+
+// Arguments of any tool; each reads the ones it takes
+type arguments struct {
+	Query   string `json:"query"`
+	Limit   int    `json:"limit"`
+	ChatJID string `json:"chat_jid"`
+	Since   string `json:"since"`
+	Until   string `json:"until"`
+	Text    string `json:"text"`
+	SendAt  string `json:"send_at"`
+}
+
+func (a arguments) limit() int {
+	switch {
+	case a.Limit <= 0:
+		return defaultLimit
+	case a.Limit > maxLimit:
+		return maxLimit
+	}
+	return a.Limit
+}
+
+// Parse an optional RFC 3339 argument
+func parseTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time, like 2024-01-15T14:00:00Z", name)
+	}
+	return t, nil
+}
+
+// Run a tool. Failures the assistant can act on come back as tool results
+// flagged isError, not as protocol errors.
+func (s *Server) callTool(ctx context.Context, name string, raw json.RawMessage) (interface{}, error) {
+	var args arguments
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, &rpcError{codeInvalidParams, "invalid arguments: " + err.Error()}
+		}
+	}
+	var result interface{}
+	var err error
+	switch name {
+	case "list_chats":
+		result, err = s.listChats(args)
+	case "get_chat_history":
+		result, err = s.chatHistory(args)
+	case "search_messages":
+		result, err = s.searchMessages(args)
+	case "send_message":
+		result, err = s.sendMessage(ctx, args)
+	default:
+		return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool %q", name)}
+	}
+	if err != nil {
+		return toolResult(err.Error(), true), nil
+	}
+	text, ok := result.(string)
+	if !ok {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	return toolResult(text, false), nil
+}
+
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+func (s *Server) listChats(args arguments) ([]store.Chat, error) {
+	chats, err := s.store.ListChats()
+	if err != nil {
+		return nil, err
+	}
+	query := strings.ToLower(args.Query)
+	found := []store.Chat{}
+	for _, c := range chats {
+		if len(found) == args.limit() {
+			break
+		}
+		if query == "" || strings.Contains(strings.ToLower(c.Name), query) ||
+			strings.Contains(c.JID, query) || (c.Phone != "" && strings.Contains(c.Phone, query)) {
+			found = append(found, c)
+		}
+	}
+	return found, nil
+}
+
+// The last messages of a chat and the chats linked to it in the range
+func (s *Server) chatHistory(args arguments) ([]store.Message, error) {
+	if args.ChatJID == "" {
+		return nil, errors.New("chat_jid is required")
+	}
+	since, err := parseTime("since", args.Since)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseTime("until", args.Until)
+	if err != nil {
+		return nil, err
+	}
+	group, err := s.store.ChatGroup(args.ChatJID)
+	if err != nil {
+		return nil, err
+	}
+	inChat := map[string]bool{}
+	for _, jid := range group {
+		inChat[jid] = true
+	}
+	limit := args.limit()
+	messages := []store.Message{}
+	err = s.store.ForEachMessage(since, until, func(m store.Message) error {
+		if !inChat[m.ChatJID] {
+			return nil
+		}
+		if len(messages) == limit {
+			messages = messages[1:]
+		}
+		messages = append(messages, m)
+		return nil
+	})
+	return messages, err
+}
+
+func (s *Server) searchMessages(args arguments) ([]store.Message, error) {
+	if strings.TrimSpace(args.Query) == "" {
+		return nil, errors.New("query is required")
+	}
+	limit := args.limit()
+	messages, err := s.store.SearchMessages(args.Query, limit)
+	if err != nil {
+		return nil, err
+	}
+	// Cold messages are all older than hot ones, so only fill the remainder
+	if s.cold != nil && len(messages) < limit {
+		older, err := s.cold.Search(args.Query, limit-len(messages))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, older...)
+	}
+	if messages == nil {
+		messages = []store.Message{}
+	}
+	return messages, nil
+}
+
+// Send, queue or hold a message, as configured, and say which happened
+func (s *Server) sendMessage(ctx context.Context, args arguments) (string, error) {
+	if args.ChatJID == "" || strings.TrimSpace(args.Text) == "" {
+		return "", errors.New("chat_jid and text are required")
+	}
+	sendAt, err := parseTime("send_at", args.SendAt)
+	if err != nil {
+		return "", err
+	}
+	if s.requireApproval {
+		p := store.PendingSend{ChatJID: args.ChatJID, Text: args.Text}
+		if !sendAt.IsZero() {
+			p.SendAt = &sendAt
+		}
+		id, err := s.store.AddPendingSend(p)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Held for approval as pending message %d", id), nil
+	}
+	if s.sender != nil && sendAt.IsZero() {
+		if err := s.sender.SendText(ctx, args.ChatJID, args.Text); err != nil {
+			return "", err
+		}
+		return "Sent", nil
+	}
+	if sendAt.IsZero() {
+		sendAt = time.Now()
+	}
+	id, err := s.store.QueueOutbox(store.OutboxMessage{ChatJID: args.ChatJID, Text: args.Text, SendAt: sendAt})
+	if err != nil {
+		return "", err
+	}
+	if s.sender == nil {
+		return fmt.Sprintf("Queued as outbox message %d; it is sent once the logger runs", id), nil
+	}
+	return fmt.Sprintf("Queued as outbox message %d for %s", id, sendAt.Format(time.RFC3339)), nil
+}
@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"whatsapp-logger/internal/config"
+)
+
+// Largest request accepted, on either transport
+const maxRequest = 1 << 20
+
+// Answer requests read from r, one JSON message per line, writing replies
+// to w until r ends or ctx is cancelled
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxRequest)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if out := s.handle(ctx, line); out != nil {
+			if _, err := fmt.Fprintf(w, "%s\n", out); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// An SSE client: replies to its POSTs go out on its event stream
+type session struct {
+	replies chan []byte
+}
+
+// Handler serves the HTTP+SSE transport: GET /sse opens an event stream
+// whose first "endpoint" event names the URL to POST requests to, and
+// replies arrive on the stream as "message" events
+func (s *Server) Handler(cfg config.API) http.Handler {
+	var mu sync.Mutex
+	sessions := map[string]*session{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sse", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		id := sessionID()
+		sess := &session{replies: make(chan []byte, 16)}
+		mu.Lock()
+		sessions[id] = sess
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			delete(sessions, id)
+			mu.Unlock()
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		fmt.Fprintf(w, "event: endpoint\ndata: /message?sessionId=%s\n\n", id)
+		flusher.Flush()
+		keepalive := time.NewTicker(30 * time.Second)
+		defer keepalive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case out := <-sess.replies:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", out)
+				flusher.Flush()
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+				flusher.Flush()
+			}
+		}
+	})
+	mux.HandleFunc("POST /message", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sess, ok := sessions[r.URL.Query().Get("sessionId")]
+		mu.Unlock()
+		if !ok {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequest))
+		if err != nil {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		if out := s.handle(r.Context(), data); out != nil {
+			select {
+			case sess.replies <- out:
+			case <-r.Context().Done():
+			}
+		}
+	})
+
+	if cfg.Username == "" || cfg.Password == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(cfg.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Kenny WhatsApp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func sessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Serve the SSE transport on cfg.Addr until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, cfg config.API) error {
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           s.Handler(cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	s.log.Infof("Serving MCP on http://%s/sse", cfg.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Package mcpdb merges the messages.db written by the whatsapp-mcp bridge
+// into the archive. Its chats and messages tables are the ones this schema
+// grew from, so rows map across directly.
+package mcpdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"whatsapp-logger/internal/phone"
+	"whatsapp-logger/internal/store"
+)
+
+// ErrNotMCP is returned for a database without whatsapp-mcp's tables
+var ErrNotMCP = errors.New("not a whatsapp-mcp messages database")
+
+// Result counts what an import added
+type Result struct {
+	Chats    int `json:"chats"`
+	Messages int `json:"messages"`
+	// Messages already in the archive under the same ID and chat
+	Skipped int `json:"skipped"`
+}
+
+// Message columns read when present; older bridges lack the media ones
+var messageColumns = []string{"id", "chat_jid", "sender", "content", "timestamp", "is_from_me",
+	"media_type", "filename", "url", "media_key", "file_sha256", "file_enc_sha256", "file_length"}
+
+// Import the chats and messages of the whatsapp-mcp database at path into
+// st. Messages already stored, by ID and chat, are left as they are, so
+// importing twice adds nothing; so are chats, keeping their names.
+func Import(st store.Store, path string) (Result, error) {
+	var res Result
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return res, err
+	}
+	defer db.Close()
+
+	columns, err := tableColumns(db, "messages")
+	if err != nil {
+		return res, err
+	}
+	chatColumns, err := tableColumns(db, "chats")
+	if err != nil {
+		return res, err
+	}
+	for _, required := range []string{"id", "chat_jid", "content", "timestamp"} {
+		if !columns[required] {
+			return res, ErrNotMCP
+		}
+	}
+	if !chatColumns["jid"] {
+		return res, ErrNotMCP
+	}
+
+	known, err := knownChats(st)
+	if err != nil {
+		return res, err
+	}
+	rows, err := db.Query(`SELECT jid, COALESCE(name, ''), last_message_time FROM chats`)
+	if err != nil {
+		return res, fmt.Errorf("failed to read chats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var jid, name string
+		var last sql.NullTime
+		if err := rows.Scan(&jid, &name, &last); err != nil {
+			return res, fmt.Errorf("failed to read chats: %w", err)
+		}
+		if known[jid] {
+			continue
+		}
+		if err := st.StoreChat(jid, name, last.Time); err != nil {
+			return res, err
+		}
+		known[jid] = true
+		res.Chats++
+	}
+	if err := rows.Err(); err != nil {
+		return res, fmt.Errorf("failed to read chats: %w", err)
+	}
+	rows.Close()
+
+	selected := make([]string, len(messageColumns))
+	for i, c := range messageColumns {
+		selected[i] = "NULL"
+		if columns[c] {
+			selected[i] = c
+		}
+	}
+	rows, err = db.Query(`SELECT ` + strings.Join(selected, ", ") + ` FROM messages ORDER BY timestamp`)
+	if err != nil {
+		return res, fmt.Errorf("failed to read messages: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, chat string
+		var sender, content, mediaType, filename, url sql.NullString
+		var ts sql.NullTime
+		var fromMe sql.NullBool
+		var length sql.NullInt64
+		var m store.Media
+		if err := rows.Scan(&id, &chat, &sender, &content, &ts, &fromMe, &mediaType, &filename, &url,
+			&m.MediaKey, &m.FileSHA256, &m.FileEncSHA256, &length); err != nil {
+			return res, fmt.Errorf("failed to read messages: %w", err)
+		}
+		key := store.MessageKey{ID: id, ChatJID: chat}
+		_, err := st.GetMessage(key)
+		if err == nil {
+			res.Skipped++
+			continue
+		}
+		if !errors.Is(err, store.ErrMessageNotFound) {
+			return res, err
+		}
+		// Messages must belong to a stored chat
+		if !known[chat] {
+			if err := st.StoreChat(chat, chat, ts.Time); err != nil {
+				return res, err
+			}
+			known[chat] = true
+			res.Chats++
+		}
+		if err := st.StoreMessage(id, chat, senderJID(sender.String), content.String, ts.Time, fromMe.Bool,
+			mediaType.String, filename.String, url.String); err != nil {
+			return res, err
+		}
+		if url.String != "" || len(m.MediaKey) > 0 {
+			m.URL, m.FileLength = url.String, length.Int64
+			if err := st.StoreMedia(key, m); err != nil {
+				return res, err
+			}
+		}
+		res.Messages++
+	}
+	if err := rows.Err(); err != nil {
+		return res, fmt.Errorf("failed to read messages: %w", err)
+	}
+	return res, nil
+}
+
+// Names of a table's columns; none when the table doesn't exist
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// JIDs of every chat in st, linked ones included
+func knownChats(st store.Store) (map[string]bool, error) {
+	chats, err := st.ListChats()
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, c := range chats {
+		known[c.JID] = true
+		for _, linked := range c.LinkedJIDs {
+			known[linked] = true
+		}
+	}
+	return known, nil
+}
+
+// The bridge stores most senders as a bare phone number; make those JIDs
+// like the logger stores
+func senderJID(sender string) string {
+	if strings.Contains(sender, "@") {
+		return sender
+	}
+	if e164 := phone.FromJID(sender); e164 != "" {
+		return phone.JID(e164)
+	}
+	return sender
+}
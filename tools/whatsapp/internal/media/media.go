@@ -0,0 +1,141 @@
+// Package media locates attachments on disk and lists the archive's files.
+package media
+
+import (
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"whatsapp-logger/internal/store"
+)
+
+// A media message and whether its attachment has been downloaded; LocalPath
+// is only set when the file is on disk, ObjectURL when it is in S3
+type File struct {
+	store.MediaFile
+	Downloaded bool `json:"downloaded"`
+}
+
+// Per-chat location of m's attachment under dir, as used before downloads
+// were content-addressed and for files placed by hand: one directory per
+// chat, files named by message ID so two attachments called the same never
+// collide. Attachments without a filename get an extension from their MIME type.
+func Path(dir string, m store.Message) string {
+	name := m.ID
+	if m.Filename != "" {
+		name += "_" + sanitize(m.Filename)
+	} else {
+		name += Extension(m.MimeType)
+	}
+	return filepath.Join(dir, sanitize(m.ChatJID), name)
+}
+
+// Storage key of a downloaded attachment with this hex SHA-256. Files are
+// content-addressed so an image forwarded to many chats is stored once,
+// fanned out by the first two hex digits to keep directories small. ext
+// comes from the original filename or the MIME type.
+func BlobKey(sha256, ext string) string {
+	prefix := sha256
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	if ext = sanitize(ext); ext != "" {
+		ext = "." + ext
+	}
+	return "blobs/" + prefix + "/" + sha256 + ext
+}
+
+// Extension to give m's attachment on disk
+func FileExtension(m store.Message) string {
+	if ext := filepath.Ext(m.Filename); ext != "" {
+		return strings.ToLower(ext)
+	}
+	return Extension(m.MimeType)
+}
+
+// Extensions for the types WhatsApp sends, where the system table may
+// offer a rarer spelling first (".jpe") or nothing at all
+var extensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"video/mp4":       ".mp4",
+	"video/3gpp":      ".3gp",
+	"audio/ogg":       ".ogg",
+	"audio/mpeg":      ".mp3",
+	"audio/mp4":       ".m4a",
+	"audio/aac":       ".aac",
+	"application/pdf": ".pdf",
+}
+
+// File extension for a MIME type such as "audio/ogg; codecs=opus", or ""
+func Extension(mimeType string) string {
+	base, _, _ := strings.Cut(mimeType, ";")
+	base = strings.ToLower(strings.TrimSpace(base))
+	if ext, ok := extensions[base]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(base); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// Where the profile picture of the contact or group with this JID user
+// part is saved under dir
+func AvatarPath(dir, user string) string {
+	return filepath.Join(dir, "avatars", sanitize(user)+".jpg")
+}
+
+// Replace characters that are unsafe in file names on any platform
+func sanitize(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return strings.Trim(name, ". ")
+}
+
+// Document messages in a chat (all chats when chatJID is empty), newest
+// first, with the local path of those already downloaded into dir
+func Documents(st store.Store, dir, chatJID string) ([]File, error) {
+	media, err := st.ListMedia(chatJID, "document")
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(media))
+	for _, m := range media {
+		f := File{MediaFile: m}
+		if loc := Locate(dir, m.Message); loc != "" && !IsRemote(loc) {
+			f.LocalPath = loc
+		}
+		f.Downloaded = f.LocalPath != "" || f.ObjectURL != ""
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// Location of m's attachment, or "" when it has not been downloaded: its
+// object URL when kept in S3, else its path on disk. The recorded path
+// wins; files placed by hand at Path are found too.
+func Locate(dir string, m store.Message) string {
+	if m.ObjectURL != "" {
+		return m.ObjectURL
+	}
+	if m.LocalPath != "" && fileExists(m.LocalPath) {
+		return m.LocalPath
+	}
+	if path := Path(dir, m); fileExists(path) {
+		return path
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,92 @@
+package media
+
+import "whatsapp-logger/internal/config"
+
+// Which attachments are downloaded automatically, globally and per chat
+type DownloadPolicy struct {
+	global   rule
+	chats    map[string]rule
+	viewOnce bool
+}
+
+type rule struct {
+	enabled bool
+	// Allowed media types; nil allows all
+	types   map[string]bool
+	maxSize int64
+}
+
+// Build the policy described by the media configuration
+func DownloadPolicyFromConfig(cfg config.Media) *DownloadPolicy {
+	p := &DownloadPolicy{
+		global: rule{enabled: cfg.Download, types: typeSet(cfg.Types), maxSize: cfg.MaxMB << 20},
+		chats:    map[string]rule{},
+		viewOnce: cfg.ViewOnce,
+	}
+	for jid, c := range cfg.Chats {
+		r := p.global
+		if c.Download != nil {
+			r.enabled = *c.Download
+		}
+		if c.Types != nil {
+			r.types = typeSet(c.Types)
+		}
+		if c.MaxMB != nil {
+			r.maxSize = *c.MaxMB << 20
+		}
+		p.chats[jid] = r
+	}
+	return p
+}
+
+func typeSet(types []string) map[string]bool {
+	if len(types) == 0 {
+		return nil
+	}
+	set := map[string]bool{}
+	for _, t := range types {
+		set[t] = true
+	}
+	return set
+}
+
+func (p *DownloadPolicy) rule(chatJID string) rule {
+	if r, ok := p.chats[chatJID]; ok {
+		return r
+	}
+	return p.global
+}
+
+// Whether any chat downloads automatically
+func (p *DownloadPolicy) Enabled() bool {
+	if p.global.enabled || p.viewOnce {
+		return true
+	}
+	for _, r := range p.chats {
+		if r.enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a live attachment of this type and size in chatJID is downloaded as it arrives
+func (p *DownloadPolicy) Automatic(chatJID, mediaType string, size int64) bool {
+	r := p.rule(chatJID)
+	return r.enabled && p.Wanted(chatJID, mediaType, size)
+}
+
+// Whether view-once media is downloaded; the other rules do not apply to it
+func (p *DownloadPolicy) ViewOnce() bool {
+	return p.viewOnce
+}
+
+// Whether the chat's type and size limits admit the attachment, regardless
+// of whether downloads happen automatically there; backfills go by this
+func (p *DownloadPolicy) Wanted(chatJID, mediaType string, size int64) bool {
+	r := p.rule(chatJID)
+	if r.types != nil && !r.types[mediaType] {
+		return false
+	}
+	return r.maxSize == 0 || size <= r.maxSize
+}
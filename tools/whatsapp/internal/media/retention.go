@@ -0,0 +1,68 @@
+package media
+
+import (
+	"context"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Limits on kept attachments; zero fields impose no limit
+type Policy struct {
+	// Total size of stored files
+	MaxBytes int64
+	// Files whose newest message is older than this are deleted
+	MaxAge time.Duration
+}
+
+// What an eviction deleted, or would delete on a dry run, and what remains
+type Eviction struct {
+	Files     int   `json:"files"`
+	Bytes     int64 `json:"bytes"`
+	KeptFiles int   `json:"kept_files"`
+	KeptBytes int64 `json:"kept_bytes"`
+}
+
+// Delete stored files, least recently used first, until p holds. Messages
+// and their download metadata are kept; only the files go. storage is used
+// for files kept remotely, local files are removed directly.
+func Evict(ctx context.Context, st store.Store, storage Storage, p Policy, now time.Time, dryRun bool) (Eviction, error) {
+	blobs, err := st.MediaBlobs()
+	if err != nil {
+		return Eviction{}, err
+	}
+	var res Eviction
+	for _, b := range blobs {
+		res.KeptFiles++
+		res.KeptBytes += b.Size
+	}
+
+	for _, b := range blobs {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		tooOld := p.MaxAge > 0 && now.Sub(b.LastUsed) > p.MaxAge
+		overQuota := p.MaxBytes > 0 && res.KeptBytes > p.MaxBytes
+		if !tooOld && !overQuota {
+			// Blobs are oldest first, so nothing later is too old either
+			break
+		}
+		if !dryRun {
+			s := storage
+			if !IsRemote(b.Location) {
+				s = Local("")
+			}
+			if err := s.Delete(ctx, b.Location); err != nil {
+				return res, err
+			}
+			if err := st.EvictMediaBlob(b.SHA256); err != nil {
+				return res, err
+			}
+		}
+		res.Files++
+		res.Bytes += b.Size
+		res.KeptFiles--
+		res.KeptBytes -= b.Size
+	}
+	return res, nil
+}
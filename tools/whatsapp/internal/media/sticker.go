@@ -0,0 +1,97 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	"whatsapp-logger/internal/store"
+)
+
+// EXIF tag WhatsApp stores sticker pack JSON under
+const stickerPackTag = 0x5741
+
+// Read the pack metadata WhatsApp embeds in a WebP sticker's EXIF chunk;
+// ok is false when the file carries none
+func StickerInfo(webp []byte) (s store.Sticker, ok bool) {
+	exif := webpChunk(webp, "EXIF")
+	if exif == nil {
+		return store.Sticker{}, false
+	}
+	raw := tiffValue(bytes.TrimPrefix(exif, []byte("Exif\x00\x00")), stickerPackTag)
+	if raw == nil {
+		return store.Sticker{}, false
+	}
+	var meta struct {
+		PackID    string   `json:"sticker-pack-id"`
+		PackName  string   `json:"sticker-pack-name"`
+		Publisher string   `json:"sticker-pack-publisher"`
+		Emojis    []string `json:"emojis"`
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return store.Sticker{}, false
+	}
+	s = store.Sticker{PackID: meta.PackID, PackName: meta.PackName, Publisher: meta.Publisher, Emojis: meta.Emojis}
+	return s, s.PackID != "" || s.PackName != "" || len(s.Emojis) > 0
+}
+
+// The payload of the first RIFF chunk with this FourCC in a WebP file
+func webpChunk(data []byte, fourCC string) []byte {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil
+	}
+	for pos := 12; pos+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		start := pos + 8
+		if size < 0 || start+size > len(data) {
+			return nil
+		}
+		if string(data[pos:pos+4]) == fourCC {
+			return data[start : start+size]
+		}
+		// Chunks are padded to an even length
+		pos = start + size + size%2
+	}
+	return nil
+}
+
+// The bytes of a tag in the first IFD of TIFF-structured data
+func tiffValue(tiff []byte, tag uint16) []byte {
+	if len(tiff) < 8 {
+		return nil
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return nil
+	}
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < entries; i++ {
+		e := ifd + 2 + i*12
+		if e+12 > len(tiff) {
+			return nil
+		}
+		if order.Uint16(tiff[e:e+2]) != tag {
+			continue
+		}
+		// Treat the value as bytes, whatever its declared type
+		count := int(order.Uint32(tiff[e+4 : e+8]))
+		if count <= 4 {
+			return tiff[e+8 : e+8+count]
+		}
+		offset := int(order.Uint32(tiff[e+8 : e+12]))
+		if offset < 0 || count < 0 || offset+count > len(tiff) {
+			return nil
+		}
+		return tiff[offset : offset+count]
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/s3"
+)
+
+// Storage keeps downloaded attachments. Put returns the location of the
+// saved object, which the other methods take: a file path for local
+// storage, an object URL for S3.
+type Storage interface {
+	// Short description for logs
+	String() string
+	// Save data under a slash-separated key
+	Put(ctx context.Context, key string, data []byte, mimeType string) (location string, err error)
+	Open(ctx context.Context, location string) (io.ReadCloser, error)
+	Exists(ctx context.Context, location string) (bool, error)
+	Delete(ctx context.Context, location string) error
+}
+
+// Build the storage configured in cfg: S3 when media.s3 is set, else the media directory
+func StorageFromConfig(cfg config.Media) Storage {
+	if cfg.S3 != nil {
+		return NewS3(*cfg.S3)
+	}
+	return Local(cfg.Dir)
+}
+
+// Whether a location names a remote object rather than a local file
+func IsRemote(location string) bool {
+	return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://")
+}
+
+// Files under a local directory
+type Local string
+
+func (d Local) String() string { return string(d) }
+
+func (d Local) Put(ctx context.Context, key string, data []byte, mimeType string) (string, error) {
+	path := filepath.Join(string(d), filepath.FromSlash(key))
+	if fileExists(path) {
+		return path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, os.Rename(tmp, path)
+}
+
+func (d Local) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	return os.Open(location)
+}
+
+func (d Local) Exists(ctx context.Context, location string) (bool, error) {
+	return fileExists(location), nil
+}
+
+func (d Local) Delete(ctx context.Context, location string) error {
+	err := os.Remove(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Objects in an S3 or S3-compatible bucket
+type S3 struct {
+	client *s3.Client
+}
+
+func NewS3(cfg config.S3Target) *S3 {
+	return &S3{client: s3.New(cfg)}
+}
+
+func (s *S3) String() string { return s.client.String() }
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, mimeType string) (string, error) {
+	if err := s.client.Put(ctx, key, bytes.NewReader(data), int64(len(data)), mimeType); err != nil {
+		return "", err
+	}
+	return s.client.URL(key), nil
+}
+
+func (s *S3) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	key, err := s.key(location)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Get(ctx, key)
+}
+
+func (s *S3) Exists(ctx context.Context, location string) (bool, error) {
+	key, err := s.key(location)
+	if err != nil {
+		return false, err
+	}
+	return s.client.Exists(ctx, key)
+}
+
+func (s *S3) Delete(ctx context.Context, location string) error {
+	key, err := s.key(location)
+	if err != nil {
+		return err
+	}
+	return s.client.Delete(ctx, key)
+}
+
+func (s *S3) key(location string) (string, error) {
+	key, ok := s.client.Key(location)
+	if !ok {
+		return "", fmt.Errorf("%s is not in %s", location, s.client)
+	}
+	return key, nil
+}
@@ -0,0 +1,423 @@
+// Package msgstore imports the msgstore.db of an Android phone's WhatsApp
+// backup, for history from before the logger ran. Backups are encrypted on
+// the phone (msgstore.db.crypt14, .crypt15) and must be decrypted first with
+// the backup key, by a tool such as wa-crypt-tools.
+//
+// The importer reads the message, chat, jid and message_media tables of
+// current app versions. Databases only holding the older messages table,
+// from before the 2022 schema change, are not supported.
+package msgstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/store"
+)
+
+var (
+	// ErrEncrypted is returned for a backup still in its .crypt form
+	ErrEncrypted = errors.New("backup is encrypted; decrypt it to a plain msgstore.db first")
+	// ErrNotMsgstore is returned for a database without msgstore's tables
+	ErrNotMsgstore = errors.New("not an Android msgstore database")
+)
+
+// Options shape an import
+type Options struct {
+	// The phone's WhatsApp directory, holding Media/, to read attached
+	// files from; empty to import only their metadata
+	MediaDir string
+	// Where attached files are saved
+	Storage media.Storage
+}
+
+// Result counts what an import did
+type Result struct {
+	Chats    int `json:"chats"`
+	Messages int `json:"messages"`
+	// Messages already in the archive under the same ID and chat
+	Skipped int `json:"skipped"`
+	// System messages and deleted ones, which are not imported
+	System int `json:"system"`
+	// Attached files saved
+	Media int `json:"media"`
+}
+
+// Values of message.message_type
+const (
+	typeText         = 0
+	typeImage        = 1
+	typeAudio        = 2
+	typeVideo        = 3
+	typeContact      = 4
+	typeLocation     = 5
+	typeSystem       = 7
+	typeDocument     = 9
+	typeGIF          = 13
+	typeContacts     = 14
+	typeRevoked      = 15
+	typeLiveLocation = 16
+	typeSticker      = 20
+	typeViewOnceImg  = 42
+	typeViewOnceVid  = 43
+	typePoll         = 46
+)
+
+// Columns read when present, as their versions of the schema differ
+var (
+	messageColumns = []string{"key_id", "from_me", "timestamp", "message_type", "text_data"}
+	mediaColumns   = []string{"file_path", "mime_type", "file_length", "media_name", "message_url",
+		"direct_path", "media_key", "file_hash", "enc_file_hash"}
+)
+
+// Import the chats and messages of the decrypted msgstore.db at file into
+// st. Messages keep the IDs WhatsApp gave them, so ones the logger already
+// stored are skipped and importing twice adds nothing; known chats keep
+// their names.
+func Import(ctx context.Context, st store.Store, file string, opts Options) (Result, error) {
+	var res Result
+	if err := checkHeader(file); err != nil {
+		return res, err
+	}
+	db, err := sql.Open("sqlite3", "file:"+file+"?mode=ro")
+	if err != nil {
+		return res, err
+	}
+	defer db.Close()
+
+	columns := map[string]map[string]bool{}
+	for _, table := range []string{"message", "chat", "jid", "message_media"} {
+		if columns[table], err = tableColumns(db, table); err != nil {
+			return res, err
+		}
+	}
+	if !columns["message"]["chat_row_id"] || !columns["message"]["key_id"] ||
+		!columns["chat"]["jid_row_id"] || !columns["jid"]["raw_string"] {
+		return res, ErrNotMsgstore
+	}
+
+	known, err := knownChats(st)
+	if err != nil {
+		return res, err
+	}
+	if res.Chats, err = importChats(db, st, columns["chat"], known); err != nil {
+		return res, err
+	}
+
+	// Media columns are only read through the join on message_row_id
+	join := ""
+	if columns["message_media"]["message_row_id"] {
+		join = `LEFT JOIN message_media mm ON mm.message_row_id = m._id`
+	} else {
+		columns["message_media"] = map[string]bool{}
+	}
+	selected := make([]string, 0, len(messageColumns)+len(mediaColumns))
+	for _, c := range messageColumns {
+		selected = append(selected, column("m", c, columns["message"]))
+	}
+	for _, c := range mediaColumns {
+		selected = append(selected, column("mm", c, columns["message_media"]))
+	}
+	sender := "NULL"
+	if columns["message"]["sender_jid_row_id"] {
+		sender = "(SELECT raw_string FROM jid WHERE _id = m.sender_jid_row_id)"
+	}
+	query := `SELECT cj.raw_string, ` + sender + `, ` + strings.Join(selected, ", ") + `
+		FROM message m
+		JOIN chat c ON c._id = m.chat_row_id
+		JOIN jid cj ON cj._id = c.jid_row_id ` + join
+	rows, err := db.Query(query + ` ORDER BY m.timestamp`)
+	if err != nil {
+		return res, fmt.Errorf("failed to read messages: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.chat, &r.sender, &r.id, &r.fromMe, &r.timestamp, &r.messageType, &r.text,
+			&r.filePath, &r.mimeType, &r.fileLength, &r.mediaName, &r.url, &r.directPath, &r.mediaKey,
+			&r.fileHash, &r.encFileHash); err != nil {
+			return res, fmt.Errorf("failed to read messages: %w", err)
+		}
+		// Placeholder rows, like the one msgstore opens with, have no ID or -1
+		if r.id.String == "" || r.id.String == "-1" || !r.chat.Valid {
+			continue
+		}
+		content, mediaType, filename, ok := r.content()
+		if !ok {
+			res.System++
+			continue
+		}
+
+		key := store.MessageKey{ID: r.id.String, ChatJID: r.chat.String}
+		_, err := st.GetMessage(key)
+		if err == nil {
+			res.Skipped++
+			continue
+		}
+		if !errors.Is(err, store.ErrMessageNotFound) {
+			return res, err
+		}
+		ts := time.UnixMilli(r.timestamp.Int64)
+		// Messages must belong to a stored chat
+		if !known[key.ChatJID] {
+			if err := st.StoreChat(key.ChatJID, key.ChatJID, ts); err != nil {
+				return res, err
+			}
+			known[key.ChatJID] = true
+			res.Chats++
+		}
+		if err := st.StoreMessage(key.ID, key.ChatJID, r.senderJID(), content, ts, r.fromMe.Int64 == 1,
+			mediaType, filename, r.url.String); err != nil {
+			return res, err
+		}
+		res.Messages++
+		if mediaType == "" {
+			continue
+		}
+		if err := st.StoreMedia(key, r.media()); err != nil {
+			return res, err
+		}
+		if opts.MediaDir != "" && opts.Storage != nil && r.filePath.String != "" {
+			saved, err := saveFile(ctx, st, opts, key, r)
+			if err != nil {
+				return res, fmt.Errorf("failed to save %s: %w", r.filePath.String, err)
+			}
+			if saved {
+				res.Media++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return res, fmt.Errorf("failed to read messages: %w", err)
+	}
+	return res, nil
+}
+
+// A message row joined with its chat, sender and media
+type row struct {
+	chat, sender, id               sql.NullString
+	fromMe, timestamp, messageType sql.NullInt64
+	text                           sql.NullString
+	filePath, mimeType             sql.NullString
+	fileLength                     sql.NullInt64
+	mediaName, url, directPath     sql.NullString
+	mediaKey                       []byte
+	fileHash, encFileHash          sql.NullString
+}
+
+// The content, media type and filename to store for a message, with media
+// placeholders as the logger writes them; ok is false for messages that
+// aren't imported
+func (r row) content() (content, mediaType, filename string, ok bool) {
+	text := r.text.String
+	switch r.messageType.Int64 {
+	case typeSystem, typeRevoked:
+		return "", "", "", false
+	case typeImage, typeViewOnceImg:
+		return withCaption("[Image]", text), "image", "", true
+	case typeVideo, typeGIF, typeViewOnceVid:
+		return withCaption("[Video]", text), "video", "", true
+	case typeAudio:
+		return "[Audio]", "audio", "", true
+	case typeDocument:
+		filename = r.mediaName.String
+		return withCaption("[Document]", filename), "document", filename, true
+	case typeSticker:
+		return "[Sticker]", "sticker", "", true
+	case typeLocation:
+		return withCaption("[Location]", text), "", "", true
+	case typeLiveLocation:
+		return withCaption("[Live location]", text), "", "", true
+	case typeContact:
+		return withCaption("[Contact]", text), "", "", true
+	case typeContacts:
+		return withCaption("[Contacts]", text), "", "", true
+	case typePoll:
+		return withCaption("[Poll]", text), "", "", true
+	}
+	if text == "" {
+		return "[Unknown message type]", "", "", true
+	}
+	return text, "", "", true
+}
+
+func withCaption(placeholder, caption string) string {
+	if caption == "" {
+		return placeholder
+	}
+	return placeholder + " " + caption
+}
+
+// The sender as the logger stores it: empty for my messages, the contact
+// in a direct chat
+func (r row) senderJID() string {
+	switch {
+	case r.fromMe.Int64 == 1:
+		return ""
+	case r.sender.String != "":
+		return r.sender.String
+	default:
+		return r.chat.String
+	}
+}
+
+// Attachment metadata, enough for media-backfill to download a file the
+// backup doesn't hold while WhatsApp's servers still keep it
+func (r row) media() store.Media {
+	m := store.Media{
+		URL:        r.url.String,
+		DirectPath: r.directPath.String,
+		MediaKey:   r.mediaKey,
+		FileLength: r.fileLength.Int64,
+		MimeType:   r.mimeType.String,
+		ViewOnce:   r.messageType.Int64 == typeViewOnceImg || r.messageType.Int64 == typeViewOnceVid,
+	}
+	// Hashes are stored base64 encoded
+	m.FileSHA256, _ = base64.StdEncoding.DecodeString(r.fileHash.String)
+	m.FileEncSHA256, _ = base64.StdEncoding.DecodeString(r.encFileHash.String)
+	return m
+}
+
+// Save the attached file a message's media row points at, when the media
+// directory holds it. Reports false when it doesn't.
+func saveFile(ctx context.Context, st store.Store, opts Options, key store.MessageKey, r row) (bool, error) {
+	data, err := os.ReadFile(mediaPath(opts.MediaDir, r.filePath.String))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ext := strings.ToLower(path.Ext(r.filePath.String))
+	mimeType := r.mimeType.String
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(ext)
+	}
+	sum := sha256.Sum256(data)
+	b := store.MediaBlob{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data)), MimeType: mimeType}
+	if b.Location, err = opts.Storage.Put(ctx, media.BlobKey(b.SHA256, ext), data, mimeType); err != nil {
+		return false, err
+	}
+	return true, st.SetMediaFile(key, b)
+}
+
+// The file under dir a media row's path names. Paths are relative to the
+// WhatsApp directory, or absolute on the phone in older rows, as
+// /storage/emulated/0/WhatsApp/Media/...
+func mediaPath(dir, p string) string {
+	if i := strings.Index(p, "Media/"); i >= 0 {
+		p = p[i:]
+	}
+	return filepath.Join(dir, filepath.FromSlash(p))
+}
+
+// Store the chats the archive doesn't know, named by their subject when
+// they have one. Returns how many were stored.
+func importChats(db *sql.DB, st store.Store, columns map[string]bool, known map[string]bool) (int, error) {
+	subject := column("c", "subject", columns)
+	last := column("c", "sort_timestamp", columns)
+	rows, err := db.Query(`SELECT j.raw_string, ` + subject + `, ` + last + `
+		FROM chat c JOIN jid j ON j._id = c.jid_row_id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read chats: %w", err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var jid, name sql.NullString
+		var ts sql.NullInt64
+		if err := rows.Scan(&jid, &name, &ts); err != nil {
+			return n, fmt.Errorf("failed to read chats: %w", err)
+		}
+		if !jid.Valid || known[jid.String] {
+			continue
+		}
+		if name.String == "" {
+			name.String = jid.String
+		}
+		if err := st.StoreChat(jid.String, name.String, time.UnixMilli(ts.Int64)); err != nil {
+			return n, err
+		}
+		known[jid.String] = true
+		n++
+	}
+	return n, rows.Err()
+}
+
+// Fail early for files that aren't a plain SQLite database, naming the
+// likely cause
+func checkHeader(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return ErrNotMsgstore
+	}
+	if bytes.Equal(header, []byte("SQLite format 3\x00")) {
+		return nil
+	}
+	if strings.Contains(filepath.Base(file), ".crypt") {
+		return ErrEncrypted
+	}
+	return ErrNotMsgstore
+}
+
+// A qualified column, or NULL when the table lacks it
+func column(alias, name string, columns map[string]bool) string {
+	if !columns[name] {
+		return "NULL"
+	}
+	return alias + "." + name
+}
+
+// Names of a table's columns; none when the table doesn't exist
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// JIDs of every chat in st, linked ones included
+func knownChats(st store.Store) (map[string]bool, error) {
+	chats, err := st.ListChats()
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, c := range chats {
+		known[c.JID] = true
+		for _, linked := range c.LinkedJIDs {
+			known[linked] = true
+		}
+	}
+	return known, nil
+}
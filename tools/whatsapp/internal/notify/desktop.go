@@ -0,0 +1,12 @@
+package notify
+
+import "context"
+
+// Desktop shows notifications through the operating system's notification centre
+type Desktop struct{}
+
+func (Desktop) Name() string { return "desktop" }
+
+func (Desktop) Notify(ctx context.Context, n Notification) error {
+	return desktopNotify(ctx, n.Title, n.Body, n.Priority)
+}
@@ -0,0 +1,21 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Post through the freedesktop notification daemon via notify-send
+func desktopNotify(ctx context.Context, title, body string, priority int) error {
+	urgency := "normal"
+	if priority >= 3 {
+		urgency = "critical"
+	}
+	cmd := exec.CommandContext(ctx, "notify-send", "--app-name=Kenny", "--urgency="+urgency, "--", title, body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notify-send: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !darwin && !linux && !windows
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"runtime"
+)
+
+func desktopNotify(ctx context.Context, title, body string, priority int) error {
+	return errors.New("desktop notifications are not supported on " + runtime.GOOS)
+}
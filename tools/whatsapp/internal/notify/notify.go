@@ -0,0 +1,221 @@
+// Package notify raises notifications for live messages that match watch rules.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/quiet"
+	"whatsapp-logger/internal/store"
+)
+
+// How long a single sink may take to deliver
+const sendTimeout = 15 * time.Second
+
+// A notification ready to deliver
+type Notification struct {
+	Title    string
+	Body     string
+	Priority int
+	// Name of the rule that matched, empty when a chat is set to always notify
+	Rule string
+	// Names of the sinks to deliver to
+	Sinks   []string
+	Message store.Message
+}
+
+// A Notifier delivers notifications to one destination
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Dispatcher scores live messages against the configured rules and fans
+// notifications out to its sinks
+type Dispatcher struct {
+	cfg   config.Notifications
+	sinks map[string]Notifier
+	log   waLog.Logger
+	quiet *quiet.Hours
+	muted func(chatJID string) bool
+
+	// Notifications held back during quiet hours, and the timer that
+	// sends their summary when the window ends
+	mu       sync.Mutex
+	held     []Notification
+	catchUpT *time.Timer
+}
+
+// Create a dispatcher delivering to sinks, which rules select by Name
+func NewDispatcher(cfg config.Notifications, log waLog.Logger, sinks ...Notifier) *Dispatcher {
+	byName := make(map[string]Notifier, len(sinks))
+	for _, sink := range sinks {
+		byName[sink.Name()] = sink
+	}
+	return &Dispatcher{cfg: cfg, sinks: byName, log: log}
+}
+
+// Hold notifications back during quiet hours and summarize them afterwards
+func (d *Dispatcher) SetQuietHours(h *quiet.Hours) {
+	d.quiet = h
+}
+
+// Skip chats that muted reports as muted, unless they have per-chat
+// settings
+func (d *Dispatcher) SetPhoneMutes(muted func(chatJID string) bool) {
+	d.muted = muted
+}
+
+// Evaluate msg against the rules and per-chat settings. Returns the
+// notification to send and whether one is due at all.
+func (d *Dispatcher) Evaluate(msg store.Message) (Notification, bool) {
+	if msg.IsFromMe {
+		return Notification{}, false
+	}
+
+	threshold := d.cfg.MinPriority
+	chat, hasChat := d.cfg.Chats[msg.ChatJID]
+	if hasChat {
+		if chat.Mute {
+			return Notification{}, false
+		}
+		if chat.MinPriority > 0 {
+			threshold = chat.MinPriority
+		}
+	} else if d.muted != nil && d.muted(msg.ChatJID) {
+		return Notification{}, false
+	}
+
+	n := Notification{Message: msg, Sinks: d.cfg.DefaultSinks}
+	for _, rule := range d.cfg.Rules {
+		if rule.Priority > n.Priority && rule.Match(msg) {
+			n.Priority = rule.Priority
+			n.Rule = rule.Name
+			n.Sinks = d.cfg.DefaultSinks
+			if len(rule.Sinks) > 0 {
+				n.Sinks = rule.Sinks
+			}
+		}
+	}
+	if hasChat && chat.Always && n.Priority < threshold {
+		n.Priority = threshold
+	}
+	if n.Priority < threshold {
+		return Notification{}, false
+	}
+
+	n.Title = title(msg)
+	n.Body = msg.Content
+	return n, true
+}
+
+// Message hook: notify asynchronously so the event loop never waits on a sink
+func (d *Dispatcher) HandleMessage(msg store.Message) {
+	n, ok := d.Evaluate(msg)
+	if !ok {
+		return
+	}
+	breaksThrough := d.cfg.BreakThroughPriority > 0 && n.Priority >= d.cfg.BreakThroughPriority
+	if quiet, until := d.quiet.Active(time.Now()); quiet && !breaksThrough {
+		d.hold(n, until)
+		return
+	}
+	go d.send(n)
+}
+
+// Queue n until the quiet window ending at until is over
+func (d *Dispatcher) hold(n Notification, until time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.held = append(d.held, n)
+	if d.catchUpT == nil {
+		d.catchUpT = time.AfterFunc(time.Until(until), d.catchUp)
+	}
+}
+
+// Send one summary of everything held during quiet hours
+func (d *Dispatcher) catchUp() {
+	d.mu.Lock()
+	held := d.held
+	d.held, d.catchUpT = nil, nil
+	d.mu.Unlock()
+	if len(held) == 0 {
+		return
+	}
+	d.send(summary(held))
+}
+
+// Maximum chats listed in a catch-up summary
+const summaryChats = 10
+
+// Summarize held notifications per chat, delivered to every sink any of
+// them was meant for
+func summary(held []Notification) Notification {
+	n := Notification{Title: fmt.Sprintf("During quiet hours: %d messages", len(held))}
+	counts := map[string]int{}
+	var chats []string
+	sinks := map[string]bool{}
+	for _, h := range held {
+		name := h.Message.ChatName
+		if name == "" {
+			name = h.Message.ChatJID
+		}
+		if counts[name] == 0 {
+			chats = append(chats, name)
+		}
+		counts[name]++
+		n.Priority = max(n.Priority, h.Priority)
+		for _, s := range h.Sinks {
+			if !sinks[s] {
+				sinks[s] = true
+				n.Sinks = append(n.Sinks, s)
+			}
+		}
+	}
+
+	var lines []string
+	for i, chat := range chats {
+		if i == summaryChats {
+			lines = append(lines, fmt.Sprintf("and %d more chats", len(chats)-summaryChats))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s: %d", chat, counts[chat]))
+	}
+	n.Body = strings.Join(lines, "\n")
+	n.Message = held[len(held)-1].Message
+	return n
+}
+
+// Deliver to each selected sink, logging failures
+func (d *Dispatcher) send(n Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	for _, name := range n.Sinks {
+		sink, ok := d.sinks[name]
+		if !ok {
+			d.log.Warnf("Notification sink %q is not configured", name)
+			continue
+		}
+		if err := sink.Notify(ctx, n); err != nil {
+			d.log.Warnf("Failed to deliver %s notification: %v", name, err)
+		}
+	}
+}
+
+// Build a title naming the sender and, for groups, the chat
+func title(msg store.Message) string {
+	sender := msg.SenderName
+	if sender == "" {
+		sender = msg.Sender
+	}
+	if msg.ChatName != "" && msg.ChatName != msg.ChatJID && msg.ChatJID != msg.Sender {
+		return sender + " in " + msg.ChatName
+	}
+	return sender
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"whatsapp-logger/internal/config"
+)
+
+// Ntfy publishes notifications to an ntfy topic
+type Ntfy struct {
+	cfg config.NtfySink
+}
+
+func (*Ntfy) Name() string { return "ntfy" }
+
+func (s *Ntfy) Notify(ctx context.Context, n Notification) error {
+	base := s.cfg.URL
+	if base == "" {
+		base = "https://ntfy.sh"
+	}
+	req, err := newRequest(ctx, "POST", strings.TrimRight(base, "/")+"/"+s.cfg.Topic, strings.NewReader(n.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", n.Title)
+	req.Header.Set("Priority", strconv.Itoa(ntfyPriority(n.Priority)))
+	req.Header.Set("Tags", "speech_balloon")
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	}
+	return do(req)
+}
+
+// Map rule priority onto ntfy's 1 (min) to 5 (max) scale, 3 being default
+func ntfyPriority(p int) int {
+	switch {
+	case p <= 1:
+		return 3
+	case p == 2:
+		return 4
+	default:
+		return 5
+	}
+}
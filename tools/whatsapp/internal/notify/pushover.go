@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"whatsapp-logger/internal/config"
+)
+
+const pushoverAPI = "https://api.pushover.net/1/messages.json"
+
+// Pushover sends notifications through the Pushover API
+type Pushover struct {
+	cfg config.PushoverSink
+}
+
+func (*Pushover) Name() string { return "pushover" }
+
+func (s *Pushover) Notify(ctx context.Context, n Notification) error {
+	form := url.Values{
+		"token":   {s.cfg.Token},
+		"user":    {s.cfg.User},
+		"title":   {n.Title},
+		"message": {n.Body},
+	}
+	// Emergency priority needs retry/expire acknowledgement handling, so cap at high
+	if n.Priority >= 2 {
+		form.Set("priority", "1")
+	}
+	req, err := newRequest(ctx, "POST", pushoverAPI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return do(req)
+}
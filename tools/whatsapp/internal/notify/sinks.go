@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"whatsapp-logger/internal/config"
+)
+
+// Build every sink the configuration provides credentials for, plus desktop
+func SinksFromConfig(cfg config.Sinks) []Notifier {
+	sinks := []Notifier{Desktop{}}
+	if cfg.Ntfy != nil {
+		sinks = append(sinks, &Ntfy{cfg: *cfg.Ntfy})
+	}
+	if cfg.Pushover != nil {
+		sinks = append(sinks, &Pushover{cfg: *cfg.Pushover})
+	}
+	if cfg.Telegram != nil {
+		sinks = append(sinks, &Telegram{cfg: *cfg.Telegram})
+	}
+	return sinks
+}
+
+// Perform req and turn a non-2xx response into an error carrying the body.
+// Transport errors drop the URL, which can embed credentials (Telegram bot tokens).
+func do(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s %s: %w", req.Method, req.URL.Host, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Request with ctx, failing early on a malformed URL
+func newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	return req, nil
+}
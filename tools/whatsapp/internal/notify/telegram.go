@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"whatsapp-logger/internal/config"
+)
+
+// Telegram sends notifications as messages from a Telegram bot
+type Telegram struct {
+	cfg config.TelegramSink
+}
+
+func (*Telegram) Name() string { return "telegram" }
+
+func (s *Telegram) Notify(ctx context.Context, n Notification) error {
+	form := url.Values{
+		"chat_id": {s.cfg.ChatID},
+		// Plain text, so message content never needs Markdown escaping
+		"text": {n.Title + "\n" + n.Body},
+	}
+	if n.Priority <= 1 {
+		form.Set("disable_notification", "true")
+	}
+	endpoint := "https://api.telegram.org/bot" + s.cfg.BotToken + "/sendMessage"
+	req, err := newRequest(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return do(req)
+}
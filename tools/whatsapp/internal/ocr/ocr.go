@@ -0,0 +1,123 @@
+// Package ocr extracts text from images with tesseract or an HTTP OCR service.
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"whatsapp-logger/internal/config"
+)
+
+// Engine reads the text in an image
+type Engine interface {
+	// Text found in the encoded image, "" when there is none
+	Recognize(ctx context.Context, image []byte, mimeType string) (string, error)
+}
+
+// Build the engine configured in cfg
+func New(cfg config.OCR) (Engine, error) {
+	switch cfg.Engine {
+	case "", "tesseract":
+		return &Tesseract{Command: cfg.Command, Languages: cfg.Languages}, nil
+	case "http":
+		if cfg.URL == "" {
+			return nil, errors.New("ocr.url is required for the http engine")
+		}
+		return &HTTP{URL: cfg.URL, Token: cfg.Token}, nil
+	default:
+		return nil, fmt.Errorf("unknown OCR engine %q: use tesseract or http", cfg.Engine)
+	}
+}
+
+// Tesseract runs the tesseract CLI, passing the image on stdin
+type Tesseract struct {
+	// Binary to run (default "tesseract")
+	Command string
+	// Language codes joined by "+", e.g. "eng+deu" (default: tesseract's own)
+	Languages string
+}
+
+func (t *Tesseract) Recognize(ctx context.Context, image []byte, mimeType string) (string, error) {
+	command := t.Command
+	if command == "" {
+		command = "tesseract"
+	}
+	args := []string{"stdin", "stdout"}
+	if t.Languages != "" {
+		args = append(args, "-l", t.Languages)
+	}
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdin = bytes.NewReader(image)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("tesseract: %v: %s", err, msg)
+		}
+		return "", fmt.Errorf("tesseract: %w", err)
+	}
+	return Clean(stdout.String()), nil
+}
+
+// HTTP posts the image to a service that answers with the text, either as
+// plain text or as JSON {"text": "..."}
+type HTTP struct {
+	URL string
+	// Sent as a bearer token when set
+	Token string
+}
+
+func (h *HTTP) Recognize(ctx context.Context, image []byte, mimeType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(image))
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", mimeType)
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("OCR service: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var out struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(body, &out); err != nil {
+			return "", fmt.Errorf("invalid OCR response: %w", err)
+		}
+		return Clean(out.Text), nil
+	}
+	return Clean(string(body)), nil
+}
+
+// Trim OCR output and drop the blank lines and stray form feeds engines emit
+func Clean(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "\f", ""))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// ErrNotPending is returned for held messages that do not exist or were
+// already approved or rejected
+var ErrNotPending = errors.New("no message awaiting approval with this ID")
+
+// TextSender sends a text message right away; *wa.Logger implements it
+type TextSender interface {
+	SendText(ctx context.Context, chatJID, text string) error
+}
+
+// Approve a held message: send it now, or queue it in the outbox when it
+// is meant for later. send may be nil for messages meant for later. A
+// failed send leaves the message pending.
+func Approve(ctx context.Context, st store.Store, send TextSender, id int64) (store.PendingSend, error) {
+	p, err := pending(st, id)
+	if err != nil {
+		return p, err
+	}
+	switch {
+	case Later(p, time.Now()):
+		if _, err := st.QueueOutbox(store.OutboxMessage{ChatJID: p.ChatJID, Text: p.Text, SendAt: *p.SendAt}); err != nil {
+			return p, err
+		}
+	case send == nil:
+		return p, errors.New("cannot send without a connection")
+	default:
+		if err := send.SendText(ctx, p.ChatJID, p.Text); err != nil {
+			return p, err
+		}
+	}
+	return p, decide(st, id, store.SendApproved)
+}
+
+// Whether a held message is meant for after now, so approving it only
+// queues it
+func Later(p store.PendingSend, now time.Time) bool {
+	return p.SendAt != nil && p.SendAt.After(now)
+}
+
+// Reject a held message, so it is never sent
+func Reject(st store.Store, id int64) (store.PendingSend, error) {
+	p, err := pending(st, id)
+	if err != nil {
+		return p, err
+	}
+	return p, decide(st, id, store.SendRejected)
+}
+
+// The held message with this ID, if still undecided
+func pending(st store.Store, id int64) (store.PendingSend, error) {
+	p, ok, err := st.GetPendingSend(id)
+	if err != nil {
+		return p, err
+	}
+	if !ok || p.Status != store.SendPending {
+		return p, ErrNotPending
+	}
+	return p, nil
+}
+
+func decide(st store.Store, id int64, status string) error {
+	ok, err := st.DecidePendingSend(id, status, time.Now())
+	if err == nil && !ok {
+		err = ErrNotPending
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+// Package outbox delivers messages queued to send at a later time.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/store"
+)
+
+// How often due messages are checked for
+const pollInterval = 30 * time.Second
+
+// How long one send may take
+const sendTimeout = 30 * time.Second
+
+// Failed sends are retried after retryBase, doubling each time up to
+// maxBackoff, and given up after maxAttempts
+const (
+	retryBase   = time.Minute
+	maxBackoff  = time.Hour
+	maxAttempts = 8
+)
+
+// Sender delivers messages; *wa.Logger implements it
+type Sender interface {
+	SendText(ctx context.Context, chatJID, text string) error
+	Connected() bool
+}
+
+// Outbox sends queued messages from a store once they are due
+type Outbox struct {
+	st   store.Store
+	send Sender
+	log  waLog.Logger
+	now  func() time.Time
+}
+
+// Create an outbox delivering the messages queued in st through send
+func New(st store.Store, send Sender, log waLog.Logger) *Outbox {
+	return &Outbox{st: st, send: send, log: log, now: time.Now}
+}
+
+// Deliver due messages until ctx is cancelled
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		o.sendDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Send every message that is due. Nothing is attempted while disconnected,
+// so time offline does not use up retries.
+func (o *Outbox) sendDue(ctx context.Context) {
+	if !o.send.Connected() {
+		return
+	}
+	due, err := o.st.DueOutbox(o.now())
+	if err != nil {
+		o.log.Warnf("Failed to list outbox: %v", err)
+		return
+	}
+	for _, m := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		o.deliver(ctx, m)
+	}
+}
+
+// Send one message, recording the outcome
+func (o *Outbox) deliver(ctx context.Context, m store.OutboxMessage) {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	if err := o.send.SendText(sendCtx, m.ChatJID, m.Text); err != nil {
+		next := time.Time{}
+		if m.Attempts+1 < maxAttempts {
+			next = o.now().Add(backoff(m.Attempts + 1))
+		}
+		o.log.Warnf("Failed to send outbox message %d to %s (attempt %d): %v", m.ID, m.ChatJID, m.Attempts+1, err)
+		if err := o.st.RecordOutboxFailure(m.ID, err.Error(), next); err != nil {
+			o.log.Warnf("Failed to record outbox failure: %v", err)
+		}
+		return
+	}
+	if err := o.st.MarkOutboxSent(m.ID, o.now()); err != nil {
+		o.log.Warnf("Failed to mark outbox message %d sent: %v", m.ID, err)
+	}
+	o.log.Infof("Sent outbox message %d to %s", m.ID, m.ChatJID)
+}
+
+// Delay before retrying a message that has failed attempts times
+func backoff(attempts int) time.Duration {
+	d := retryBase
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
@@ -0,0 +1,70 @@
+// Package phone normalizes phone numbers and phone-number JIDs to E.164.
+package phone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Normalize a phone number as a person might write it ("+44 7700 900123",
+// "(555) 123-4567", "07700 900123") to E.164, "+447700900123". Numbers
+// without a country code are read in region (an ISO 3166 code such as "GB");
+// with no region, bare digits are taken to start with the country code, as
+// in a JID.
+func Normalize(raw, region string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("empty phone number")
+	}
+	// Candidate readings, most likely first; a valid number beats one that
+	// merely has a plausible length
+	var candidates []*phonenumbers.PhoneNumber
+	if strings.HasPrefix(raw, "+") {
+		if num, err := phonenumbers.Parse(raw, ""); err == nil {
+			candidates = append(candidates, num)
+		}
+	} else {
+		if region != "" {
+			if num, err := phonenumbers.Parse(raw, strings.ToUpper(region)); err == nil {
+				candidates = append(candidates, num)
+			}
+		}
+		if num, err := phonenumbers.Parse("+"+raw, ""); err == nil {
+			candidates = append(candidates, num)
+		}
+	}
+	for _, check := range []func(*phonenumbers.PhoneNumber) bool{phonenumbers.IsValidNumber, phonenumbers.IsPossibleNumber} {
+		for _, num := range candidates {
+			if check(num) {
+				return phonenumbers.Format(num, phonenumbers.E164), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("invalid phone number %q", raw)
+}
+
+// The E.164 number behind a phone-number JID, a device JID, a legacy c.us
+// JID or a bare user part; "" for groups, LIDs and anything else
+func FromJID(jid string) string {
+	user, server, hasServer := strings.Cut(jid, "@")
+	user, _, _ = strings.Cut(user, ":")
+	if hasServer && server != types.DefaultUserServer && server != types.LegacyUserServer {
+		return ""
+	}
+	if user == "" || strings.Trim(user, "0123456789") != "" {
+		return ""
+	}
+	e164, err := Normalize("+"+user, "")
+	if err != nil {
+		return ""
+	}
+	return e164
+}
+
+// The user JID for an E.164 number
+func JID(e164 string) string {
+	return strings.TrimPrefix(e164, "+") + "@" + types.DefaultUserServer
+}
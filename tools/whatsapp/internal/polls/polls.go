@@ -0,0 +1,91 @@
+// Package polls tallies the votes cast in WhatsApp polls.
+package polls
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	"whatsapp-logger/internal/store"
+)
+
+// A poll and how its votes stand
+type Result struct {
+	store.Poll
+	Tally []Option `json:"tally"`
+	// People with a vote in, not counting withdrawn ones
+	Voters int `json:"voters"`
+}
+
+// One option and who picked it
+type Option struct {
+	Name   string   `json:"name"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters"`
+}
+
+// Count votes for each of p's options, keeping the poll's option order
+func Tally(p store.Poll, votes []store.PollVote) Result {
+	r := Result{Poll: p, Tally: make([]Option, len(p.Options))}
+	byHash := map[string]int{}
+	for i, name := range p.Options {
+		r.Tally[i] = Option{Name: name, Voters: []string{}}
+		byHash[Hash(name)] = i
+	}
+	for _, v := range votes {
+		counted := false
+		for _, h := range v.OptionHashes {
+			i, ok := byHash[h]
+			if !ok {
+				continue
+			}
+			r.Tally[i].Votes++
+			r.Tally[i].Voters = append(r.Tally[i].Voters, v.Voter)
+			counted = true
+		}
+		if counted {
+			r.Voters++
+		}
+	}
+	for i := range r.Tally {
+		sort.Strings(r.Tally[i].Voters)
+	}
+	return r
+}
+
+// Hex SHA-256 of an option's text, which is how votes refer to it
+func Hash(option string) string {
+	sum := sha256.Sum256([]byte(option))
+	return hex.EncodeToString(sum[:])
+}
+
+// The poll started by the message with this key, with its votes counted
+func Get(st store.Store, key store.MessageKey) (Result, error) {
+	p, err := st.GetPoll(key)
+	if err != nil {
+		return Result{}, err
+	}
+	votes, err := st.PollVotes(key)
+	if err != nil {
+		return Result{}, err
+	}
+	return Tally(p, votes), nil
+}
+
+// Results of every poll in a chat and the chats linked to it, newest first.
+// An empty chatJID covers all chats.
+func List(st store.Store, chatJID string) ([]Result, error) {
+	found, err := st.ListPolls(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(found))
+	for _, p := range found {
+		votes, err := st.PollVotes(store.MessageKey{ID: p.ID, ChatJID: p.ChatJID})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, Tally(p, votes))
+	}
+	return results, nil
+}
@@ -0,0 +1,111 @@
+// Package quiet decides when quiet hours are in effect.
+package quiet
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/config"
+)
+
+// A parsed quiet window on a set of weekdays
+type window struct {
+	days [7]bool
+	// Minutes after midnight; end <= start means the window runs past midnight
+	start, end int
+}
+
+// Hours holds the configured quiet windows in a time zone
+type Hours struct {
+	windows []window
+	loc     *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parse the configured windows, evaluated in loc. Returns nil when no
+// windows are configured; a nil *Hours is never quiet.
+func New(cfg config.QuietHours, loc *time.Location) (*Hours, error) {
+	if len(cfg.Windows) == 0 {
+		return nil, nil
+	}
+	h := &Hours{loc: loc}
+	for i, w := range cfg.Windows {
+		var pw window
+		var err error
+		if pw.start, err = parseClock(w.Start); err != nil {
+			return nil, fmt.Errorf("quiet window %d: %w", i+1, err)
+		}
+		if pw.end, err = parseClock(w.End); err != nil {
+			return nil, fmt.Errorf("quiet window %d: %w", i+1, err)
+		}
+		if len(w.Days) == 0 {
+			for d := range pw.days {
+				pw.days[d] = true
+			}
+		}
+		for _, name := range w.Days {
+			d, ok := weekdays[strings.ToLower(name)[:min(3, len(name))]]
+			if !ok {
+				return nil, fmt.Errorf("quiet window %d: unknown day %q", i+1, name)
+			}
+			pw.days[d] = true
+		}
+		h.windows = append(h.windows, pw)
+	}
+	return h, nil
+}
+
+// Parse "HH:MM" into minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Report whether t falls in a quiet window, and if so when it ends
+func (h *Hours) Active(t time.Time) (bool, time.Time) {
+	if h == nil {
+		return false, time.Time{}
+	}
+	t = t.In(h.loc)
+	var until time.Time
+	for _, w := range h.windows {
+		if end, ok := w.contains(t); ok && end.After(until) {
+			until = end
+		}
+	}
+	return !until.IsZero(), until
+}
+
+// Whether t is inside the window, which starts on one of its days
+func (w window) contains(t time.Time) (time.Time, bool) {
+	minute := t.Hour()*60 + t.Minute()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	at := func(day time.Time, m int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), m/60, m%60, 0, 0, day.Location())
+	}
+
+	if w.end > w.start {
+		if w.days[t.Weekday()] && minute >= w.start && minute < w.end {
+			return at(midnight, w.end), true
+		}
+		return time.Time{}, false
+	}
+	// Overnight: the evening part today, or the morning tail of a window
+	// that started yesterday
+	if w.days[t.Weekday()] && minute >= w.start {
+		return at(midnight.AddDate(0, 0, 1), w.end), true
+	}
+	yesterday := midnight.AddDate(0, 0, -1)
+	if w.days[yesterday.Weekday()] && minute < w.end {
+		return at(midnight, w.end), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,129 @@
+// Package reconcile finds chat rows that name the same conversation under
+// different JID spellings and merges them.
+package reconcile
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/store"
+)
+
+// A planned merge of duplicate chat rows into one
+type Merge struct {
+	Into string `json:"into"`
+	// Name kept for the merged chat
+	Name string   `json:"name"`
+	From []string `json:"from"`
+	// Latest activity across the merged rows
+	LastMessageTime time.Time `json:"last_message_time"`
+}
+
+// The canonical spelling of a chat JID: no agent or device suffix, the
+// current user server instead of the legacy one, and lowercase server
+func Canonical(jid string) string {
+	jid = strings.TrimSpace(jid)
+	parsed, err := types.ParseJID(jid)
+	if err != nil || parsed.User == "" {
+		return jid
+	}
+	parsed.Server = strings.ToLower(parsed.Server)
+	if parsed.Server == types.LegacyUserServer {
+		parsed.Server = types.DefaultUserServer
+	}
+	return parsed.ToNonAD().String()
+}
+
+// Work out which chats need merging, without changing anything
+func Plan(st store.Store) ([]Merge, error) {
+	chats, err := st.ListChats()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string][]store.Chat{}
+	var order []string
+	for _, c := range chats {
+		key := Canonical(c.JID)
+		if _, seen := groups[key]; !seen {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], c)
+	}
+
+	var merges []Merge
+	for _, key := range order {
+		rows := groups[key]
+		if len(rows) == 1 && rows[0].JID == key {
+			continue
+		}
+		m := Merge{Into: key}
+		for _, c := range rows {
+			if c.JID != key {
+				m.From = append(m.From, c.JID)
+			}
+			if c.LastMessageTime.After(m.LastMessageTime) {
+				m.LastMessageTime = c.LastMessageTime
+			}
+		}
+		sort.Strings(m.From)
+		m.Name = bestName(key, rows)
+		merges = append(merges, m)
+	}
+	return merges, nil
+}
+
+// Apply planned merges, returning how many chat rows were removed
+func Apply(st store.Store, merges []Merge) (int, error) {
+	removed := 0
+	for _, m := range merges {
+		for _, from := range m.From {
+			if err := st.MergeChats(from, m.Into); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+		if err := st.StoreChat(m.Into, m.Name, m.LastMessageTime); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// Pick the most informative name among duplicate rows: a real name over a
+// placeholder derived from the JID, preferring the canonical row and then
+// the most recently active one
+func bestName(canonical string, rows []store.Chat) string {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if (rows[i].JID == canonical) != (rows[j].JID == canonical) {
+			return rows[i].JID == canonical
+		}
+		return rows[i].LastMessageTime.After(rows[j].LastMessageTime)
+	})
+	for _, c := range rows {
+		if !placeholder(c.Name, c.JID) {
+			return c.Name
+		}
+	}
+	if parsed, err := types.ParseJID(canonical); err == nil {
+		return extract.ChatName(parsed)
+	}
+	return canonical
+}
+
+// Whether name is empty or just derived from the JID
+func placeholder(name, jid string) bool {
+	name = strings.TrimSpace(name)
+	if name == "" || name == jid {
+		return true
+	}
+	parsed, err := types.ParseJID(jid)
+	if err != nil {
+		return false
+	}
+	return name == parsed.User || name == parsed.ToNonAD().String() || name == extract.ChatName(parsed.ToNonAD())
+}
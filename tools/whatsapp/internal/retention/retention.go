@@ -0,0 +1,87 @@
+// Package retention deletes messages older than their chat's limit.
+package retention
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// How long messages are kept; a zero age keeps them forever
+type Policy struct {
+	// Age limit for every chat
+	MaxAge time.Duration
+	// Limits for group chats and channels, and for direct chats, overriding
+	// MaxAge when set
+	Groups, Direct *time.Duration
+	// Limits by chat JID, overriding all of the above
+	Chats map[string]time.Duration
+}
+
+// Messages deleted from one chat, or due for deletion on a dry run
+type Pruned struct {
+	ChatJID  string    `json:"chat_jid"`
+	Name     string    `json:"name"`
+	Before   time.Time `json:"before"`
+	Messages int       `json:"messages"`
+}
+
+// The age limit for a chat. A limit set for any of its linked JIDs covers
+// the whole conversation.
+func (p Policy) maxAge(c store.Chat) time.Duration {
+	for _, jid := range append([]string{c.JID}, c.LinkedJIDs...) {
+		if age, ok := p.Chats[jid]; ok {
+			return age
+		}
+	}
+	if isGroup(c) {
+		if p.Groups != nil {
+			return *p.Groups
+		}
+	} else if p.Direct != nil {
+		return *p.Direct
+	}
+	return p.MaxAge
+}
+
+func isGroup(c store.Chat) bool {
+	return c.Channel || strings.HasSuffix(c.JID, "@g.us")
+}
+
+// Delete every chat's messages past its limit, or only count them on a dry
+// run. Chats with nothing to delete are left out of the result.
+func Prune(ctx context.Context, st store.Store, p Policy, now time.Time, dryRun bool) ([]Pruned, error) {
+	chats, err := st.ListChats()
+	if err != nil {
+		return nil, err
+	}
+	var pruned []Pruned
+	for _, c := range chats {
+		if ctx.Err() != nil {
+			return pruned, ctx.Err()
+		}
+		age := p.maxAge(c)
+		if age <= 0 {
+			continue
+		}
+		r := Pruned{ChatJID: c.JID, Name: c.Name, Before: now.Add(-age)}
+		for _, jid := range append([]string{c.JID}, c.LinkedJIDs...) {
+			var n int
+			if dryRun {
+				n, err = st.CountMessagesBefore(jid, r.Before)
+			} else {
+				n, err = st.DeleteMessagesBefore(jid, r.Before)
+			}
+			if err != nil {
+				return pruned, err
+			}
+			r.Messages += n
+		}
+		if r.Messages > 0 {
+			pruned = append(pruned, r)
+		}
+	}
+	return pruned, nil
+}
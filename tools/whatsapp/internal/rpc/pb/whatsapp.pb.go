@@ -0,0 +1,944 @@
+// Typed access to the WhatsApp archive for other Kenny services. Generate
+// clients from this file, e.g. with grpcio-tools for Python.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: whatsapp.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SendResponse_Status int32
+
+const (
+	SendResponse_STATUS_UNSPECIFIED SendResponse_Status = 0
+	// Delivered to WhatsApp
+	SendResponse_SENT SendResponse_Status = 1
+	// Queued in the outbox under id
+	SendResponse_QUEUED SendResponse_Status = 2
+	// Held for approval under id
+	SendResponse_HELD SendResponse_Status = 3
+)
+
+// Enum value maps for SendResponse_Status.
+var (
+	SendResponse_Status_name = map[int32]string{
+		0: "STATUS_UNSPECIFIED",
+		1: "SENT",
+		2: "QUEUED",
+		3: "HELD",
+	}
+	SendResponse_Status_value = map[string]int32{
+		"STATUS_UNSPECIFIED": 0,
+		"SENT":               1,
+		"QUEUED":             2,
+		"HELD":               3,
+	}
+)
+
+func (x SendResponse_Status) Enum() *SendResponse_Status {
+	p := new(SendResponse_Status)
+	*p = x
+	return p
+}
+
+func (x SendResponse_Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SendResponse_Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_whatsapp_proto_enumTypes[0].Descriptor()
+}
+
+func (SendResponse_Status) Type() protoreflect.EnumType {
+	return &file_whatsapp_proto_enumTypes[0]
+}
+
+func (x SendResponse_Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SendResponse_Status.Descriptor instead.
+func (SendResponse_Status) EnumDescriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{7, 0}
+}
+
+type Chat struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Jid             string                 `protobuf:"bytes,1,opt,name=jid,proto3" json:"jid,omitempty"`
+	Name            string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	LastMessageTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=last_message_time,json=lastMessageTime,proto3" json:"last_message_time,omitempty"`
+	// E.164 number of a direct chat
+	Phone string `protobuf:"bytes,4,opt,name=phone,proto3" json:"phone,omitempty"`
+	// Earlier JIDs of this conversation, linked with link-chats
+	LinkedJids []string `protobuf:"bytes,5,rep,name=linked_jids,json=linkedJids,proto3" json:"linked_jids,omitempty"`
+	// A WhatsApp Channel the account follows
+	Channel  bool `protobuf:"varint,6,opt,name=channel,proto3" json:"channel,omitempty"`
+	Muted    bool `protobuf:"varint,7,opt,name=muted,proto3" json:"muted,omitempty"`
+	Archived bool `protobuf:"varint,8,opt,name=archived,proto3" json:"archived,omitempty"`
+	Pinned   bool `protobuf:"varint,9,opt,name=pinned,proto3" json:"pinned,omitempty"`
+	// The community a group chat belongs to
+	Community     string `protobuf:"bytes,10,opt,name=community,proto3" json:"community,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chat) Reset() {
+	*x = Chat{}
+	mi := &file_whatsapp_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chat) ProtoMessage() {}
+
+func (x *Chat) ProtoReflect() protoreflect.Message {
+	mi := &file_whatsapp_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chat.ProtoReflect.Descriptor instead.
+func (*Chat) Descriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Chat) GetJid() string {
+	if x != nil {
+		return x.Jid
+	}
+	return ""
+}
+
+func (x *Chat) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Chat) GetLastMessageTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastMessageTime
+	}
+	return nil
+}
+
+func (x *Chat) GetPhone() string {
+	if x != nil {
+		return x.Phone
+	}
+	return ""
+}
+
+func (x *Chat) GetLinkedJids() []string {
+	if x != nil {
+		return x.LinkedJids
+	}
+	return nil
+}
+
+func (x *Chat) GetChannel() bool {
+	if x != nil {
+		return x.Channel
+	}
+	return false
+}
+
+func (x *Chat) GetMuted() bool {
+	if x != nil {
+		return x.Muted
+	}
+	return false
+}
+
+func (x *Chat) GetArchived() bool {
+	if x != nil {
+		return x.Archived
+	}
+	return false
+}
+
+func (x *Chat) GetPinned() bool {
+	if x != nil {
+		return x.Pinned
+	}
+	return false
+}
+
+func (x *Chat) GetCommunity() string {
+	if x != nil {
+		return x.Community
+	}
+	return ""
+}
+
+type Message struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Id         string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ChatJid    string                 `protobuf:"bytes,2,opt,name=chat_jid,json=chatJid,proto3" json:"chat_jid,omitempty"`
+	ChatName   string                 `protobuf:"bytes,3,opt,name=chat_name,json=chatName,proto3" json:"chat_name,omitempty"`
+	Sender     string                 `protobuf:"bytes,4,opt,name=sender,proto3" json:"sender,omitempty"`
+	SenderName string                 `protobuf:"bytes,5,opt,name=sender_name,json=senderName,proto3" json:"sender_name,omitempty"`
+	// Sender's number in E.164, when the sender is a phone-number JID
+	SenderPhone string                 `protobuf:"bytes,6,opt,name=sender_phone,json=senderPhone,proto3" json:"sender_phone,omitempty"`
+	Content     string                 `protobuf:"bytes,7,opt,name=content,proto3" json:"content,omitempty"`
+	Timestamp   *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	IsFromMe    bool                   `protobuf:"varint,9,opt,name=is_from_me,json=isFromMe,proto3" json:"is_from_me,omitempty"`
+	// image, video, audio, document or sticker; empty for text
+	MediaType string `protobuf:"bytes,10,opt,name=media_type,json=mediaType,proto3" json:"media_type,omitempty"`
+	Filename  string `protobuf:"bytes,11,opt,name=filename,proto3" json:"filename,omitempty"`
+	MimeType  string `protobuf:"bytes,12,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	// Where a downloaded attachment is kept, on disk or in object storage
+	LocalPath string `protobuf:"bytes,13,opt,name=local_path,json=localPath,proto3" json:"local_path,omitempty"`
+	ObjectUrl string `protobuf:"bytes,14,opt,name=object_url,json=objectUrl,proto3" json:"object_url,omitempty"`
+	// Text read from a downloaded image
+	OcrText string `protobuf:"bytes,15,opt,name=ocr_text,json=ocrText,proto3" json:"ocr_text,omitempty"`
+	// ID and sender of the message this one replies to
+	ReplyTo       string `protobuf:"bytes,16,opt,name=reply_to,json=replyTo,proto3" json:"reply_to,omitempty"`
+	ReplyToSender string `protobuf:"bytes,17,opt,name=reply_to_sender,json=replyToSender,proto3" json:"reply_to_sender,omitempty"`
+	IsForwarded   bool   `protobuf:"varint,18,opt,name=is_forwarded,json=isForwarded,proto3" json:"is_forwarded,omitempty"`
+	// Unset unless the message was edited or deleted for everyone
+	EditedAt      *timestamppb.Timestamp `protobuf:"bytes,19,opt,name=edited_at,json=editedAt,proto3" json:"edited_at,omitempty"`
+	DeletedAt     *timestamppb.Timestamp `protobuf:"bytes,20,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	mi := &file_whatsapp_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_whatsapp_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Message) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Message) GetChatJid() string {
+	if x != nil {
+		return x.ChatJid
+	}
+	return ""
+}
+
+func (x *Message) GetChatName() string {
+	if x != nil {
+		return x.ChatName
+	}
+	return ""
+}
+
+func (x *Message) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *Message) GetSenderName() string {
+	if x != nil {
+		return x.SenderName
+	}
+	return ""
+}
+
+func (x *Message) GetSenderPhone() string {
+	if x != nil {
+		return x.SenderPhone
+	}
+	return ""
+}
+
+func (x *Message) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *Message) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Message) GetIsFromMe() bool {
+	if x != nil {
+		return x.IsFromMe
+	}
+	return false
+}
+
+func (x *Message) GetMediaType() string {
+	if x != nil {
+		return x.MediaType
+	}
+	return ""
+}
+
+func (x *Message) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *Message) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *Message) GetLocalPath() string {
+	if x != nil {
+		return x.LocalPath
+	}
+	return ""
+}
+
+func (x *Message) GetObjectUrl() string {
+	if x != nil {
+		return x.ObjectUrl
+	}
+	return ""
+}
+
+func (x *Message) GetOcrText() string {
+	if x != nil {
+		return x.OcrText
+	}
+	return ""
+}
+
+func (x *Message) GetReplyTo() string {
+	if x != nil {
+		return x.ReplyTo
+	}
+	return ""
+}
+
+func (x *Message) GetReplyToSender() string {
+	if x != nil {
+		return x.ReplyToSender
+	}
+	return ""
+}
+
+func (x *Message) GetIsForwarded() bool {
+	if x != nil {
+		return x.IsForwarded
+	}
+	return false
+}
+
+func (x *Message) GetEditedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EditedAt
+	}
+	return nil
+}
+
+func (x *Message) GetDeletedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return nil
+}
+
+type ChatsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only chats whose name, JID or phone number contains this
+	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// Most chats to return; 0 for all
+	Limit         int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatsRequest) Reset() {
+	*x = ChatsRequest{}
+	mi := &file_whatsapp_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatsRequest) ProtoMessage() {}
+
+func (x *ChatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_whatsapp_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatsRequest.ProtoReflect.Descriptor instead.
+func (*ChatsRequest) Descriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ChatsRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ChatsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ChatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chats         []*Chat                `protobuf:"bytes,1,rep,name=chats,proto3" json:"chats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatsResponse) Reset() {
+	*x = ChatsResponse{}
+	mi := &file_whatsapp_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatsResponse) ProtoMessage() {}
+
+func (x *ChatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_whatsapp_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatsResponse.ProtoReflect.Descriptor instead.
+func (*ChatsResponse) Descriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ChatsResponse) GetChats() []*Chat {
+	if x != nil {
+		return x.Chats
+	}
+	return nil
+}
+
+type MessagesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The chat to read, including chats linked to it; required unless query
+	// is set
+	ChatJid string `protobuf:"bytes,1,opt,name=chat_jid,json=chatJid,proto3" json:"chat_jid,omitempty"`
+	// Full-text query; results come newest first
+	Query string `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+	// Only messages at or after since and before until; history only
+	Since *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=since,proto3" json:"since,omitempty"`
+	Until *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=until,proto3" json:"until,omitempty"`
+	// Most messages to return (default 50, at most 500)
+	Limit         int32 `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessagesRequest) Reset() {
+	*x = MessagesRequest{}
+	mi := &file_whatsapp_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessagesRequest) ProtoMessage() {}
+
+func (x *MessagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_whatsapp_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessagesRequest.ProtoReflect.Descriptor instead.
+func (*MessagesRequest) Descriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MessagesRequest) GetChatJid() string {
+	if x != nil {
+		return x.ChatJid
+	}
+	return ""
+}
+
+func (x *MessagesRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *MessagesRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *MessagesRequest) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+func (x *MessagesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type MessagesResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Oldest first for a chat's history, the latest in range; newest first
+	// for a query
+	Messages      []*Message `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessagesResponse) Reset() {
+	*x = MessagesResponse{}
+	mi := &file_whatsapp_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessagesResponse) ProtoMessage() {}
+
+func (x *MessagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_whatsapp_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessagesResponse.ProtoReflect.Descriptor instead.
+func (*MessagesResponse) Descriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MessagesResponse) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+type SendRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	ChatJid string                 `protobuf:"bytes,1,opt,name=chat_jid,json=chatJid,proto3" json:"chat_jid,omitempty"`
+	Text    string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	// Send later, through the outbox
+	SendAt        *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=send_at,json=sendAt,proto3" json:"send_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendRequest) Reset() {
+	*x = SendRequest{}
+	mi := &file_whatsapp_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendRequest) ProtoMessage() {}
+
+func (x *SendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_whatsapp_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendRequest.ProtoReflect.Descriptor instead.
+func (*SendRequest) Descriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SendRequest) GetChatJid() string {
+	if x != nil {
+		return x.ChatJid
+	}
+	return ""
+}
+
+func (x *SendRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *SendRequest) GetSendAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SendAt
+	}
+	return nil
+}
+
+type SendResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        SendResponse_Status    `protobuf:"varint,1,opt,name=status,proto3,enum=kenny.whatsapp.v1.SendResponse_Status" json:"status,omitempty"`
+	Id            int64                  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendResponse) Reset() {
+	*x = SendResponse{}
+	mi := &file_whatsapp_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendResponse) ProtoMessage() {}
+
+func (x *SendResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_whatsapp_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendResponse.ProtoReflect.Descriptor instead.
+func (*SendResponse) Descriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SendResponse) GetStatus() SendResponse_Status {
+	if x != nil {
+		return x.Status
+	}
+	return SendResponse_STATUS_UNSPECIFIED
+}
+
+func (x *SendResponse) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type StreamRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only messages in these chats; empty for all
+	ChatJids      []string `protobuf:"bytes,1,rep,name=chat_jids,json=chatJids,proto3" json:"chat_jids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamRequest) Reset() {
+	*x = StreamRequest{}
+	mi := &file_whatsapp_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamRequest) ProtoMessage() {}
+
+func (x *StreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_whatsapp_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamRequest.ProtoReflect.Descriptor instead.
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return file_whatsapp_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StreamRequest) GetChatJids() []string {
+	if x != nil {
+		return x.ChatJids
+	}
+	return nil
+}
+
+var File_whatsapp_proto protoreflect.FileDescriptor
+
+const file_whatsapp_proto_rawDesc = "" +
+	"\n" +
+	"\x0ewhatsapp.proto\x12\x11kenny.whatsapp.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xad\x02\n" +
+	"\x04Chat\x12\x10\n" +
+	"\x03jid\x18\x01 \x01(\tR\x03jid\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12F\n" +
+	"\x11last_message_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x0flastMessageTime\x12\x14\n" +
+	"\x05phone\x18\x04 \x01(\tR\x05phone\x12\x1f\n" +
+	"\vlinked_jids\x18\x05 \x03(\tR\n" +
+	"linkedJids\x12\x18\n" +
+	"\achannel\x18\x06 \x01(\bR\achannel\x12\x14\n" +
+	"\x05muted\x18\a \x01(\bR\x05muted\x12\x1a\n" +
+	"\barchived\x18\b \x01(\bR\barchived\x12\x16\n" +
+	"\x06pinned\x18\t \x01(\bR\x06pinned\x12\x1c\n" +
+	"\tcommunity\x18\n" +
+	" \x01(\tR\tcommunity\"\xaa\x05\n" +
+	"\aMessage\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x19\n" +
+	"\bchat_jid\x18\x02 \x01(\tR\achatJid\x12\x1b\n" +
+	"\tchat_name\x18\x03 \x01(\tR\bchatName\x12\x16\n" +
+	"\x06sender\x18\x04 \x01(\tR\x06sender\x12\x1f\n" +
+	"\vsender_name\x18\x05 \x01(\tR\n" +
+	"senderName\x12!\n" +
+	"\fsender_phone\x18\x06 \x01(\tR\vsenderPhone\x12\x18\n" +
+	"\acontent\x18\a \x01(\tR\acontent\x128\n" +
+	"\ttimestamp\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x1c\n" +
+	"\n" +
+	"is_from_me\x18\t \x01(\bR\bisFromMe\x12\x1d\n" +
+	"\n" +
+	"media_type\x18\n" +
+	" \x01(\tR\tmediaType\x12\x1a\n" +
+	"\bfilename\x18\v \x01(\tR\bfilename\x12\x1b\n" +
+	"\tmime_type\x18\f \x01(\tR\bmimeType\x12\x1d\n" +
+	"\n" +
+	"local_path\x18\r \x01(\tR\tlocalPath\x12\x1d\n" +
+	"\n" +
+	"object_url\x18\x0e \x01(\tR\tobjectUrl\x12\x19\n" +
+	"\bocr_text\x18\x0f \x01(\tR\aocrText\x12\x19\n" +
+	"\breply_to\x18\x10 \x01(\tR\areplyTo\x12&\n" +
+	"\x0freply_to_sender\x18\x11 \x01(\tR\rreplyToSender\x12!\n" +
+	"\fis_forwarded\x18\x12 \x01(\bR\visForwarded\x127\n" +
+	"\tedited_at\x18\x13 \x01(\v2\x1a.google.protobuf.TimestampR\beditedAt\x129\n" +
+	"\n" +
+	"deleted_at\x18\x14 \x01(\v2\x1a.google.protobuf.TimestampR\tdeletedAt\":\n" +
+	"\fChatsRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\">\n" +
+	"\rChatsResponse\x12-\n" +
+	"\x05chats\x18\x01 \x03(\v2\x17.kenny.whatsapp.v1.ChatR\x05chats\"\xbc\x01\n" +
+	"\x0fMessagesRequest\x12\x19\n" +
+	"\bchat_jid\x18\x01 \x01(\tR\achatJid\x12\x14\n" +
+	"\x05query\x18\x02 \x01(\tR\x05query\x120\n" +
+	"\x05since\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x05until\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\"J\n" +
+	"\x10MessagesResponse\x126\n" +
+	"\bmessages\x18\x01 \x03(\v2\x1a.kenny.whatsapp.v1.MessageR\bmessages\"q\n" +
+	"\vSendRequest\x12\x19\n" +
+	"\bchat_jid\x18\x01 \x01(\tR\achatJid\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x123\n" +
+	"\asend_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x06sendAt\"\xa0\x01\n" +
+	"\fSendResponse\x12>\n" +
+	"\x06status\x18\x01 \x01(\x0e2&.kenny.whatsapp.v1.SendResponse.StatusR\x06status\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x03R\x02id\"@\n" +
+	"\x06Status\x12\x16\n" +
+	"\x12STATUS_UNSPECIFIED\x10\x00\x12\b\n" +
+	"\x04SENT\x10\x01\x12\n" +
+	"\n" +
+	"\x06QUEUED\x10\x02\x12\b\n" +
+	"\x04HELD\x10\x03\",\n" +
+	"\rStreamRequest\x12\x1b\n" +
+	"\tchat_jids\x18\x01 \x03(\tR\bchatJids2\xbe\x02\n" +
+	"\bWhatsApp\x12J\n" +
+	"\x05Chats\x12\x1f.kenny.whatsapp.v1.ChatsRequest\x1a .kenny.whatsapp.v1.ChatsResponse\x12S\n" +
+	"\bMessages\x12\".kenny.whatsapp.v1.MessagesRequest\x1a#.kenny.whatsapp.v1.MessagesResponse\x12G\n" +
+	"\x04Send\x12\x1e.kenny.whatsapp.v1.SendRequest\x1a\x1f.kenny.whatsapp.v1.SendResponse\x12H\n" +
+	"\x06Stream\x12 .kenny.whatsapp.v1.StreamRequest\x1a\x1a.kenny.whatsapp.v1.Message0\x01B!Z\x1fwhatsapp-logger/internal/rpc/pbb\x06proto3"
+
+var (
+	file_whatsapp_proto_rawDescOnce sync.Once
+	file_whatsapp_proto_rawDescData []byte
+)
+
+func file_whatsapp_proto_rawDescGZIP() []byte {
+	file_whatsapp_proto_rawDescOnce.Do(func() {
+		file_whatsapp_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_whatsapp_proto_rawDesc), len(file_whatsapp_proto_rawDesc)))
+	})
+	return file_whatsapp_proto_rawDescData
+}
+
+var file_whatsapp_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_whatsapp_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_whatsapp_proto_goTypes = []any{
+	(SendResponse_Status)(0),      // 0: kenny.whatsapp.v1.SendResponse.Status
+	(*Chat)(nil),                  // 1: kenny.whatsapp.v1.Chat
+	(*Message)(nil),               // 2: kenny.whatsapp.v1.Message
+	(*ChatsRequest)(nil),          // 3: kenny.whatsapp.v1.ChatsRequest
+	(*ChatsResponse)(nil),         // 4: kenny.whatsapp.v1.ChatsResponse
+	(*MessagesRequest)(nil),       // 5: kenny.whatsapp.v1.MessagesRequest
+	(*MessagesResponse)(nil),      // 6: kenny.whatsapp.v1.MessagesResponse
+	(*SendRequest)(nil),           // 7: kenny.whatsapp.v1.SendRequest
+	(*SendResponse)(nil),          // 8: kenny.whatsapp.v1.SendResponse
+	(*StreamRequest)(nil),         // 9: kenny.whatsapp.v1.StreamRequest
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_whatsapp_proto_depIdxs = []int32{
+	10, // 0: kenny.whatsapp.v1.Chat.last_message_time:type_name -> google.protobuf.Timestamp
+	10, // 1: kenny.whatsapp.v1.Message.timestamp:type_name -> google.protobuf.Timestamp
+	10, // 2: kenny.whatsapp.v1.Message.edited_at:type_name -> google.protobuf.Timestamp
+	10, // 3: kenny.whatsapp.v1.Message.deleted_at:type_name -> google.protobuf.Timestamp
+	1,  // 4: kenny.whatsapp.v1.ChatsResponse.chats:type_name -> kenny.whatsapp.v1.Chat
+	10, // 5: kenny.whatsapp.v1.MessagesRequest.since:type_name -> google.protobuf.Timestamp
+	10, // 6: kenny.whatsapp.v1.MessagesRequest.until:type_name -> google.protobuf.Timestamp
+	2,  // 7: kenny.whatsapp.v1.MessagesResponse.messages:type_name -> kenny.whatsapp.v1.Message
+	10, // 8: kenny.whatsapp.v1.SendRequest.send_at:type_name -> google.protobuf.Timestamp
+	0,  // 9: kenny.whatsapp.v1.SendResponse.status:type_name -> kenny.whatsapp.v1.SendResponse.Status
+	3,  // 10: kenny.whatsapp.v1.WhatsApp.Chats:input_type -> kenny.whatsapp.v1.ChatsRequest
+	5,  // 11: kenny.whatsapp.v1.WhatsApp.Messages:input_type -> kenny.whatsapp.v1.MessagesRequest
+	7,  // 12: kenny.whatsapp.v1.WhatsApp.Send:input_type -> kenny.whatsapp.v1.SendRequest
+	9,  // 13: kenny.whatsapp.v1.WhatsApp.Stream:input_type -> kenny.whatsapp.v1.StreamRequest
+	4,  // 14: kenny.whatsapp.v1.WhatsApp.Chats:output_type -> kenny.whatsapp.v1.ChatsResponse
+	6,  // 15: kenny.whatsapp.v1.WhatsApp.Messages:output_type -> kenny.whatsapp.v1.MessagesResponse
+	8,  // 16: kenny.whatsapp.v1.WhatsApp.Send:output_type -> kenny.whatsapp.v1.SendResponse
+	2,  // 17: kenny.whatsapp.v1.WhatsApp.Stream:output_type -> kenny.whatsapp.v1.Message
+	14, // [14:18] is the sub-list for method output_type
+	10, // [10:14] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_whatsapp_proto_init() }
+func file_whatsapp_proto_init() {
+	if File_whatsapp_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_whatsapp_proto_rawDesc), len(file_whatsapp_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_whatsapp_proto_goTypes,
+		DependencyIndexes: file_whatsapp_proto_depIdxs,
+		EnumInfos:         file_whatsapp_proto_enumTypes,
+		MessageInfos:      file_whatsapp_proto_msgTypes,
+	}.Build()
+	File_whatsapp_proto = out.File
+	file_whatsapp_proto_goTypes = nil
+	file_whatsapp_proto_depIdxs = nil
+}
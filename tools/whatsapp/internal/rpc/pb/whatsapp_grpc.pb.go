@@ -0,0 +1,250 @@
+// Typed access to the WhatsApp archive for other Kenny services. Generate
+// clients from this file, e.g. with grpcio-tools for Python.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: whatsapp.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	WhatsApp_Chats_FullMethodName    = "/kenny.whatsapp.v1.WhatsApp/Chats"
+	WhatsApp_Messages_FullMethodName = "/kenny.whatsapp.v1.WhatsApp/Messages"
+	WhatsApp_Send_FullMethodName     = "/kenny.whatsapp.v1.WhatsApp/Send"
+	WhatsApp_Stream_FullMethodName   = "/kenny.whatsapp.v1.WhatsApp/Stream"
+)
+
+// WhatsAppClient is the client API for WhatsApp service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WhatsAppClient interface {
+	// Chats, most recently active first
+	Chats(ctx context.Context, in *ChatsRequest, opts ...grpc.CallOption) (*ChatsResponse, error)
+	// A chat's history, or messages matching a full-text query
+	Messages(ctx context.Context, in *MessagesRequest, opts ...grpc.CallOption) (*MessagesResponse, error)
+	// Send a text message, or queue it for later
+	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error)
+	// Messages as the logger stores them, until the call is cancelled
+	Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Message], error)
+}
+
+type whatsAppClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWhatsAppClient(cc grpc.ClientConnInterface) WhatsAppClient {
+	return &whatsAppClient{cc}
+}
+
+func (c *whatsAppClient) Chats(ctx context.Context, in *ChatsRequest, opts ...grpc.CallOption) (*ChatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatsResponse)
+	err := c.cc.Invoke(ctx, WhatsApp_Chats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whatsAppClient) Messages(ctx context.Context, in *MessagesRequest, opts ...grpc.CallOption) (*MessagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessagesResponse)
+	err := c.cc.Invoke(ctx, WhatsApp_Messages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whatsAppClient) Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendResponse)
+	err := c.cc.Invoke(ctx, WhatsApp_Send_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whatsAppClient) Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Message], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WhatsApp_ServiceDesc.Streams[0], WhatsApp_Stream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamRequest, Message]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WhatsApp_StreamClient = grpc.ServerStreamingClient[Message]
+
+// WhatsAppServer is the server API for WhatsApp service.
+// All implementations must embed UnimplementedWhatsAppServer
+// for forward compatibility.
+type WhatsAppServer interface {
+	// Chats, most recently active first
+	Chats(context.Context, *ChatsRequest) (*ChatsResponse, error)
+	// A chat's history, or messages matching a full-text query
+	Messages(context.Context, *MessagesRequest) (*MessagesResponse, error)
+	// Send a text message, or queue it for later
+	Send(context.Context, *SendRequest) (*SendResponse, error)
+	// Messages as the logger stores them, until the call is cancelled
+	Stream(*StreamRequest, grpc.ServerStreamingServer[Message]) error
+	mustEmbedUnimplementedWhatsAppServer()
+}
+
+// UnimplementedWhatsAppServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedWhatsAppServer struct{}
+
+func (UnimplementedWhatsAppServer) Chats(context.Context, *ChatsRequest) (*ChatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Chats not implemented")
+}
+func (UnimplementedWhatsAppServer) Messages(context.Context, *MessagesRequest) (*MessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Messages not implemented")
+}
+func (UnimplementedWhatsAppServer) Send(context.Context, *SendRequest) (*SendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedWhatsAppServer) Stream(*StreamRequest, grpc.ServerStreamingServer[Message]) error {
+	return status.Errorf(codes.Unimplemented, "method Stream not implemented")
+}
+func (UnimplementedWhatsAppServer) mustEmbedUnimplementedWhatsAppServer() {}
+func (UnimplementedWhatsAppServer) testEmbeddedByValue()                  {}
+
+// UnsafeWhatsAppServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WhatsAppServer will
+// result in compilation errors.
+type UnsafeWhatsAppServer interface {
+	mustEmbedUnimplementedWhatsAppServer()
+}
+
+func RegisterWhatsAppServer(s grpc.ServiceRegistrar, srv WhatsAppServer) {
+	// If the following call pancis, it indicates UnimplementedWhatsAppServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&WhatsApp_ServiceDesc, srv)
+}
+
+func _WhatsApp_Chats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhatsAppServer).Chats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WhatsApp_Chats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhatsAppServer).Chats(ctx, req.(*ChatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WhatsApp_Messages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhatsAppServer).Messages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WhatsApp_Messages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhatsAppServer).Messages(ctx, req.(*MessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WhatsApp_Send_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhatsAppServer).Send(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WhatsApp_Send_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhatsAppServer).Send(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WhatsApp_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WhatsAppServer).Stream(m, &grpc.GenericServerStream[StreamRequest, Message]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WhatsApp_StreamServer = grpc.ServerStreamingServer[Message]
+
+// WhatsApp_ServiceDesc is the grpc.ServiceDesc for WhatsApp service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WhatsApp_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kenny.whatsapp.v1.WhatsApp",
+	HandlerType: (*WhatsAppServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Chats",
+			Handler:    _WhatsApp_Chats_Handler,
+		},
+		{
+			MethodName: "Messages",
+			Handler:    _WhatsApp_Messages_Handler,
+		},
+		{
+			MethodName: "Send",
+			Handler:    _WhatsApp_Send_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			Handler:       _WhatsApp_Stream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "whatsapp.proto",
+}
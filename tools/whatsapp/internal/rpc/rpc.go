@@ -0,0 +1,404 @@
+// Package rpc serves the message archive over gRPC, so other Kenny services
+// get typed clients instead of reading the database. The service is defined
+// in pb/whatsapp.proto; regenerate pb with go generate after changing it.
+package rpc
+
+//go:generate protoc -I pb --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative pb/whatsapp.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"whatsapp-logger/internal/cold"
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/live"
+	"whatsapp-logger/internal/rpc/pb"
+	"whatsapp-logger/internal/store"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// Server implements the WhatsApp gRPC service over a store
+type Server struct {
+	pb.UnimplementedWhatsAppServer
+
+	store store.Store
+	cfg   config.API
+	log   waLog.Logger
+	// Optional archive of messages moved out of the store
+	cold *cold.Archive
+	// Sends messages right away; nil queues them in the outbox instead
+	sender Sender
+	// Hold messages for approval instead of sending or queueing them
+	requireApproval bool
+	// Live messages for Stream; nil when not running with the logger
+	hub *live.Hub
+}
+
+// Sender delivers text messages to WhatsApp; *wa.Logger implements it
+type Sender interface {
+	SendText(ctx context.Context, chatJID, text string) error
+}
+
+// Create a server reading from st, requiring the basic auth credentials of
+// cfg when it has them
+func New(st store.Store, cfg config.API, log waLog.Logger) *Server {
+	return &Server{store: st, cfg: cfg, log: log}
+}
+
+// Also search messages moved to cold storage; nil disables it
+func (s *Server) SetCold(a *cold.Archive) {
+	s.cold = a
+}
+
+// Send messages through sender; nil queues them for the logger to send
+func (s *Server) SetSender(sender Sender) {
+	s.sender = sender
+}
+
+// Hold messages sent through Send until approved
+func (s *Server) SetRequireApproval(on bool) {
+	s.requireApproval = on
+}
+
+// Stream messages published to hub
+func (s *Server) SetHub(hub *live.Hub) {
+	s.hub = hub
+}
+
+// Serve on addr until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := s.authorize(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := s.authorize(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	pb.RegisterWhatsAppServer(srv, s)
+	go func() {
+		<-ctx.Done()
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		// Streams stay open until cancelled, so don't wait on them for long
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			srv.Stop()
+		}
+	}()
+
+	s.log.Infof("Serving gRPC on %s", addr)
+	if err := srv.Serve(lis); !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Check the call's "authorization" metadata against the configured basic
+// auth credentials, when there are any
+func (s *Server) authorize(ctx context.Context) error {
+	if s.cfg.Username == "" || s.cfg.Password == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		encoded, ok := strings.CutPrefix(value, "Basic ")
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		user, pass, _ := strings.Cut(string(decoded), ":")
+		if subtle.ConstantTimeCompare([]byte(user), []byte(s.cfg.Username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(pass), []byte(s.cfg.Password)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "basic auth credentials required")
+}
+
+func (s *Server) Chats(ctx context.Context, req *pb.ChatsRequest) (*pb.ChatsResponse, error) {
+	chats, err := s.store.ListChats()
+	if err != nil {
+		return nil, s.internal(err)
+	}
+	query := strings.ToLower(req.Query)
+	resp := &pb.ChatsResponse{}
+	for _, c := range chats {
+		if req.Limit > 0 && len(resp.Chats) == int(req.Limit) {
+			break
+		}
+		if query == "" || strings.Contains(strings.ToLower(c.Name), query) ||
+			strings.Contains(c.JID, query) || (c.Phone != "" && strings.Contains(c.Phone, query)) {
+			resp.Chats = append(resp.Chats, chatProto(c))
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) Messages(ctx context.Context, req *pb.MessagesRequest) (*pb.MessagesResponse, error) {
+	limit := int(req.Limit)
+	switch {
+	case limit <= 0:
+		limit = defaultLimit
+	case limit > maxLimit:
+		limit = maxLimit
+	}
+	var messages []store.Message
+	var err error
+	switch {
+	case strings.TrimSpace(req.Query) != "":
+		messages, err = s.search(req.Query, req.ChatJid, limit)
+	case req.ChatJid != "":
+		messages, err = s.history(req.ChatJid, req.Since, req.Until, limit)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "chat_jid or query is required")
+	}
+	if err != nil {
+		return nil, s.internal(err)
+	}
+	resp := &pb.MessagesResponse{Messages: make([]*pb.Message, 0, len(messages))}
+	for _, m := range messages {
+		resp.Messages = append(resp.Messages, messageProto(m))
+	}
+	return resp, nil
+}
+
+// Messages matching query, newest first, in the hot store then cold
+// storage; only those in chatJID and the chats linked to it when set
+func (s *Server) search(query, chatJID string, limit int) ([]store.Message, error) {
+	inChat, err := s.chatGroup(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	// Filtering by chat afterwards needs a wider search to fill the limit
+	want := limit
+	if inChat != nil {
+		want = maxLimit
+	}
+	messages, err := s.store.SearchMessages(query, want)
+	if err != nil {
+		return nil, err
+	}
+	// Cold messages are all older than hot ones, so only fill the remainder
+	if s.cold != nil && len(messages) < want {
+		older, err := s.cold.Search(query, want-len(messages))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, older...)
+	}
+	found := messages[:0]
+	for _, m := range messages {
+		if len(found) == limit {
+			break
+		}
+		if inChat == nil || inChat[m.ChatJID] {
+			found = append(found, m)
+		}
+	}
+	return found, nil
+}
+
+// The last messages of a chat and the chats linked to it between since and
+// until, oldest first
+func (s *Server) history(chatJID string, since, until *timestamppb.Timestamp, limit int) ([]store.Message, error) {
+	inChat, err := s.chatGroup(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	var from, to time.Time
+	if since != nil {
+		from = since.AsTime()
+	}
+	if until != nil {
+		to = until.AsTime()
+	}
+	var messages []store.Message
+	err = s.store.ForEachMessage(from, to, func(m store.Message) error {
+		if !inChat[m.ChatJID] {
+			return nil
+		}
+		if len(messages) == limit {
+			messages = messages[1:]
+		}
+		messages = append(messages, m)
+		return nil
+	})
+	return messages, err
+}
+
+// The JIDs of a chat and the chats linked to it; nil when chatJID is empty
+func (s *Server) chatGroup(chatJID string) (map[string]bool, error) {
+	if chatJID == "" {
+		return nil, nil
+	}
+	group, err := s.store.ChatGroup(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	inChat := map[string]bool{}
+	for _, jid := range group {
+		inChat[jid] = true
+	}
+	return inChat, nil
+}
+
+func (s *Server) Send(ctx context.Context, req *pb.SendRequest) (*pb.SendResponse, error) {
+	if req.ChatJid == "" || strings.TrimSpace(req.Text) == "" {
+		return nil, status.Error(codes.InvalidArgument, "chat_jid and text are required")
+	}
+	var sendAt *time.Time
+	if req.SendAt != nil {
+		t := req.SendAt.AsTime()
+		sendAt = &t
+	}
+
+	if s.requireApproval {
+		id, err := s.store.AddPendingSend(store.PendingSend{ChatJID: req.ChatJid, Text: req.Text, SendAt: sendAt})
+		if err != nil {
+			return nil, s.internal(err)
+		}
+		return &pb.SendResponse{Status: pb.SendResponse_HELD, Id: id}, nil
+	}
+	if s.sender != nil && sendAt == nil {
+		if err := s.sender.SendText(ctx, req.ChatJid, req.Text); err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return &pb.SendResponse{Status: pb.SendResponse_SENT}, nil
+	}
+	m := store.OutboxMessage{ChatJID: req.ChatJid, Text: req.Text, SendAt: time.Now()}
+	if sendAt != nil {
+		m.SendAt = *sendAt
+	}
+	id, err := s.store.QueueOutbox(m)
+	if err != nil {
+		return nil, s.internal(err)
+	}
+	return &pb.SendResponse{Status: pb.SendResponse_QUEUED, Id: id}, nil
+}
+
+func (s *Server) Stream(req *pb.StreamRequest, stream grpc.ServerStreamingServer[pb.Message]) error {
+	if s.hub == nil {
+		return status.Error(codes.Unavailable, "streaming needs the logger running")
+	}
+	var inChats map[string]bool
+	if len(req.ChatJids) > 0 {
+		inChats = map[string]bool{}
+		for _, jid := range req.ChatJids {
+			group, err := s.chatGroup(jid)
+			if err != nil {
+				return s.internal(err)
+			}
+			for linked := range group {
+				inChats[linked] = true
+			}
+		}
+	}
+
+	messages, cancel := s.hub.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case m := <-messages:
+			if inChats != nil && !inChats[m.ChatJID] {
+				continue
+			}
+			if err := stream.Send(messageProto(m)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Log a store failure and report it without its details
+func (s *Server) internal(err error) error {
+	s.log.Errorf("gRPC request failed: %v", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func chatProto(c store.Chat) *pb.Chat {
+	return &pb.Chat{
+		Jid:             c.JID,
+		Name:            c.Name,
+		LastMessageTime: timestamp(c.LastMessageTime),
+		Phone:           c.Phone,
+		LinkedJids:      c.LinkedJIDs,
+		Channel:         c.Channel,
+		Muted:           c.Muted,
+		Archived:        c.Archived,
+		Pinned:          c.Pinned,
+		Community:       c.Community,
+	}
+}
+
+func messageProto(m store.Message) *pb.Message {
+	p := &pb.Message{
+		Id:            m.ID,
+		ChatJid:       m.ChatJID,
+		ChatName:      m.ChatName,
+		Sender:        m.Sender,
+		SenderName:    m.SenderName,
+		SenderPhone:   m.SenderPhone,
+		Content:       m.Content,
+		Timestamp:     timestamp(m.Timestamp),
+		IsFromMe:      m.IsFromMe,
+		MediaType:     m.MediaType,
+		Filename:      m.Filename,
+		MimeType:      m.MimeType,
+		LocalPath:     m.LocalPath,
+		ObjectUrl:     m.ObjectURL,
+		OcrText:       m.OCRText,
+		ReplyTo:       m.ReplyTo,
+		ReplyToSender: m.ReplyToSender,
+		IsForwarded:   m.IsForwarded,
+	}
+	if m.EditedAt != nil {
+		p.EditedAt = timestamppb.New(*m.EditedAt)
+	}
+	if m.DeletedAt != nil {
+		p.DeletedAt = timestamppb.New(*m.DeletedAt)
+	}
+	return p
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
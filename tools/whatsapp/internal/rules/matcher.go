@@ -0,0 +1,62 @@
+// Package rules holds the message matchers shared by config-driven features.
+package rules
+
+import (
+	"strings"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Matcher selects messages by chat, sender and keyword. Empty lists match
+// everything; a message must satisfy every non-empty list.
+type Matcher struct {
+	// Chat JIDs, or their user part
+	Chats []string `json:"chats,omitempty"`
+	// Sender JIDs, or their user part (phone number)
+	Senders []string `json:"senders,omitempty"`
+	// Case-insensitive substrings; any one matching is enough
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// Report whether msg satisfies the matcher
+func (m Matcher) Match(msg store.Message) bool {
+	if len(m.Chats) > 0 && !matchJID(m.Chats, msg.ChatJID) {
+		return false
+	}
+	if len(m.Senders) > 0 && !matchJID(m.Senders, msg.Sender) {
+		return false
+	}
+	if len(m.Keywords) > 0 && !containsAny(msg.Content, m.Keywords) {
+		return false
+	}
+	return true
+}
+
+// Match a JID against a list of JIDs or bare user parts
+func matchJID(list []string, jid string) bool {
+	user := jid
+	if at := strings.IndexByte(user, '@'); at >= 0 {
+		user = user[:at]
+	}
+	// Drop any device suffix such as ":12"
+	if colon := strings.IndexByte(user, ':'); colon >= 0 {
+		user = user[:colon]
+	}
+	for _, want := range list {
+		if want == jid || want == user {
+			return true
+		}
+	}
+	return false
+}
+
+// Case-insensitive substring test against any keyword
+func containsAny(text string, keywords []string) bool {
+	lower := strings.ToLower(text)
+	for _, kw := range keywords {
+		if kw != "" && strings.Contains(lower, strings.ToLower(kw)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package stats
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Ways to rank a contact report
+const (
+	// Most messages first
+	ByVolume = "volume"
+	// Most recently active first
+	ByRecency = "recency"
+	// People I wrote to often but not lately first
+	ByNeglect = "neglect"
+)
+
+// ContactOptions bound and shape a contact report
+type ContactOptions struct {
+	Since time.Time
+	// Zero means now
+	Until time.Time
+	// Zone used to count active days (default local time)
+	Location *time.Location
+	// ByVolume (default), ByRecency or ByNeglect
+	Sort string
+	// Contacts listed (default 20)
+	Limit int
+	// Only one-to-one chats. ByNeglect always leaves groups out.
+	DirectOnly bool
+	// Fewer messages than this in the window and a contact is not ranked
+	// by neglect, being no regular conversation to drop (default 5)
+	MinMessages int
+}
+
+// ContactReport ranks the chats I talk in
+type ContactReport struct {
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Sort     string    `json:"sort"`
+	Contacts []Contact `json:"contacts"`
+}
+
+// How much and how lately I talked with one contact or group. Linked
+// chats count as one.
+type Contact struct {
+	JID      string `json:"jid"`
+	Name     string `json:"name"`
+	Group    bool   `json:"group,omitempty"`
+	Messages int    `json:"messages"`
+	Sent     int    `json:"sent"`
+	Received int    `json:"received"`
+	// Sent divided by received; absent when nothing was received
+	SentRatio *float64 `json:"sent_ratio,omitempty"`
+	// Days with at least one message, and messages per week over the window
+	ActiveDays   int        `json:"active_days"`
+	PerWeek      float64    `json:"per_week"`
+	LastMessage  time.Time  `json:"last_message"`
+	LastSent     *time.Time `json:"last_sent,omitempty"`
+	LastReceived *time.Time `json:"last_received,omitempty"`
+	// Whole days from the last message, and from my last one, to the end of
+	// the window. DaysSinceSent counts from the start of the window when I
+	// sent nothing in it.
+	DaysQuiet     int `json:"days_quiet"`
+	DaysSinceSent int `json:"days_since_sent"`
+	// PerWeek times DaysSinceSent: high for a busy conversation I have
+	// stopped taking part in
+	NeglectScore float64 `json:"neglect_score"`
+}
+
+// Running counts for a contact
+type contactTally struct {
+	Contact
+	first time.Time
+	days  map[string]bool
+}
+
+// Rank contacts and groups in a single pass over the store
+func Contacts(st store.Store, opts ContactOptions) (*ContactReport, error) {
+	if opts.Until.IsZero() {
+		opts.Until = time.Now()
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	if opts.Sort == "" {
+		opts.Sort = ByVolume
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+	if opts.MinMessages <= 0 {
+		opts.MinMessages = 5
+	}
+
+	chats, err := st.ListChats()
+	if err != nil {
+		return nil, err
+	}
+	// Every JID to the chat it is folded into, leaving out the status feed
+	// and channels, which are nobody to talk to
+	canonical := map[string]store.Chat{}
+	for _, c := range chats {
+		if c.Channel || strings.HasSuffix(c.JID, "@broadcast") {
+			continue
+		}
+		canonical[c.JID] = c
+		for _, jid := range c.LinkedJIDs {
+			canonical[jid] = c
+		}
+	}
+
+	tallies := map[string]*contactTally{}
+	err = st.ForEachMessage(opts.Since, opts.Until, func(m store.Message) error {
+		c, ok := canonical[m.ChatJID]
+		if !ok || m.System != nil {
+			return nil
+		}
+		group := strings.HasSuffix(c.JID, "@g.us")
+		if group && (opts.DirectOnly || opts.Sort == ByNeglect) {
+			return nil
+		}
+		t := tallies[c.JID]
+		if t == nil {
+			t = &contactTally{Contact: Contact{JID: c.JID, Name: c.Name, Group: group}, first: m.Timestamp, days: map[string]bool{}}
+			tallies[c.JID] = t
+		}
+		t.Messages++
+		t.days[m.Timestamp.In(opts.Location).Format("2006-01-02")] = true
+		// Messages come oldest first
+		t.LastMessage = m.Timestamp
+		at := m.Timestamp
+		if m.IsFromMe {
+			t.Sent++
+			t.LastSent = &at
+		} else {
+			t.Received++
+			t.LastReceived = &at
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rates run from the first message when the window is unbounded
+	start := opts.Since
+	if start.IsZero() {
+		for _, t := range tallies {
+			if start.IsZero() || t.first.Before(start) {
+				start = t.first
+			}
+		}
+	}
+	weeks := max(opts.Until.Sub(start).Hours()/(24*7), 1)
+
+	contacts := make([]Contact, 0, len(tallies))
+	for _, t := range tallies {
+		c := t.Contact
+		c.ActiveDays = len(t.days)
+		c.PerWeek = float64(c.Messages) / weeks
+		if c.Received > 0 {
+			ratio := float64(c.Sent) / float64(c.Received)
+			c.SentRatio = &ratio
+		}
+		c.DaysQuiet = wholeDays(opts.Until.Sub(c.LastMessage))
+		c.DaysSinceSent = wholeDays(opts.Until.Sub(start))
+		if c.LastSent != nil {
+			c.DaysSinceSent = wholeDays(opts.Until.Sub(*c.LastSent))
+		}
+		c.NeglectScore = c.PerWeek * float64(c.DaysSinceSent)
+		if opts.Sort == ByNeglect && (c.Messages < opts.MinMessages || c.NeglectScore == 0) {
+			continue
+		}
+		contacts = append(contacts, c)
+	}
+
+	sort.Slice(contacts, func(i, j int) bool {
+		a, b := contacts[i], contacts[j]
+		switch {
+		case opts.Sort == ByRecency && !a.LastMessage.Equal(b.LastMessage):
+			return a.LastMessage.After(b.LastMessage)
+		case opts.Sort == ByNeglect && a.NeglectScore != b.NeglectScore:
+			return a.NeglectScore > b.NeglectScore
+		case a.Messages != b.Messages:
+			return a.Messages > b.Messages
+		}
+		return a.JID < b.JID
+	})
+	if len(contacts) > opts.Limit {
+		contacts = contacts[:opts.Limit]
+	}
+	return &ContactReport{Since: opts.Since, Until: opts.Until, Sort: opts.Sort, Contacts: contacts}, nil
+}
+
+func wholeDays(d time.Duration) int {
+	return max(int(d.Hours()/24), 0)
+}
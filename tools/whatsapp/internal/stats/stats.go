@@ -0,0 +1,330 @@
+// Package stats aggregates the message archive into activity reports.
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Options bound and shape a report
+type Options struct {
+	Since time.Time
+	// Zero means now
+	Until time.Time
+	// Zone used to bucket days (default local time)
+	Location *time.Location
+	// Number of chats to rank (default 10)
+	TopChats int
+	// Number of senders to rank (default 10)
+	TopSenders int
+	// Only messages in this chat, including the chats linked to it
+	ChatJID string
+}
+
+// Report summarises activity in a time window
+type Report struct {
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Total    int       `json:"total"`
+	Sent     int       `json:"sent"`
+	Received int       `json:"received"`
+	// One entry per calendar day in the window, including empty days
+	Daily []DayCount `json:"daily"`
+	// The same by week, Monday to Sunday
+	Weekly   []WeekCount `json:"weekly"`
+	TopChats []ChatCount `json:"top_chats"`
+	// Who wrote to me most, across chats
+	TopSenders []SenderCount `json:"top_senders"`
+	// Messages by hour of the day, 0 to 23
+	Hours []int `json:"hours"`
+	// How quickly I replied to incoming messages
+	ResponseTimes         []Bucket `json:"response_times"`
+	MedianResponseSeconds float64  `json:"median_response_seconds"`
+	// Message counts per media type
+	Media map[string]int `json:"media"`
+	// Forwarded messages, and those among them WhatsApp labels "Forwarded
+	// many times"
+	Forwarded          int `json:"forwarded"`
+	ForwardedManyTimes int `json:"forwarded_many_times"`
+}
+
+// Messages on one day
+type DayCount struct {
+	Day      string `json:"day"`
+	Sent     int    `json:"sent"`
+	Received int    `json:"received"`
+}
+
+// Messages in the week starting Monday Week
+type WeekCount struct {
+	Week     string `json:"week"`
+	Sent     int    `json:"sent"`
+	Received int    `json:"received"`
+}
+
+// Messages in one chat
+type ChatCount struct {
+	JID      string `json:"jid"`
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+	Sent     int    `json:"sent"`
+	Received int    `json:"received"`
+}
+
+// Messages received from one person. Their devices count together.
+type SenderCount struct {
+	JID   string `json:"jid"`
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+	// Chats they wrote in
+	Chats int `json:"chats"`
+}
+
+// A labelled histogram bucket
+type Bucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// Upper bounds of the response-time histogram; the last bucket is open-ended
+var responseBounds = []struct {
+	label string
+	max   time.Duration
+}{
+	{"< 1m", time.Minute},
+	{"1-5m", 5 * time.Minute},
+	{"5-15m", 15 * time.Minute},
+	{"15m-1h", time.Hour},
+	{"1-6h", 6 * time.Hour},
+	{"6-24h", 24 * time.Hour},
+	{"> 24h", 0},
+}
+
+// Compute a report in a single pass over the store
+func Compute(st store.Store, opts Options) (*Report, error) {
+	if opts.Until.IsZero() {
+		opts.Until = time.Now()
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	if opts.TopChats <= 0 {
+		opts.TopChats = 10
+	}
+	if opts.TopSenders <= 0 {
+		opts.TopSenders = 10
+	}
+	var inChat map[string]bool
+	if opts.ChatJID != "" {
+		group, err := st.ChatGroup(opts.ChatJID)
+		if err != nil {
+			return nil, err
+		}
+		inChat = map[string]bool{}
+		for _, jid := range group {
+			inChat[jid] = true
+		}
+	}
+
+	r := &Report{Since: opts.Since, Until: opts.Until, Media: map[string]int{}, Hours: make([]int, 24)}
+	days := map[string]*DayCount{}
+	chats := map[string]*ChatCount{}
+	senders := map[string]*senderTally{}
+	// First unanswered incoming message per chat, for response times
+	pending := map[string]time.Time{}
+	var responses []time.Duration
+
+	err := st.ForEachMessage(opts.Since, opts.Until, func(m store.Message) error {
+		// Group changes are not activity
+		if m.System != nil || (inChat != nil && !inChat[m.ChatJID]) {
+			return nil
+		}
+		r.Total++
+		local := m.Timestamp.In(opts.Location)
+		r.Hours[local.Hour()]++
+		day := local.Format("2006-01-02")
+		d := days[day]
+		if d == nil {
+			d = &DayCount{Day: day}
+			days[day] = d
+		}
+		c := chats[m.ChatJID]
+		if c == nil {
+			c = &ChatCount{JID: m.ChatJID, Name: m.ChatName}
+			chats[m.ChatJID] = c
+		}
+		c.Count++
+
+		if m.IsFromMe {
+			r.Sent++
+			d.Sent++
+			c.Sent++
+			if since, ok := pending[m.ChatJID]; ok {
+				responses = append(responses, m.Timestamp.Sub(since))
+				delete(pending, m.ChatJID)
+			}
+		} else {
+			r.Received++
+			d.Received++
+			c.Received++
+			if _, ok := pending[m.ChatJID]; !ok {
+				pending[m.ChatJID] = m.Timestamp
+			}
+			key := m.Sender
+			if m.SenderPhone != "" {
+				key = m.SenderPhone
+			}
+			t := senders[key]
+			if t == nil {
+				t = &senderTally{SenderCount: SenderCount{JID: m.Sender}, chats: map[string]bool{}}
+				senders[key] = t
+			}
+			if m.SenderName != "" {
+				t.Name = m.SenderName
+			}
+			t.Count++
+			t.chats[m.ChatJID] = true
+		}
+
+		if m.MediaType != "" {
+			r.Media[m.MediaType]++
+		}
+		if m.IsForwarded {
+			r.Forwarded++
+			if m.ForwardingScore >= store.ForwardedManyTimes {
+				r.ForwardedManyTimes++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if r.Total == 0 && opts.ChatJID != "" {
+		if _, err := st.QueryMessages(opts.ChatJID, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	r.Daily = fillDays(days, opts)
+	r.Weekly = weeks(r.Daily, opts.Location)
+	r.TopChats = topChats(chats, opts.TopChats)
+	r.TopSenders = topSenders(senders, opts.TopSenders)
+	r.ResponseTimes, r.MedianResponseSeconds = responseHistogram(responses)
+	return r, nil
+}
+
+// Expand sparse day counts into a contiguous series
+func fillDays(days map[string]*DayCount, opts Options) []DayCount {
+	if len(days) == 0 {
+		return []DayCount{}
+	}
+	// Start at the first active day when the window is unbounded
+	start := opts.Since
+	if start.IsZero() {
+		first := ""
+		for day := range days {
+			if first == "" || day < first {
+				first = day
+			}
+		}
+		start, _ = time.ParseInLocation("2006-01-02", first, opts.Location)
+	}
+
+	var series []DayCount
+	y, mo, dd := start.In(opts.Location).Date()
+	for t := time.Date(y, mo, dd, 0, 0, 0, 0, opts.Location); t.Before(opts.Until); t = t.AddDate(0, 0, 1) {
+		day := t.Format("2006-01-02")
+		if d, ok := days[day]; ok {
+			series = append(series, *d)
+		} else {
+			series = append(series, DayCount{Day: day})
+		}
+	}
+	return series
+}
+
+// Sum a day series into weeks starting on Monday
+func weeks(days []DayCount, loc *time.Location) []WeekCount {
+	series := []WeekCount{}
+	for _, d := range days {
+		t, err := time.ParseInLocation("2006-01-02", d.Day, loc)
+		if err != nil {
+			continue
+		}
+		monday := t.AddDate(0, 0, -(int(t.Weekday())+6)%7).Format("2006-01-02")
+		if len(series) == 0 || series[len(series)-1].Week != monday {
+			series = append(series, WeekCount{Week: monday})
+		}
+		w := &series[len(series)-1]
+		w.Sent += d.Sent
+		w.Received += d.Received
+	}
+	return series
+}
+
+type senderTally struct {
+	SenderCount
+	chats map[string]bool
+}
+
+// Rank senders by message count
+func topSenders(senders map[string]*senderTally, n int) []SenderCount {
+	ranked := make([]SenderCount, 0, len(senders))
+	for _, t := range senders {
+		t.Chats = len(t.chats)
+		ranked = append(ranked, t.SenderCount)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].JID < ranked[j].JID
+	})
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+// Rank chats by message count
+func topChats(chats map[string]*ChatCount, n int) []ChatCount {
+	ranked := make([]ChatCount, 0, len(chats))
+	for _, c := range chats {
+		ranked = append(ranked, *c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].JID < ranked[j].JID
+	})
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+// Bucket response delays and find their median
+func responseHistogram(delays []time.Duration) ([]Bucket, float64) {
+	buckets := make([]Bucket, len(responseBounds))
+	for i, b := range responseBounds {
+		buckets[i].Label = b.label
+	}
+	for _, d := range delays {
+		for i, b := range responseBounds {
+			if b.max == 0 || d < b.max {
+				buckets[i].Count++
+				break
+			}
+		}
+	}
+
+	if len(delays) == 0 {
+		return buckets, 0
+	}
+	sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+	return buckets, delays[len(delays)/2].Seconds()
+}
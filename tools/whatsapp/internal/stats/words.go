@@ -0,0 +1,219 @@
+package stats
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Ways to group a word report
+const (
+	ByChat   = "chat"
+	BySender = "sender"
+)
+
+// WordOptions bound and shape a word report
+type WordOptions struct {
+	Since time.Time
+	// Zero means now
+	Until time.Time
+	// Group by ByChat or BySender; empty gives a single group for everything
+	By string
+	// Only messages in this chat when set
+	ChatJID string
+	// Terms kept per group (default 20)
+	Top int
+	// Phrases need at least this many occurrences (default 3)
+	MinPhraseCount int
+	// Extra words to ignore, on top of the built-in list
+	Stopwords []string
+	// Skip messages I sent
+	ReceivedOnly bool
+	// Skip forwarded messages, counting only what senders wrote
+	OriginalOnly bool
+}
+
+// WordReport ranks words and phrases per group
+type WordReport struct {
+	Since  time.Time   `json:"since"`
+	Until  time.Time   `json:"until"`
+	By     string      `json:"by,omitempty"`
+	Groups []WordGroup `json:"groups"`
+}
+
+// Top terms for one chat, one sender, or everything
+type WordGroup struct {
+	Key      string      `json:"key"`
+	Name     string      `json:"name"`
+	Messages int         `json:"messages"`
+	Words    []TermCount `json:"words"`
+	// Two- and three-word sequences that neither start nor end with a stopword
+	Phrases []TermCount `json:"phrases"`
+}
+
+// A term and how often it was used
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// Running counts for a group
+type wordTally struct {
+	name     string
+	messages int
+	words    map[string]int
+	phrases  map[string]int
+}
+
+// Rank words and phrases in a single pass over the store
+func Words(st store.Store, opts WordOptions) (*WordReport, error) {
+	if opts.Until.IsZero() {
+		opts.Until = time.Now()
+	}
+	if opts.Top <= 0 {
+		opts.Top = 20
+	}
+	if opts.MinPhraseCount <= 0 {
+		opts.MinPhraseCount = 3
+	}
+	stop := make(map[string]bool, len(stopwords)+len(opts.Stopwords))
+	for _, w := range stopwords {
+		stop[w] = true
+	}
+	for _, w := range opts.Stopwords {
+		stop[strings.ToLower(w)] = true
+	}
+
+	tallies := map[string]*wordTally{}
+	err := st.ForEachMessage(opts.Since, opts.Until, func(m store.Message) error {
+		if opts.ChatJID != "" && m.ChatJID != opts.ChatJID {
+			return nil
+		}
+		if opts.ReceivedOnly && m.IsFromMe || opts.OriginalOnly && m.IsForwarded {
+			return nil
+		}
+		// Media placeholders like "[Image]" and group changes carry no
+		// words of their own
+		if m.System != nil || m.MediaType != "" && strings.HasPrefix(m.Content, "[") {
+			return nil
+		}
+
+		key, name := "", "All messages"
+		switch opts.By {
+		case ByChat:
+			key, name = m.ChatJID, m.ChatName
+		case BySender:
+			key, name = m.Sender, m.SenderName
+			if m.IsFromMe {
+				name = "Me"
+			}
+		}
+		t := tallies[key]
+		if t == nil {
+			t = &wordTally{name: name, words: map[string]int{}, phrases: map[string]int{}}
+			tallies[key] = t
+		}
+		t.messages++
+
+		tokens := tokenize(m.Content)
+		for i, tok := range tokens {
+			if !stop[tok] {
+				t.words[tok]++
+			}
+			for n := 2; n <= 3 && i+n <= len(tokens); n++ {
+				if !stop[tok] && !stop[tokens[i+n-1]] {
+					t.phrases[strings.Join(tokens[i:i+n], " ")]++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &WordReport{Since: opts.Since, Until: opts.Until, By: opts.By, Groups: []WordGroup{}}
+	for key, t := range tallies {
+		name := t.name
+		if name == "" {
+			name = key
+		}
+		r.Groups = append(r.Groups, WordGroup{
+			Key:      key,
+			Name:     name,
+			Messages: t.messages,
+			Words:    topTerms(t.words, opts.Top, 1),
+			Phrases:  topTerms(t.phrases, opts.Top, opts.MinPhraseCount),
+		})
+	}
+	sort.Slice(r.Groups, func(i, j int) bool {
+		if r.Groups[i].Messages != r.Groups[j].Messages {
+			return r.Groups[i].Messages > r.Groups[j].Messages
+		}
+		return r.Groups[i].Key < r.Groups[j].Key
+	})
+	return r, nil
+}
+
+// The n most frequent terms seen at least min times, ties broken alphabetically
+func topTerms(counts map[string]int, n, min int) []TermCount {
+	terms := []TermCount{}
+	for term, c := range counts {
+		if c >= min {
+			terms = append(terms, TermCount{Term: term, Count: c})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+// Split text into lowercase words, dropping links, numbers and single letters
+func tokenize(text string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(text) {
+		if strings.Contains(field, "://") || strings.HasPrefix(field, "www.") || strings.HasPrefix(field, "@") {
+			continue
+		}
+		words := strings.FieldsFunc(strings.ToLower(field), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+		})
+		for _, w := range words {
+			w = strings.Trim(w, "'")
+			if len([]rune(w)) < 2 || strings.IndexFunc(w, unicode.IsLetter) < 0 {
+				continue
+			}
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+// Common English words and chat filler that say nothing about a conversation
+var stopwords = strings.Fields(`
+	a about above after again against all am an and any are aren't as at be
+	because been before being below between both but by can can't cannot could
+	couldn't did didn't do does doesn't doing don't down during each few for
+	from further had hadn't has hasn't have haven't having he he'd he'll he's
+	her here here's hers herself him himself his how how's i i'd i'll i'm i've
+	if in into is isn't it it's its itself let's me more most mustn't my myself
+	no nor not of off on once only or other ought our ours ourselves out over
+	own same shan't she she'd she'll she's should shouldn't so some such than
+	that that's the their theirs them themselves then there there's these they
+	they'd they'll they're they've this those through to too under until up
+	very was wasn't we we'd we'll we're we've were weren't what what's when
+	when's where where's which while who who's whom why why's will with won't
+	would wouldn't you you'd you'll you're you've your yours yourself
+	yourselves just also get got like now one really yeah yes ok okay oh lol
+	haha hahaha im dont thats its u ur ya yep nope gonna wanna hey hi
+`)
@@ -0,0 +1,306 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Message text, current and earlier revisions, text read from images and
+// raw payloads can be sealed with AES-256-GCM under a key derived from a
+// passphrase, so the archive alone doesn't reveal it. Sealed values are
+// sealedPrefix followed by base64 of nonce | ciphertext. Whether a value is
+// sealed follows from the archive, not the value: in an encrypted archive
+// every non-empty one is, in a plaintext archive none are.
+const (
+	sealedPrefix = "kwe1:"
+	keyCheck     = "kenny-whatsapp"
+	saltSize     = 16
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	keySize      = 32
+	sealBatch    = 1000
+	// Archives encrypted before text read from images was sealed have a
+	// lower sealed_version, and have it sealed when next unlocked
+	sealedVersion = 1
+)
+
+// The columns sealed in an encrypted archive
+var sealedColumns = []struct{ table, column string }{
+	{"messages", "content"},
+	{"messages", "ocr_text"},
+	{"message_revisions", "content"},
+}
+
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Unlock the archive with passphrase. The first passphrase given encrypts
+// the archive: text already stored is sealed in place and, on SQLite, the
+// file is vacuumed so no plaintext is left in free pages. The full-text
+// index would hold the text in the clear, so encrypted archives go without
+// it.
+func (s *SQLiteStore) initKey(passphrase string) error {
+	var salt []byte
+	var check string
+	var version int
+	err := s.queryRow(`SELECT salt, check_value, sealed_version FROM encryption WHERE id = 1`).Scan(&salt, &check, &version)
+	switch {
+	case err == sql.ErrNoRows && passphrase == "":
+		return nil
+	case err == sql.ErrNoRows:
+		return s.encrypt(passphrase)
+	case err != nil:
+		return err
+	case passphrase == "":
+		return ErrEncrypted
+	}
+	if s.aead, err = deriveKey(passphrase, salt); err != nil {
+		return err
+	}
+	if plain, err := s.unseal(check); err != nil || plain != keyCheck {
+		return ErrWrongKey
+	}
+	if version < sealedVersion {
+		return s.sealImageText()
+	}
+	return nil
+}
+
+// Seal the text read from images in an archive encrypted before it was
+func (s *SQLiteStore) sealImageText() error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.sealTable(tx, "messages", "ocr_text"); err != nil {
+		return fmt.Errorf("failed to encrypt messages.ocr_text: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE encryption SET sealed_version = ? WHERE id = 1`, sealedVersion); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return s.vacuum()
+}
+
+// Whether the archive was unlocked with a passphrase
+func (s *SQLiteStore) Encrypted() bool {
+	return s.aead != nil
+}
+
+// Encrypt a plaintext archive under passphrase
+func (s *SQLiteStore) encrypt(passphrase string) error {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	aead, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	s.aead = aead
+
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	check, err := s.seal(keyCheck)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO encryption (id, salt, check_value, sealed_version) VALUES (1, ?, ?, ?)`,
+		salt, check, sealedVersion)
+	if err != nil {
+		return err
+	}
+	for _, c := range sealedColumns {
+		if err := s.sealTable(tx, c.table, c.column); err != nil {
+			return fmt.Errorf("failed to encrypt %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	if err := s.sealRaw(tx); err != nil {
+		return fmt.Errorf("failed to encrypt message_raw: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if s.dialect == dialectSQLite {
+		if err := s.dropFTS(); err != nil {
+			return err
+		}
+	}
+	return s.vacuum()
+}
+
+// Rewrite an SQLite file so no plaintext is left in free pages or the WAL
+func (s *SQLiteStore) vacuum() error {
+	if s.dialect != dialectSQLite {
+		return nil
+	}
+	for _, stmt := range []string{`VACUUM`, `PRAGMA wal_checkpoint(TRUNCATE)`} {
+		if _, err := s.exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search the messages f selects in an encrypted archive, where only the
+// store can read the text: they are opened newest first until f.Limit of
+// them match
+func (s *SQLiteStore) searchSealed(query string, f MessageFilter) ([]Message, error) {
+	conditions, args, err := s.messageConditions(f)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.query(`SELECT `+messageColumns+`
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE 1 = 1`+conditions+`
+		ORDER BY m.timestamp DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	query = strings.ToLower(query)
+	var found []Message
+	for (f.Limit <= 0 || len(found) < f.Limit) && rows.Next() {
+		m, err := s.scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(strings.ToLower(m.Content), query) || strings.Contains(strings.ToLower(m.OCRText), query) {
+			found = append(found, m)
+		}
+	}
+	return found, rows.Err()
+}
+
+// Seal the plaintext in column of every row of table, a batch at a time.
+// Rows are walked in key order, which sealing doesn't change, rather than
+// by what the text looks like, so text that happens to look sealed is
+// sealed too.
+func (s *SQLiteStore) sealTable(tx txn, table, column string) error {
+	keys, where := "id, chat_jid, NULL", "id = ? AND chat_jid = ?"
+	order := "id, chat_jid"
+	if table == "message_revisions" {
+		keys, where = "message_id, chat_jid, replaced_at", "message_id = ? AND chat_jid = ? AND replaced_at = ?"
+		order = keys
+	}
+	type plain struct {
+		id, chat, content string
+		replaced          sql.NullTime
+	}
+	for offset := 0; ; offset += sealBatch {
+		rows, err := tx.Query(`SELECT `+keys+`, `+column+` FROM `+table+`
+			WHERE `+column+` != '' ORDER BY `+order+` LIMIT ? OFFSET ?`, sealBatch, offset)
+		if err != nil {
+			return err
+		}
+		var batch []plain
+		for rows.Next() {
+			var p plain
+			if err := rows.Scan(&p.id, &p.chat, &p.replaced, &p.content); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, p := range batch {
+			sealed, err := s.seal(p.content)
+			if err != nil {
+				return err
+			}
+			args := []interface{}{sealed, p.id, p.chat}
+			if p.replaced.Valid {
+				args = append(args, p.replaced.Time)
+			}
+			if _, err := tx.Exec(`UPDATE `+table+` SET `+column+` = ? WHERE `+where, args...); err != nil {
+				return err
+			}
+		}
+		if len(batch) < sealBatch {
+			return nil
+		}
+	}
+}
+
+// Seal text for storage; empty text and text in unencrypted archives are
+// stored as is
+func (s *SQLiteStore) seal(text string) (string, error) {
+	if s.aead == nil || text == "" {
+		return text, nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, []byte(text), nil)), nil
+}
+
+// Open text read from the archive. Text in a plaintext archive passes
+// through as is, whatever it starts with.
+func (s *SQLiteStore) unseal(stored string) (string, error) {
+	if s.aead == nil || stored == "" {
+		return stored, nil
+	}
+	if !strings.HasPrefix(stored, sealedPrefix) {
+		return "", fmt.Errorf("%w: malformed sealed text", ErrWrongKey)
+	}
+	data, err := base64.StdEncoding.DecodeString(stored[len(sealedPrefix):])
+	if err != nil || len(data) < s.aead.NonceSize() {
+		return "", fmt.Errorf("%w: malformed sealed text", ErrWrongKey)
+	}
+	n := s.aead.NonceSize()
+	plain, err := s.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return "", ErrWrongKey
+	}
+	return string(plain), nil
+}
+
+// Scans a possibly sealed text column into a string, "" for NULL
+type sealedDest struct {
+	s *SQLiteStore
+	v *string
+}
+
+func (d sealedDest) Scan(v interface{}) error {
+	var stored string
+	switch v := v.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into text", v)
+	}
+	plain, err := d.s.unseal(stored)
+	*d.v = plain
+	return err
+}
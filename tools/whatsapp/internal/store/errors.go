@@ -0,0 +1,17 @@
+package store
+
+import "errors"
+
+var (
+	// ErrStoreClosed is returned by every operation after Close
+	ErrStoreClosed = errors.New("store is closed")
+	// ErrChatNotFound is returned when a lookup names a chat that was never stored
+	ErrChatNotFound = errors.New("chat not found")
+	// ErrMessageNotFound is returned when an operation names a message that is not stored
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrEncrypted is returned by Open when the archive is encrypted and no
+	// key was given
+	ErrEncrypted = errors.New("archive is encrypted; a key is needed to open it")
+	// ErrWrongKey is returned by Open when the key does not unlock the archive
+	ErrWrongKey = errors.New("wrong archive key")
+)
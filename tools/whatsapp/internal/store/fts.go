@@ -0,0 +1,227 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+)
+
+// The full-text index over message content and image text. It shares
+// rowids with messages and is kept current by triggers; REPLACE deletes
+// fire the delete trigger because connections enable recursive_triggers.
+const ftsSchema = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+		content, ocr_text, tokenize = 'unicode61 remove_diacritics 2'
+	);
+	DELETE FROM messages_fts;
+
+	CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts (rowid, content, ocr_text) VALUES (new.rowid, new.content, new.ocr_text);
+	END;
+	CREATE TRIGGER messages_fts_update AFTER UPDATE OF content, ocr_text ON messages BEGIN
+		UPDATE messages_fts SET content = new.content, ocr_text = new.ocr_text WHERE rowid = new.rowid;
+	END;
+	CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.rowid;
+	END;
+
+	INSERT INTO messages_fts (rowid, content, ocr_text) SELECT rowid, content, ocr_text FROM messages;
+`
+
+var ftsTriggers = []string{"messages_fts_insert", "messages_fts_update", "messages_fts_delete"}
+
+// Build the full-text index when SQLite has FTS5, which go-sqlite3 only
+// compiles in with the sqlite_fts5 build tag. Without it, search falls back
+// to substring matching, and the index's triggers are dropped so writes
+// don't fail on a module this build lacks; the next build with FTS5 then
+// rebuilds the index from scratch. Encrypted archives have no index, as it
+// would hold their text in the clear.
+func (s *SQLiteStore) initFTS() error {
+	if s.dialect != dialectSQLite {
+		return nil
+	}
+	if s.aead != nil {
+		return s.dropFTS()
+	}
+	var hasFTS5 bool
+	if err := s.queryRow(`SELECT COUNT(*) > 0 FROM pragma_module_list WHERE name = 'fts5'`).Scan(&hasFTS5); err != nil {
+		return err
+	}
+	var triggers int
+	err := s.queryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'messages\_fts\_%' ESCAPE '\'`).Scan(&triggers)
+	if err != nil {
+		return err
+	}
+	if !hasFTS5 {
+		for _, t := range ftsTriggers {
+			if _, err := s.exec(`DROP TRIGGER IF EXISTS ` + t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s.fts = true
+	if triggers == len(ftsTriggers) {
+		return nil
+	}
+
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, t := range ftsTriggers {
+		if _, err := tx.Exec(`DROP TRIGGER IF EXISTS ` + t); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ftsSchema); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Remove the full-text index and its triggers. Without FTS5 the index
+// can't be dropped, so the tables holding its data are emptied instead.
+func (s *SQLiteStore) dropFTS() error {
+	for _, t := range ftsTriggers {
+		if _, err := s.exec(`DROP TRIGGER IF EXISTS ` + t); err != nil {
+			return err
+		}
+	}
+	if _, err := s.exec(`DROP TABLE IF EXISTS messages_fts`); err == nil {
+		return nil
+	}
+	rows, err := s.query(`SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name IN ('messages_fts_data', 'messages_fts_idx', 'messages_fts_content', 'messages_fts_docsize')`)
+	if err != nil {
+		return err
+	}
+	var shadows []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		shadows = append(shadows, name)
+	}
+	rows.Close()
+	for _, name := range shadows {
+		if _, err := s.exec(`DELETE FROM ` + name); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Search messages and the text read from images. With the full-text index
+// query is FTS5 syntax (words, "phrases", prefix*, OR, NOT), and text that
+// isn't valid syntax is searched as plain words; without the index query
+// is matched as a substring, newest first. Only the messages f selects are
+// searched, and f.Limit caps the results. Selecting a chat the archive
+// doesn't have is an error.
+func (s *SQLiteStore) Search(query string, f MessageFilter) ([]SearchResult, error) {
+	results, err := s.search(query, f)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		for _, jid := range f.Chats {
+			if err := s.requireChat(jid); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, nil
+}
+
+func (s *SQLiteStore) search(query string, f MessageFilter) ([]SearchResult, error) {
+	if !s.fts {
+		messages, err := s.searchMessages(query, f)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]SearchResult, len(messages))
+		for i, m := range messages {
+			text := m.Content
+			if !strings.Contains(strings.ToLower(text), strings.ToLower(query)) {
+				text = m.OCRText
+			}
+			results[i] = SearchResult{Message: m, Snippet: markMatch(text, query)}
+		}
+		return results, nil
+	}
+
+	results, err := s.searchFTS(query, f)
+	if err != nil {
+		// Not valid syntax, e.g. "e-mail" reads as a column filter
+		return s.searchFTS(quoteTerms(query), f)
+	}
+	return results, nil
+}
+
+func (s *SQLiteStore) searchFTS(query string, f MessageFilter) ([]SearchResult, error) {
+	conditions, args, err := s.messageConditions(f)
+	if err != nil {
+		return nil, err
+	}
+	q := `SELECT ` + messageColumns + `, snippet(messages_fts, -1, '[', ']', '…', 12), f.rank
+		FROM messages_fts f
+		JOIN messages m ON m.rowid = f.rowid
+		LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE messages_fts MATCH ?` + conditions + `
+		ORDER BY f.rank`
+	args = append([]interface{}{query}, args...)
+	if f.Limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+	rows, err := s.query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		var ts sql.NullTime
+		if err := rows.Scan(append(s.messageDest(&r.Message, &ts), &r.Snippet, &r.Rank)...); err != nil {
+			return nil, err
+		}
+		r.Timestamp = ts.Time
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// Each word of query as an FTS5 string, so punctuation in it is no syntax
+func quoteTerms(query string) string {
+	terms := strings.Fields(query)
+	for i, t := range terms {
+		terms[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// Context of width runes around each side of the first case-insensitive
+// match of query in text, with the match in [brackets], like the index's
+// snippets
+func markMatch(text, query string) string {
+	const width = 40
+	i := strings.Index(strings.ToLower(text), strings.ToLower(query))
+	if i < 0 || query == "" || len(strings.ToLower(text)) != len(text) {
+		return text
+	}
+	end := i + len(query)
+	before, after := text[:i], text[end:]
+	if utf8.RuneCountInString(before) > width {
+		r := []rune(before)
+		before = "…" + string(r[len(r)-width:])
+	}
+	if utf8.RuneCountInString(after) > width {
+		after = string([]rune(after)[:width]) + "…"
+	}
+	return before + "[" + text[i:end] + "]" + after
+}
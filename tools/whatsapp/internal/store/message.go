@@ -0,0 +1,446 @@
+package store
+
+import (
+	"time"
+
+	"whatsapp-logger/archive"
+)
+
+// The records callers get back, defined in the public archive package so
+// code outside this module can use them too
+type (
+	Message     = archive.Message
+	Chat        = archive.Chat
+	MessageKey  = archive.MessageKey
+	Location    = archive.Location
+	Sticker     = archive.Sticker
+	SystemEvent = archive.SystemEvent
+)
+
+// Forwarding score from which WhatsApp labels a message "Forwarded many
+// times"
+const ForwardedManyTimes = archive.ForwardedManyTimes
+
+// Kinds of group change logged as a system message
+const (
+	SystemSubject     = archive.SystemSubject
+	SystemDescription = archive.SystemDescription
+	SystemIcon        = archive.SystemIcon
+	SystemLocked      = archive.SystemLocked
+	SystemAnnounce    = archive.SystemAnnounce
+	SystemEphemeral   = archive.SystemEphemeral
+	SystemApproval    = archive.SystemApproval
+	SystemInviteLink  = archive.SystemInviteLink
+	SystemJoin        = archive.SystemJoin
+	SystemLeave       = archive.SystemLeave
+	SystemPromote     = archive.SystemPromote
+	SystemDemote      = archive.SystemDemote
+)
+
+// A message with the messages it replies to and the replies to it
+type Thread struct {
+	// The quoted messages above Message, the start of the chain first
+	Ancestors []Message `json:"ancestors"`
+	Message   Message   `json:"message"`
+	// Replies to Message and to those replies, oldest first; each names the
+	// message it answers in reply_to
+	Descendants []Message `json:"descendants"`
+	// The first of the chain quotes a message that is not stored
+	RootMissing bool `json:"root_missing,omitempty"`
+}
+
+// Why a message counts as waiting on my answer
+const (
+	// Sent to me in a direct chat
+	AwaitingDirect = "direct"
+	// Mentions me in a group
+	AwaitingMention = "mention"
+	// Replies to a message of mine in a group
+	AwaitingReply = "reply"
+)
+
+// A chat with messages to me I haven't answered
+type UnrepliedChat struct {
+	ChatJID  string `json:"chat_jid"`
+	ChatName string `json:"chat_name"`
+	// The oldest message to me since I last wrote in the chat, and why it
+	// counts as to me: AwaitingDirect, AwaitingMention or AwaitingReply
+	First  Message `json:"first"`
+	Reason string  `json:"reason"`
+	// Messages to me since I last wrote, and the newest of them
+	Count  int     `json:"count"`
+	Latest Message `json:"latest"`
+}
+
+// Content a message had before an edit replaced it
+type Revision struct {
+	Content    string    `json:"content"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// A message flagged locally, with the user's note
+type Bookmark struct {
+	Message
+	Note         string    `json:"note"`
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+// A message found by Search. Snippet is the matching text with matches in
+// [brackets]; Rank orders results, lower first, and is 0 without the
+// full-text index.
+type SearchResult struct {
+	Message
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// Title and description WhatsApp showed for a shared URL
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// What WhatsApp tells us about an attachment, enough to download it later
+type Media struct {
+	URL           string
+	DirectPath    string
+	MediaKey      []byte
+	FileSHA256    []byte
+	FileEncSHA256 []byte
+	FileLength    int64
+	MimeType      string
+	// Photo or video the sender allowed to be viewed only once
+	ViewOnce bool
+}
+
+// A stored media message and the size of its attachment, when known
+type MediaFile struct {
+	Message
+	Size int64 `json:"size,omitempty"`
+}
+
+// An attachment file on disk, shared by every message carrying the same content
+type MediaBlob struct {
+	// Hex SHA-256 of the decrypted file
+	SHA256 string `json:"sha256"`
+	// File path, or object URL when kept in object storage
+	Location string `json:"location"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type,omitempty"`
+	// Messages referencing the file; not filled by SetMediaFile
+	Refs int `json:"refs,omitempty"`
+	// Time of the newest referencing message; only filled by MediaBlobs
+	LastUsed time.Time `json:"last_used,omitempty"`
+}
+
+// A media message still to be downloaded, with what is needed to fetch it
+type PendingMedia struct {
+	Message
+	Media Media
+	// Earlier failed downloads and the latest reason
+	Attempts  int
+	LastError string
+}
+
+// The profile picture saved for a contact or group
+type Avatar struct {
+	JID string `json:"jid"`
+	// WhatsApp's ID for the picture, empty when there is none
+	PictureID string `json:"picture_id,omitempty"`
+	// Where the picture is saved, empty when there is none
+	Path string `json:"path,omitempty"`
+	// When the picture last changed, and when WhatsApp was last asked
+	ChangedAt time.Time `json:"changed_at"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// A contact coming online or going offline
+type PresenceChange struct {
+	JID    string    `json:"jid"`
+	Online bool      `json:"online"`
+	At     time.Time `json:"at"`
+	// When going offline, the last-seen time WhatsApp reported; nil when
+	// the contact hides it
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// A name a contact has been seen under, and when
+type ContactName struct {
+	Name      string    `json:"name"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// A LID and the phone-number JID of the same account
+type LIDMapping struct {
+	LID      string `json:"lid"`
+	PhoneJID string `json:"phone_jid"`
+}
+
+// The names known for a contact. Each is kept until replaced by a newer
+// non-empty one.
+type Contact struct {
+	JID          string `json:"jid"`
+	FullName     string `json:"full_name,omitempty"`
+	FirstName    string `json:"first_name,omitempty"`
+	PushName     string `json:"push_name,omitempty"`
+	BusinessName string `json:"business_name,omitempty"`
+	// E.164 number, from the JID or, for a contact known by LID, as
+	// WhatsApp mapped it
+	Phone string `json:"phone,omitempty"`
+}
+
+// A reminder to send to a chat once it is due
+type Reminder struct {
+	ID        int64     `json:"id"`
+	ChatJID   string    `json:"chat_jid"`
+	Text      string    `json:"text"`
+	DueAt     time.Time `json:"due_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Outbox message states
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	// Attempts ran out
+	OutboxFailed = "failed"
+)
+
+// A message queued to send to a chat once SendAt passes
+type OutboxMessage struct {
+	ID      int64     `json:"id"`
+	ChatJID string    `json:"chat_jid"`
+	Text    string    `json:"text"`
+	SendAt  time.Time `json:"send_at"`
+	Status  string    `json:"status"`
+	// Failed attempts so far, and when the next one is due
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Pending send states
+const (
+	SendPending  = "pending"
+	SendApproved = "approved"
+	SendRejected = "rejected"
+)
+
+// A message held back until I approve it
+type PendingSend struct {
+	ID      int64  `json:"id"`
+	ChatJID string `json:"chat_jid"`
+	Text    string `json:"text"`
+	// When to send once approved; nil sends on approval
+	SendAt    *time.Time `json:"send_at,omitempty"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
+
+// When one recipient of a message I sent got it and read it
+type Receipt struct {
+	MessageID   string     `json:"message_id"`
+	ChatJID     string     `json:"chat_jid"`
+	Recipient   string     `json:"recipient"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+// An emoji reaction one person left on a message
+type Reaction struct {
+	MessageID string    `json:"message_id"`
+	ChatJID   string    `json:"chat_jid"`
+	Reactor   string    `json:"reactor"`
+	Emoji     string    `json:"emoji"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// A URL shared in a message, with the preview WhatsApp showed for it
+type SharedLink struct {
+	MessageID   string    `json:"message_id"`
+	ChatJID     string    `json:"chat_jid"`
+	Sender      string    `json:"sender,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	// Host without a leading "www.", lower case
+	Domain string `json:"domain"`
+}
+
+// Narrows ListLinks; zero fields match everything
+type LinkFilter struct {
+	// Chat JID, including the chats linked to it
+	ChatJID string
+	// Sender JID; matches any device of the same number
+	Sender string
+	Domain string
+	// since <= timestamp < until
+	Since, Until time.Time
+}
+
+// Selects messages for ListMessages; zero fields match every message
+type MessageFilter struct {
+	// Chat JIDs, each including the chats linked to it
+	Chats []string
+	// Sender JIDs; each matches any device of the same number
+	Senders []string
+	// since <= timestamp < until
+	Since, Until time.Time
+	// Media types, as in Message.MediaType
+	MediaTypes []string
+	// Only messages with an attachment, of any type
+	MediaOnly bool
+	// Only messages I sent (true) or received (false)
+	FromMe *bool
+	// Most messages to return, the newest; 0 for all
+	Limit int
+}
+
+// An invitation to join a group, sent as a message
+type GroupInvite struct {
+	MessageID string    `json:"message_id"`
+	ChatJID   string    `json:"chat_jid"`
+	Sender    string    `json:"sender,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	GroupJID  string    `json:"group_jid"`
+	GroupName string    `json:"group_name,omitempty"`
+	Code      string    `json:"code"`
+	// When the invite stops working; nil when it does not say
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Caption   string     `json:"caption,omitempty"`
+}
+
+// A group as WhatsApp last described it
+type Group struct {
+	JID         string    `json:"jid"`
+	Subject     string    `json:"subject"`
+	Description string    `json:"description,omitempty"`
+	Owner       string    `json:"owner,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	// When the metadata was fetched
+	SyncedAt time.Time `json:"synced_at"`
+	Size     int       `json:"size"`
+	// A community, the parent of the groups linked to it
+	IsCommunity bool `json:"is_community,omitempty"`
+	// The community a group is linked to
+	CommunityJID string `json:"community_jid,omitempty"`
+	// The announcement group of its community, where only admins post
+	IsAnnouncement bool `json:"is_announcement,omitempty"`
+	// Current members; left empty when groups are listed
+	Participants []GroupParticipant `json:"participants,omitempty"`
+	// The groups linked to a community, announcement group first
+	Subgroups []Group `json:"subgroups,omitempty"`
+}
+
+// A current member of a group
+type GroupParticipant struct {
+	JID string `json:"jid"`
+	// Phone-number JID of a member known by LID
+	PhoneJID     string `json:"phone_jid,omitempty"`
+	IsAdmin      bool   `json:"is_admin"`
+	IsSuperAdmin bool   `json:"is_super_admin"`
+}
+
+// Someone joining, leaving, or gaining or losing admin in a group
+type MembershipChange struct {
+	GroupJID string `json:"group_jid"`
+	Member   string `json:"member"`
+	// The member's contact name, when known
+	Name string `json:"name,omitempty"`
+	// EventJoin, EventLeave, EventPromote or EventDemote
+	Kind string `json:"kind"`
+	// Who added, removed, promoted or demoted the member; empty when
+	// unknown or the member acted themselves
+	Actor string    `json:"actor,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+// A contact card shared in a message
+type SharedContact struct {
+	MessageID string    `json:"message_id"`
+	ChatJID   string    `json:"chat_jid"`
+	Sender    string    `json:"sender,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Name      string    `json:"name"`
+	// E.164 where the number could be read as one, else as written
+	Phones []string `json:"phones"`
+	VCard  string   `json:"vcard"`
+}
+
+// A poll someone created in a chat; ID is the poll message's ID
+type Poll struct {
+	ID       string   `json:"id"`
+	ChatJID  string   `json:"chat_jid"`
+	Creator  string   `json:"creator"`
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	// How many options a voter may pick; 0 means any number
+	SelectableCount int       `json:"selectable_count"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// One person's current choice in a poll. Votes name options by the hex
+// SHA-256 of their text, as WhatsApp sends them; none selected is a
+// withdrawn vote.
+type PollVote struct {
+	PollID       string    `json:"poll_id"`
+	ChatJID      string    `json:"chat_jid"`
+	Voter        string    `json:"voter"`
+	OptionHashes []string  `json:"option_hashes"`
+	At           time.Time `json:"at"`
+}
+
+// How a call ended
+const (
+	CallAnswered = "answered"
+	CallMissed   = "missed"
+	CallRejected = "rejected"
+)
+
+// A voice or video call to or from me
+type Call struct {
+	ID string `json:"id"`
+	// The direct chat, or the group for group calls
+	ChatJID  string `json:"chat_jid"`
+	Caller   string `json:"caller"`
+	IsFromMe bool   `json:"is_from_me"`
+	IsVideo  bool   `json:"is_video"`
+	IsGroup  bool   `json:"is_group"`
+	// CallAnswered, CallMissed or CallRejected; calls are missed until
+	// answered or rejected
+	Outcome    string     `json:"outcome"`
+	StartedAt  time.Time  `json:"started_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	// Seconds from answer to hang-up
+	Duration int `json:"duration"`
+}
+
+// Kinds of chat event
+const (
+	EventJoin     = "join"
+	EventLeave    = "leave"
+	EventPromote  = "promote"
+	EventDemote   = "demote"
+	EventPin      = "pin"
+	EventUnpin    = "unpin"
+	EventTopic    = "topic"
+	EventAnnounce = "announce"
+)
+
+// Something that happened in a chat other than a message
+type ChatEvent struct {
+	ChatJID string `json:"chat_jid"`
+	Kind    string `json:"kind"`
+	// Member JID for membership events, message ID for pins, new text for
+	// topics, "on" or "off" for announce mode
+	Subject string    `json:"subject"`
+	Actor   string    `json:"actor,omitempty"`
+	At      time.Time `json:"at"`
+}
@@ -0,0 +1,272 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq" // registers the "postgres" driver
+)
+
+// SQL dialects the store speaks. Queries are written for SQLite and
+// rewritten when the store runs on PostgreSQL.
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+// ErrNotSQLite is returned for operations that only exist on SQLite, like
+// file snapshots, when the store runs on PostgreSQL
+var ErrNotSQLite = errors.New("not supported on PostgreSQL")
+
+// Whether dsn names a PostgreSQL database rather than an SQLite file
+func IsPostgres(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// Open the message store named by dsn: a postgres:// URL, or else the path
+// of an SQLite file
+func OpenDSN(dsn string, opts Options) (*SQLiteStore, error) {
+	if IsPostgres(dsn) {
+		return OpenPostgres(dsn, opts)
+	}
+	return OpenSQLite(dsn, opts)
+}
+
+// Open the message store in a PostgreSQL database, creating the schema if
+// needed. The same tables as on SQLite are used, so both hold the same data.
+// Of opts, only Key applies.
+func OpenPostgres(dsn string, opts Options) (*SQLiteStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	s := &SQLiteStore{db: db, writer: db, dialect: dialectPostgres}
+	if _, err := db.Exec(postgresDDL(schema)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	if err := s.addColumns(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	if s.keys, err = primaryKeys(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	if err := s.initKey(opts.Key); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Column types and statements in SQLite DDL, and their PostgreSQL
+// equivalents. Booleans stay integers, as SQLite stores them.
+var postgresTypes = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+	"CREATE VIEW IF NOT EXISTS", "CREATE OR REPLACE VIEW",
+)
+
+var postgresColumnTypes = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\bINTEGER\b`), "BIGINT"},
+	{regexp.MustCompile(`\bBOOLEAN\b`), "BIGINT"},
+	{regexp.MustCompile(`\bBLOB\b`), "BYTEA"},
+	{regexp.MustCompile(`\bTIMESTAMP\b`), "TIMESTAMPTZ"},
+	{regexp.MustCompile(`\bREAL\b`), "DOUBLE PRECISION"},
+}
+
+// Rewrite SQLite DDL for PostgreSQL
+func postgresDDL(ddl string) string {
+	ddl = postgresTypes.Replace(ddl)
+	for _, t := range postgresColumnTypes {
+		ddl = t.re.ReplaceAllString(ddl, t.repl)
+	}
+	return ddl
+}
+
+// Primary key columns of every table in the current schema, in key order
+func primaryKeys(db *sql.DB) (map[string][]string, error) {
+	rows, err := db.Query(`SELECT tc.table_name, kcu.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+		WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = current_schema()
+		ORDER BY tc.table_name, kcu.ordinal_position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := map[string][]string{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		keys[table] = append(keys[table], column)
+	}
+	return keys, rows.Err()
+}
+
+var (
+	insertOrReplace = regexp.MustCompile(`(?s)^\s*INSERT OR REPLACE INTO (\w+)\s*\(([^)]*)\)`)
+	updateOrIgnore  = regexp.MustCompile(`(?s)^\s*UPDATE OR IGNORE (\w+) SET (\w+) = \? WHERE (\w+) = \?\s*$`)
+	returning       = regexp.MustCompile(`(?is)\sRETURNING\s.*$`)
+	jsonCall        = regexp.MustCompile(`\bjson\(`)
+	nocase          = regexp.MustCompile(`([\w.]+) COLLATE NOCASE`)
+)
+
+// Rewrite a query written for SQLite into the store's dialect
+func (s *SQLiteStore) rebind(query string) string {
+	if s.dialect != dialectPostgres {
+		return query
+	}
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(query), "INSERT OR IGNORE INTO"):
+		query = onConflict(strings.Replace(query, "INSERT OR IGNORE INTO", "INSERT INTO", 1), "ON CONFLICT DO NOTHING")
+	case insertOrReplace.MatchString(query):
+		query = s.upsert(query)
+	case updateOrIgnore.MatchString(query):
+		query = s.updateUnlessTaken(query)
+	}
+	query = strings.ReplaceAll(query, "json_object(", "json_build_object(")
+	query = castJSON(query)
+	query = strings.ReplaceAll(query, " LIKE ", " ILIKE ")
+	query = nocase.ReplaceAllString(query, "LOWER($1)")
+	return numberParams(query)
+}
+
+// INSERT OR REPLACE as an upsert on the table's primary key
+func (s *SQLiteStore) upsert(query string) string {
+	m := insertOrReplace.FindStringSubmatch(query)
+	table := m[1]
+	key := s.keys[table]
+	inKey := map[string]bool{}
+	for _, k := range key {
+		inKey[k] = true
+	}
+	var set []string
+	for _, col := range strings.Split(m[2], ",") {
+		if col = strings.TrimSpace(col); !inKey[col] {
+			set = append(set, col+" = excluded."+col)
+		}
+	}
+	query = strings.Replace(query, "INSERT OR REPLACE INTO", "INSERT INTO", 1)
+	if len(set) == 0 {
+		return onConflict(query, "ON CONFLICT DO NOTHING")
+	}
+	return onConflict(query, "ON CONFLICT ("+strings.Join(key, ", ")+") DO UPDATE SET "+strings.Join(set, ", "))
+}
+
+// Add an ON CONFLICT clause to an INSERT, ahead of its RETURNING clause if
+// it has one
+func onConflict(query, clause string) string {
+	query = strings.TrimRight(query, " \t\n;")
+	if loc := returning.FindStringIndex(query); loc != nil {
+		return query[:loc[0]] + " " + clause + query[loc[0]:]
+	}
+	return query + " " + clause
+}
+
+// UPDATE OR IGNORE of one key column, skipping rows whose new key is
+// already taken
+func (s *SQLiteStore) updateUnlessTaken(query string) string {
+	m := updateOrIgnore.FindStringSubmatch(query)
+	table, col := m[1], m[2]
+	same := []string{"taken." + col + " = ?1"}
+	for _, k := range s.keys[table] {
+		if k != col {
+			same = append(same, "taken."+k+" = "+table+"."+k)
+		}
+	}
+	return fmt.Sprintf(`UPDATE %s SET %s = ?1 WHERE %s = ?2 AND NOT EXISTS (SELECT 1 FROM %s taken WHERE %s)`,
+		table, col, m[3], table, strings.Join(same, " AND "))
+}
+
+// Rewrite json(x), which SQLite uses to mark x as JSON, as a cast
+func castJSON(query string) string {
+	for {
+		loc := jsonCall.FindStringIndex(query)
+		if loc == nil {
+			return query
+		}
+		depth, end := 1, loc[1]
+		for ; end < len(query) && depth > 0; end++ {
+			switch query[end] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		inner := query[loc[1] : end-1]
+		query = query[:loc[0]] + "CAST(" + inner + " AS json)" + query[end:]
+	}
+}
+
+// Replace ? and ?N placeholders with $N, leaving quoted strings alone
+func numberParams(query string) string {
+	var b strings.Builder
+	next, quoted := 1, false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == '\'' {
+			quoted = !quoted
+		}
+		if c != '?' || quoted {
+			b.WriteByte(c)
+			continue
+		}
+		j := i + 1
+		for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+			j++
+		}
+		if j > i+1 {
+			b.WriteString("$" + query[i+1:j])
+			i = j - 1
+			continue
+		}
+		b.WriteString("$" + strconv.Itoa(next))
+		next++
+	}
+	return b.String()
+}
+
+// Arguments as the dialect stores them: PostgreSQL keeps booleans in
+// integer columns, as SQLite does, and SQLite keeps times as Unix
+// milliseconds; see epochArg. Both store the zero time as NULL.
+func (s *SQLiteStore) bindArgs(args []interface{}) []interface{} {
+	bound := make([]interface{}, len(args))
+	for i, a := range args {
+		if s.dialect != dialectPostgres {
+			bound[i] = epochArg(a)
+			continue
+		}
+		switch v := a.(type) {
+		case bool:
+			a = int64(0)
+			if v {
+				a = int64(1)
+			}
+		case time.Time:
+			if v.IsZero() {
+				a = nil
+			}
+		}
+		bound[i] = a
+	}
+	return bound
+}
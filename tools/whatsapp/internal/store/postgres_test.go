@@ -0,0 +1,227 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRebind(t *testing.T) {
+	s := &SQLiteStore{dialect: dialectPostgres, keys: map[string][]string{
+		"avatars":            {"jid"},
+		"messages":           {"id", "chat_jid"},
+		"group_participants": {"group_jid", "jid"},
+		"chat_links":         {"jid", "canonical_jid"},
+	}}
+	for _, tc := range []struct{ name, query, want string }{
+		{
+			"insert or ignore",
+			`INSERT OR IGNORE INTO chats (jid, last_message_time, phone, is_channel) VALUES (?, ?, ?, ?)`,
+			`INSERT INTO chats (jid, last_message_time, phone, is_channel) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
+		},
+		{
+			"insert or ignore from a select",
+			`INSERT OR IGNORE INTO chats (jid, name, last_message_time, phone, is_channel)
+			SELECT ?, name, last_message_time, ?, CAST(? AS INTEGER) FROM chats WHERE jid = ?`,
+			`INSERT INTO chats (jid, name, last_message_time, phone, is_channel)
+			SELECT $1, name, last_message_time, $2, CAST($3 AS INTEGER) FROM chats WHERE jid = $4 ON CONFLICT DO NOTHING`,
+		},
+		{
+			"insert or ignore returning",
+			`INSERT OR IGNORE INTO reminders (chat_jid, text, due_at, created_at) VALUES (?, ?, ?, ?) RETURNING id`,
+			`INSERT INTO reminders (chat_jid, text, due_at, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING RETURNING id`,
+		},
+		{
+			"insert or ignore with trailing space",
+			"INSERT OR IGNORE INTO calls (id, chat_jid)\n\t\tVALUES (?, ?)\n\t",
+			"INSERT INTO calls (id, chat_jid)\n\t\tVALUES ($1, $2) ON CONFLICT DO NOTHING",
+		},
+		{
+			"insert or replace",
+			`INSERT OR REPLACE INTO avatars (jid, picture_id, path, changed_at, checked_at) VALUES (?, ?, ?, ?, ?)`,
+			`INSERT INTO avatars (jid, picture_id, path, changed_at, checked_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (jid) DO UPDATE SET picture_id = excluded.picture_id, path = excluded.path, changed_at = excluded.changed_at, checked_at = excluded.checked_at`,
+		},
+		{
+			"insert or replace of key columns only",
+			`INSERT OR REPLACE INTO chat_links (jid, canonical_jid) VALUES (?, ?)`,
+			`INSERT INTO chat_links (jid, canonical_jid) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		},
+		{
+			"insert or replace returning",
+			`INSERT OR REPLACE INTO avatars (jid, path) VALUES (?, ?) RETURNING jid`,
+			`INSERT INTO avatars (jid, path) VALUES ($1, $2) ON CONFLICT (jid) DO UPDATE SET path = excluded.path RETURNING jid`,
+		},
+		{
+			"update or ignore",
+			`UPDATE OR IGNORE messages SET chat_jid = ? WHERE chat_jid = ?`,
+			`UPDATE messages SET chat_jid = $1 WHERE chat_jid = $2 AND NOT EXISTS (SELECT 1 FROM messages taken WHERE taken.chat_jid = $1 AND taken.id = messages.id)`,
+		},
+		{
+			"update or ignore of a leading key column",
+			`UPDATE OR IGNORE group_participants SET group_jid = ? WHERE group_jid = ?`,
+			`UPDATE group_participants SET group_jid = $1 WHERE group_jid = $2 AND NOT EXISTS (SELECT 1 FROM group_participants taken WHERE taken.group_jid = $1 AND taken.jid = group_participants.jid)`,
+		},
+		{
+			"update or ignore of a whole key",
+			`UPDATE OR IGNORE avatars SET jid = ? WHERE jid = ?`,
+			`UPDATE avatars SET jid = $1 WHERE jid = $2 AND NOT EXISTS (SELECT 1 FROM avatars taken WHERE taken.jid = $1)`,
+		},
+		{
+			"json",
+			`SELECT CASE WHEN m.system_kind IS NOT NULL THEN json_object('kind', m.system_kind,
+				'data', json(COALESCE(m.system_data, '{}'))) END FROM messages m WHERE m.id = ?`,
+			`SELECT CASE WHEN m.system_kind IS NOT NULL THEN json_build_object('kind', m.system_kind,
+				'data', CAST(COALESCE(m.system_data, '{}') AS json)) END FROM messages m WHERE m.id = $1`,
+		},
+		{
+			"like",
+			`SELECT id FROM messages m WHERE (m.content LIKE ? ESCAPE '\' OR m.ocr_text LIKE ? ESCAPE '\')`,
+			`SELECT id FROM messages m WHERE (m.content ILIKE $1 ESCAPE '\' OR m.ocr_text ILIKE $2 ESCAPE '\')`,
+		},
+		{
+			"collate nocase",
+			`SELECT jid FROM groups g WHERE g.community_jid = ? ORDER BY g.is_announcement DESC, g.subject COLLATE NOCASE`,
+			`SELECT jid FROM groups g WHERE g.community_jid = $1 ORDER BY g.is_announcement DESC, LOWER(g.subject)`,
+		},
+		{
+			"quoted question mark",
+			`SELECT '?', id FROM messages WHERE chat_jid = ?`,
+			`SELECT '?', id FROM messages WHERE chat_jid = $1`,
+		},
+		{
+			"numbered parameters",
+			`UPDATE outbox SET status = ?1 WHERE id = ?2`,
+			`UPDATE outbox SET status = $1 WHERE id = $2`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := s.rebind(tc.query); got != tc.want {
+				t.Errorf("rebind(%q)\n got %q\nwant %q", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+// Arguments to prepared statements are converted like any others: times
+// become Unix milliseconds on SQLite
+func TestPreparedStatementBindsArgs(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	if err := st.StoreChat(mergeInto, "Alice", at); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := st.begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.Prepare(`UPDATE chats SET last_message_time = ? WHERE jid = ?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if _, err := p.Exec(at.Add(time.Hour), mergeInto); err != nil {
+		t.Fatal(err)
+	}
+	var kind string
+	if err := tx.QueryRow(`SELECT typeof(last_message_time) FROM chats WHERE jid = ?`, mergeInto).Scan(&kind); err != nil {
+		t.Fatal(err)
+	}
+	if kind != "integer" {
+		t.Errorf("prepared statement stored the time as %s, want integer", kind)
+	}
+}
+
+func TestRebindLeavesSQLiteAlone(t *testing.T) {
+	s := &SQLiteStore{}
+	query := `INSERT OR IGNORE INTO chats (jid) VALUES (?) RETURNING jid`
+	if got := s.rebind(query); got != query {
+		t.Errorf("rebind(%q) = %q on SQLite", query, got)
+	}
+}
+
+// Run the store against a real PostgreSQL server, in a schema of its own
+// that is dropped afterwards. Set KENNY_TEST_POSTGRES_DSN to a postgres://
+// URL to enable it.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("KENNY_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("KENNY_TEST_POSTGRES_DSN not set")
+	}
+	admin, err := OpenPostgres(dsn, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("kenny_test_%d", time.Now().UnixNano())
+	if _, err := admin.db.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		admin.Close()
+	})
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	st, err := OpenPostgres(dsn+sep+"search_path="+schema, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	exerciseStore(t, st)
+}
+
+func TestExerciseSQLite(t *testing.T) {
+	exerciseStore(t, openTestStore(t))
+}
+
+// Writes and reads using each statement form the PostgreSQL rewrite handles
+func exerciseStore(t *testing.T, st *SQLiteStore) {
+	t.Helper()
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, jid := range []string{mergeFrom, mergeInto} {
+		must(st.StoreChat(jid, "Alice", at))
+	}
+	key := MessageKey{ID: "MSG1", ChatJID: mergeFrom}
+	must(st.StoreMessage(key.ID, key.ChatJID, "447700900456@s.whatsapp.net", "hello", at, false, "image", "", ""))
+	must(st.StoreMessage(key.ID, key.ChatJID, "", "hello", time.Time{}, false, "", "", ""))
+	must(st.StoreLocation(key, Location{Latitude: 51.5, Longitude: -0.12, Name: "London Eye"}))
+	must(st.StoreRawMessage(key, []byte("raw")))
+	must(st.StoreLinks(key, []SharedLink{{URL: "https://example.com/a", Domain: "example.com"}}))
+	must(st.StoreAvatar(Avatar{JID: mergeInto, PictureID: "1", CheckedAt: at}))
+	must(st.StoreAvatar(Avatar{JID: mergeInto, PictureID: "2", CheckedAt: at}))
+	must(st.StoreCall(Call{ID: "CALL1", ChatJID: mergeFrom, Caller: mergeFrom, Outcome: "missed", StartedAt: at}))
+	must(st.StoreCall(Call{ID: "CALL1", ChatJID: mergeFrom, Caller: mergeFrom, Outcome: "missed", StartedAt: at}))
+	if _, err := st.AddReminder(Reminder{ChatJID: mergeInto, Text: "call back", DueAt: at, CreatedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+	must(st.MergeChats(mergeFrom, mergeInto))
+	must(st.StoreMessage("MSG2", mergeInto, "", "gone soon", at, true, "", "", ""))
+	if n, err := st.DeleteMessages([]MessageKey{{ID: "MSG2", ChatJID: mergeInto}}); err != nil || n != 1 {
+		t.Errorf("DeleteMessages = %d, %v, want 1", n, err)
+	}
+
+	m, err := st.GetMessage(MessageKey{ID: key.ID, ChatJID: mergeInto})
+	must(err)
+	if !m.Timestamp.Equal(at) || m.Location == nil || m.Location.Name != "London Eye" {
+		t.Errorf("message %+v after merging", m)
+	}
+	results, err := st.SearchMessages("HELLO", 10)
+	must(err)
+	if len(results) != 1 {
+		t.Errorf("%d search results, want 1", len(results))
+	}
+	if _, err := st.ListGroups(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+)
+
+// Encodings of a stored raw payload
+const (
+	rawProto = "proto"
+	rawGzip  = "gzip"
+)
+
+// Raw messages handed to ForEachRawMessage's fn per query, so fn can write
+// to the store between batches
+const rawBatch = 200
+
+// Keep the marshaled proto a message arrived as, gzipped when that makes it
+// smaller and sealed when the archive is encrypted
+func (s *SQLiteStore) StoreRawMessage(key MessageKey, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	encoding := rawProto
+	if packed, err := gzipped(data); err == nil && len(packed) < len(data) {
+		encoding, data = rawGzip, packed
+	}
+	data, err := s.sealBytes(data)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(`INSERT OR REPLACE INTO message_raw (message_id, chat_jid, encoding, data) VALUES (?, ?, ?, ?)`,
+		key.ID, key.ChatJID, encoding, data)
+	return err
+}
+
+// Walk the messages with a stored raw payload in a chat and the chats
+// linked to it, oldest first, passing each with its marshaled proto; an
+// empty chatJID means all chats. Returning an error from fn stops the walk.
+func (s *SQLiteStore) ForEachRawMessage(chatJID string, fn func(Message, []byte) error) error {
+	query := `SELECT ` + messageColumns + `, r.encoding, r.data
+		FROM message_raw r
+		JOIN messages m ON m.id = r.message_id AND m.chat_jid = r.chat_jid
+		LEFT JOIN chats c ON c.jid = m.chat_jid`
+	var args []interface{}
+	if chatJID != "" {
+		group, err := s.ChatGroup(chatJID)
+		if err != nil {
+			return err
+		}
+		query += ` WHERE m.chat_jid IN (` + placeholders(len(group)) + `)`
+		args = stringArgs(group)
+	}
+	query += ` ORDER BY m.timestamp, m.id, m.chat_jid LIMIT ? OFFSET ?`
+
+	type raw struct {
+		m    Message
+		data []byte
+	}
+	for offset := 0; ; offset += rawBatch {
+		rows, err := s.query(query, append(args, rawBatch, offset)...)
+		if err != nil {
+			return err
+		}
+		var batch []raw
+		for rows.Next() {
+			var r raw
+			var ts sql.NullTime
+			var encoding string
+			if err := rows.Scan(append(s.messageDest(&r.m, &ts), &encoding, &r.data)...); err != nil {
+				rows.Close()
+				return err
+			}
+			r.m.Timestamp = ts.Time
+			if r.data, err = s.openRaw(encoding, r.data); err != nil {
+				rows.Close()
+				return fmt.Errorf("raw payload of %s in %s: %w", r.m.ID, r.m.ChatJID, err)
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, r := range batch {
+			if err := fn(r.m, r.data); err != nil {
+				return err
+			}
+		}
+		if len(batch) < rawBatch {
+			return nil
+		}
+	}
+}
+
+// Replace what was extracted from a stored message. The text of an edited
+// message is its latest edit, which the original payload doesn't have, so
+// it is kept.
+func (s *SQLiteStore) UpdateExtracted(key MessageKey, content, mediaType, filename string) error {
+	content, err := s.seal(content)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(`UPDATE messages SET content = CASE WHEN edited_at IS NULL THEN ? ELSE content END,
+		media_type = ?, filename = ? WHERE id = ? AND chat_jid = ?`,
+		content, mediaType, filename, key.ID, key.ChatJID)
+	return err
+}
+
+// The marshaled proto in a stored raw payload
+func (s *SQLiteStore) openRaw(encoding string, data []byte) ([]byte, error) {
+	data, err := s.unsealBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	switch encoding {
+	case rawProto:
+		return data, nil
+	case rawGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
+
+func gzipped(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Seal a binary value like text
+func (s *SQLiteStore) sealBytes(data []byte) ([]byte, error) {
+	if s.aead == nil {
+		return data, nil
+	}
+	sealed, err := s.seal(string(data))
+	return []byte(sealed), err
+}
+
+func (s *SQLiteStore) unsealBytes(data []byte) ([]byte, error) {
+	if s.aead == nil {
+		return data, nil
+	}
+	plain, err := s.unseal(string(data))
+	return []byte(plain), err
+}
+
+// Seal every raw payload, a batch at a time in key order
+func (s *SQLiteStore) sealRaw(tx txn) error {
+	type plain struct {
+		id, chat string
+		data     []byte
+	}
+	for offset := 0; ; offset += sealBatch {
+		rows, err := tx.Query(`SELECT message_id, chat_jid, data FROM message_raw
+			ORDER BY message_id, chat_jid LIMIT ? OFFSET ?`, sealBatch, offset)
+		if err != nil {
+			return err
+		}
+		var batch []plain
+		for rows.Next() {
+			var p plain
+			if err := rows.Scan(&p.id, &p.chat, &p.data); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, p := range batch {
+			sealed, err := s.sealBytes(p.data)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE message_raw SET data = ? WHERE message_id = ? AND chat_jid = ?`,
+				sealed, p.id, p.chat); err != nil {
+				return err
+			}
+		}
+		if len(batch) < sealBatch {
+			return nil
+		}
+	}
+}
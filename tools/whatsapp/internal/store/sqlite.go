@@ -0,0 +1,3542 @@
+package store
+
+import (
+	"context"
+	"crypto/cipher"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"whatsapp-logger/internal/phone"
+)
+
+// SQLiteStore handles SQLite database operations. It also runs on
+// PostgreSQL, rewriting its queries for that dialect; see OpenPostgres.
+type SQLiteStore struct {
+	db *sql.DB
+	// Connection every write goes through. On an SQLite file it is a
+	// separate single-connection pool, so writers queue here instead of
+	// failing with "database is locked"; otherwise it is db.
+	writer  *sql.DB
+	closed  atomic.Bool
+	dialect dialect
+	// Primary key columns by table, for rewriting SQLite's conflict clauses
+	keys map[string][]string
+	// Whether messages_fts indexes messages; see initFTS
+	fts bool
+	// Seals message text when the archive is encrypted; see initKey
+	aead cipher.AEAD
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// Options tunes how the store is opened. The zero value is the default.
+type Options struct {
+	// Passphrase encrypting message text; see initKey. Required once set.
+	Key string
+	// SQLite's PRAGMA synchronous: "OFF", "NORMAL", "FULL" or "EXTRA" (default
+	// "NORMAL", which in WAL mode can lose the last commits on power loss
+	// but never corrupts the file)
+	Synchronous string
+	// How long a statement waits for another process to release its lock
+	// (default 5s)
+	BusyTimeout time.Duration
+}
+
+// The connection parameters for opts
+func (o Options) params() (string, error) {
+	sync := strings.ToUpper(o.Synchronous)
+	switch sync {
+	case "":
+		sync = "NORMAL"
+	case "OFF", "NORMAL", "FULL", "EXTRA":
+	default:
+		return "", fmt.Errorf("invalid synchronous mode %q", o.Synchronous)
+	}
+	timeout := o.BusyTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return fmt.Sprintf("_foreign_keys=on&_recursive_triggers=on&_journal_mode=WAL&_synchronous=%s&_busy_timeout=%d",
+		sync, timeout.Milliseconds()), nil
+}
+
+// Open the message store at dbPath, creating the schema from whatsapp-mcp if needed
+func Open(dbPath string) (*SQLiteStore, error) {
+	return OpenSQLite(dbPath, Options{})
+}
+
+// Open the message store at dbPath in WAL mode, so readers like the query
+// command never wait for a long history sync to finish writing
+func OpenSQLite(dbPath string, opts Options) (*SQLiteStore, error) {
+	params, err := opts.params()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Open SQLite database
+	dsn := fmt.Sprintf("file:%s?%s", dbPath, params)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// The writer takes the write lock as each transaction begins, so a
+	// transaction never fails upgrading from a read lock
+	writer, err := sql.Open("sqlite3", dsn+"&_txlock=immediate")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	writer.SetMaxOpenConns(1)
+
+	return initSQLite(db, writer, opts.Key)
+}
+
+// Open a throwaway in-memory message store, useful for tests and dry runs
+func OpenMemory() (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on&_recursive_triggers=on")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// Every connection to :memory: is a separate database, so pin to one
+	db.SetMaxOpenConns(1)
+
+	return initSQLite(db, db, "")
+}
+
+// Create the schema on an opened database
+func initSQLite(db, writer *sql.DB, key string) (*SQLiteStore, error) {
+	s := &SQLiteStore{db: db, writer: writer}
+	if _, err := writer.Exec(schema); err != nil {
+		s.closeDB()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	if err := s.addColumns(); err != nil {
+		s.closeDB()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	if err := s.epochTimestamps(); err != nil {
+		s.closeDB()
+		return nil, fmt.Errorf("failed to migrate timestamps: %w", err)
+	}
+	if err := s.initKey(key); err != nil {
+		s.closeDB()
+		return nil, err
+	}
+	if err := s.initFTS(); err != nil {
+		s.closeDB()
+		return nil, fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	return s, nil
+}
+
+// Columns added after a table's first release, which CREATE TABLE IF NOT
+// EXISTS leaves out of databases created before them
+var addedColumns = []struct{ table, column, decl string }{
+	{"messages", "reply_to", "TEXT"},
+	{"messages", "reply_to_sender", "TEXT"},
+	// E.164 forms of the raw sender and chat JIDs
+	{"messages", "sender_phone", "TEXT"},
+	{"chats", "phone", "TEXT"},
+	// Downloaded attachments
+	{"messages", "direct_path", "TEXT"},
+	{"messages", "mime_type", "TEXT"},
+	{"messages", "local_path", "TEXT"},
+	// Text read from images; NULL until OCR has run
+	{"messages", "ocr_text", "TEXT"},
+	// Attachments kept in object storage instead of on disk
+	{"messages", "object_url", "TEXT"},
+	// Files deleted by media retention; their metadata stays
+	{"media_blobs", "evicted_at", "TIMESTAMP"},
+	// Set when the sender edits a message; earlier text is in message_revisions
+	{"messages", "edited_at", "TIMESTAMP"},
+	// Set when a message is deleted for everyone, with the JID that deleted it
+	{"messages", "deleted_at", "TIMESTAMP"},
+	{"messages", "revoked_by", "TEXT"},
+	// Shared locations; latitude is NULL for other messages
+	{"messages", "latitude", "REAL"},
+	{"messages", "longitude", "REAL"},
+	{"messages", "location_accuracy", "INTEGER"},
+	{"messages", "location_name", "TEXT"},
+	{"messages", "location_address", "TEXT"},
+	{"messages", "location_live", "INTEGER"},
+	{"messages", "live_duration", "INTEGER"},
+	{"messages", "is_view_once", "INTEGER NOT NULL DEFAULT 0"},
+	// WhatsApp Channels (newsletters) followed by the account
+	{"chats", "is_channel", "INTEGER NOT NULL DEFAULT 0"},
+	// Forwarded messages and how many times they had been forwarded
+	{"messages", "is_forwarded", "INTEGER NOT NULL DEFAULT 0"},
+	{"messages", "forwarding_score", "INTEGER NOT NULL DEFAULT 0"},
+	// Set when a message mentions the account
+	{"messages", "mentions_me", "INTEGER NOT NULL DEFAULT 0"},
+	// Buttons, list and template messages as JSON
+	{"messages", "interactive", "TEXT"},
+	// Group changes logged as messages; system_data is a JSON object
+	{"messages", "system_kind", "TEXT"},
+	{"messages", "system_data", "TEXT"},
+	// Chat settings synced from the phone; a muted chat with no end time is
+	// muted until unmuted
+	{"chats", "is_muted", "INTEGER NOT NULL DEFAULT 0"},
+	{"chats", "muted_until", "TIMESTAMP"},
+	{"chats", "is_archived", "INTEGER NOT NULL DEFAULT 0"},
+	{"chats", "is_pinned", "INTEGER NOT NULL DEFAULT 0"},
+	// E.164 number of a contact
+	{"contacts", "phone", "TEXT"},
+	// Community hierarchy: parents, the community each group is linked
+	// to, and each community's announcement group
+	{"groups", "is_community", "INTEGER NOT NULL DEFAULT 0"},
+	{"groups", "community_jid", "TEXT"},
+	{"groups", "is_announcement", "INTEGER NOT NULL DEFAULT 0"},
+	// What an encrypted archive has sealed; see sealedVersion
+	{"encryption", "sealed_version", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// Add any addedColumns an older database lacks, filling in derived ones
+func (s *SQLiteStore) addColumns() error {
+	existing := map[string]map[string]bool{}
+	for _, c := range addedColumns {
+		if existing[c.table] == nil {
+			cols, err := s.tableColumns(c.table)
+			if err != nil {
+				return err
+			}
+			existing[c.table] = cols
+		}
+		if existing[c.table][c.column] {
+			continue
+		}
+		decl := c.decl
+		if s.dialect == dialectPostgres {
+			decl = postgresDDL(decl)
+		}
+		if _, err := s.exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.column, decl)); err != nil {
+			return err
+		}
+		if fill := backfills[c.table+"."+c.column]; fill != nil {
+			if err := fill(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Populate a newly added column on existing rows
+var backfills = map[string]func(*SQLiteStore) error{
+	"messages.sender_phone": func(s *SQLiteStore) error {
+		return s.fillPhones(`SELECT DISTINCT sender FROM messages WHERE sender IS NOT NULL`,
+			`UPDATE messages SET sender_phone = ? WHERE sender = ?`)
+	},
+	"chats.phone": func(s *SQLiteStore) error {
+		return s.fillPhones(`SELECT jid FROM chats`, `UPDATE chats SET phone = ? WHERE jid = ?`)
+	},
+	"chats.is_channel": func(s *SQLiteStore) error {
+		_, err := s.exec(`UPDATE chats SET is_channel = 1 WHERE jid LIKE ?`, "%"+channelSuffix)
+		return err
+	},
+}
+
+// SQLite's user_version once timestamps are stored as Unix milliseconds,
+// and once unknown times are stored as NULL
+const (
+	epochVersion    = 1
+	nullZeroVersion = 2
+)
+
+// The zero time in Unix milliseconds, as stored before nullZeroVersion
+const zeroEpoch = -62135596800000
+
+// Rewrite the timestamps of databases written before epochVersion. The
+// driver stored them as text in the zone of the time it was handed, so
+// times written either side of a DST change or from another zone compared
+// and sorted as strings out of order. Before nullZeroVersion, unknown times
+// were stored as the zero time, which reads back as a date in 1754; they
+// become NULL where the column allows it. Every TIMESTAMP column is
+// converted, once, in one transaction.
+func (s *SQLiteStore) epochTimestamps() error {
+	var version int
+	if err := s.writer.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version >= nullZeroVersion {
+		return nil
+	}
+	// Virtual tables are left out: the full-text index's can't be read by a
+	// build without FTS5, and it has no timestamps
+	rows, err := s.query(`SELECT t.name, c.name, c."notnull" FROM sqlite_master t, pragma_table_info(t.name) c
+		WHERE t.type = 'table' AND t.sql NOT LIKE 'CREATE VIRTUAL TABLE%' AND UPPER(c.type) = 'TIMESTAMP'`)
+	if err != nil {
+		return err
+	}
+	type column struct {
+		table, name string
+		notNull     bool
+	}
+	var columns []column
+	for rows.Next() {
+		var c column
+		if err := rows.Scan(&c.table, &c.name, &c.notNull); err != nil {
+			rows.Close()
+			return err
+		}
+		columns = append(columns, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, c := range columns {
+		if version < epochVersion {
+			// unixepoch() reads the offset the driver wrote; text it can't
+			// read is left alone
+			_, err := tx.Exec(fmt.Sprintf(`UPDATE %[1]s SET %[2]s = CAST(ROUND(unixepoch(%[2]s, 'subsec') * 1000) AS INTEGER)
+				WHERE typeof(%[2]s) = 'text' AND unixepoch(%[2]s, 'subsec') IS NOT NULL`, c.table, c.name))
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", c.table, c.name, err)
+			}
+		}
+		if !c.notNull {
+			_, err := tx.Exec(fmt.Sprintf(`UPDATE %[1]s SET %[2]s = NULL WHERE %[2]s = %[3]d`, c.table, c.name, zeroEpoch))
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", c.table, c.name, err)
+			}
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, nullZeroVersion)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// A time argument as SQLite stores it, in Unix milliseconds, so times
+// handed over in any zone compare and sort as the instants they are. The
+// driver reads these back into time.Time, in UTC, from TIMESTAMP columns.
+// The zero time, which callers pass for a time they don't know, is stored
+// as NULL and read back as the zero time.
+func epochArg(a interface{}) interface{} {
+	switch t := a.(type) {
+	case time.Time:
+		if t.IsZero() {
+			return nil
+		}
+		return t.UnixMilli()
+	case *time.Time:
+		if t == nil || t.IsZero() {
+			return nil
+		}
+		return t.UnixMilli()
+	case sql.NullTime:
+		if !t.Valid || t.Time.IsZero() {
+			return nil
+		}
+		return t.Time.UnixMilli()
+	}
+	return a
+}
+
+// Names of the columns table has
+func (s *SQLiteStore) tableColumns(table string) (map[string]bool, error) {
+	query := `SELECT name FROM pragma_table_info(?)`
+	if s.dialect == dialectPostgres {
+		query = `SELECT column_name FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ?`
+	}
+	rows, err := s.query(query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
+// Normalize every JID selected by list and write it back with update
+func (s *SQLiteStore) fillPhones(list, update string) error {
+	rows, err := s.query(list)
+	if err != nil {
+		return err
+	}
+	var jids []string
+	for rows.Next() {
+		var jid string
+		if err := rows.Scan(&jid); err != nil {
+			rows.Close()
+			return err
+		}
+		jids = append(jids, jid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, jid := range jids {
+		if e164 := phone.FromJID(jid); e164 != "" {
+			if _, err := tx.Exec(update, e164, jid); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Tables with schema from whatsapp-mcp
+const schema = `
+	CREATE TABLE IF NOT EXISTS chats (
+		jid TEXT PRIMARY KEY,
+		name TEXT,
+		last_message_time TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS messages (
+		id TEXT,
+		chat_jid TEXT,
+		sender TEXT,
+		content TEXT,
+		timestamp TIMESTAMP,
+		is_from_me BOOLEAN,
+		media_type TEXT,
+		filename TEXT,
+		url TEXT,
+		media_key BLOB,
+		file_sha256 BLOB,
+		file_enc_sha256 BLOB,
+		file_length INTEGER,
+		PRIMARY KEY (id, chat_jid),
+		FOREIGN KEY (chat_jid) REFERENCES chats(jid)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_messages_chat_jid ON messages(chat_jid);
+
+	-- Local flags on messages, independent of WhatsApp stars
+	CREATE TABLE IF NOT EXISTS bookmarks (
+		message_id TEXT,
+		chat_jid TEXT,
+		note TEXT,
+		created_at TIMESTAMP,
+		PRIMARY KEY (message_id, chat_jid)
+	);
+
+	-- Chats that continue another one under a new JID; every linked JID
+	-- points straight at the canonical one
+	CREATE TABLE IF NOT EXISTS chat_links (
+		jid TEXT PRIMARY KEY,
+		canonical_jid TEXT NOT NULL,
+		linked_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_chat_links_canonical ON chat_links(canonical_jid);
+
+	-- Push names contacts have used, for name history
+	CREATE TABLE IF NOT EXISTS contact_names (
+		jid TEXT,
+		name TEXT,
+		first_seen TIMESTAMP,
+		last_seen TIMESTAMP,
+		PRIMARY KEY (jid, name)
+	);
+
+	-- Phone-number JIDs of contacts WhatsApp addresses by LID. Chats and
+	-- senders under either JID are treated as the same contact.
+	CREATE TABLE IF NOT EXISTS lid_mappings (
+		lid TEXT PRIMARY KEY,
+		phone_jid TEXT NOT NULL,
+		updated_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_lid_mappings_phone ON lid_mappings(phone_jid);
+
+	-- Names from the address book and WhatsApp, current values only
+	CREATE TABLE IF NOT EXISTS contacts (
+		jid TEXT PRIMARY KEY,
+		full_name TEXT,
+		first_name TEXT,
+		push_name TEXT,
+		business_name TEXT,
+		updated_at TIMESTAMP
+	);
+
+	-- The name to show for each contact: as saved in the address book,
+	-- else the verified business name, else the push name
+	CREATE VIEW IF NOT EXISTS contact_display AS
+		SELECT jid, COALESCE(NULLIF(full_name, ''), NULLIF(business_name, ''), NULLIF(push_name, '')) AS name
+		FROM contacts;
+
+	-- Group metadata as last fetched from WhatsApp
+	CREATE TABLE IF NOT EXISTS groups (
+		jid TEXT PRIMARY KEY,
+		subject TEXT,
+		description TEXT,
+		owner TEXT,
+		created_at TIMESTAMP,
+		synced_at TIMESTAMP
+	);
+
+	-- Current members of each synced group
+	CREATE TABLE IF NOT EXISTS group_participants (
+		group_jid TEXT NOT NULL,
+		jid TEXT NOT NULL,
+		phone_jid TEXT,
+		is_admin INTEGER NOT NULL DEFAULT 0,
+		is_super_admin INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (group_jid, jid)
+	);
+
+	-- Joins, leaves, promotions and demotions in groups, from live events
+	-- and history sync
+	CREATE TABLE IF NOT EXISTS membership_changes (
+		group_jid TEXT NOT NULL,
+		member_jid TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		actor TEXT,
+		at TIMESTAMP NOT NULL,
+		PRIMARY KEY (group_jid, member_jid, kind, at)
+	);
+
+	-- Profile pictures saved for contacts and groups
+	CREATE TABLE IF NOT EXISTS avatars (
+		jid TEXT PRIMARY KEY,
+		picture_id TEXT,
+		path TEXT,
+		changed_at TIMESTAMP,
+		checked_at TIMESTAMP
+	);
+
+	-- Online and offline transitions of contacts whose presence is watched
+	CREATE TABLE IF NOT EXISTS presence_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		jid TEXT NOT NULL,
+		online INTEGER NOT NULL,
+		at TIMESTAMP NOT NULL,
+		last_seen TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_presence_log_jid ON presence_log(jid, at);
+
+	-- Reminders set from the self-chat command inbox
+	CREATE TABLE IF NOT EXISTS reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_jid TEXT NOT NULL,
+		text TEXT NOT NULL,
+		due_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP
+	);
+
+	-- Messages queued to send later, kept once sent
+	CREATE TABLE IF NOT EXISTS outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_jid TEXT NOT NULL,
+		text TEXT NOT NULL,
+		send_at TIMESTAMP NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL,
+		last_error TEXT,
+		sent_at TIMESTAMP,
+		created_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox(status, next_attempt_at);
+
+	-- Messages held back until approved, kept once decided
+	CREATE TABLE IF NOT EXISTS pending_sends (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_jid TEXT NOT NULL,
+		text TEXT NOT NULL,
+		send_at TIMESTAMP,
+		status TEXT NOT NULL DEFAULT 'pending',
+		created_at TIMESTAMP NOT NULL,
+		decided_at TIMESTAMP
+	);
+
+	-- Group membership, pin and settings changes
+	CREATE TABLE IF NOT EXISTS chat_events (
+		chat_jid TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		subject TEXT,
+		actor TEXT,
+		at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_chat_events_chat ON chat_events(chat_jid, at);
+
+	-- Latest reaction of each person to a message; an empty emoji is a
+	-- removed reaction, kept so an older one replayed later cannot revive it
+	CREATE TABLE IF NOT EXISTS reactions (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		reactor TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		PRIMARY KEY (message_id, chat_jid, reactor)
+	);
+
+	-- Delivery and read receipts for messages I sent, one row per recipient
+	CREATE TABLE IF NOT EXISTS receipts (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		recipient TEXT NOT NULL,
+		delivered_at TIMESTAMP,
+		read_at TIMESTAMP,
+		PRIMARY KEY (message_id, chat_jid, recipient)
+	);
+
+	-- URLs shared in messages, one row per URL per message
+	CREATE TABLE IF NOT EXISTS links (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		url TEXT NOT NULL,
+		title TEXT,
+		description TEXT,
+		domain TEXT NOT NULL,
+		PRIMARY KEY (message_id, chat_jid, url)
+	);
+	CREATE INDEX IF NOT EXISTS idx_links_domain ON links(domain);
+
+	-- Contact cards shared in messages, in the order the message lists them;
+	-- phones is a JSON array
+	CREATE TABLE IF NOT EXISTS shared_contacts (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		display_name TEXT,
+		phones TEXT NOT NULL,
+		vcard TEXT,
+		PRIMARY KEY (message_id, chat_jid, position)
+	);
+
+	-- Group invites sent as messages
+	CREATE TABLE IF NOT EXISTS group_invites (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		group_jid TEXT NOT NULL,
+		group_name TEXT,
+		invite_code TEXT NOT NULL,
+		expires_at TIMESTAMP,
+		caption TEXT,
+		PRIMARY KEY (message_id, chat_jid)
+	);
+
+	-- Polls and each voter's latest choice; options are JSON arrays, of
+	-- option text for polls and of hex SHA-256 hashes of it for votes
+	CREATE TABLE IF NOT EXISTS polls (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		creator TEXT,
+		question TEXT NOT NULL,
+		options TEXT NOT NULL,
+		selectable_count INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP,
+		PRIMARY KEY (message_id, chat_jid)
+	);
+	CREATE TABLE IF NOT EXISTS poll_votes (
+		poll_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		voter TEXT NOT NULL,
+		options TEXT NOT NULL,
+		voted_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (poll_id, chat_jid, voter)
+	);
+
+	-- Calls to and from me; outcome is answered, missed or rejected
+	CREATE TABLE IF NOT EXISTS calls (
+		id TEXT PRIMARY KEY,
+		chat_jid TEXT NOT NULL,
+		caller TEXT NOT NULL,
+		is_from_me INTEGER NOT NULL DEFAULT 0,
+		is_video INTEGER NOT NULL DEFAULT 0,
+		is_group INTEGER NOT NULL DEFAULT 0,
+		outcome TEXT NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		accepted_at TIMESTAMP,
+		ended_at TIMESTAMP,
+		duration INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_calls_chat ON calls(chat_jid, started_at);
+
+	-- Text an edited message had before each edit
+	CREATE TABLE IF NOT EXISTS message_revisions (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		content TEXT,
+		replaced_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_message_revisions_message ON message_revisions(message_id, chat_jid);
+
+	-- Link preview metadata seen on shared URLs
+	CREATE TABLE IF NOT EXISTS link_previews (
+		url TEXT PRIMARY KEY,
+		title TEXT,
+		description TEXT
+	);
+
+	-- Downloaded attachments stored once by content hash, and the messages using each
+	-- path is a file path, or an object URL for object storage
+	CREATE TABLE IF NOT EXISTS media_blobs (
+		sha256 TEXT PRIMARY KEY,
+		path TEXT NOT NULL,
+		size INTEGER,
+		mime_type TEXT,
+		created_at TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS media_refs (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		sha256 TEXT NOT NULL,
+		PRIMARY KEY (message_id, chat_jid)
+	);
+	CREATE INDEX IF NOT EXISTS idx_media_refs_sha256 ON media_refs(sha256);
+
+	-- Pack metadata read from downloaded sticker files; emojis is a JSON array
+	CREATE TABLE IF NOT EXISTS stickers (
+		sha256 TEXT PRIMARY KEY,
+		pack_id TEXT,
+		pack_name TEXT,
+		publisher TEXT,
+		emojis TEXT NOT NULL
+	);
+
+	-- The salt of the passphrase encrypting message text, and a value sealed
+	-- with it to tell a wrong passphrase
+	CREATE TABLE IF NOT EXISTS encryption (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		salt BLOB NOT NULL,
+		check_value TEXT NOT NULL
+	);
+
+	-- Each message's proto as received, so extraction can be re-run over it.
+	-- encoding is proto, or gzip for a gzipped proto; data may be sealed.
+	CREATE TABLE IF NOT EXISTS message_raw (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		encoding TEXT NOT NULL,
+		data BLOB NOT NULL,
+		PRIMARY KEY (message_id, chat_jid)
+	);
+
+	-- Failed attachment downloads, so media-backfill can resume without retrying forever
+	CREATE TABLE IF NOT EXISTS media_downloads (
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		attempts INTEGER NOT NULL,
+		last_error TEXT,
+		last_attempt TIMESTAMP,
+		PRIMARY KEY (message_id, chat_jid)
+	);
+`
+
+// Close the database connection
+func (s *SQLiteStore) Close() error {
+	if s.closed.Swap(true) {
+		return ErrStoreClosed
+	}
+	return s.closeDB()
+}
+
+func (s *SQLiteStore) closeDB() error {
+	err := s.db.Close()
+	if s.writer != s.db {
+		if werr := s.writer.Close(); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// Run a statement, reporting ErrStoreClosed once the store has been closed
+func (s *SQLiteStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	if s.closed.Load() {
+		return nil, ErrStoreClosed
+	}
+	return s.writer.Exec(s.rebind(query), s.bindArgs(args)...)
+}
+
+// Run a statement that returns a row, like INSERT ... RETURNING
+func (s *SQLiteStore) execRow(query string, args ...interface{}) row {
+	if s.closed.Load() {
+		return row{err: ErrStoreClosed}
+	}
+	return row{Row: s.writer.QueryRow(s.rebind(query), s.bindArgs(args)...)}
+}
+
+// Run a query, reporting ErrStoreClosed once the store has been closed
+func (s *SQLiteStore) query(query string, args ...interface{}) (*sql.Rows, error) {
+	if s.closed.Load() {
+		return nil, ErrStoreClosed
+	}
+	return s.db.Query(s.rebind(query), s.bindArgs(args)...)
+}
+
+// Run a single-row query; Scan on the result reports ErrStoreClosed once closed
+func (s *SQLiteStore) queryRow(query string, args ...interface{}) row {
+	if s.closed.Load() {
+		return row{err: ErrStoreClosed}
+	}
+	return row{Row: s.db.QueryRow(s.rebind(query), s.bindArgs(args)...)}
+}
+
+// Start a transaction, reporting ErrStoreClosed once the store has been closed
+func (s *SQLiteStore) begin() (txn, error) {
+	if s.closed.Load() {
+		return txn{}, ErrStoreClosed
+	}
+	tx, err := s.writer.Begin()
+	return txn{Tx: tx, s: s}, err
+}
+
+// A transaction whose statements are rewritten for the store's dialect
+type txn struct {
+	*sql.Tx
+	s *SQLiteStore
+}
+
+func (t txn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.s.rebind(query), t.s.bindArgs(args)...)
+}
+
+func (t txn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.s.rebind(query), t.s.bindArgs(args)...)
+}
+
+func (t txn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.s.rebind(query), t.s.bindArgs(args)...)
+}
+
+func (t txn) Prepare(query string) (stmt, error) {
+	prepared, err := t.Tx.Prepare(t.s.rebind(query))
+	return stmt{Stmt: prepared, s: t.s}, err
+}
+
+// A prepared statement whose arguments are converted for the store's
+// dialect, like those of txn's statements
+type stmt struct {
+	*sql.Stmt
+	s *SQLiteStore
+}
+
+func (p stmt) Exec(args ...interface{}) (sql.Result, error) {
+	return p.Stmt.Exec(p.s.bindArgs(args)...)
+}
+
+func (p stmt) Query(args ...interface{}) (*sql.Rows, error) {
+	return p.Stmt.Query(p.s.bindArgs(args)...)
+}
+
+func (p stmt) QueryRow(args ...interface{}) *sql.Row {
+	return p.Stmt.QueryRow(p.s.bindArgs(args)...)
+}
+
+// Single-row result that can carry an error from before the query ran
+type row struct {
+	*sql.Row
+	err error
+}
+
+func (r row) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Row.Scan(dest...)
+}
+
+// Store a chat in the database. A name that is empty or just the JID, as
+// callers pass when they know no better, keeps the one stored, and the last
+// message time only moves forward, so history sync and imports of older
+// messages don't turn it back.
+func (s *SQLiteStore) StoreChat(jid, name string, lastMessageTime time.Time) error {
+	query := `INSERT INTO chats (jid, name, last_message_time, phone, is_channel) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			name = CASE WHEN COALESCE(excluded.name, '') IN ('', excluded.jid) AND COALESCE(chats.name, '') != ''
+				THEN chats.name ELSE excluded.name END,
+			last_message_time = CASE WHEN chats.last_message_time IS NULL OR excluded.last_message_time > chats.last_message_time
+				THEN excluded.last_message_time ELSE chats.last_message_time END,
+			phone = excluded.phone, is_channel = excluded.is_channel`
+	_, err := s.exec(query, jid, name, lastMessageTime, nullString(s.jidPhone(jid)), IsChannel(jid))
+	return err
+}
+
+// Mute or unmute a chat; a nil until mutes it until unmuted. Chats not
+// yet stored are created.
+func (s *SQLiteStore) SetChatMuted(jid string, muted bool, until *time.Time) error {
+	var end sql.NullTime
+	if muted && until != nil {
+		end = sql.NullTime{Time: until.UTC(), Valid: true}
+	}
+	return s.setChatState(jid, `is_muted = excluded.is_muted, muted_until = excluded.muted_until`,
+		`is_muted, muted_until`, muted, end)
+}
+
+// Archive or unarchive a chat, creating it when not yet stored
+func (s *SQLiteStore) SetChatArchived(jid string, archived bool) error {
+	return s.setChatState(jid, `is_archived = excluded.is_archived`, `is_archived`, archived)
+}
+
+// Pin or unpin a chat, creating it when not yet stored
+func (s *SQLiteStore) SetChatPinned(jid string, pinned bool) error {
+	return s.setChatState(jid, `is_pinned = excluded.is_pinned`, `is_pinned`, pinned)
+}
+
+// Set the given chat columns, inserting the chat named by its JID when new
+func (s *SQLiteStore) setChatState(jid, set, columns string, values ...interface{}) error {
+	_, err := s.exec(`INSERT INTO chats (jid, name, phone, is_channel, `+columns+`)
+		VALUES (?, ?, ?, ?`+strings.Repeat(", ?", len(values))+`)
+		ON CONFLICT (jid) DO UPDATE SET `+set,
+		append([]interface{}{jid, jid, nullString(phone.FromJID(jid)), IsChannel(jid)}, values...)...)
+	return err
+}
+
+// Whether a chat is muted at the given time
+func (s *SQLiteStore) ChatMuted(jid string, at time.Time) (bool, error) {
+	var muted bool
+	err := s.queryRow(`SELECT is_muted = 1 AND (muted_until IS NULL OR muted_until > ?) FROM chats WHERE jid = ?`,
+		at.UTC(), jid).Scan(&muted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return muted, err
+}
+
+// Server part of channel (newsletter) JIDs
+const channelSuffix = "@newsletter"
+
+// Server part of LID JIDs, WhatsApp's addresses that hide phone numbers
+const lidSuffix = "@lid"
+
+// Whether jid is a WhatsApp Channel rather than a person or group
+func IsChannel(jid string) bool {
+	return strings.HasSuffix(jid, channelSuffix)
+}
+
+// Store a message in the database. Storing one again, as history sync
+// re-delivers messages, updates it in place: empty values keep what is
+// stored, content stays once edited or when the new copy lost its media,
+// and columns filled in later, like downloaded media, are left alone.
+func (s *SQLiteStore) StoreMessage(id, chatJID, sender, content string, timestamp time.Time, isFromMe bool, mediaType, filename, url string) error {
+	query := `INSERT INTO messages
+		(id, chat_jid, sender, content, timestamp, is_from_me, media_type, filename, url, sender_phone)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id, chat_jid) DO UPDATE SET
+			sender = COALESCE(NULLIF(excluded.sender, ''), messages.sender),
+			content = CASE WHEN messages.edited_at IS NOT NULL OR COALESCE(excluded.content, '') = ''
+					OR (COALESCE(excluded.media_type, '') = '' AND COALESCE(messages.media_type, '') != '')
+				THEN messages.content ELSE excluded.content END,
+			timestamp = COALESCE(excluded.timestamp, messages.timestamp),
+			is_from_me = excluded.is_from_me,
+			media_type = COALESCE(NULLIF(excluded.media_type, ''), messages.media_type),
+			filename = COALESCE(NULLIF(excluded.filename, ''), messages.filename),
+			url = COALESCE(NULLIF(excluded.url, ''), messages.url),
+			sender_phone = COALESCE(excluded.sender_phone, messages.sender_phone)`
+
+	content, err := s.seal(content)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(query, id, chatJID, sender, content, timestamp, isFromMe, mediaType, filename, url,
+		nullString(s.jidPhone(sender)))
+	return err
+}
+
+// NULL for an empty string, for optional derived columns
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// Query the most recent messages in a chat and the chats linked to it
+func (s *SQLiteStore) QueryMessages(chatJID string, limit int) ([]Message, error) {
+	return s.ListMessages(MessageFilter{Chats: []string{chatJID}, Limit: limit})
+}
+
+// Query the most recent messages across a community's groups and the
+// chats linked to them
+func (s *SQLiteStore) QueryCommunity(jid string, limit int) ([]Message, error) {
+	jids, err := s.CommunityChats(jid)
+	if err != nil {
+		return nil, err
+	}
+	return s.listMessages(MessageFilter{Chats: jids, Limit: limit})
+}
+
+// Return ErrChatNotFound unless a chat row exists for jid
+func (s *SQLiteStore) requireChat(jid string) error {
+	var one int
+	err := s.queryRow(`SELECT 1 FROM chats WHERE jid = ?`, jid).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrChatNotFound, jid)
+	}
+	return err
+}
+
+// List all chats, most recently active first. Chats linked into another
+// one are listed under it rather than on their own.
+func (s *SQLiteStore) ListChats() ([]Chat, error) {
+	links, err := s.links()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(`SELECT c.jid, `+chatName+`, c.last_message_time, COALESCE(c.phone, ''), c.is_channel,
+			c.is_muted = 1 AND (c.muted_until IS NULL OR c.muted_until > ?), c.muted_until, c.is_archived, c.is_pinned,
+			COALESCE(g.community_jid, ''), COALESCE(g.is_announcement, 0)
+		FROM chats c LEFT JOIN groups g ON g.jid = c.jid ORDER BY c.last_message_time DESC`, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []Chat
+	for rows.Next() {
+		var c Chat
+		var last sql.NullTime
+		if err := rows.Scan(&c.JID, &c.Name, &last, &c.Phone, &c.Channel, &c.Muted, optionalTime{&c.MutedUntil},
+			&c.Archived, &c.Pinned, &c.Community, &c.Announcement); err != nil {
+			return nil, err
+		}
+		if !c.Muted {
+			// A mute that ran out
+			c.MutedUntil = nil
+		}
+		if _, linked := links[c.JID]; linked {
+			continue
+		}
+		c.LastMessageTime = last.Time
+		for jid, canonical := range links {
+			if canonical == c.JID {
+				c.LinkedJIDs = append(c.LinkedJIDs, jid)
+			}
+		}
+		sort.Strings(c.LinkedJIDs)
+		chats = append(chats, c)
+	}
+	return chats, rows.Err()
+}
+
+// Every chat link, linked JID to canonical JID, with the chats of LIDs
+// whose phone number has a chat of its own
+func (s *SQLiteStore) links() (map[string]string, error) {
+	rows, err := s.query(`SELECT jid, canonical_jid FROM chat_links
+		UNION ALL
+		SELECT lm.lid, COALESCE(cl.canonical_jid, lm.phone_jid) FROM lid_mappings lm
+			LEFT JOIN chat_links cl ON cl.jid = lm.phone_jid
+		WHERE lm.lid NOT IN (SELECT jid FROM chat_links)
+			AND COALESCE(cl.canonical_jid, lm.phone_jid) IN (SELECT jid FROM chats)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := map[string]string{}
+	for rows.Next() {
+		var jid, canonical string
+		if err := rows.Scan(&jid, &canonical); err != nil {
+			return nil, err
+		}
+		links[jid] = canonical
+	}
+	return links, rows.Err()
+}
+
+// Record that from continues as to. Chats already linked to from follow it.
+func (s *SQLiteStore) LinkChats(from, to string) error {
+	if from == to {
+		return fmt.Errorf("cannot link chat %s to itself", from)
+	}
+	canonical, err := s.canonical(to)
+	if err != nil {
+		return err
+	}
+	if canonical == from {
+		return fmt.Errorf("%s is already the chat %s continues", from, to)
+	}
+
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE chat_links SET canonical_jid = ? WHERE canonical_jid = ?`, canonical, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO chat_links (jid, canonical_jid, linked_at) VALUES (?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET canonical_jid = excluded.canonical_jid, linked_at = excluded.linked_at`,
+		from, canonical, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Move every message of chat from, whole, and every row about its messages
+// and its group into chat into, then delete from. Where into already holds
+// a message with the same ID, or a row with the same key, into's is kept.
+func (s *SQLiteStore) MergeChats(from, into string) error {
+	if from == into {
+		return nil
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	type step struct {
+		query string
+		args  []interface{}
+	}
+	steps := []step{
+		// Copy the chat row first so messages always satisfy the foreign key
+		{`INSERT OR IGNORE INTO chats (jid, name, last_message_time, phone, is_channel)
+			SELECT ?, name, last_message_time, ?, CAST(? AS INTEGER) FROM chats WHERE jid = ?`, []interface{}{into, nullString(phone.FromJID(into)), IsChannel(into), from}},
+		// A message in both chats keeps into's copy, and with it into's edit
+		// history; revisions have no key of their own to collide on
+		{`DELETE FROM message_revisions WHERE chat_jid = ?
+			AND message_id IN (SELECT id FROM messages WHERE chat_jid = ?)`, []interface{}{from, into}},
+	}
+	// Rows keyed by chat: move those whose key is free in into, and drop the
+	// duplicates left behind
+	for _, t := range []struct{ table, column string }{
+		{"messages", "chat_jid"},
+		{"bookmarks", "chat_jid"},
+		{"links", "chat_jid"},
+		{"shared_contacts", "chat_jid"},
+		{"message_raw", "chat_jid"},
+		{"group_invites", "chat_jid"},
+		{"polls", "chat_jid"},
+		{"poll_votes", "chat_jid"},
+		{"receipts", "chat_jid"},
+		{"reactions", "chat_jid"},
+		{"media_refs", "chat_jid"},
+		{"media_downloads", "chat_jid"},
+		{"group_participants", "group_jid"},
+		{"membership_changes", "group_jid"},
+		{"groups", "jid"},
+		{"avatars", "jid"},
+	} {
+		steps = append(steps,
+			step{`UPDATE OR IGNORE ` + t.table + ` SET ` + t.column + ` = ? WHERE ` + t.column + ` = ?`, []interface{}{into, from}},
+			step{`DELETE FROM ` + t.table + ` WHERE ` + t.column + ` = ?`, []interface{}{from}})
+	}
+	// Rows without a key of their own
+	for _, table := range []string{"message_revisions", "calls", "chat_events"} {
+		steps = append(steps, step{`UPDATE ` + table + ` SET chat_jid = ? WHERE chat_jid = ?`, []interface{}{into, from}})
+	}
+	steps = append(steps, []step{
+		{`UPDATE groups SET community_jid = ? WHERE community_jid = ?`, []interface{}{into, from}},
+		{`UPDATE chat_links SET canonical_jid = ? WHERE canonical_jid = ?`, []interface{}{into, from}},
+		{`DELETE FROM chat_links WHERE jid = ? OR jid = canonical_jid`, []interface{}{from}},
+		{`DELETE FROM chats WHERE jid = ?`, []interface{}{from}},
+	}...)
+	for _, st := range steps {
+		if _, err := tx.Exec(st.query, st.args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Forget jid's link, so it is a chat of its own again
+func (s *SQLiteStore) UnlinkChat(jid string) error {
+	_, err := s.exec(`DELETE FROM chat_links WHERE jid = ?`, jid)
+	return err
+}
+
+// The JID a chat is linked to, else the chat of a LID's phone number, or
+// jid itself
+func (s *SQLiteStore) canonical(jid string) (string, error) {
+	var canonical string
+	err := s.queryRow(`SELECT COALESCE(
+		(SELECT canonical_jid FROM chat_links WHERE jid = ?1),
+		(SELECT COALESCE(cl.canonical_jid, lm.phone_jid) FROM lid_mappings lm
+			LEFT JOIN chat_links cl ON cl.jid = lm.phone_jid WHERE lm.lid = ?1),
+		?1)`, jid).Scan(&canonical)
+	return canonical, err
+}
+
+// The canonical JID of jid's conversation followed by every JID linked to it
+func (s *SQLiteStore) ChatGroup(jid string) ([]string, error) {
+	canonical, err := s.canonical(jid)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.query(`SELECT jid FROM chat_links WHERE canonical_jid = ? ORDER BY jid`, canonical)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	group := []string{canonical}
+	for rows.Next() {
+		var linked string
+		if err := rows.Scan(&linked); err != nil {
+			return nil, err
+		}
+		group = append(group, linked)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The LIDs of every phone number in the conversation, unless linked
+	// elsewhere by hand
+	lids, err := s.query(`SELECT lid FROM lid_mappings WHERE phone_jid IN (`+placeholders(len(group))+`)
+		AND lid NOT IN (SELECT jid FROM chat_links) ORDER BY lid`, stringArgs(group)...)
+	if err != nil {
+		return nil, err
+	}
+	defer lids.Close()
+	for lids.Next() {
+		var lid string
+		if err := lids.Scan(&lid); err != nil {
+			return nil, err
+		}
+		if lid != canonical {
+			group = append(group, lid)
+		}
+	}
+	return group, lids.Err()
+}
+
+// SQL placeholders for an IN list of n values
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// Look up one message by key
+func (s *SQLiteStore) GetMessage(key MessageKey) (Message, error) {
+	rows, err := s.query(`SELECT `+messageColumns+`
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE m.id = ? AND m.chat_jid = ?`, key.ID, key.ChatJID)
+	if err != nil {
+		return Message{}, err
+	}
+	found, err := s.scanMessages(rows)
+	if err != nil {
+		return Message{}, err
+	}
+	if len(found) == 0 {
+		return Message{}, fmt.Errorf("%w: %s in %s", ErrMessageNotFound, key.ID, key.ChatJID)
+	}
+	return found[0], nil
+}
+
+// Look up a message and the message it replies to; parent is nil when it
+// replies to none or the quoted message was never stored
+func (s *SQLiteStore) GetMessageWithParent(key MessageKey) (m Message, parent *Message, err error) {
+	if m, err = s.GetMessage(key); err != nil || m.ReplyTo == "" {
+		return m, nil, err
+	}
+	p, err := s.GetMessage(MessageKey{ID: m.ReplyTo, ChatJID: m.ChatJID})
+	if errors.Is(err, ErrMessageNotFound) {
+		return m, nil, nil
+	}
+	if err != nil {
+		return m, nil, err
+	}
+	return m, &p, nil
+}
+
+// Collect the reply chain around a message in its chat and the chats linked
+// to it: the messages it quotes, back to one that quotes nothing stored,
+// and every reply below it. Each lookup is by reply link, so a long chain
+// costs a query per message above and a query per level below.
+func (s *SQLiteStore) Thread(key MessageKey) (Thread, error) {
+	m, err := s.GetMessage(key)
+	if err != nil {
+		return Thread{}, err
+	}
+	group, err := s.ChatGroup(m.ChatJID)
+	if err != nil {
+		return Thread{}, err
+	}
+	t := Thread{Message: m, Ancestors: []Message{}, Descendants: []Message{}}
+	// Seen IDs keep corrupt links from looping
+	seen := map[string]bool{m.ID: true}
+
+	for id := m.ReplyTo; id != "" && !seen[id]; {
+		parents, err := s.threadMessages(group, `m.id = ?`, []string{id})
+		if err != nil {
+			return Thread{}, err
+		}
+		if len(parents) == 0 {
+			t.RootMissing = true
+			break
+		}
+		parent := parents[0]
+		seen[parent.ID] = true
+		t.Ancestors = append([]Message{parent}, t.Ancestors...)
+		id = parent.ReplyTo
+	}
+
+	for level := []string{m.ID}; len(level) > 0; {
+		replies, err := s.threadMessages(group, `m.reply_to IN (`+placeholders(len(level))+`)`, level)
+		if err != nil {
+			return Thread{}, err
+		}
+		level = level[:0]
+		for _, r := range replies {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				t.Descendants = append(t.Descendants, r)
+				level = append(level, r.ID)
+			}
+		}
+	}
+	sort.SliceStable(t.Descendants, func(i, j int) bool {
+		return t.Descendants[i].Timestamp.Before(t.Descendants[j].Timestamp)
+	})
+	return t, nil
+}
+
+// Messages in the chats of group matching a condition on values, oldest first
+func (s *SQLiteStore) threadMessages(group []string, condition string, values []string) ([]Message, error) {
+	rows, err := s.query(`SELECT `+messageColumns+`
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE m.chat_jid IN (`+placeholders(len(group))+`) AND `+condition+`
+		ORDER BY m.timestamp`, append(stringArgs(group), stringArgs(values)...)...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanMessages(rows)
+}
+
+// Find messages whose content or text read from their image contains query (case-insensitive for ASCII), newest first
+func (s *SQLiteStore) SearchMessages(query string, limit int) ([]Message, error) {
+	return s.searchMessages(query, MessageFilter{Limit: limit})
+}
+
+// Substring search among the messages f selects, newest first
+func (s *SQLiteStore) searchMessages(query string, f MessageFilter) ([]Message, error) {
+	if s.aead != nil {
+		return s.searchSealed(query, f)
+	}
+	conditions, args, err := s.messageConditions(f)
+	if err != nil {
+		return nil, err
+	}
+	pattern := "%" + escapeLike(query) + "%"
+	q := `SELECT ` + messageColumns + `
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE (m.content LIKE ? ESCAPE '\' OR m.ocr_text LIKE ? ESCAPE '\')` + conditions + `
+		ORDER BY m.timestamp DESC`
+	args = append([]interface{}{pattern, pattern}, args...)
+	if f.Limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+	rows, err := s.query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanMessages(rows)
+}
+
+// List the messages f selects, newest first. Selecting a chat the archive
+// doesn't have is an error.
+func (s *SQLiteStore) ListMessages(f MessageFilter) ([]Message, error) {
+	messages, err := s.listMessages(f)
+	if err != nil {
+		return nil, err
+	}
+	// An empty result is only an error when a chat itself is unknown
+	if len(messages) == 0 {
+		for _, jid := range f.Chats {
+			if err := s.requireChat(jid); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return messages, nil
+}
+
+// List the messages f selects, newest first
+func (s *SQLiteStore) listMessages(f MessageFilter) ([]Message, error) {
+	conditions, args, err := s.messageConditions(f)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + messageColumns + `
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE 1 = 1` + conditions + `
+		ORDER BY m.timestamp DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanMessages(rows)
+}
+
+// SQL conditions on messages m, each starting with AND, selecting what f
+// does apart from its limit
+func (s *SQLiteStore) messageConditions(f MessageFilter) (string, []interface{}, error) {
+	query := ""
+	var args []interface{}
+	if len(f.Chats) > 0 {
+		var chats []string
+		for _, jid := range f.Chats {
+			group, err := s.ChatGroup(jid)
+			if err != nil {
+				return "", nil, err
+			}
+			chats = append(chats, group...)
+		}
+		query += ` AND m.chat_jid IN (` + placeholders(len(chats)) + `)`
+		args = append(args, stringArgs(chats)...)
+	}
+	if len(f.Senders) > 0 {
+		var phones []interface{}
+		for _, sender := range f.Senders {
+			phones = append(phones, nullString(s.jidPhone(sender)))
+		}
+		query += ` AND (m.sender IN (` + placeholders(len(f.Senders)) + `) OR m.sender_phone IN (` + placeholders(len(phones)) + `))`
+		args = append(append(args, stringArgs(f.Senders)...), phones...)
+	}
+	if !f.Since.IsZero() {
+		query += ` AND m.timestamp >= ?`
+		args = append(args, f.Since)
+	}
+	if !f.Until.IsZero() {
+		query += ` AND m.timestamp < ?`
+		args = append(args, f.Until)
+	}
+	if len(f.MediaTypes) > 0 {
+		query += ` AND m.media_type IN (` + placeholders(len(f.MediaTypes)) + `)`
+		args = append(args, stringArgs(f.MediaTypes)...)
+	}
+	if f.MediaOnly {
+		query += ` AND COALESCE(m.media_type, '') <> ''`
+	}
+	if f.FromMe != nil {
+		query += ` AND COALESCE(m.is_from_me, 0) = ?`
+		args = append(args, *f.FromMe)
+	}
+	return query, args, nil
+}
+
+// Walk messages in a time range, oldest first, without loading them all at once
+func (s *SQLiteStore) ForEachMessage(since, until time.Time, fn func(Message) error) error {
+	query := `SELECT ` + messageColumns + `
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE 1 = 1`
+	var args []interface{}
+	if !since.IsZero() {
+		query += ` AND m.timestamp >= ?`
+		args = append(args, since)
+	}
+	if !until.IsZero() {
+		query += ` AND m.timestamp < ?`
+		args = append(args, until)
+	}
+	query += ` ORDER BY m.timestamp`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := s.scanMessage(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// The name of chat c: a synced group's subject, else its own unless that
+// is only its JID, else the contact's name for a direct chat
+const chatName = `COALESCE((SELECT NULLIF(subject, '') FROM groups WHERE jid = c.jid),
+	NULLIF(NULLIF(c.name, ''), c.jid), (SELECT name FROM contact_display WHERE jid = c.jid), c.name, '')`
+
+// Columns read by scanMessages, from messages m joined to chats c
+const messageColumns = `m.id, m.chat_jid, ` + chatName + `, COALESCE(m.sender, ''), COALESCE(m.content, ''),
+	m.timestamp, COALESCE(m.is_from_me, 0), COALESCE(m.media_type, ''), COALESCE(m.filename, ''), COALESCE(m.reply_to, ''),
+	COALESCE(m.sender_phone, ''), COALESCE(m.mime_type, ''), COALESCE(m.local_path, ''),
+	COALESCE(m.object_url, ''), COALESCE(m.ocr_text, ''), m.edited_at, m.deleted_at, COALESCE(m.revoked_by, ''),
+	COALESCE(m.reply_to_sender, ''),
+	CASE WHEN m.latitude IS NOT NULL THEN json_object('latitude', m.latitude, 'longitude', m.longitude,
+		'accuracy', COALESCE(m.location_accuracy, 0), 'name', COALESCE(m.location_name, ''),
+		'address', COALESCE(m.location_address, ''), 'live', json(CASE WHEN m.location_live = 1 THEN 'true' ELSE 'false' END),
+		'live_duration', COALESCE(m.live_duration, 0)) END,
+	(SELECT json_object('pack_id', COALESCE(st.pack_id, ''), 'pack_name', COALESCE(st.pack_name, ''),
+		'publisher', COALESCE(st.publisher, ''), 'emojis', json(st.emojis))
+		FROM media_refs r JOIN stickers st ON st.sha256 = r.sha256
+		WHERE r.message_id = m.id AND r.chat_jid = m.chat_jid),
+	m.is_view_once,
+	CASE WHEN m.system_kind IS NOT NULL THEN json_object('kind', m.system_kind,
+		'data', json(COALESCE(m.system_data, '{}'))) END,
+	m.is_forwarded, m.forwarding_score, m.interactive,
+	COALESCE((SELECT name FROM contact_display WHERE jid = m.sender), '')`
+
+// Scan and close rows selected with messageColumns
+func (s *SQLiteStore) scanMessages(rows *sql.Rows) ([]Message, error) {
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		m, err := s.scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// Scan the current row selected with messageColumns
+func (s *SQLiteStore) scanMessage(rows *sql.Rows) (Message, error) {
+	var m Message
+	var ts sql.NullTime
+	err := rows.Scan(s.messageDest(&m, &ts)...)
+	m.Timestamp = ts.Time
+	return m, err
+}
+
+// Scan destinations for messageColumns, for queries selecting more after them
+func (s *SQLiteStore) messageDest(m *Message, ts *sql.NullTime) []interface{} {
+	return []interface{}{&m.ID, &m.ChatJID, &m.ChatName, &m.Sender, sealedDest{s, &m.Content}, ts, &m.IsFromMe, &m.MediaType, &m.Filename,
+		&m.ReplyTo, &m.SenderPhone, &m.MimeType, &m.LocalPath, &m.ObjectURL, sealedDest{s, &m.OCRText}, optionalTime{&m.EditedAt},
+		optionalTime{&m.DeletedAt}, &m.RevokedBy, &m.ReplyToSender, jsonDest[Location]{&m.Location},
+		jsonDest[Sticker]{&m.Sticker}, &m.IsViewOnce, jsonDest[SystemEvent]{&m.System},
+		&m.IsForwarded, &m.ForwardingScore, rawJSON{&m.Interactive}, &m.SenderName}
+}
+
+// Scans a JSON object column into a pointer left nil for NULL
+type jsonDest[T any] struct {
+	v **T
+}
+
+func (d jsonDest[T]) Scan(v interface{}) error {
+	*d.v = nil
+	var data []byte
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unexpected JSON column value %T", v)
+	}
+	var t T
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	*d.v = &t
+	return nil
+}
+
+// Scans a nullable JSON column as is, leaving NULL empty
+type rawJSON struct {
+	v *json.RawMessage
+}
+
+func (r rawJSON) Scan(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		*r.v = nil
+	case string:
+		*r.v = json.RawMessage(v)
+	case []byte:
+		*r.v = append(json.RawMessage(nil), v...)
+	default:
+		return fmt.Errorf("unexpected JSON column value %T", v)
+	}
+	return nil
+}
+
+// Scans a nullable timestamp into a pointer left nil for NULL
+type optionalTime struct {
+	t **time.Time
+}
+
+func (o optionalTime) Scan(v interface{}) error {
+	var n sql.NullTime
+	if err := n.Scan(v); err != nil {
+		return err
+	}
+	*o.t = nil
+	if n.Valid {
+		*o.t = &n.Time
+	}
+	return nil
+}
+
+// Escape LIKE wildcards so user input matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Delete messages by key in one transaction
+func (s *SQLiteStore) DeleteMessages(keys []MessageKey) (int, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`DELETE FROM messages WHERE id = ? AND chat_jid = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	raw, err := tx.Prepare(`DELETE FROM message_raw WHERE message_id = ? AND chat_jid = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer raw.Close()
+
+	deleted := 0
+	for _, k := range keys {
+		res, err := stmt.Exec(k.ID, k.ChatJID)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		deleted += int(n)
+		if _, err := raw.Exec(k.ID, k.ChatJID); err != nil {
+			return 0, err
+		}
+	}
+	return deleted, tx.Commit()
+}
+
+// Messages older than a cutoff, sparing bookmarked ones
+const messagesBefore = `FROM messages WHERE chat_jid = ? AND timestamp < ?
+	AND NOT EXISTS (SELECT 1 FROM bookmarks b WHERE b.message_id = messages.id AND b.chat_jid = messages.chat_jid)`
+
+func (s *SQLiteStore) CountMessagesBefore(chatJID string, before time.Time) (int, error) {
+	var n int
+	err := s.queryRow(`SELECT COUNT(*) `+messagesBefore, chatJID, before.UTC()).Scan(&n)
+	return n, err
+}
+
+func (s *SQLiteStore) DeleteMessagesBefore(chatJID string, before time.Time) (int, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE `+messagesBefore, chatJID, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if _, err := tx.Exec(`DELETE FROM message_raw WHERE chat_jid = ? AND NOT EXISTS
+		(SELECT 1 FROM messages m WHERE m.id = message_raw.message_id AND m.chat_jid = message_raw.chat_jid)`, chatJID); err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// Write a consistent copy of the database to path, which must not exist.
+// The copy is taken with SQLite's online backup API in a single read
+// transaction, so writers carry on meanwhile, then vacuumed so it holds no
+// deleted rows and needs no WAL beside it.
+func (s *SQLiteStore) Snapshot(path string) error {
+	if s.dialect == dialectPostgres {
+		return fmt.Errorf("snapshot: %w; back up with pg_dump instead", ErrNotSQLite)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("snapshot: %s already exists", path)
+	}
+	if s.closed.Load() {
+		return ErrStoreClosed
+	}
+	dst, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	ctx := context.Background()
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+	srcConn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	err = dstConn.Raw(func(d interface{}) error {
+		return srcConn.Raw(func(src interface{}) error {
+			b, err := d.(*sqlite3.SQLiteConn).Backup("main", src.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	for _, stmt := range []string{`PRAGMA journal_mode = DELETE`, `VACUUM`} {
+		if _, err := dstConn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run SQLite's integrity check, returning its first complaint as an error
+func (s *SQLiteStore) IntegrityCheck() error {
+	if s.dialect == dialectPostgres {
+		return fmt.Errorf("integrity check: %w", ErrNotSQLite)
+	}
+	var result string
+	if err := s.queryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return errors.New(result)
+	}
+	return nil
+}
+
+// Bookmark a message, keeping its original bookmark time when updating the note
+func (s *SQLiteStore) SetBookmark(key MessageKey, note string) error {
+	var one int
+	err := s.queryRow(`SELECT 1 FROM messages WHERE id = ? AND chat_jid = ?`, key.ID, key.ChatJID).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s in %s", ErrMessageNotFound, key.ID, key.ChatJID)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(`INSERT INTO bookmarks (message_id, chat_jid, note, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (message_id, chat_jid) DO UPDATE SET note = excluded.note`,
+		key.ID, key.ChatJID, note, time.Now())
+	return err
+}
+
+// Remove a bookmark
+func (s *SQLiteStore) RemoveBookmark(key MessageKey) error {
+	_, err := s.exec(`DELETE FROM bookmarks WHERE message_id = ? AND chat_jid = ?`, key.ID, key.ChatJID)
+	return err
+}
+
+// List bookmarked messages that are still stored
+func (s *SQLiteStore) ListBookmarks(chatJID string) ([]Bookmark, error) {
+	query := `SELECT ` + messageColumns + `, COALESCE(b.note, ''), b.created_at
+		FROM bookmarks b
+		JOIN messages m ON m.id = b.message_id AND m.chat_jid = b.chat_jid
+		LEFT JOIN chats c ON c.jid = m.chat_jid`
+	var args []interface{}
+	if chatJID != "" {
+		group, err := s.ChatGroup(chatJID)
+		if err != nil {
+			return nil, err
+		}
+		query += ` WHERE b.chat_jid IN (` + placeholders(len(group)) + `)`
+		args = stringArgs(group)
+	}
+	query += ` ORDER BY b.created_at DESC`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookmarks []Bookmark
+	for rows.Next() {
+		var b Bookmark
+		var ts, created sql.NullTime
+		err := rows.Scan(append(s.messageDest(&b.Message, &ts), &b.Note, &created)...)
+		if err != nil {
+			return nil, err
+		}
+		b.Timestamp, b.BookmarkedAt = ts.Time, created.Time
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
+
+// Record which message a stored message replies to, and who sent that one
+func (s *SQLiteStore) StoreReply(key MessageKey, replyTo, replySender string) error {
+	_, err := s.exec(`UPDATE messages SET reply_to = ?, reply_to_sender = ? WHERE id = ? AND chat_jid = ?`,
+		replyTo, nullString(replySender), key.ID, key.ChatJID)
+	return err
+}
+
+// Keep the JSON payload of a stored interactive message
+func (s *SQLiteStore) StoreInteractive(key MessageKey, payload []byte) error {
+	_, err := s.exec(`UPDATE messages SET interactive = ? WHERE id = ? AND chat_jid = ?`,
+		nullString(string(payload)), key.ID, key.ChatJID)
+	return err
+}
+
+// Mark a stored message forwarded
+func (s *SQLiteStore) StoreForwarded(key MessageKey, score int) error {
+	_, err := s.exec(`UPDATE messages SET is_forwarded = 1, forwarding_score = ? WHERE id = ? AND chat_jid = ?`,
+		score, key.ID, key.ChatJID)
+	return err
+}
+
+// Mark a stored message as mentioning me
+func (s *SQLiteStore) StoreMentionsMe(key MessageKey) error {
+	_, err := s.exec(`UPDATE messages SET mentions_me = 1 WHERE id = ? AND chat_jid = ?`, key.ID, key.ChatJID)
+	return err
+}
+
+// Fill in the location columns of a stored message
+func (s *SQLiteStore) StoreLocation(key MessageKey, l Location) error {
+	_, err := s.exec(`UPDATE messages SET latitude = ?, longitude = ?, location_accuracy = ?, location_name = ?,
+		location_address = ?, location_live = ?, live_duration = ? WHERE id = ? AND chat_jid = ?`,
+		l.Latitude, l.Longitude, l.Accuracy, nullString(l.Name), nullString(l.Address), l.Live, l.LiveDuration,
+		key.ID, key.ChatJID)
+	return err
+}
+
+// List location messages in a chat and the chats linked to it, newest
+// first; an empty chatJID means all chats
+func (s *SQLiteStore) ListLocations(chatJID string) ([]Message, error) {
+	query := `SELECT ` + messageColumns + `
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE m.latitude IS NOT NULL`
+	var args []interface{}
+	if chatJID != "" {
+		group, err := s.ChatGroup(chatJID)
+		if err != nil {
+			return nil, err
+		}
+		query += ` AND m.chat_jid IN (` + placeholders(len(group)) + `)`
+		args = stringArgs(group)
+	}
+	rows, err := s.query(query+` ORDER BY m.timestamp DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanMessages(rows)
+}
+
+// Messages received in a chat since I last sent one there, newest first.
+// Only chatJID itself is read, since receipts go to the JID a message came in.
+func (s *SQLiteStore) UnreadMessages(chatJID string, limit int) ([]Message, error) {
+	rows, err := s.query(`SELECT `+messageColumns+`
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE m.chat_jid = ? AND m.is_from_me = 0 AND m.system_kind IS NULL
+			AND NOT EXISTS (SELECT 1 FROM messages mine
+				WHERE mine.chat_jid = m.chat_jid AND mine.is_from_me = 1 AND mine.timestamp >= m.timestamp)
+		ORDER BY m.timestamp DESC LIMIT ?`, chatJID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanMessages(rows)
+}
+
+// Conversations waiting on me: messages sent to me since since, in a direct
+// chat, mentioning me or replying to one of mine in a group, that I haven't
+// written anything in their chat after. Chats whose oldest such message is
+// from before are listed, that one waiting longest first. Linked chats count
+// as one, and the status feed and channels are left out.
+func (s *SQLiteStore) Unreplied(since, before time.Time) ([]UnrepliedChat, error) {
+	links, err := s.links()
+	if err != nil {
+		return nil, err
+	}
+	canonical := func(jid string) string {
+		if c, ok := links[jid]; ok {
+			return c
+		}
+		return jid
+	}
+
+	// When I last wrote in each conversation
+	rows, err := s.query(`SELECT chat_jid, timestamp FROM messages WHERE is_from_me = 1 AND timestamp >= ?`, since)
+	if err != nil {
+		return nil, err
+	}
+	lastSent := map[string]time.Time{}
+	for rows.Next() {
+		var jid string
+		var at sql.NullTime
+		if err := rows.Scan(&jid, &at); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if c := canonical(jid); at.Time.After(lastSent[c]) {
+			lastSent[c] = at.Time
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.query(`SELECT `+messageColumns+`, m.mentions_me, COALESCE(q.is_from_me, 0)
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+			LEFT JOIN messages q ON q.id = m.reply_to AND q.chat_jid = m.chat_jid
+		WHERE m.is_from_me = 0 AND m.system_kind IS NULL AND m.timestamp >= ?
+			AND COALESCE(c.is_channel, 0) = 0 AND m.chat_jid NOT LIKE ?
+			AND (m.chat_jid NOT LIKE ? OR m.mentions_me = 1 OR q.is_from_me = 1)
+		ORDER BY m.timestamp`, since, "%@broadcast", "%@g.us")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byChat := map[string]*UnrepliedChat{}
+	var chats []*UnrepliedChat
+	for rows.Next() {
+		var m Message
+		var ts sql.NullTime
+		var mentionsMe, repliesToMe bool
+		if err := rows.Scan(append(s.messageDest(&m, &ts), &mentionsMe, &repliesToMe)...); err != nil {
+			return nil, err
+		}
+		m.Timestamp = ts.Time
+		jid := canonical(m.ChatJID)
+		if !m.Timestamp.After(lastSent[jid]) {
+			continue
+		}
+		u := byChat[jid]
+		if u == nil {
+			u = &UnrepliedChat{ChatJID: jid, ChatName: m.ChatName, First: m, Reason: AwaitingDirect}
+			switch {
+			case mentionsMe:
+				u.Reason = AwaitingMention
+			case repliesToMe:
+				u.Reason = AwaitingReply
+			}
+			byChat[jid] = u
+			chats = append(chats, u)
+		}
+		if m.ChatJID == jid {
+			u.ChatName = m.ChatName
+		}
+		u.Count++
+		u.Latest = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	waiting := []UnrepliedChat{}
+	for _, u := range chats {
+		if u.First.Timestamp.Before(before) {
+			waiting = append(waiting, *u)
+		}
+	}
+	return waiting, nil
+}
+
+// Fill in the attachment columns of a stored message. Empty fields keep
+// what is stored, so a copy with less metadata doesn't erase it.
+func (s *SQLiteStore) StoreMedia(key MessageKey, m Media) error {
+	_, err := s.exec(`UPDATE messages SET url = COALESCE(NULLIF(?, ''), url), direct_path = COALESCE(?, direct_path),
+		media_key = COALESCE(?, media_key), file_sha256 = COALESCE(?, file_sha256),
+		file_enc_sha256 = COALESCE(?, file_enc_sha256), file_length = COALESCE(NULLIF(?, 0), file_length),
+		mime_type = COALESCE(?, mime_type), is_view_once = CASE WHEN ? = 1 THEN 1 ELSE is_view_once END
+		WHERE id = ? AND chat_jid = ?`,
+		m.URL, nullString(m.DirectPath), nullBytes(m.MediaKey), nullBytes(m.FileSHA256), nullBytes(m.FileEncSHA256),
+		m.FileLength, nullString(m.MimeType), m.ViewOnce, key.ID, key.ChatJID)
+	return err
+}
+
+// NULL for empty bytes, so COALESCE keeps the stored value
+func nullBytes(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+// Point a message at the stored file holding its attachment, recording the
+// file on first use
+func (s *SQLiteStore) SetMediaFile(key MessageKey, b MediaBlob) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	localPath, objectURL := b.Location, ""
+	if strings.HasPrefix(b.Location, "https://") || strings.HasPrefix(b.Location, "http://") {
+		localPath, objectURL = "", b.Location
+	}
+	res, err := tx.Exec(`UPDATE messages SET local_path = ?, object_url = ?, file_length = ? WHERE id = ? AND chat_jid = ?`,
+		nullString(localPath), nullString(objectURL), b.Size, key.ID, key.ChatJID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s in %s", ErrMessageNotFound, key.ID, key.ChatJID)
+	}
+	_, err = tx.Exec(`INSERT INTO media_blobs (sha256, path, size, mime_type, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (sha256) DO UPDATE SET path = excluded.path, size = excluded.size, evicted_at = NULL`,
+		b.SHA256, b.Location, b.Size, nullString(b.MimeType), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO media_refs (message_id, chat_jid, sha256) VALUES (?, ?, ?)
+		ON CONFLICT (message_id, chat_jid) DO UPDATE SET sha256 = excluded.sha256`,
+		key.ID, key.ChatJID, b.SHA256)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM media_downloads WHERE message_id = ? AND chat_jid = ?`, key.ID, key.ChatJID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Record the pack metadata of the sticker file with this hex SHA-256
+func (s *SQLiteStore) StoreSticker(sha256 string, st Sticker) error {
+	emojis := st.Emojis
+	if emojis == nil {
+		emojis = []string{}
+	}
+	encoded, err := json.Marshal(emojis)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(`INSERT INTO stickers (sha256, pack_id, pack_name, publisher, emojis) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (sha256) DO UPDATE SET pack_id = COALESCE(excluded.pack_id, stickers.pack_id),
+			pack_name = COALESCE(excluded.pack_name, stickers.pack_name),
+			publisher = COALESCE(excluded.publisher, stickers.publisher),
+			emojis = CASE WHEN excluded.emojis = '[]' THEN stickers.emojis ELSE excluded.emojis END`,
+		sha256, nullString(st.PackID), nullString(st.PackName), nullString(st.Publisher), string(encoded))
+	return err
+}
+
+// Look up a stored file by the hex SHA-256 of its content
+func (s *SQLiteStore) FindMediaBlob(sha256 string) (MediaBlob, bool, error) {
+	b := MediaBlob{SHA256: sha256}
+	err := s.queryRow(`SELECT path, COALESCE(size, 0), COALESCE(mime_type, ''),
+			(SELECT COUNT(*) FROM media_refs r WHERE r.sha256 = b.sha256)
+		FROM media_blobs b WHERE sha256 = ?`, sha256).Scan(&b.Location, &b.Size, &b.MimeType, &b.Refs)
+	if errors.Is(err, sql.ErrNoRows) {
+		return MediaBlob{}, false, nil
+	}
+	return b, err == nil, err
+}
+
+// Record the text read from a message's image; "" marks an image without text
+func (s *SQLiteStore) StoreOCRText(key MessageKey, text string) error {
+	sealed, err := s.seal(text)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(`UPDATE messages SET ocr_text = ? WHERE id = ? AND chat_jid = ?`, sealed, key.ID, key.ChatJID)
+	return err
+}
+
+// Downloaded images OCR has not run on yet, newest first
+func (s *SQLiteStore) PendingOCR(chatJID string) ([]Message, error) {
+	query := `SELECT ` + messageColumns + `
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE m.media_type = 'image' AND m.ocr_text IS NULL
+			AND (COALESCE(m.local_path, '') != '' OR COALESCE(m.object_url, '') != '')`
+	var args []interface{}
+	if chatJID != "" {
+		group, err := s.ChatGroup(chatJID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.requireChat(chatJID); err != nil {
+			return nil, err
+		}
+		query += ` AND m.chat_jid IN (` + placeholders(len(group)) + `)`
+		args = append(args, stringArgs(group)...)
+	}
+	rows, err := s.query(query+` ORDER BY m.timestamp DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanMessages(rows)
+}
+
+// List stored files not yet evicted, least recently used first, where a
+// file's last use is its newest referencing message
+func (s *SQLiteStore) MediaBlobs() ([]MediaBlob, error) {
+	rows, err := s.query(`SELECT b.sha256, b.path, COALESCE(b.size, 0), COALESCE(b.mime_type, ''),
+			COUNT(r.sha256), MAX(m.timestamp)
+		FROM media_blobs b
+		LEFT JOIN media_refs r ON r.sha256 = b.sha256
+		LEFT JOIN messages m ON m.id = r.message_id AND m.chat_jid = r.chat_jid
+		WHERE b.evicted_at IS NULL
+		GROUP BY b.sha256
+		ORDER BY MAX(m.timestamp), b.created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blobs []MediaBlob
+	for rows.Next() {
+		var b MediaBlob
+		var lastUsed sql.NullString
+		if err := rows.Scan(&b.SHA256, &b.Location, &b.Size, &b.MimeType, &b.Refs, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			// MAX() loses the column type, so the driver hands back text
+			if b.LastUsed, err = parseTime(lastUsed.String); err != nil {
+				return nil, err
+			}
+		}
+		blobs = append(blobs, b)
+	}
+	return blobs, rows.Err()
+}
+
+// Parse a timestamp that reached Go as text, as aggregates like MAX() do:
+// Unix milliseconds on SQLite, formatted on PostgreSQL
+func parseTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	s = strings.TrimSuffix(s, "Z")
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
+
+// Mark a stored file deleted, detaching it from its messages. The blob row
+// and references remain, so backfills do not fetch the file again.
+func (s *SQLiteStore) EvictMediaBlob(sha256 string) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE media_blobs SET evicted_at = ? WHERE sha256 = ?`, time.Now().UTC(), sha256); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE messages SET local_path = NULL, object_url = NULL
+		WHERE EXISTS (SELECT 1 FROM media_refs r
+			WHERE r.sha256 = ? AND r.message_id = messages.id AND r.chat_jid = messages.chat_jid)`, sha256)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Count a failed download of a message's attachment
+func (s *SQLiteStore) RecordMediaFailure(key MessageKey, reason string) error {
+	now := time.Now().UTC()
+	_, err := s.exec(`INSERT INTO media_downloads (message_id, chat_jid, attempts, last_error, last_attempt) VALUES (?, ?, 1, ?, ?)
+		ON CONFLICT (message_id, chat_jid) DO UPDATE SET
+			attempts = media_downloads.attempts + 1, last_error = excluded.last_error, last_attempt = excluded.last_attempt`,
+		key.ID, key.ChatJID, reason, now)
+	return err
+}
+
+// List media messages with download metadata but no local file, newest
+// first, skipping those that failed maxAttempts times (none when 0)
+func (s *SQLiteStore) PendingMedia(chatJID string, maxAttempts int) ([]PendingMedia, error) {
+	query := `SELECT ` + messageColumns + `, COALESCE(m.url, ''), COALESCE(m.direct_path, ''), m.media_key,
+			m.file_sha256, m.file_enc_sha256, COALESCE(m.file_length, 0), COALESCE(d.attempts, 0), COALESCE(d.last_error, '')
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		LEFT JOIN media_downloads d ON d.message_id = m.id AND d.chat_jid = m.chat_jid
+		WHERE COALESCE(m.media_type, '') != '' AND m.media_key IS NOT NULL
+			AND COALESCE(m.local_path, '') = '' AND COALESCE(m.object_url, '') = ''
+			AND NOT EXISTS (SELECT 1 FROM media_refs r JOIN media_blobs b ON b.sha256 = r.sha256
+				WHERE r.message_id = m.id AND r.chat_jid = m.chat_jid AND b.evicted_at IS NOT NULL)`
+	var args []interface{}
+	if maxAttempts > 0 {
+		query += ` AND COALESCE(d.attempts, 0) < ?`
+		args = append(args, maxAttempts)
+	}
+	if chatJID != "" {
+		group, err := s.ChatGroup(chatJID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.requireChat(chatJID); err != nil {
+			return nil, err
+		}
+		query += ` AND m.chat_jid IN (` + placeholders(len(group)) + `)`
+		args = append(args, stringArgs(group)...)
+	}
+	query += ` ORDER BY m.timestamp DESC`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []PendingMedia
+	for rows.Next() {
+		var p PendingMedia
+		var ts sql.NullTime
+		err := rows.Scan(append(s.messageDest(&p.Message, &ts),
+			&p.Media.URL, &p.Media.DirectPath, &p.Media.MediaKey, &p.Media.FileSHA256, &p.Media.FileEncSHA256, &p.Media.FileLength,
+			&p.Attempts, &p.LastError)...)
+		if err != nil {
+			return nil, err
+		}
+		p.Timestamp = ts.Time
+		p.Media.MimeType = p.MimeType
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// List media messages, optionally of one type and in one chat's group
+func (s *SQLiteStore) ListMedia(chatJID, mediaType string) ([]MediaFile, error) {
+	query := `SELECT ` + messageColumns + `, COALESCE(m.file_length, 0)
+		FROM messages m LEFT JOIN chats c ON c.jid = m.chat_jid
+		WHERE COALESCE(m.media_type, '') != ''`
+	var args []interface{}
+	if mediaType != "" {
+		query += ` AND m.media_type = ?`
+		args = append(args, mediaType)
+	}
+	if chatJID != "" {
+		group, err := s.ChatGroup(chatJID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.requireChat(chatJID); err != nil {
+			return nil, err
+		}
+		query += ` AND m.chat_jid IN (` + placeholders(len(group)) + `)`
+		args = append(args, stringArgs(group)...)
+	}
+	query += ` ORDER BY m.timestamp DESC`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []MediaFile
+	for rows.Next() {
+		var f MediaFile
+		var ts sql.NullTime
+		err := rows.Scan(append(s.messageDest(&f.Message, &ts), &f.Size)...)
+		if err != nil {
+			return nil, err
+		}
+		f.Timestamp = ts.Time
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// Record a sighting of a contact's name, widening its seen range
+func (s *SQLiteStore) StoreContactName(jid, name string, seen time.Time) error {
+	_, err := s.exec(`INSERT INTO contact_names (jid, name, first_seen, last_seen) VALUES (?, ?, ?, ?)
+		ON CONFLICT (jid, name) DO UPDATE SET
+			first_seen = CASE WHEN excluded.first_seen < contact_names.first_seen
+				THEN excluded.first_seen ELSE contact_names.first_seen END,
+			last_seen = CASE WHEN excluded.last_seen > contact_names.last_seen
+				THEN excluded.last_seen ELSE contact_names.last_seen END`,
+		jid, name, seen.UTC(), seen.UTC())
+	return err
+}
+
+// Log a presence update unless the contact's last logged state matches
+func (s *SQLiteStore) LogPresence(p PresenceChange) (bool, error) {
+	var lastSeen sql.NullTime
+	if p.LastSeen != nil {
+		lastSeen = sql.NullTime{Time: p.LastSeen.UTC(), Valid: true}
+	}
+	res, err := s.exec(`INSERT INTO presence_log (jid, online, at, last_seen)
+		SELECT ?1, ?2, ?3, ?4
+		WHERE COALESCE((SELECT online FROM presence_log WHERE jid = ?1 ORDER BY at DESC, id DESC LIMIT 1), -1) != ?2`,
+		p.JID, p.Online, p.At.UTC(), lastSeen)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// List a contact's presence changes since a time, oldest first
+func (s *SQLiteStore) PresenceLog(jid string, since time.Time) ([]PresenceChange, error) {
+	rows, err := s.query(`SELECT jid, online, at, last_seen FROM presence_log WHERE jid = ? AND at >= ? ORDER BY at, id`,
+		jid, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []PresenceChange
+	for rows.Next() {
+		var p PresenceChange
+		if err := rows.Scan(&p.JID, &p.Online, &p.At, optionalTime{&p.LastSeen}); err != nil {
+			return nil, err
+		}
+		changes = append(changes, p)
+	}
+	return changes, rows.Err()
+}
+
+// Record or replace a saved profile picture
+func (s *SQLiteStore) StoreAvatar(a Avatar) error {
+	_, err := s.exec(`INSERT OR REPLACE INTO avatars (jid, picture_id, path, changed_at, checked_at) VALUES (?, ?, ?, ?, ?)`,
+		a.JID, nullString(a.PictureID), nullString(a.Path), a.ChangedAt.UTC(), a.CheckedAt.UTC())
+	return err
+}
+
+// Get the profile picture saved for jid
+func (s *SQLiteStore) GetAvatar(jid string) (Avatar, bool, error) {
+	a := Avatar{JID: jid}
+	var changed, checked sql.NullTime
+	err := s.queryRow(`SELECT COALESCE(picture_id, ''), COALESCE(path, ''), changed_at, checked_at FROM avatars WHERE jid = ?`,
+		jid).Scan(&a.PictureID, &a.Path, &changed, &checked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Avatar{}, false, nil
+	}
+	if err != nil {
+		return Avatar{}, false, err
+	}
+	a.ChangedAt, a.CheckedAt = changed.Time, checked.Time
+	return a, true, nil
+}
+
+// Record the phone-number JIDs of LIDs, and fill in the numbers of chats
+// and messages stored under those LIDs before the mapping was known
+func (s *SQLiteStore) StoreLIDMappings(mappings []LIDMapping) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, m := range mappings {
+		number := nullString(phone.FromJID(m.PhoneJID))
+		steps := []struct {
+			query string
+			args  []interface{}
+		}{
+			{`INSERT INTO lid_mappings (lid, phone_jid, updated_at) VALUES (?, ?, ?)
+				ON CONFLICT (lid) DO UPDATE SET phone_jid = excluded.phone_jid, updated_at = excluded.updated_at
+				WHERE lid_mappings.phone_jid != excluded.phone_jid`, []interface{}{m.LID, m.PhoneJID, now}},
+			{`UPDATE chats SET phone = ? WHERE jid = ? AND phone IS NULL`, []interface{}{number, m.LID}},
+			{`UPDATE messages SET sender_phone = ? WHERE sender = ? AND sender_phone IS NULL`, []interface{}{number, m.LID}},
+			{`UPDATE contacts SET phone = ? WHERE jid = ? AND phone IS NULL`, []interface{}{number, m.LID}},
+		}
+		for _, step := range steps {
+			if _, err := tx.Exec(step.query, step.args...); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// The phone-number JID a LID maps to; ok is false when none is known
+func (s *SQLiteStore) PhoneJIDForLID(lid string) (jid string, ok bool, err error) {
+	err = s.queryRow(`SELECT phone_jid FROM lid_mappings WHERE lid = ?`, lid).Scan(&jid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	return jid, err == nil, err
+}
+
+// E.164 number of a JID, looking up the phone number of a LID; "" when
+// unknown or for groups and channels
+func (s *SQLiteStore) jidPhone(jid string) string {
+	if !strings.HasSuffix(jid, lidSuffix) {
+		return phone.FromJID(jid)
+	}
+	pn, ok, err := s.PhoneJIDForLID(jid)
+	if err != nil || !ok {
+		return ""
+	}
+	return phone.FromJID(pn)
+}
+
+// Update the names known for a contact; empty fields keep what is stored
+func (s *SQLiteStore) StoreContact(c Contact) error {
+	number := c.Phone
+	if number == "" {
+		number = s.jidPhone(c.JID)
+	}
+	_, err := s.exec(`INSERT INTO contacts (jid, full_name, first_name, push_name, business_name, phone, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			full_name = COALESCE(excluded.full_name, contacts.full_name),
+			first_name = COALESCE(excluded.first_name, contacts.first_name),
+			push_name = COALESCE(excluded.push_name, contacts.push_name),
+			business_name = COALESCE(excluded.business_name, contacts.business_name),
+			phone = COALESCE(excluded.phone, contacts.phone),
+			updated_at = excluded.updated_at`,
+		c.JID, nullString(c.FullName), nullString(c.FirstName), nullString(c.PushName), nullString(c.BusinessName),
+		nullString(number), time.Now().UTC())
+	return err
+}
+
+// List the stored contacts, by JID
+func (s *SQLiteStore) ListContacts() ([]Contact, error) {
+	rows, err := s.query(`SELECT jid, COALESCE(full_name, ''), COALESCE(first_name, ''), COALESCE(push_name, ''),
+			COALESCE(business_name, ''), COALESCE(phone, '')
+		FROM contacts ORDER BY jid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contacts []Contact
+	for rows.Next() {
+		var c Contact
+		if err := rows.Scan(&c.JID, &c.FullName, &c.FirstName, &c.PushName, &c.BusinessName, &c.Phone); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, rows.Err()
+}
+
+// List the names a contact has used, oldest first
+func (s *SQLiteStore) ContactNames(jid string) ([]ContactName, error) {
+	rows, err := s.query(`SELECT name, first_seen, last_seen FROM contact_names WHERE jid = ? ORDER BY first_seen`, jid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []ContactName
+	for rows.Next() {
+		var n ContactName
+		var first, last sql.NullTime
+		if err := rows.Scan(&n.Name, &first, &last); err != nil {
+			return nil, err
+		}
+		n.FirstSeen, n.LastSeen = first.Time, last.Time
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+// List the contacts saved or seen under name, ignoring case: address book
+// names first, then business and push names, then names seen on messages
+func (s *SQLiteStore) ContactJIDs(name string) ([]string, error) {
+	rows, err := s.query(`SELECT jid FROM contacts WHERE LOWER(full_name) = LOWER(?1)
+		UNION ALL SELECT jid FROM contacts WHERE LOWER(business_name) = LOWER(?1) OR LOWER(push_name) = LOWER(?1)
+		UNION ALL SELECT jid FROM contact_names WHERE LOWER(name) = LOWER(?1)`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jids []string
+	seen := map[string]bool{}
+	for rows.Next() {
+		var jid string
+		if err := rows.Scan(&jid); err != nil {
+			return nil, err
+		}
+		if !seen[jid] {
+			seen[jid] = true
+			jids = append(jids, jid)
+		}
+	}
+	return jids, rows.Err()
+}
+
+// Record a delivery or read receipt for each of ids
+func (s *SQLiteStore) StoreReceipt(chatJID, recipient string, ids []string, at time.Time, read bool) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var readAt interface{}
+	if read {
+		readAt = at.UTC()
+	}
+	for _, id := range ids {
+		_, err := tx.Exec(`INSERT INTO receipts (message_id, chat_jid, recipient, delivered_at, read_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (message_id, chat_jid, recipient) DO UPDATE SET
+				delivered_at = COALESCE(receipts.delivered_at, excluded.delivered_at),
+				read_at = COALESCE(receipts.read_at, excluded.read_at)`,
+			id, chatJID, recipient, at.UTC(), readAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// List the receipts for a message, by recipient
+func (s *SQLiteStore) Receipts(key MessageKey) ([]Receipt, error) {
+	rows, err := s.query(`SELECT message_id, chat_jid, recipient, delivered_at, read_at FROM receipts
+		WHERE message_id = ? AND chat_jid = ? ORDER BY recipient`, key.ID, key.ChatJID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var receipts []Receipt
+	for rows.Next() {
+		var r Receipt
+		if err := rows.Scan(&r.MessageID, &r.ChatJID, &r.Recipient, optionalTime{&r.DeliveredAt}, optionalTime{&r.ReadAt}); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, rows.Err()
+}
+
+// Insert a pending reminder
+func (s *SQLiteStore) AddReminder(r Reminder) (int64, error) {
+	var id int64
+	err := s.execRow(`INSERT INTO reminders (chat_jid, text, due_at, created_at) VALUES (?, ?, ?, ?) RETURNING id`,
+		r.ChatJID, r.Text, r.DueAt.UTC(), r.CreatedAt.UTC()).Scan(&id)
+	return id, err
+}
+
+// List pending reminders, soonest first
+func (s *SQLiteStore) ListReminders() ([]Reminder, error) {
+	rows, err := s.query(`SELECT id, chat_jid, text, due_at, created_at FROM reminders ORDER BY due_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []Reminder
+	for rows.Next() {
+		var r Reminder
+		var created sql.NullTime
+		if err := rows.Scan(&r.ID, &r.ChatJID, &r.Text, &r.DueAt, &created); err != nil {
+			return nil, err
+		}
+		r.CreatedAt = created.Time
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+// Delete a reminder by ID
+func (s *SQLiteStore) DeleteReminder(id int64) (bool, error) {
+	res, err := s.exec(`DELETE FROM reminders WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Insert a pending outbox message, first due at its send time
+func (s *SQLiteStore) QueueOutbox(m OutboxMessage) (int64, error) {
+	var id int64
+	err := s.execRow(`INSERT INTO outbox (chat_jid, text, send_at, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING id`,
+		m.ChatJID, m.Text, m.SendAt.UTC(), OutboxPending, m.SendAt.UTC(), time.Now().UTC()).Scan(&id)
+	return id, err
+}
+
+// List pending outbox messages due by now
+func (s *SQLiteStore) DueOutbox(now time.Time) ([]OutboxMessage, error) {
+	return s.outbox(`WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id`, OutboxPending, now.UTC())
+}
+
+// List outbox messages, pending only unless all is set
+func (s *SQLiteStore) ListOutbox(all bool) ([]OutboxMessage, error) {
+	if all {
+		return s.outbox(`ORDER BY send_at, id`)
+	}
+	return s.outbox(`WHERE status = ? ORDER BY send_at, id`, OutboxPending)
+}
+
+// Outbox rows selected by the clauses following FROM
+func (s *SQLiteStore) outbox(clauses string, args ...interface{}) ([]OutboxMessage, error) {
+	rows, err := s.query(`SELECT id, chat_jid, text, send_at, status, attempts, next_attempt_at,
+		COALESCE(last_error, ''), sent_at, created_at FROM outbox `+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []OutboxMessage
+	for rows.Next() {
+		var m OutboxMessage
+		var created sql.NullTime
+		if err := rows.Scan(&m.ID, &m.ChatJID, &m.Text, &m.SendAt, &m.Status, &m.Attempts, &m.NextAttemptAt,
+			&m.LastError, optionalTime{&m.SentAt}, &created); err != nil {
+			return nil, err
+		}
+		m.CreatedAt = created.Time
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// Mark an outbox message sent
+func (s *SQLiteStore) MarkOutboxSent(id int64, at time.Time) error {
+	_, err := s.exec(`UPDATE outbox SET status = ?, sent_at = ? WHERE id = ?`, OutboxSent, at.UTC(), id)
+	return err
+}
+
+// Count a failed attempt, rescheduling it or marking it failed
+func (s *SQLiteStore) RecordOutboxFailure(id int64, reason string, next time.Time) error {
+	status := OutboxPending
+	if next.IsZero() {
+		status = OutboxFailed
+	}
+	_, err := s.exec(`UPDATE outbox SET attempts = attempts + 1, last_error = ?, status = ?,
+		next_attempt_at = CASE WHEN ? THEN next_attempt_at ELSE ? END WHERE id = ?`,
+		reason, status, next.IsZero(), next.UTC(), id)
+	return err
+}
+
+// Delete a pending outbox message by ID
+func (s *SQLiteStore) CancelOutbox(id int64) (bool, error) {
+	res, err := s.exec(`DELETE FROM outbox WHERE id = ? AND status = ?`, id, OutboxPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Insert a message awaiting approval
+func (s *SQLiteStore) AddPendingSend(p PendingSend) (int64, error) {
+	var sendAt interface{}
+	if p.SendAt != nil {
+		sendAt = p.SendAt.UTC()
+	}
+	var id int64
+	err := s.execRow(`INSERT INTO pending_sends (chat_jid, text, send_at, status, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id`,
+		p.ChatJID, p.Text, sendAt, SendPending, time.Now().UTC()).Scan(&id)
+	return id, err
+}
+
+// Look up a held message by ID
+func (s *SQLiteStore) GetPendingSend(id int64) (PendingSend, bool, error) {
+	sends, err := s.pendingSends(`WHERE id = ?`, id)
+	if err != nil || len(sends) == 0 {
+		return PendingSend{}, false, err
+	}
+	return sends[0], true, nil
+}
+
+// List held messages, undecided only unless all is set
+func (s *SQLiteStore) ListPendingSends(all bool) ([]PendingSend, error) {
+	if all {
+		return s.pendingSends(`ORDER BY id`)
+	}
+	return s.pendingSends(`WHERE status = ? ORDER BY id`, SendPending)
+}
+
+// Held messages selected by the clauses following FROM
+func (s *SQLiteStore) pendingSends(clauses string, args ...interface{}) ([]PendingSend, error) {
+	rows, err := s.query(`SELECT id, chat_jid, text, send_at, status, created_at, decided_at
+		FROM pending_sends `+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sends []PendingSend
+	for rows.Next() {
+		var p PendingSend
+		if err := rows.Scan(&p.ID, &p.ChatJID, &p.Text, optionalTime{&p.SendAt}, &p.Status, &p.CreatedAt,
+			optionalTime{&p.DecidedAt}); err != nil {
+			return nil, err
+		}
+		sends = append(sends, p)
+	}
+	return sends, rows.Err()
+}
+
+// Record the decision on a message that is still pending
+func (s *SQLiteStore) DecidePendingSend(id int64, status string, at time.Time) (bool, error) {
+	res, err := s.exec(`UPDATE pending_sends SET status = ?, decided_at = ? WHERE id = ? AND status = ?`,
+		status, at.UTC(), id, SendPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Append a chat event
+func (s *SQLiteStore) StoreChatEvent(e ChatEvent) error {
+	_, err := s.exec(`INSERT INTO chat_events (chat_jid, kind, subject, actor, at) VALUES (?, ?, ?, ?, ?)`,
+		e.ChatJID, e.Kind, e.Subject, e.Actor, e.At.UTC())
+	return err
+}
+
+// List a chat's events in a time range, oldest first
+func (s *SQLiteStore) ChatEvents(chatJID string, since, until time.Time) ([]ChatEvent, error) {
+	rows, err := s.query(`SELECT chat_jid, kind, COALESCE(subject, ''), COALESCE(actor, ''), at
+		FROM chat_events WHERE chat_jid = ? AND at >= ? AND at < ? ORDER BY at`, chatJID, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []ChatEvent
+	for rows.Next() {
+		var e ChatEvent
+		if err := rows.Scan(&e.ChatJID, &e.Kind, &e.Subject, &e.Actor, &e.At); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Replace a message's content with its edited text, keeping the old text as
+// a revision. Edits no newer than the last one applied are ignored, so
+// replayed or out-of-order edits are harmless.
+func (s *SQLiteStore) EditMessage(key MessageKey, content string, at time.Time) error {
+	content, err := s.seal(content)
+	if err != nil {
+		return err
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var old sql.NullString
+	var edited sql.NullTime
+	err = tx.QueryRow(`SELECT content, edited_at FROM messages WHERE id = ? AND chat_jid = ?`, key.ID, key.ChatJID).Scan(&old, &edited)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s in %s", ErrMessageNotFound, key.ID, key.ChatJID)
+	}
+	if err != nil {
+		return err
+	}
+	if edited.Valid && !at.After(edited.Time) {
+		return nil
+	}
+	// A message stored again by history sync forgets its edit, which then
+	// replays; don't record the same revision twice
+	if _, err := tx.Exec(`INSERT INTO message_revisions (message_id, chat_jid, content, replaced_at)
+		SELECT ?1, ?2, ?3, ?4 WHERE NOT EXISTS (SELECT 1 FROM message_revisions
+			WHERE message_id = ?1 AND chat_jid = ?2 AND replaced_at = ?4)`,
+		key.ID, key.ChatJID, old, at.UTC()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE messages SET content = ?, edited_at = ? WHERE id = ? AND chat_jid = ?`,
+		content, at.UTC(), key.ID, key.ChatJID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Mark a message as deleted for everyone by the given JID. When it was
+// never stored, store tombstone in its place so the deletion still shows.
+func (s *SQLiteStore) RevokeMessage(tombstone Message, by string) error {
+	content, err := s.seal(tombstone.Content)
+	if err != nil {
+		return err
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	at := tombstone.Timestamp.UTC()
+	res, err := tx.Exec(`UPDATE messages SET deleted_at = COALESCE(deleted_at, ?), revoked_by = COALESCE(revoked_by, ?)
+		WHERE id = ? AND chat_jid = ?`, at, by, tombstone.ID, tombstone.ChatJID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		steps := []struct {
+			query string
+			args  []interface{}
+		}{
+			{`INSERT OR IGNORE INTO chats (jid, last_message_time, phone, is_channel) VALUES (?, ?, ?, ?)`,
+				[]interface{}{tombstone.ChatJID, at, nullString(phone.FromJID(tombstone.ChatJID)), IsChannel(tombstone.ChatJID)}},
+			{`INSERT INTO messages (id, chat_jid, sender, content, timestamp, is_from_me, media_type, filename, sender_phone, deleted_at, revoked_by)
+				VALUES (?, ?, ?, ?, ?, ?, '', '', ?, ?, ?)`,
+				[]interface{}{tombstone.ID, tombstone.ChatJID, tombstone.Sender, content, at, tombstone.IsFromMe,
+					nullString(phone.FromJID(tombstone.Sender)), at, by}},
+		}
+		for _, step := range steps {
+			if _, err := tx.Exec(step.query, step.args...); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Store a group change as a message, creating its chat when unseen
+func (s *SQLiteStore) StoreSystemMessage(m Message) error {
+	if m.System == nil {
+		return fmt.Errorf("message %s is not a system message", m.ID)
+	}
+	data, err := json.Marshal(m.System.Data)
+	if err != nil {
+		return err
+	}
+	content, err := s.seal(m.Content)
+	if err != nil {
+		return err
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	steps := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT OR IGNORE INTO chats (jid, last_message_time, phone, is_channel) VALUES (?, ?, ?, ?)`,
+			[]interface{}{m.ChatJID, m.Timestamp, nullString(phone.FromJID(m.ChatJID)), IsChannel(m.ChatJID)}},
+		{`INSERT INTO messages (id, chat_jid, sender, content, timestamp, is_from_me, media_type, filename,
+				sender_phone, system_kind, system_data)
+			VALUES (?, ?, ?, ?, ?, ?, '', '', ?, ?, ?)
+			ON CONFLICT (id, chat_jid) DO UPDATE SET sender = excluded.sender, content = excluded.content,
+				timestamp = excluded.timestamp, is_from_me = excluded.is_from_me, sender_phone = excluded.sender_phone,
+				system_kind = excluded.system_kind, system_data = excluded.system_data`,
+			[]interface{}{m.ID, m.ChatJID, m.Sender, content, m.Timestamp, m.IsFromMe,
+				nullString(phone.FromJID(m.Sender)), m.System.Kind, string(data)}},
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step.query, step.args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// List the earlier contents of a message, oldest first
+func (s *SQLiteStore) Revisions(key MessageKey) ([]Revision, error) {
+	rows, err := s.query(`SELECT COALESCE(content, ''), replaced_at FROM message_revisions
+		WHERE message_id = ? AND chat_jid = ? ORDER BY replaced_at`, key.ID, key.ChatJID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		var r Revision
+		if err := rows.Scan(sealedDest{s, &r.Content}, &r.ReplacedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// Replace the links recorded for a message
+func (s *SQLiteStore) StoreLinks(key MessageKey, links []SharedLink) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM links WHERE message_id = ? AND chat_jid = ?`, key.ID, key.ChatJID); err != nil {
+		return err
+	}
+	for _, l := range links {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO links (message_id, chat_jid, url, title, description, domain)
+			VALUES (?, ?, ?, ?, ?, ?)`, key.ID, key.ChatJID, l.URL, nullString(l.Title), nullString(l.Description), l.Domain); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// List shared links matching f, newest message first
+func (s *SQLiteStore) ListLinks(f LinkFilter) ([]SharedLink, error) {
+	query := `SELECT l.message_id, l.chat_jid, COALESCE(m.sender, ''), m.timestamp, l.url,
+			COALESCE(l.title, ''), COALESCE(l.description, ''), l.domain
+		FROM links l JOIN messages m ON m.id = l.message_id AND m.chat_jid = l.chat_jid
+		WHERE 1 = 1`
+	var args []interface{}
+	if f.ChatJID != "" {
+		group, err := s.ChatGroup(f.ChatJID)
+		if err != nil {
+			return nil, err
+		}
+		query += ` AND l.chat_jid IN (` + placeholders(len(group)) + `)`
+		args = append(args, stringArgs(group)...)
+	}
+	if f.Sender != "" {
+		query += ` AND (m.sender = ? OR m.sender_phone = ?)`
+		args = append(args, f.Sender, nullString(s.jidPhone(f.Sender)))
+	}
+	if f.Domain != "" {
+		query += ` AND l.domain = ?`
+		args = append(args, strings.TrimPrefix(strings.ToLower(f.Domain), "www."))
+	}
+	if !f.Since.IsZero() {
+		query += ` AND m.timestamp >= ?`
+		args = append(args, f.Since)
+	}
+	if !f.Until.IsZero() {
+		query += ` AND m.timestamp < ?`
+		args = append(args, f.Until)
+	}
+	rows, err := s.query(query+` ORDER BY m.timestamp DESC, l.url`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []SharedLink
+	for rows.Next() {
+		var l SharedLink
+		var ts sql.NullTime
+		if err := rows.Scan(&l.MessageID, &l.ChatJID, &l.Sender, &ts, &l.URL, &l.Title, &l.Description, &l.Domain); err != nil {
+			return nil, err
+		}
+		l.Timestamp = ts.Time
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// Replace the contact cards recorded for a message
+func (s *SQLiteStore) StoreSharedContacts(key MessageKey, contacts []SharedContact) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM shared_contacts WHERE message_id = ? AND chat_jid = ?`, key.ID, key.ChatJID); err != nil {
+		return err
+	}
+	for i, c := range contacts {
+		phones := c.Phones
+		if phones == nil {
+			phones = []string{}
+		}
+		encoded, err := json.Marshal(phones)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO shared_contacts (message_id, chat_jid, position, display_name, phones, vcard)
+			VALUES (?, ?, ?, ?, ?, ?)`, key.ID, key.ChatJID, i, c.Name, string(encoded), c.VCard); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// List contact cards shared in a chat and the chats linked to it, newest
+// message first; an empty chatJID means all chats
+func (s *SQLiteStore) ListSharedContacts(chatJID string) ([]SharedContact, error) {
+	query := `SELECT sc.message_id, sc.chat_jid, COALESCE(m.sender, ''), m.timestamp, COALESCE(sc.display_name, ''),
+			sc.phones, COALESCE(sc.vcard, '')
+		FROM shared_contacts sc JOIN messages m ON m.id = sc.message_id AND m.chat_jid = sc.chat_jid`
+	var args []interface{}
+	if chatJID != "" {
+		group, err := s.ChatGroup(chatJID)
+		if err != nil {
+			return nil, err
+		}
+		query += ` WHERE sc.chat_jid IN (` + placeholders(len(group)) + `)`
+		args = stringArgs(group)
+	}
+	rows, err := s.query(query+` ORDER BY m.timestamp DESC, sc.position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contacts []SharedContact
+	for rows.Next() {
+		var c SharedContact
+		var ts sql.NullTime
+		var phones string
+		if err := rows.Scan(&c.MessageID, &c.ChatJID, &c.Sender, &ts, &c.Name, &phones, &c.VCard); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(phones), &c.Phones); err != nil {
+			return nil, fmt.Errorf("shared contact in %s has malformed phones: %w", c.MessageID, err)
+		}
+		c.Timestamp = ts.Time
+		contacts = append(contacts, c)
+	}
+	return contacts, rows.Err()
+}
+
+// Record a ringing call unless it is already stored
+func (s *SQLiteStore) StoreCall(c Call) error {
+	if c.Outcome == "" {
+		c.Outcome = CallMissed
+	}
+	_, err := s.exec(`INSERT OR IGNORE INTO calls (id, chat_jid, caller, is_from_me, is_video, is_group, outcome, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, c.ID, c.ChatJID, c.Caller, c.IsFromMe, c.IsVideo, c.IsGroup, c.Outcome, c.StartedAt.UTC())
+	return err
+}
+
+// Mark a call answered
+func (s *SQLiteStore) AcceptCall(id string, at time.Time) error {
+	_, err := s.exec(`UPDATE calls SET outcome = ?, accepted_at = COALESCE(accepted_at, ?) WHERE id = ?`,
+		CallAnswered, at.UTC(), id)
+	return err
+}
+
+// Mark a call ended. A rejection only counts before the call was answered;
+// an answered call's duration runs from its answer to at.
+func (s *SQLiteStore) EndCall(id string, at time.Time, rejected bool) error {
+	outcome := CallMissed
+	if rejected {
+		outcome = CallRejected
+	}
+	var accepted sql.NullTime
+	err := s.queryRow(`SELECT accepted_at FROM calls WHERE id = ? AND ended_at IS NULL`, id).Scan(&accepted)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var duration int64
+	if accepted.Valid && at.After(accepted.Time) {
+		duration = int64(at.Sub(accepted.Time).Round(time.Second) / time.Second)
+	}
+	_, err = s.exec(`UPDATE calls SET ended_at = ?,
+			outcome = CASE WHEN accepted_at IS NULL THEN ? ELSE outcome END,
+			duration = ?
+		WHERE id = ? AND ended_at IS NULL`, at.UTC(), outcome, duration, id)
+	return err
+}
+
+// List calls in a chat and the chats linked to it, newest first; an empty
+// chatJID means all chats
+func (s *SQLiteStore) ListCalls(chatJID string) ([]Call, error) {
+	query := `SELECT id, chat_jid, caller, is_from_me, is_video, is_group, outcome, started_at, accepted_at, ended_at, duration
+		FROM calls`
+	var args []interface{}
+	if chatJID != "" {
+		group, err := s.ChatGroup(chatJID)
+		if err != nil {
+			return nil, err
+		}
+		query += ` WHERE chat_jid IN (` + placeholders(len(group)) + `)`
+		args = stringArgs(group)
+	}
+	rows, err := s.query(query+` ORDER BY started_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls []Call
+	for rows.Next() {
+		var c Call
+		if err := rows.Scan(&c.ID, &c.ChatJID, &c.Caller, &c.IsFromMe, &c.IsVideo, &c.IsGroup, &c.Outcome, &c.StartedAt,
+			optionalTime{&c.AcceptedAt}, optionalTime{&c.EndedAt}, &c.Duration); err != nil {
+			return nil, err
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
+
+// Replace a group's metadata and members
+func (s *SQLiteStore) StoreGroup(g Group) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var created sql.NullTime
+	if !g.CreatedAt.IsZero() {
+		created = sql.NullTime{Time: g.CreatedAt.UTC(), Valid: true}
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO groups (jid, subject, description, owner, created_at, synced_at,
+			is_community, community_jid, is_announcement)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, g.JID, g.Subject, nullString(g.Description), nullString(g.Owner), created,
+		g.SyncedAt.UTC(), g.IsCommunity, nullString(g.CommunityJID), g.IsAnnouncement); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM group_participants WHERE group_jid = ?`, g.JID); err != nil {
+		return err
+	}
+	for _, p := range g.Participants {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO group_participants (group_jid, jid, phone_jid, is_admin, is_super_admin)
+			VALUES (?, ?, ?, ?, ?)`, g.JID, p.JID, nullString(p.PhoneJID), p.IsAdmin, p.IsSuperAdmin); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Get a synced group with its members, admins first, or a community with
+// its groups
+func (s *SQLiteStore) GetGroup(jid string) (Group, error) {
+	groups, err := s.groups(`WHERE g.jid = ?`, jid)
+	if err != nil {
+		return Group{}, err
+	}
+	if len(groups) == 0 {
+		return Group{}, fmt.Errorf("%w: no metadata synced for group %s", ErrChatNotFound, jid)
+	}
+	g := groups[0]
+	if g.IsCommunity {
+		if g.Subgroups, err = s.subgroups(jid); err != nil {
+			return Group{}, err
+		}
+	}
+
+	rows, err := s.query(`SELECT jid, COALESCE(phone_jid, ''), is_admin, is_super_admin FROM group_participants
+		WHERE group_jid = ? ORDER BY is_super_admin DESC, is_admin DESC, jid`, jid)
+	if err != nil {
+		return Group{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p GroupParticipant
+		if err := rows.Scan(&p.JID, &p.PhoneJID, &p.IsAdmin, &p.IsSuperAdmin); err != nil {
+			return Group{}, err
+		}
+		g.Participants = append(g.Participants, p)
+	}
+	return g, rows.Err()
+}
+
+// List synced groups by subject, without their members
+func (s *SQLiteStore) ListGroups() ([]Group, error) {
+	return s.groups(`ORDER BY g.subject COLLATE NOCASE`)
+}
+
+// The synced groups linked to a community, announcement group first
+func (s *SQLiteStore) subgroups(communityJID string) ([]Group, error) {
+	return s.groups(`WHERE g.community_jid = ? ORDER BY g.is_announcement DESC, g.subject COLLATE NOCASE`, communityJID)
+}
+
+// Look up a community's JID and the JIDs of its synced groups
+func (s *SQLiteStore) CommunityChats(jid string) ([]string, error) {
+	groups, err := s.groups(`WHERE g.jid = ? AND g.is_community = 1`, jid)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("%w: no community synced as %s", ErrChatNotFound, jid)
+	}
+	subgroups, err := s.subgroups(jid)
+	if err != nil {
+		return nil, err
+	}
+	jids := []string{jid}
+	for _, g := range subgroups {
+		jids = append(jids, g.JID)
+	}
+	return jids, nil
+}
+
+// Groups matching clauses, which follow the FROM clause
+func (s *SQLiteStore) groups(clauses string, args ...interface{}) ([]Group, error) {
+	rows, err := s.query(`SELECT g.jid, COALESCE(g.subject, ''), COALESCE(g.description, ''), COALESCE(g.owner, ''),
+			g.created_at, g.synced_at, (SELECT COUNT(*) FROM group_participants p WHERE p.group_jid = g.jid),
+			g.is_community, COALESCE(g.community_jid, ''), g.is_announcement
+		FROM groups g `+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []Group
+	for rows.Next() {
+		var g Group
+		var created, synced sql.NullTime
+		if err := rows.Scan(&g.JID, &g.Subject, &g.Description, &g.Owner, &created, &synced, &g.Size,
+			&g.IsCommunity, &g.CommunityJID, &g.IsAnnouncement); err != nil {
+			return nil, err
+		}
+		g.CreatedAt, g.SyncedAt = created.Time, synced.Time
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// Record membership changes, skipping ones already recorded
+func (s *SQLiteStore) StoreMembershipChanges(changes []MembershipChange) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, c := range changes {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO membership_changes (group_jid, member_jid, kind, actor, at)
+			VALUES (?, ?, ?, ?, ?)`, c.GroupJID, c.Member, c.Kind, nullString(c.Actor),
+			c.At.UTC().Truncate(time.Second)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// List a group's membership changes, optionally of one member, oldest first
+func (s *SQLiteStore) MembershipChanges(groupJID, member string) ([]MembershipChange, error) {
+	query := `SELECT mc.group_jid, mc.member_jid, COALESCE(cd.name, ''), mc.kind, COALESCE(mc.actor, ''), mc.at
+		FROM membership_changes mc LEFT JOIN contact_display cd ON cd.jid = mc.member_jid
+		WHERE mc.group_jid = ?`
+	args := []interface{}{groupJID}
+	if member != "" {
+		query += ` AND mc.member_jid = ?`
+		args = append(args, member)
+	}
+	rows, err := s.query(query+` ORDER BY mc.at, mc.member_jid`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []MembershipChange
+	for rows.Next() {
+		var c MembershipChange
+		if err := rows.Scan(&c.GroupJID, &c.Member, &c.Name, &c.Kind, &c.Actor, &c.At); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// Work out who was in a group at a time from its synced member list: joins
+// and leaves between then and the sync are undone newest first, or, for a
+// time after the sync, replayed oldest first
+func (s *SQLiteStore) GroupMembersAt(groupJID string, at time.Time) ([]string, error) {
+	g, err := s.GetGroup(groupJID)
+	if err != nil {
+		return nil, err
+	}
+	members := map[string]bool{}
+	for _, p := range g.Participants {
+		members[p.JID] = true
+	}
+
+	query := `SELECT member_jid, kind FROM membership_changes
+		WHERE group_jid = ? AND kind IN (?, ?) AND at > ? AND at <= ? ORDER BY at `
+	from, to, undo := at, g.SyncedAt, true
+	if at.After(g.SyncedAt) {
+		from, to, undo = g.SyncedAt, at, false
+	}
+	if undo {
+		query += `DESC`
+	}
+	rows, err := s.query(query, groupJID, EventJoin, EventLeave, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var member, kind string
+		if err := rows.Scan(&member, &kind); err != nil {
+			return nil, err
+		}
+		members[member] = (kind == EventJoin) != undo
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var list []string
+	for jid, in := range members {
+		if in {
+			list = append(list, jid)
+		}
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// Record or replace the group invite a message carries
+func (s *SQLiteStore) StoreGroupInvite(key MessageKey, inv GroupInvite) error {
+	var expires sql.NullTime
+	if inv.ExpiresAt != nil {
+		expires = sql.NullTime{Time: inv.ExpiresAt.UTC(), Valid: true}
+	}
+	_, err := s.exec(`INSERT OR REPLACE INTO group_invites
+		(message_id, chat_jid, group_jid, group_name, invite_code, expires_at, caption) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, key.ChatJID, inv.GroupJID, nullString(inv.GroupName), inv.Code, expires, nullString(inv.Caption))
+	return err
+}
+
+// Get the invite a message carries
+func (s *SQLiteStore) GetGroupInvite(key MessageKey) (GroupInvite, error) {
+	invites, err := s.groupInvites(`WHERE gi.message_id = ? AND gi.chat_jid = ?`, key.ID, key.ChatJID)
+	if err != nil {
+		return GroupInvite{}, err
+	}
+	if len(invites) == 0 {
+		return GroupInvite{}, fmt.Errorf("%w: no group invite %s in %s", ErrMessageNotFound, key.ID, key.ChatJID)
+	}
+	return invites[0], nil
+}
+
+// List group invites, newest first; an empty chatJID means all chats
+func (s *SQLiteStore) ListGroupInvites(chatJID string) ([]GroupInvite, error) {
+	if chatJID == "" {
+		return s.groupInvites(`ORDER BY m.timestamp DESC`)
+	}
+	group, err := s.ChatGroup(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupInvites(`WHERE gi.chat_jid IN (`+placeholders(len(group))+`) ORDER BY m.timestamp DESC`, stringArgs(group)...)
+}
+
+// Group invites selected by the given WHERE and ORDER BY clauses
+func (s *SQLiteStore) groupInvites(clauses string, args ...interface{}) ([]GroupInvite, error) {
+	rows, err := s.query(`SELECT gi.message_id, gi.chat_jid, COALESCE(m.sender, ''), m.timestamp, gi.group_jid,
+			COALESCE(gi.group_name, ''), gi.invite_code, gi.expires_at, COALESCE(gi.caption, '')
+		FROM group_invites gi JOIN messages m ON m.id = gi.message_id AND m.chat_jid = gi.chat_jid `+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []GroupInvite
+	for rows.Next() {
+		var inv GroupInvite
+		var ts sql.NullTime
+		if err := rows.Scan(&inv.MessageID, &inv.ChatJID, &inv.Sender, &ts, &inv.GroupJID, &inv.GroupName, &inv.Code,
+			optionalTime{&inv.ExpiresAt}, &inv.Caption); err != nil {
+			return nil, err
+		}
+		inv.Timestamp = ts.Time
+		invites = append(invites, inv)
+	}
+	return invites, rows.Err()
+}
+
+// Store a poll, replacing an earlier copy of the same one
+func (s *SQLiteStore) StorePoll(p Poll) error {
+	options, err := json.Marshal(p.Options)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(`INSERT OR REPLACE INTO polls (message_id, chat_jid, creator, question, options, selectable_count, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, p.ID, p.ChatJID, p.Creator, p.Question, string(options), p.SelectableCount, p.CreatedAt.UTC())
+	return err
+}
+
+// Look up one poll by the key of its message
+func (s *SQLiteStore) GetPoll(key MessageKey) (Poll, error) {
+	polls, err := s.polls(`WHERE message_id = ? AND chat_jid = ?`, key.ID, key.ChatJID)
+	if err != nil {
+		return Poll{}, err
+	}
+	if len(polls) == 0 {
+		return Poll{}, fmt.Errorf("%w: no poll %s in %s", ErrMessageNotFound, key.ID, key.ChatJID)
+	}
+	return polls[0], nil
+}
+
+// List polls, newest first; an empty chatJID means all chats
+func (s *SQLiteStore) ListPolls(chatJID string) ([]Poll, error) {
+	if chatJID == "" {
+		return s.polls(`ORDER BY created_at DESC`)
+	}
+	group, err := s.ChatGroup(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	return s.polls(`WHERE chat_jid IN (`+placeholders(len(group))+`) ORDER BY created_at DESC`, stringArgs(group)...)
+}
+
+// Polls selected by the given WHERE and ORDER BY clauses
+func (s *SQLiteStore) polls(clauses string, args ...interface{}) ([]Poll, error) {
+	rows, err := s.query(`SELECT message_id, chat_jid, COALESCE(creator, ''), question, options, selectable_count, created_at
+		FROM polls `+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var polls []Poll
+	for rows.Next() {
+		var p Poll
+		var options string
+		var created sql.NullTime
+		if err := rows.Scan(&p.ID, &p.ChatJID, &p.Creator, &p.Question, &options, &p.SelectableCount, &created); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(options), &p.Options); err != nil {
+			return nil, fmt.Errorf("poll %s has malformed options: %w", p.ID, err)
+		}
+		p.CreatedAt = created.Time
+		polls = append(polls, p)
+	}
+	return polls, rows.Err()
+}
+
+// Record a vote unless a newer one by the same person is already stored
+func (s *SQLiteStore) StorePollVote(v PollVote) error {
+	hashes := v.OptionHashes
+	if hashes == nil {
+		hashes = []string{}
+	}
+	options, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(`INSERT INTO poll_votes (poll_id, chat_jid, voter, options, voted_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (poll_id, chat_jid, voter) DO UPDATE SET options = excluded.options, voted_at = excluded.voted_at
+		WHERE excluded.voted_at >= poll_votes.voted_at`,
+		v.PollID, v.ChatJID, v.Voter, string(options), v.At.UTC())
+	return err
+}
+
+// List the current votes in a poll, oldest first
+func (s *SQLiteStore) PollVotes(key MessageKey) ([]PollVote, error) {
+	rows, err := s.query(`SELECT poll_id, chat_jid, voter, options, voted_at FROM poll_votes
+		WHERE poll_id = ? AND chat_jid = ? ORDER BY voted_at`, key.ID, key.ChatJID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var votes []PollVote
+	for rows.Next() {
+		var v PollVote
+		var options string
+		if err := rows.Scan(&v.PollID, &v.ChatJID, &v.Voter, &options, &v.At); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(options), &v.OptionHashes); err != nil {
+			return nil, fmt.Errorf("vote by %s has malformed options: %w", v.Voter, err)
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+// Record a reaction unless a newer one by the same person is already stored
+func (s *SQLiteStore) StoreReaction(r Reaction) error {
+	_, err := s.exec(`INSERT INTO reactions (message_id, chat_jid, reactor, emoji, timestamp) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (message_id, chat_jid, reactor) DO UPDATE SET emoji = excluded.emoji, timestamp = excluded.timestamp
+		WHERE excluded.timestamp >= reactions.timestamp`,
+		r.MessageID, r.ChatJID, r.Reactor, r.Emoji, r.Timestamp.UTC())
+	return err
+}
+
+// List the current reactions to a message, oldest first
+func (s *SQLiteStore) Reactions(key MessageKey) ([]Reaction, error) {
+	rows, err := s.query(`SELECT message_id, chat_jid, reactor, emoji, timestamp FROM reactions
+		WHERE message_id = ? AND chat_jid = ? AND emoji != '' ORDER BY timestamp`, key.ID, key.ChatJID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reactions []Reaction
+	for rows.Next() {
+		var r Reaction
+		if err := rows.Scan(&r.MessageID, &r.ChatJID, &r.Reactor, &r.Emoji, &r.Timestamp); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, r)
+	}
+	return reactions, rows.Err()
+}
+
+// Store a link preview, replacing an older one for the same URL
+func (s *SQLiteStore) StoreLinkPreview(p LinkPreview) error {
+	_, err := s.exec(`INSERT INTO link_previews (url, title, description) VALUES (?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET title = excluded.title, description = excluded.description`,
+		p.URL, p.Title, p.Description)
+	return err
+}
+
+// Look up previews in batches small enough for SQLite's parameter limit
+func (s *SQLiteStore) LinkPreviews(urls []string) (map[string]LinkPreview, error) {
+	const batch = 500
+	previews := map[string]LinkPreview{}
+	for start := 0; start < len(urls); start += batch {
+		chunk := urls[start:min(start+batch, len(urls))]
+		rows, err := s.query(`SELECT url, COALESCE(title, ''), COALESCE(description, '')
+			FROM link_previews WHERE url IN (`+placeholders(len(chunk))+`)`, stringArgs(chunk)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var p LinkPreview
+			if err := rows.Scan(&p.URL, &p.Title, &p.Description); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			previews[p.URL] = p
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return previews, nil
+}
+
+// Count stored messages
+func (s *SQLiteStore) MessageCount() (int, error) {
+	var count int
+	err := s.queryRow("SELECT COUNT(*) FROM messages").Scan(&count)
+	return count, err
+}
+
+// Count stored chats
+func (s *SQLiteStore) ChatCount() (int, error) {
+	var count int
+	err := s.queryRow("SELECT COUNT(*) FROM chats").Scan(&count)
+	return count, err
+}
@@ -0,0 +1,491 @@
+package store
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	mergeFrom = "123456789@lid"
+	mergeInto = "447700900123@s.whatsapp.net"
+)
+
+func openTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	st, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+// A message in from with every column set: through the store's setters
+// where it has one, and with a placeholder of the column's type otherwise,
+// so columns added later are covered too
+func storeFullMessage(t *testing.T, st *SQLiteStore, key MessageKey, at time.Time) {
+	t.Helper()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(st.StoreMessage(key.ID, key.ChatJID, "447700900456@s.whatsapp.net", "original text", at, false, "image", "photo.jpg", "https://mmg.whatsapp.net/photo"))
+	must(st.StoreReply(key, "QUOTED", "447700900789@s.whatsapp.net"))
+	must(st.StoreMedia(key, Media{DirectPath: "/v/t62/photo", MediaKey: []byte{1}, FileSHA256: []byte{2}, FileEncSHA256: []byte{3}, FileLength: 4, MimeType: "image/jpeg"}))
+	must(st.SetMediaFile(key, MediaBlob{SHA256: "abc123", Location: "media/abc123.jpg", Size: 4, MimeType: "image/jpeg"}))
+	must(st.StoreOCRText(key, "text in the photo"))
+	must(st.EditMessage(key, "edited text", at.Add(time.Minute)))
+	must(st.StoreForwarded(key, 3))
+	must(st.StoreMentionsMe(key))
+	must(st.StoreRawMessage(key, []byte("raw")))
+	must(st.RecordMediaFailure(key, "timeout"))
+	must(st.StoreChatEvent(ChatEvent{ChatJID: key.ChatJID, Kind: "pin", Subject: key.ID, At: at}))
+
+	rows, err := st.db.Query(`SELECT name, type FROM pragma_table_info('messages')`)
+	must(err)
+	var fill []string
+	for rows.Next() {
+		var name, typ string
+		must(rows.Scan(&name, &typ))
+		value := "'x'"
+		switch typ {
+		case "INTEGER", "BOOLEAN", "TIMESTAMP":
+			value = "1"
+		case "REAL":
+			value = "1.5"
+		case "BLOB":
+			value = "x'01'"
+		}
+		fill = append(fill, name+" = COALESCE("+name+", "+value+")")
+	}
+	must(rows.Err())
+	rows.Close()
+	for _, set := range fill {
+		_, err := st.db.Exec(`UPDATE messages SET `+set+` WHERE id = ? AND chat_jid = ?`, key.ID, key.ChatJID)
+		must(err)
+	}
+}
+
+// Every column of a stored message but its chat, as stored
+func messageRow(t *testing.T, st *SQLiteStore, key MessageKey) map[string]interface{} {
+	t.Helper()
+	rows, err := st.db.Query(`SELECT * FROM messages WHERE id = ? AND chat_jid = ?`, key.ID, key.ChatJID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatalf("message %s not in %s", key.ID, key.ChatJID)
+	}
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		t.Fatal(err)
+	}
+	row := map[string]interface{}{}
+	for i, c := range columns {
+		if c != "chat_jid" {
+			row[c] = values[i]
+		}
+	}
+	return row
+}
+
+func countRows(t *testing.T, st *SQLiteStore, table, column, jid string) int {
+	t.Helper()
+	var n int
+	if err := st.db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE `+column+` = ?`, jid).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestMergeChatsKeepsEveryColumn(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	if err := st.StoreChat(mergeFrom, "Alice", at); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.StoreChat(mergeInto, "Alice", at); err != nil {
+		t.Fatal(err)
+	}
+	from := MessageKey{ID: "MSG1", ChatJID: mergeFrom}
+	storeFullMessage(t, st, from, at)
+	before := messageRow(t, st, from)
+	for column, value := range before {
+		if value == nil {
+			t.Fatalf("column %s left empty before merging", column)
+		}
+	}
+
+	if err := st.MergeChats(mergeFrom, mergeInto); err != nil {
+		t.Fatal(err)
+	}
+
+	after := messageRow(t, st, MessageKey{ID: from.ID, ChatJID: mergeInto})
+	if !reflect.DeepEqual(before, after) {
+		for column := range before {
+			if !reflect.DeepEqual(before[column], after[column]) {
+				t.Errorf("%s = %v after merging, want %v", column, after[column], before[column])
+			}
+		}
+	}
+	for _, table := range []string{"messages", "media_refs", "media_downloads", "message_raw", "message_revisions", "chat_events"} {
+		if n := countRows(t, st, table, "chat_jid", mergeFrom); n != 0 {
+			t.Errorf("%d rows of %s left in the merged chat", n, table)
+		}
+		if n := countRows(t, st, table, "chat_jid", mergeInto); n == 0 {
+			t.Errorf("no rows of %s moved", table)
+		}
+	}
+	if n := countRows(t, st, "chats", "jid", mergeFrom); n != 0 {
+		t.Errorf("merged chat not deleted")
+	}
+}
+
+func TestMergeChatsKeepsTargetOnCollision(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	for _, jid := range []string{mergeFrom, mergeInto} {
+		if err := st.StoreChat(jid, "Alice", at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.StoreMessage("MSG1", mergeFrom, "", "from copy", at, false, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.StoreMessage("MSG1", mergeInto, "", "into copy", at, false, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.StoreMessage("MSG2", mergeFrom, "", "only in from", at.Add(time.Second), false, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.EditMessage(MessageKey{ID: "MSG1", ChatJID: mergeFrom}, "from copy, edited", at.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.EditMessage(MessageKey{ID: "MSG2", ChatJID: mergeFrom}, "only in from, edited", at.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []Avatar{{JID: mergeFrom, PictureID: "from", CheckedAt: at}, {JID: mergeInto, PictureID: "into", CheckedAt: at}} {
+		if err := st.StoreAvatar(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.StoreGroup(Group{JID: mergeFrom, Subject: "Book club", SyncedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.MergeChats(mergeFrom, mergeInto); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := st.GetMessage(MessageKey{ID: "MSG1", ChatJID: mergeInto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Content != "into copy" {
+		t.Errorf("content = %q, want the merged-into chat's copy", m.Content)
+	}
+	if _, err := st.GetMessage(MessageKey{ID: "MSG2", ChatJID: mergeInto}); err != nil {
+		t.Errorf("message only in the merged chat: %v", err)
+	}
+	if n := countRows(t, st, "messages", "chat_jid", mergeFrom); n != 0 {
+		t.Errorf("%d messages left in the merged chat", n)
+	}
+	// The kept copy was never edited, so has no history; the moved one keeps its own
+	if revisions, err := st.Revisions(MessageKey{ID: "MSG1", ChatJID: mergeInto}); err != nil || len(revisions) != 0 {
+		t.Errorf("revisions of the kept copy %+v, %v, want none", revisions, err)
+	}
+	if revisions, err := st.Revisions(MessageKey{ID: "MSG2", ChatJID: mergeInto}); err != nil || len(revisions) != 1 || revisions[0].Content != "only in from" {
+		t.Errorf("revisions of the moved message %+v, %v", revisions, err)
+	}
+	if n := countRows(t, st, "message_revisions", "chat_jid", mergeFrom); n != 0 {
+		t.Errorf("%d revisions left in the merged chat", n)
+	}
+	if a, ok, err := st.GetAvatar(mergeInto); err != nil || !ok || a.PictureID != "into" {
+		t.Errorf("avatar %+v, %v, %v, want the merged-into chat's", a, ok, err)
+	}
+	if n := countRows(t, st, "avatars", "jid", mergeFrom); n != 0 {
+		t.Errorf("merged chat's avatar left behind")
+	}
+	if g, err := st.GetGroup(mergeInto); err != nil || g.Subject != "Book club" {
+		t.Errorf("group %+v, %v, want it moved", g, err)
+	}
+}
+
+func TestZeroTimeStoredAsNull(t *testing.T) {
+	st := openTestStore(t)
+	if err := st.StoreChat(mergeInto, "Alice", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	var stored interface{}
+	if err := st.db.QueryRow(`SELECT last_message_time FROM chats WHERE jid = ?`, mergeInto).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != nil {
+		t.Errorf("zero time stored as %v, want NULL", stored)
+	}
+	chats, err := st.ListChats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chats) != 1 || !chats[0].LastMessageTime.IsZero() {
+		t.Fatalf("chats %+v, want one with a zero last message time", chats)
+	}
+
+	// Storing the chat again without a time keeps the one it has
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	if err := st.StoreChat(mergeInto, "Alice", at); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.StoreChat(mergeInto, "Alice B", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if chats, err = st.ListChats(); err != nil {
+		t.Fatal(err)
+	}
+	if !chats[0].LastMessageTime.Equal(at) {
+		t.Errorf("last message time %v, want %v", chats[0].LastMessageTime, at)
+	}
+}
+
+func TestEpochTimestampsNullsZeroTimes(t *testing.T) {
+	path := t.TempDir() + "/messages.db"
+	st, err := OpenSQLite(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As written before unknown times were stored as NULL
+	for _, q := range []string{
+		`INSERT INTO chats (jid, name, last_message_time) VALUES ('` + mergeInto + `', 'Alice', -62135596800000)`,
+		`INSERT INTO chats (jid, name, last_message_time) VALUES ('` + mergeFrom + `', 'Bob', '0001-01-01 00:00:00+00:00')`,
+		`PRAGMA user_version = 0`,
+	} {
+		if _, err := st.writer.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st.Close()
+
+	if st, err = OpenSQLite(path, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	var left int
+	if err := st.db.QueryRow(`SELECT COUNT(*) FROM chats WHERE last_message_time IS NOT NULL`).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("%d zero times left after migrating", left)
+	}
+}
+
+func TestEncryptionSealsImageText(t *testing.T) {
+	path := t.TempDir() + "/messages.db"
+	st, err := OpenSQLite(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	first, second := MessageKey{ID: "MSG1", ChatJID: mergeInto}, MessageKey{ID: "MSG2", ChatJID: mergeInto}
+	if err := st.StoreChat(mergeInto, "Alice", at); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []MessageKey{first, second} {
+		if err := st.StoreMessage(key.ID, key.ChatJID, "", "a receipt", at, false, "image", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.StoreOCRText(first, "total 12.50"); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	openSealed := func() *SQLiteStore {
+		t.Helper()
+		st, err := OpenSQLite(path, Options{Key: "correct horse"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+	stored := func(st *SQLiteStore, key MessageKey) string {
+		t.Helper()
+		var text string
+		if err := st.db.QueryRow(`SELECT ocr_text FROM messages WHERE id = ? AND chat_jid = ?`, key.ID, key.ChatJID).Scan(&text); err != nil {
+			t.Fatal(err)
+		}
+		return text
+	}
+
+	// Encrypting seals text already stored, and text stored afterwards
+	st = openSealed()
+	if err := st.StoreOCRText(second, "total 8.00"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[MessageKey]string{first: "total 12.50", second: "total 8.00"} {
+		if text := stored(st, key); !strings.HasPrefix(text, sealedPrefix) {
+			t.Errorf("ocr_text of %s stored as %q", key.ID, text)
+		}
+		m, err := st.GetMessage(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.OCRText != want {
+			t.Errorf("ocr_text of %s = %q, want %q", key.ID, m.OCRText, want)
+		}
+	}
+
+	// As left by an archive encrypted before image text was sealed
+	plain, err := st.GetMessage(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.db.Exec(`UPDATE messages SET ocr_text = ? WHERE id = ?`, plain.OCRText, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.db.Exec(`UPDATE encryption SET sealed_version = 0`); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	st = openSealed()
+	defer st.Close()
+	if text := stored(st, first); !strings.HasPrefix(text, sealedPrefix) {
+		t.Errorf("ocr_text left as %q after unlocking", text)
+	}
+}
+
+func TestEncryptionIgnoresLookalikeText(t *testing.T) {
+	path := t.TempDir() + "/messages.db"
+	st, err := OpenSQLite(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := MessageKey{ID: "MSG1", ChatJID: mergeInto}
+	text := sealedPrefix + "not actually sealed"
+	if err := st.StoreChat(mergeInto, "Alice", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.StoreMessage(key.ID, key.ChatJID, "", text, time.Now(), false, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.StoreRawMessage(key, []byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	read := func(st *SQLiteStore) {
+		t.Helper()
+		m, err := st.GetMessage(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Content != text {
+			t.Errorf("content = %q, want %q", m.Content, text)
+		}
+		err = st.ForEachRawMessage(key.ChatJID, func(_ Message, data []byte) error {
+			if string(data) != text {
+				t.Errorf("raw payload = %q, want %q", data, text)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	read(st)
+	st.Close()
+
+	st, err = OpenSQLite(path, Options{Key: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	var stored string
+	if err := st.db.QueryRow(`SELECT content FROM messages WHERE id = ?`, key.ID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored == text {
+		t.Errorf("content left as %q after encrypting", stored)
+	}
+	read(st)
+}
+
+func TestStoreChatKeepsNameAndLatestTime(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	chat := func() (name string, last time.Time) {
+		t.Helper()
+		var stored sql.NullTime
+		if err := st.db.QueryRow(`SELECT name, last_message_time FROM chats WHERE jid = ?`, mergeInto).Scan(&name, &stored); err != nil {
+			t.Fatal(err)
+		}
+		return name, stored.Time
+	}
+	for _, step := range []struct {
+		name     string
+		at       time.Time
+		wantName string
+		wantAt   time.Time
+	}{
+		{mergeInto, at, mergeInto, at},
+		{"Alice", at, "Alice", at},
+		{mergeInto, at.Add(time.Hour), "Alice", at.Add(time.Hour)},
+		{"", at.Add(-time.Hour), "Alice", at.Add(time.Hour)},
+		{"Alice Smith", time.Time{}, "Alice Smith", at.Add(time.Hour)},
+	} {
+		if err := st.StoreChat(mergeInto, step.name, step.at); err != nil {
+			t.Fatal(err)
+		}
+		if name, last := chat(); name != step.wantName || !last.Equal(step.wantAt) {
+			t.Errorf("after StoreChat(%q, %v): name %q, last message %v; want %q, %v",
+				step.name, step.at, name, last, step.wantName, step.wantAt)
+		}
+	}
+}
+
+func TestStoreMessageKeepsTimestamp(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	if err := st.StoreChat(mergeInto, "Alice", at); err != nil {
+		t.Fatal(err)
+	}
+	key := MessageKey{ID: "MSG1", ChatJID: mergeInto}
+	if err := st.StoreMessage(key.ID, key.ChatJID, "", "hello", at, false, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	// A re-delivery without a time keeps the one stored
+	if err := st.StoreMessage(key.ID, key.ChatJID, "", "hello", time.Time{}, false, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	m, err := st.GetMessage(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Timestamp.Equal(at) {
+		t.Errorf("timestamp %v after storing again without one, want %v", m.Timestamp, at)
+	}
+
+	// One with a time corrects it
+	later := at.Add(time.Hour)
+	if err := st.StoreMessage(key.ID, key.ChatJID, "", "hello", later, false, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = st.GetMessage(key); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Timestamp.Equal(later) {
+		t.Errorf("timestamp %v, want %v", m.Timestamp, later)
+	}
+}
@@ -0,0 +1,263 @@
+// Package store persists WhatsApp chats and messages for Kenny.
+package store
+
+import "time"
+
+// Store covers every read and write the logger and CLI perform against the
+// message archive. Handlers only ever talk to this interface, so alternative
+// engines can be dropped in without touching event handling.
+type Store interface {
+	// Insert or update a chat row
+	StoreChat(jid, name string, lastMessageTime time.Time) error
+	// Insert or update a message row
+	StoreMessage(id, chatJID, sender, content string, timestamp time.Time, isFromMe bool, mediaType, filename, url string) error
+	// Most recent messages in a chat and the chats linked to it, newest first
+	QueryMessages(chatJID string, limit int) ([]Message, error)
+	// All chats, most recently active first; linked chats are folded into
+	// the one they continue
+	ListChats() ([]Chat, error)
+	// Record that chat from continues as chat to, so queries treat them as one
+	LinkChats(from, to string) error
+	// Undo LinkChats for jid
+	UnlinkChat(jid string) error
+	// Fold chat from into chat into, moving its messages, and delete it
+	MergeChats(from, into string) error
+	// Every JID of jid's conversation, canonical first
+	ChatGroup(jid string) ([]string, error)
+	// A single stored message, or ErrMessageNotFound
+	GetMessage(key MessageKey) (Message, error)
+	// A stored message and the one it replies to, nil when it quotes none
+	// or the quoted message is not stored
+	GetMessageWithParent(key MessageKey) (m Message, parent *Message, err error)
+	// The reply chain a message belongs to: what it quotes, back to the
+	// start, and every reply below it
+	Thread(key MessageKey) (Thread, error)
+	// Messages received in a chat since I last sent one there, newest first,
+	// at most limit of them
+	UnreadMessages(chatJID string, limit int) ([]Message, error)
+	// Chats with messages to me since since that I haven't answered, whose
+	// oldest unanswered one is from before, waiting longest first
+	Unreplied(since, before time.Time) ([]UnrepliedChat, error)
+	// Messages matching every set field of f, newest first
+	ListMessages(f MessageFilter) ([]Message, error)
+	// Messages whose content or image text contains query, newest first
+	SearchMessages(query string, limit int) ([]Message, error)
+	// Messages matching a full-text search of their content and image text,
+	// best match first, each with a snippet around the match
+	Search(query string, f MessageFilter) ([]SearchResult, error)
+	// Call fn for each message with since <= timestamp < until, oldest first.
+	// A zero until means no upper bound. Returning an error from fn stops the walk.
+	ForEachMessage(since, until time.Time, fn func(Message) error) error
+	// Keep the marshaled proto a stored message arrived as
+	StoreRawMessage(key MessageKey, data []byte) error
+	// Call fn for each message in chatJID and the chats linked to it that
+	// has a raw payload, with the payload, oldest first; an empty chatJID
+	// means all chats. Returning an error from fn stops the walk.
+	ForEachRawMessage(chatJID string, fn func(Message, []byte) error) error
+	// Replace the content, media type and filename extracted from a stored
+	// message, keeping the content of edited ones
+	UpdateExtracted(key MessageKey, content, mediaType, filename string) error
+	// Remove the given messages, returning how many existed
+	DeleteMessages(keys []MessageKey) (int, error)
+	// How many messages in chatJID are older than before, not counting
+	// bookmarked ones
+	CountMessagesBefore(chatJID string, before time.Time) (int, error)
+	// Delete the messages CountMessagesBefore counts, returning how many went
+	DeleteMessagesBefore(chatJID string, before time.Time) (int, error)
+	// Bookmark a stored message, replacing the note if already bookmarked
+	SetBookmark(key MessageKey, note string) error
+	// Remove a bookmark; removing one that does not exist is not an error
+	RemoveBookmark(key MessageKey) error
+	// Bookmarked messages, newest bookmark first; an empty chatJID means all chats
+	ListBookmarks(chatJID string) ([]Bookmark, error)
+	// Record the ID and sender of the message a stored message quotes
+	StoreReply(key MessageKey, replyTo, replySender string) error
+	// Mark a stored message forwarded, with its forwarding score
+	StoreForwarded(key MessageKey, score int) error
+	// Mark a stored message as mentioning me
+	StoreMentionsMe(key MessageKey) error
+	// Keep the JSON payload of a stored business message with buttons, a
+	// list or a template
+	StoreInteractive(key MessageKey, payload []byte) error
+	// Record where a stored location message points
+	StoreLocation(key MessageKey, l Location) error
+	// Location messages, newest first; an empty chatJID means all chats
+	ListLocations(chatJID string) ([]Message, error)
+	// Record attachment metadata on a stored message
+	StoreMedia(key MessageKey, m Media) error
+	// Media messages of mediaType (any type when empty), newest first; an
+	// empty chatJID means all chats
+	ListMedia(chatJID, mediaType string) ([]MediaFile, error)
+	// Point a message at the file holding its attachment, recording the file
+	SetMediaFile(key MessageKey, b MediaBlob) error
+	// Stored files not yet evicted, least recently used first
+	MediaBlobs() ([]MediaBlob, error)
+	// Detach a deleted file from its messages, keeping the metadata
+	EvictMediaBlob(sha256 string) error
+	// Stored file with this hex SHA-256; ok is false when there is none
+	FindMediaBlob(sha256 string) (b MediaBlob, ok bool, err error)
+	// Record the pack metadata of the sticker file with this hex SHA-256
+	StoreSticker(sha256 string, s Sticker) error
+	// Record the text read from a message's image ("" when it has none)
+	StoreOCRText(key MessageKey, text string) error
+	// Downloaded images not yet run through OCR, newest first; an empty
+	// chatJID means all chats
+	PendingOCR(chatJID string) ([]Message, error)
+	// Count a failed attempt to download a message's attachment
+	RecordMediaFailure(key MessageKey, reason string) error
+	// Media messages that can be downloaded but are not yet, newest first,
+	// leaving out those that failed maxAttempts times (0: no limit)
+	PendingMedia(chatJID string, maxAttempts int) ([]PendingMedia, error)
+	// Note that jid was seen using name at the given time
+	StoreContactName(jid, name string, seen time.Time) error
+	// Names jid has been seen under, oldest first
+	ContactNames(jid string) ([]ContactName, error)
+	// Contacts saved or seen under name, ignoring case, best match first
+	ContactJIDs(name string) ([]string, error)
+	// Stored contacts and the names known for them, by JID
+	ListContacts() ([]Contact, error)
+	// Log a presence update, reporting whether it changed the contact's
+	// state; repeats of the current state are not logged
+	LogPresence(p PresenceChange) (bool, error)
+	// A contact's presence changes since the given time, oldest first
+	PresenceLog(jid string, since time.Time) ([]PresenceChange, error)
+	// Mirror a chat's mute setting from the phone; a nil until means until
+	// unmuted
+	SetChatMuted(jid string, muted bool, until *time.Time) error
+	// Mirror a chat's archived and pinned state from the phone
+	SetChatArchived(jid string, archived bool) error
+	SetChatPinned(jid string, pinned bool) error
+	// Whether a chat is muted at the given time
+	ChatMuted(jid string, at time.Time) (bool, error)
+	// Record or replace the profile picture saved for a contact or group
+	StoreAvatar(a Avatar) error
+	// The profile picture saved for jid; ok is false when never fetched
+	GetAvatar(jid string) (a Avatar, ok bool, err error)
+	// Update the names known for a contact, keeping stored ones where c
+	// leaves a field empty. Chats and messages are named from these.
+	StoreContact(c Contact) error
+	// Record the phone-number JIDs of LIDs. Chats, senders and contacts
+	// under a mapped LID are then treated as those of its phone number.
+	StoreLIDMappings(mappings []LIDMapping) error
+	// The phone-number JID a LID maps to; ok is false when none is known
+	PhoneJIDForLID(lid string) (jid string, ok bool, err error)
+	// Schedule a reminder, returning its ID
+	AddReminder(r Reminder) (int64, error)
+	// Pending reminders, soonest first
+	ListReminders() ([]Reminder, error)
+	// Cancel or retire a reminder, reporting whether it existed
+	DeleteReminder(id int64) (bool, error)
+	// Queue a message to send at m.SendAt, returning its ID
+	QueueOutbox(m OutboxMessage) (int64, error)
+	// Pending outbox messages whose next attempt is due by now, oldest first
+	DueOutbox(now time.Time) ([]OutboxMessage, error)
+	// Outbox messages, soonest first; sent and failed ones only when all is set
+	ListOutbox(all bool) ([]OutboxMessage, error)
+	// Mark an outbox message sent
+	MarkOutboxSent(id int64, at time.Time) error
+	// Count a failed send of an outbox message, retrying at next, or
+	// giving up when next is zero
+	RecordOutboxFailure(id int64, reason string, next time.Time) error
+	// Drop a pending outbox message, reporting whether there was one
+	CancelOutbox(id int64) (bool, error)
+	// Hold a message until it is approved, returning its ID
+	AddPendingSend(p PendingSend) (int64, error)
+	// A held message; ok is false when there is none with this ID
+	GetPendingSend(id int64) (p PendingSend, ok bool, err error)
+	// Held messages, oldest first; decided ones only when all is set
+	ListPendingSends(all bool) ([]PendingSend, error)
+	// Approve or reject a held message, reporting whether it was still pending
+	DecidePendingSend(id int64, status string, at time.Time) (bool, error)
+	// Record a membership, pin or settings change in a chat
+	StoreChatEvent(e ChatEvent) error
+	// Events in a chat with since <= time < until, oldest first
+	ChatEvents(chatJID string, since, until time.Time) ([]ChatEvent, error)
+	// Store a group change as a message in its chat's timeline; m.System
+	// must be set
+	StoreSystemMessage(m Message) error
+	// Apply an edit to a stored message, keeping its previous content as a
+	// revision; ErrMessageNotFound if the message was never stored
+	EditMessage(key MessageKey, content string, at time.Time) error
+	// Mark a message deleted for everyone by the JID by, at tombstone's
+	// timestamp; tombstone is stored in its place if it was never seen
+	RevokeMessage(tombstone Message, by string) error
+	// Earlier contents of an edited message, oldest first
+	Revisions(key MessageKey) ([]Revision, error)
+	// Record the URLs a stored message shares, replacing earlier ones
+	StoreLinks(key MessageKey, links []SharedLink) error
+	// Shared URLs matching f, newest message first
+	ListLinks(f LinkFilter) ([]SharedLink, error)
+	// Record the contact cards a stored message shares, replacing earlier ones
+	StoreSharedContacts(key MessageKey, contacts []SharedContact) error
+	// Shared contact cards, newest first; an empty chatJID means all chats
+	ListSharedContacts(chatJID string) ([]SharedContact, error)
+	// Record the group invite a stored message carries
+	StoreGroupInvite(key MessageKey, inv GroupInvite) error
+	// The invite sent in the message with this key, or ErrMessageNotFound
+	GetGroupInvite(key MessageKey) (GroupInvite, error)
+	// Group invites, newest first; an empty chatJID means all chats
+	ListGroupInvites(chatJID string) ([]GroupInvite, error)
+	// Replace a group's metadata and member list with g
+	StoreGroup(g Group) error
+	// A group's metadata and members, or ErrChatNotFound when never synced
+	GetGroup(jid string) (Group, error)
+	// Synced groups, by subject
+	ListGroups() ([]Group, error)
+	// A community's JID followed by those of its synced groups, or
+	// ErrChatNotFound unless jid is a synced community
+	CommunityChats(jid string) ([]string, error)
+	// The most recent messages across a community's groups
+	QueryCommunity(jid string, limit int) ([]Message, error)
+	// Record membership changes; one already recorded for the same member,
+	// kind and second is ignored, so live events and history overlap safely
+	StoreMembershipChanges(changes []MembershipChange) error
+	// Membership changes in a group, oldest first; an empty member means
+	// everyone's
+	MembershipChanges(groupJID, member string) ([]MembershipChange, error)
+	// Members of a group at the given time, worked back from its synced
+	// member list through the changes since; ErrChatNotFound when never
+	// synced
+	GroupMembersAt(groupJID string, at time.Time) ([]string, error)
+	// Store a poll created in a chat
+	StorePoll(p Poll) error
+	// The poll started by the message with this key, or ErrMessageNotFound
+	GetPoll(key MessageKey) (Poll, error)
+	// Polls, newest first; an empty chatJID means all chats
+	ListPolls(chatJID string) ([]Poll, error)
+	// Record one person's vote, replacing their earlier one
+	StorePollVote(v PollVote) error
+	// Current votes in the poll with this key, oldest first
+	PollVotes(key MessageKey) ([]PollVote, error)
+	// Record a call when it rings; a call already stored is left alone
+	StoreCall(c Call) error
+	// Mark a ringing call answered
+	AcceptCall(id string, at time.Time) error
+	// Mark a call over, rejected or hung up, working out its outcome and
+	// duration; calls never stored are ignored
+	EndCall(id string, at time.Time, rejected bool) error
+	// Calls, newest first; an empty chatJID means all chats
+	ListCalls(chatJID string) ([]Call, error)
+	// Record or replace one person's reaction to a message; an empty Emoji
+	// records that they removed it
+	StoreReaction(r Reaction) error
+	// Current reactions to a message, oldest first
+	Reactions(key MessageKey) ([]Reaction, error)
+	// Record that recipient got, or with read set read, the messages with
+	// these IDs in chatJID at the given time. Reading implies delivery;
+	// earlier times are kept.
+	StoreReceipt(chatJID, recipient string, ids []string, at time.Time, read bool) error
+	// Delivery and read times of a message I sent, one per recipient
+	Receipts(key MessageKey) ([]Receipt, error)
+	// Remember the preview shown for a shared URL
+	StoreLinkPreview(p LinkPreview) error
+	// Previews known for the given URLs, keyed by URL
+	LinkPreviews(urls []string) (map[string]LinkPreview, error)
+	// Total number of stored messages
+	MessageCount() (int, error)
+	// Total number of stored chats
+	ChatCount() (int, error)
+	// Whether message text is encrypted at rest
+	Encrypted() bool
+	// Release the underlying connection
+	Close() error
+}
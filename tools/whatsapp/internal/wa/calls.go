@@ -0,0 +1,69 @@
+package wa
+
+import (
+	"time"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Record a call as it starts ringing. video reports whether it is a video
+// call.
+func (w *Logger) handleCallOffer(meta types.BasicCallMeta, video bool) {
+	chat := meta.From.ToNonAD()
+	isGroup := !meta.GroupJID.IsEmpty()
+	if isGroup {
+		chat = meta.GroupJID
+	}
+	caller := meta.CallCreator
+	if caller.IsEmpty() {
+		caller = meta.From
+	}
+	err := w.store.StoreCall(store.Call{
+		ID:        meta.CallID,
+		ChatJID:   chat.String(),
+		Caller:    caller.ToNonAD().String(),
+		IsFromMe:  caller.User == w.ownUser(),
+		IsVideo:   video,
+		IsGroup:   isGroup,
+		StartedAt: callTime(meta),
+	})
+	if err != nil {
+		w.log.Warnf("Failed to store call: %v", err)
+	}
+}
+
+// Record a call being answered, rejected or hung up
+func (w *Logger) handleCallUpdate(evt interface{}) {
+	var err error
+	switch v := evt.(type) {
+	case *events.CallAccept:
+		err = w.store.AcceptCall(v.CallID, callTime(v.BasicCallMeta))
+	case *events.CallReject:
+		err = w.store.EndCall(v.CallID, callTime(v.BasicCallMeta), true)
+	case *events.CallTerminate:
+		err = w.store.EndCall(v.CallID, callTime(v.BasicCallMeta), false)
+	}
+	if err != nil {
+		w.log.Warnf("Failed to update call: %v", err)
+	}
+}
+
+// Whether a call offer includes video
+func offersVideo(offer *waBinary.Node) bool {
+	if offer == nil {
+		return false
+	}
+	_, ok := offer.GetOptionalChildByTag("video")
+	return ok
+}
+
+func callTime(meta types.BasicCallMeta) time.Time {
+	if meta.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return meta.Timestamp
+}
@@ -0,0 +1,108 @@
+package wa
+
+import (
+	"errors"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/store"
+)
+
+// Recent posts fetched from each followed channel on connect
+const channelBackfill = 50
+
+// Store the channels the account follows under their names, with their
+// recent posts. Channels send no history sync, so this is their backfill.
+func (w *Logger) syncChannels() {
+	channels, err := w.client.GetSubscribedNewsletters()
+	if err != nil {
+		w.log.Warnf("Failed to list followed channels: %v", err)
+		return
+	}
+	for _, c := range channels {
+		name := c.ThreadMeta.Name.Text
+		if name == "" {
+			name = c.ID.String()
+		}
+		w.channels.Store(c.ID.String(), name)
+
+		posts, err := w.client.GetNewsletterMessages(c.ID, &whatsmeow.GetNewsletterMessagesParams{Count: channelBackfill})
+		if err != nil {
+			w.log.Warnf("Failed to fetch posts of channel %s: %v", name, err)
+		}
+		latest := c.ThreadMeta.CreationTime.Time
+		for _, p := range posts {
+			if p.Timestamp.After(latest) {
+				latest = p.Timestamp
+			}
+		}
+		if err := w.store.StoreChat(c.ID.String(), name, latest); err != nil {
+			w.log.Warnf("Failed to store channel: %v", err)
+			continue
+		}
+		stored := 0
+		for _, p := range posts {
+			if p.Message == nil {
+				continue
+			}
+			if w.storeChannelPost(c.ID, p) {
+				stored++
+			}
+		}
+		w.log.Infof("Synced channel %s: %d recent posts", name, stored)
+	}
+}
+
+// Store a post fetched from a channel, reporting whether it was stored.
+// Like history sync messages, fetched posts trigger no hooks.
+func (w *Logger) storeChannelPost(channel types.JID, p *types.NewsletterMessage) bool {
+	content, mediaType, filename := extract.Content(p.Message)
+	key := store.MessageKey{ID: p.MessageID, ChatJID: channel.String()}
+	err := w.store.StoreMessage(key.ID, key.ChatJID, key.ChatJID, content, p.Timestamp, false, mediaType, filename, "")
+	if err != nil {
+		w.log.Warnf("Failed to store channel post: %v", err)
+		return false
+	}
+	w.storeRaw(key, p.Message)
+	w.storeLinkPreview(p.Message)
+	w.storeLinks(key, p.Message)
+	w.storeLocation(key, p.Message)
+	w.storeInteractive(key, p.Message)
+	if attachment, ok := extract.Media(p.Message); ok {
+		if err := w.store.StoreMedia(key, attachment); err != nil {
+			w.log.Warnf("Failed to store media metadata: %v", err)
+		}
+	}
+	return true
+}
+
+// The name of a followed channel, or its JID when not yet known
+func (w *Logger) channelName(jid string) string {
+	if name, ok := w.channels.Load(jid); ok {
+		return name.(string)
+	}
+	return jid
+}
+
+// Apply a channel post edit, which arrives as the full new post under the
+// original ID rather than wrapped like other edits. Reports whether msg was
+// one.
+func (w *Logger) handleChannelEdit(msg *events.Message) bool {
+	if msg.NewsletterMeta == nil || msg.NewsletterMeta.EditTS.IsZero() {
+		return false
+	}
+	content, _, _ := extract.Content(msg.Message)
+	key := store.MessageKey{ID: msg.Info.ID, ChatJID: msg.Info.Chat.String()}
+	err := w.store.EditMessage(key, content, msg.NewsletterMeta.EditTS)
+	switch {
+	case errors.Is(err, store.ErrMessageNotFound):
+		// The original post was never seen; keep the edited one as new
+		return false
+	case err != nil:
+		w.log.Warnf("Failed to store channel edit: %v", err)
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package wa
+
+import (
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Mirror a chat muted or unmuted on the phone. A mute with no end time
+// lasts until unmuted.
+func (w *Logger) handleMute(v *events.Mute) {
+	var until *time.Time
+	if end := v.Action.GetMuteEndTimestamp(); end > 0 {
+		t := time.UnixMilli(end)
+		until = &t
+	}
+	if err := w.store.SetChatMuted(v.JID.String(), v.Action.GetMuted(), until); err != nil {
+		w.log.Warnf("Failed to store chat mute: %v", err)
+	}
+}
+
+// Mirror a chat archived or unarchived on the phone
+func (w *Logger) handleArchive(v *events.Archive) {
+	if err := w.store.SetChatArchived(v.JID.String(), v.Action.GetArchived()); err != nil {
+		w.log.Warnf("Failed to store chat archive state: %v", err)
+	}
+}
+
+// Mirror a chat pinned or unpinned on the phone
+func (w *Logger) handleChatPin(v *events.Pin) {
+	if err := w.store.SetChatPinned(v.JID.String(), v.Action.GetPinned()); err != nil {
+		w.log.Warnf("Failed to store chat pin: %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package wa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/phone"
+	"whatsapp-logger/internal/store"
+)
+
+// How long fetching one profile picture may take
+const avatarTimeout = 30 * time.Second
+
+// Save profile pictures of contacts and groups seen in live messages under
+// dir
+func (w *Logger) SetMediaDir(dir string) {
+	w.mediaDir = dir
+}
+
+// How long reading the session's contact store may take
+const contactsTimeout = 30 * time.Second
+
+// Record the push name a contact used, for whois name history and as the
+// contact's current push name
+func (w *Logger) storeContactName(jid types.JID, name string, seen time.Time) {
+	if name == "" || jid.User == "" {
+		return
+	}
+	if err := w.store.StoreContactName(jid.ToNonAD().String(), name, seen); err != nil {
+		w.log.Warnf("Failed to store contact name: %v", err)
+	}
+	w.storeContact(store.Contact{JID: jid.ToNonAD().String(), PushName: name})
+}
+
+// Update the names stored for a contact
+func (w *Logger) storeContact(c store.Contact) {
+	if err := w.store.StoreContact(c); err != nil {
+		w.log.Warnf("Failed to store contact: %v", err)
+	}
+}
+
+// Copy the contacts whatsmeow already knows into the archive
+func (w *Logger) syncContacts() {
+	if w.client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), contactsTimeout)
+	defer cancel()
+	n, err := w.storeAllContacts(ctx)
+	if err != nil {
+		w.log.Warnf("%v", err)
+		return
+	}
+	w.log.Infof("Synced %d contacts", n)
+}
+
+// Pull the whole contact list from the phone's app state, rather than
+// waiting for the sync WhatsApp starts on its own, and store every contact.
+// Returns how many were stored.
+func (w *Logger) SyncContacts(ctx context.Context) (int, error) {
+	if !w.Connected() {
+		return 0, ErrNotConnected
+	}
+	if err := w.client.FetchAppState(ctx, appstate.WAPatchCriticalUnblockLow, true, false); err != nil {
+		return 0, fmt.Errorf("failed to fetch contact list: %w", err)
+	}
+	return w.storeAllContacts(ctx)
+}
+
+// Store every contact whatsmeow knows, from the address book sync and the
+// push and business names it has cached, with the phone number of those
+// known by LID
+func (w *Logger) storeAllContacts(ctx context.Context) (int, error) {
+	contacts, err := w.client.Store.Contacts.GetAllContacts(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read contacts: %w", err)
+	}
+	for jid, c := range contacts {
+		contact := store.Contact{
+			JID:          jid.ToNonAD().String(),
+			FullName:     c.FullName,
+			FirstName:    c.FirstName,
+			PushName:     c.PushName,
+			BusinessName: c.BusinessName,
+		}
+		if jid.Server == types.HiddenUserServer {
+			if pn, err := w.client.Store.LIDs.GetPNForLID(ctx, jid); err == nil && !pn.IsEmpty() {
+				contact.Phone = phone.FromJID(pn.String())
+				if m, ok := lidMapping(jid, pn); ok {
+					w.storeLIDMappings([]store.LIDMapping{m})
+				}
+			}
+		}
+		w.storeContact(contact)
+	}
+	return len(contacts), nil
+}
+
+// Record a contact saved or renamed in the address book
+func (w *Logger) handleContact(v *events.Contact) {
+	w.storeContact(store.Contact{
+		JID:       v.JID.ToNonAD().String(),
+		FullName:  v.Action.GetFullName(),
+		FirstName: v.Action.GetFirstName(),
+	})
+}
+
+// Record the push names a history sync carries
+func (w *Logger) storePushnames(pushnames []*waHistorySync.Pushname) {
+	now := time.Now()
+	for _, p := range pushnames {
+		jid, err := types.ParseJID(p.GetID())
+		if err != nil {
+			continue
+		}
+		w.storeContactName(jid, p.GetPushname(), now)
+	}
+}
+
+// Refresh a contact's or group's saved profile picture, at most once per
+// run unless WhatsApp reports a change
+func (w *Logger) refreshAvatar(jid types.JID) {
+	if w.mediaDir == "" || w.client == nil || jid.User == "" {
+		return
+	}
+	jid = jid.ToNonAD()
+	if _, seen := w.avatars.LoadOrStore(jid.String(), true); seen {
+		return
+	}
+	go func() {
+		if err := w.saveAvatar(jid); err != nil {
+			w.log.Debugf("No profile picture saved for %s: %v", jid, err)
+		}
+	}()
+}
+
+// Refetch a profile picture WhatsApp reports changed or removed
+func (w *Logger) avatarChanged(jid types.JID) {
+	w.avatars.Delete(jid.ToNonAD().String())
+	w.refreshAvatar(jid)
+}
+
+// Download jid's current profile picture into the media directory, unless
+// the saved one is still current. The picture ID WhatsApp reports tells
+// whether it changed.
+func (w *Logger) saveAvatar(jid types.JID) error {
+	now := time.Now()
+	prev, ok, err := w.store.GetAvatar(jid.String())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		prev = store.Avatar{JID: jid.String()}
+	}
+	existing := prev.PictureID
+	if _, err := os.Stat(prev.Path); err != nil {
+		// The saved file is gone; fetch it again
+		existing = ""
+	}
+
+	info, err := w.client.GetProfilePictureInfo(jid, &whatsmeow.GetProfilePictureParams{ExistingID: existing})
+	if errors.Is(err, whatsmeow.ErrProfilePictureNotSet) {
+		if prev.Path != "" {
+			if err := os.Remove(prev.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if prev.PictureID != "" || !ok {
+			prev.ChangedAt = now
+		}
+		prev.PictureID, prev.Path, prev.CheckedAt = "", "", now
+		return w.store.StoreAvatar(prev)
+	}
+	if err != nil {
+		return err
+	}
+	if info == nil && existing != "" {
+		prev.CheckedAt = now
+		return w.store.StoreAvatar(prev)
+	}
+	if info == nil || info.URL == "" {
+		return fmt.Errorf("profile picture hidden")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), avatarTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, info.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("profile picture download: %s", resp.Status)
+	}
+
+	path := media.AvatarPath(w.mediaDir, jid.User)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return w.store.StoreAvatar(store.Avatar{JID: jid.String(), PictureID: info.ID, Path: path, ChangedAt: now, CheckedAt: now})
+}
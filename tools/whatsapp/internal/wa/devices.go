@@ -0,0 +1,117 @@
+package wa
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// A device linked to the account
+type Device struct {
+	JID string `json:"jid"`
+	// Device 0 is the phone itself
+	Primary bool `json:"primary"`
+	// The session this logger runs on
+	Current bool `json:"current"`
+	// Keys for the device are in the session database, so it can be unlinked from here
+	Local    bool   `json:"local"`
+	Platform string `json:"platform,omitempty"`
+	PushName string `json:"push_name,omitempty"`
+}
+
+// List the account's linked devices as WhatsApp reports them, plus any
+// sessions in the session database that WhatsApp no longer knows about
+func (w *Logger) Devices(ctx context.Context) ([]Device, error) {
+	if !w.Paired() {
+		return nil, ErrNotPaired
+	}
+	if !w.client.IsLoggedIn() {
+		return nil, fmt.Errorf("cannot list devices: %w", ErrNotConnected)
+	}
+	own := *w.client.Store.ID
+	linked, err := w.client.GetUserDevicesContext(ctx, []types.JID{own.ToNonAD()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	byJID := map[types.JID]*Device{}
+	add := func(jid types.JID) *Device {
+		if d, ok := byJID[jid]; ok {
+			return d
+		}
+		d := &Device{JID: jid.String(), Primary: jid.Device == 0}
+		byJID[jid] = d
+		return d
+	}
+	for _, jid := range linked {
+		add(jid)
+	}
+	add(own).Current = true
+
+	local, err := w.container.GetAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions: %w", err)
+	}
+	for _, dev := range local {
+		if dev.ID == nil || dev.ID.User != own.User {
+			continue
+		}
+		d := add(*dev.ID)
+		d.Local = true
+		d.Platform = dev.Platform
+		d.PushName = dev.PushName
+	}
+
+	devices := make([]Device, 0, len(byJID))
+	jids := make([]types.JID, 0, len(byJID))
+	for jid := range byJID {
+		jids = append(jids, jid)
+	}
+	sort.Slice(jids, func(i, j int) bool { return jids[i].Device < jids[j].Device })
+	for _, jid := range jids {
+		devices = append(devices, *byJID[jid])
+	}
+	return devices, nil
+}
+
+// Unlink a device whose session is in the session database and delete the
+// session. Only the phone can unlink other companions, so devices paired
+// elsewhere must be removed from its Linked devices screen. Unlinking the
+// current device logs this logger out.
+func (w *Logger) UnlinkDevice(ctx context.Context, jid string) error {
+	target, err := types.ParseJID(jid)
+	if err != nil {
+		return fmt.Errorf("invalid device JID %q: %w", jid, err)
+	}
+	if w.Paired() && *w.client.Store.ID == target {
+		return w.client.Logout(ctx)
+	}
+
+	local, err := w.container.GetAllDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read sessions: %w", err)
+	}
+	for _, dev := range local {
+		if dev.ID == nil || *dev.ID != target {
+			continue
+		}
+		// Log in as the stale session just long enough to remove itself
+		client := whatsmeow.NewClient(dev, waLog.Stdout("Unlink", "WARN", true))
+		if err := client.Connect(); err != nil {
+			return fmt.Errorf("failed to connect as %s: %w", jid, err)
+		}
+		if !client.WaitForConnection(30 * time.Second) {
+			// WhatsApp already dropped the session; only the local keys remain
+			client.Disconnect()
+			w.log.Infof("Session %s is no longer linked, deleting it locally", jid)
+			return dev.Delete(ctx)
+		}
+		return client.Logout(ctx)
+	}
+	return fmt.Errorf("cannot unlink %s: %w; remove it from Linked devices on your phone", jid, ErrNoSession)
+}
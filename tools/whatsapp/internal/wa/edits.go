@@ -0,0 +1,68 @@
+package wa
+
+import (
+	"errors"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/store"
+)
+
+// Apply an edit to the message it targets rather than storing it as a
+// message of its own. Reports whether m was one.
+func (w *Logger) handleEdit(chat types.JID, m *waE2E.Message, sent time.Time) bool {
+	target, edited, at, ok := extract.Edit(m)
+	if !ok {
+		return false
+	}
+	if at.IsZero() {
+		at = sent
+	}
+	content, _, _ := extract.Content(edited)
+	key := store.MessageKey{ID: target, ChatJID: chat.String()}
+	err := w.store.EditMessage(key, content, at)
+	switch {
+	case errors.Is(err, store.ErrMessageNotFound):
+		w.log.Debugf("Ignoring edit of unknown message %s in %s", target, key.ChatJID)
+	case err != nil:
+		w.log.Warnf("Failed to store edit: %v", err)
+	default:
+		// The edit may add or drop URLs; replace them even when none remain
+		if err := w.store.StoreLinks(key, extract.Links(edited)); err != nil {
+			w.log.Warnf("Failed to store links: %v", err)
+		}
+	}
+	return true
+}
+
+// Mark the message a delete-for-everyone removes, rather than storing the
+// deletion as a message of its own. Reports whether m was one.
+func (w *Logger) handleRevoke(chat types.JID, revoker string, m *waE2E.Message, at time.Time) bool {
+	target, ok := extract.Revoke(m)
+	if !ok {
+		return false
+	}
+	// Admins can delete others' messages in groups; the key then names the author
+	sender := revoker
+	if p := target.GetParticipant(); p != "" {
+		sender = p
+		if jid, err := types.ParseJID(p); err == nil {
+			sender = jid.ToNonAD().String()
+		}
+	}
+	tombstone := store.Message{
+		ID:        target.GetID(),
+		ChatJID:   chat.String(),
+		Sender:    sender,
+		Content:   "[Deleted message]",
+		Timestamp: at,
+		IsFromMe:  sender == types.NewJID(w.ownUser(), types.DefaultUserServer).String(),
+	}
+	if err := w.store.RevokeMessage(tombstone, revoker); err != nil {
+		w.log.Warnf("Failed to store deletion: %v", err)
+	}
+	return true
+}
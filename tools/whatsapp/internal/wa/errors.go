@@ -0,0 +1,14 @@
+package wa
+
+import "errors"
+
+var (
+	// ErrNotPaired is returned when an operation needs a linked device session but none exists
+	ErrNotPaired = errors.New("device not paired")
+	// ErrNotConnected is returned when an operation needs a live connection to WhatsApp
+	ErrNotConnected = errors.New("not connected to WhatsApp")
+	// ErrInviteExpired is returned when accepting a group invite past its expiry
+	ErrInviteExpired = errors.New("group invite expired")
+	// ErrNoSession is returned when unlinking a device whose session is not in the session database
+	ErrNoSession = errors.New("no local session for device")
+)
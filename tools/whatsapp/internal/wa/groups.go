@@ -0,0 +1,303 @@
+package wa
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/store"
+)
+
+// Record membership and settings changes for group digests, log each
+// change in the group's timeline, and refetch the group's metadata
+func (w *Logger) handleGroupInfo(v *events.GroupInfo) {
+	chatJID := v.JID.String()
+	actor := ""
+	if v.Sender != nil {
+		actor = v.Sender.ToNonAD().String()
+	}
+	at := v.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	record := func(kind, subject string) {
+		err := w.store.StoreChatEvent(store.ChatEvent{ChatJID: chatJID, Kind: kind, Subject: subject, Actor: actor, At: at})
+		if err != nil {
+			w.log.Warnf("Failed to store group event: %v", err)
+		}
+	}
+	system := func(kind string, data map[string]string) {
+		w.storeSystemMessage(v.JID, actor, at, store.SystemEvent{Kind: kind, Data: data})
+	}
+	members := func(eventKind, systemKind string, jids []types.JID) {
+		var joined []string
+		for _, jid := range jids {
+			record(eventKind, jid.ToNonAD().String())
+			joined = append(joined, jid.ToNonAD().String())
+		}
+		if len(jids) > 0 {
+			system(systemKind, map[string]string{"participants": strings.Join(joined, ",")})
+			w.storeMembership(chatJID, eventKind, joined, actor, at)
+		}
+	}
+
+	members(store.EventJoin, store.SystemJoin, v.Join)
+	members(store.EventLeave, store.SystemLeave, v.Leave)
+	members(store.EventPromote, store.SystemPromote, v.Promote)
+	members(store.EventDemote, store.SystemDemote, v.Demote)
+	if v.Topic != nil {
+		record(store.EventTopic, v.Topic.Topic)
+		system(store.SystemDescription, map[string]string{"description": v.Topic.Topic})
+	}
+	if v.Announce != nil {
+		record(store.EventAnnounce, onOff(v.Announce.IsAnnounce))
+		system(store.SystemAnnounce, map[string]string{"state": onOff(v.Announce.IsAnnounce)})
+	}
+	if v.Locked != nil {
+		system(store.SystemLocked, map[string]string{"state": onOff(v.Locked.IsLocked)})
+	}
+	if v.Ephemeral != nil {
+		timer := uint32(0)
+		if v.Ephemeral.IsEphemeral {
+			timer = v.Ephemeral.DisappearingTimer
+		}
+		system(store.SystemEphemeral, map[string]string{"timer": strconv.FormatUint(uint64(timer), 10)})
+	}
+	if v.MembershipApprovalMode != nil {
+		system(store.SystemApproval, map[string]string{"state": onOff(v.MembershipApprovalMode.IsJoinApprovalRequired)})
+	}
+	if v.NewInviteLink != nil {
+		system(store.SystemInviteLink, nil)
+	}
+	if v.Name != nil && v.Name.Name != "" {
+		if err := w.store.StoreChat(chatJID, v.Name.Name, at); err != nil {
+			w.log.Warnf("Failed to update chat: %v", err)
+		}
+		system(store.SystemSubject, map[string]string{"subject": v.Name.Name})
+	}
+	go w.refreshGroup(v.JID)
+	// A group linked to or unlinked from a community changed too
+	for _, change := range []*types.GroupLinkChange{v.Link, v.Unlink} {
+		if change != nil && !change.Group.JID.IsEmpty() && change.Group.JID != v.JID {
+			go w.refreshGroup(change.Group.JID)
+		}
+	}
+}
+
+// Add membership changes of one kind to the group's membership history.
+// The actor is left out for members acting on themselves.
+func (w *Logger) storeMembership(groupJID, kind string, members []string, actor string, at time.Time) {
+	changes := make([]store.MembershipChange, len(members))
+	for i, member := range members {
+		changes[i] = store.MembershipChange{GroupJID: groupJID, Member: member, Kind: kind, Actor: actor, At: at}
+		if actor == member {
+			changes[i].Actor = ""
+		}
+	}
+	if err := w.store.StoreMembershipChanges(changes); err != nil {
+		w.log.Warnf("Failed to store membership changes: %v", err)
+	}
+}
+
+// Join the group a stored invite message invites to
+func (w *Logger) AcceptInvite(key store.MessageKey) (store.GroupInvite, error) {
+	inv, err := w.store.GetGroupInvite(key)
+	if err != nil {
+		return store.GroupInvite{}, err
+	}
+	if inv.ExpiresAt != nil && time.Now().After(*inv.ExpiresAt) {
+		return inv, fmt.Errorf("cannot join %s: %w on %s", inv.GroupJID, ErrInviteExpired, inv.ExpiresAt.Format(time.DateOnly))
+	}
+	group, err := types.ParseJID(inv.GroupJID)
+	if err != nil {
+		return inv, fmt.Errorf("invalid group JID %q: %w", inv.GroupJID, err)
+	}
+	// The inviter is whoever sent the invite; in a direct chat that may
+	// only be known from the chat
+	inviterJID := inv.Sender
+	if inviterJID == "" {
+		inviterJID = inv.ChatJID
+	}
+	inviter, err := types.ParseJID(inviterJID)
+	if err != nil {
+		return inv, fmt.Errorf("invalid inviter JID %q: %w", inviterJID, err)
+	}
+	if w.dryRun {
+		w.log.Infof("[dry-run] Would join %s (%s) invited by %s", inv.GroupJID, inv.GroupName, inviter)
+		return inv, nil
+	}
+	if w.client == nil || !w.client.IsConnected() {
+		return inv, fmt.Errorf("cannot join group: %w", ErrNotConnected)
+	}
+	var expiration int64
+	if inv.ExpiresAt != nil {
+		expiration = inv.ExpiresAt.Unix()
+	}
+	if err := w.client.JoinGroupWithInvite(group, inviter.ToNonAD(), inv.Code, expiration); err != nil {
+		return inv, fmt.Errorf("failed to join %s: %w", inv.GroupJID, err)
+	}
+	return inv, nil
+}
+
+// Refetch a changed profile picture, and log a change of group icon in the
+// group's timeline
+func (w *Logger) handlePicture(v *events.Picture) {
+	w.avatarChanged(v.JID)
+	if v.JID.Server != types.GroupServer {
+		return
+	}
+	at := v.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	actor := ""
+	if !v.Author.IsEmpty() {
+		actor = v.Author.ToNonAD().String()
+	}
+	data := map[string]string{"picture_id": v.PictureID}
+	if v.Remove {
+		data = map[string]string{"removed": "true"}
+	}
+	w.storeSystemMessage(v.JID, actor, at, store.SystemEvent{Kind: store.SystemIcon, Data: data})
+}
+
+// Store a group change as a system message. Live changes carry no message
+// ID, so one is derived from the change, which keeps replays idempotent.
+func (w *Logger) storeSystemMessage(chat types.JID, actor string, at time.Time, e store.SystemEvent) {
+	m := store.Message{
+		ID:        fmt.Sprintf("system-%s-%d", e.Kind, at.UnixNano()),
+		ChatJID:   chat.String(),
+		Sender:    actor,
+		Content:   extract.SystemContent(e),
+		Timestamp: at,
+		IsFromMe:  actor != "" && actor == types.NewJID(w.ownUser(), types.DefaultUserServer).String(),
+		System:    &e,
+	}
+	if err := w.store.StoreSystemMessage(m); err != nil {
+		w.log.Warnf("Failed to store group change: %v", err)
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// Record a pin or unpin as a chat event rather than a message. Reports
+// whether m was one.
+func (w *Logger) handlePin(info types.MessageInfo, m *waE2E.Message) bool {
+	pin := m.GetPinInChatMessage()
+	if pin == nil {
+		return false
+	}
+	kind := store.EventPin
+	if pin.GetType() == waE2E.PinInChatMessage_UNPIN_FOR_ALL {
+		kind = store.EventUnpin
+	}
+	err := w.store.StoreChatEvent(store.ChatEvent{
+		ChatJID: info.Chat.String(),
+		Kind:    kind,
+		Subject: pin.GetKey().GetID(),
+		Actor:   info.Sender.ToNonAD().String(),
+		At:      info.Timestamp,
+	})
+	if err != nil {
+		w.log.Warnf("Failed to store pin: %v", err)
+	}
+	return true
+}
+
+// How often start refreshes group metadata besides on connect
+const GroupSyncInterval = 6 * time.Hour
+
+// Refresh the metadata of every group the account is in every interval,
+// while connected, until ctx is done. Groups are also synced on connect.
+func (w *Logger) RunGroupSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.Connected() {
+				w.syncGroups()
+			}
+		}
+	}
+}
+
+// Store the subject, description and members of every group the account
+// is in
+func (w *Logger) syncGroups() {
+	if w.client == nil {
+		return
+	}
+	groups, err := w.client.GetJoinedGroups()
+	if err != nil {
+		w.log.Warnf("Failed to list groups: %v", err)
+		return
+	}
+	for _, info := range groups {
+		w.storeGroupInfo(info)
+		w.refreshAvatar(info.JID)
+	}
+	w.log.Infof("Synced %d groups", len(groups))
+}
+
+// Fetch and store one group's metadata after it changed
+func (w *Logger) refreshGroup(jid types.JID) {
+	if !w.Connected() {
+		return
+	}
+	info, err := w.client.GetGroupInfo(jid)
+	if err != nil {
+		w.log.Warnf("Failed to fetch group %s: %v", jid, err)
+		return
+	}
+	w.storeGroupInfo(info)
+}
+
+// Store a group's metadata, members and place in a community as WhatsApp
+// describes them
+func (w *Logger) storeGroupInfo(info *types.GroupInfo) {
+	g := store.Group{
+		JID:            info.JID.String(),
+		Subject:        info.Name,
+		Description:    info.Topic,
+		CreatedAt:      info.GroupCreated,
+		SyncedAt:       time.Now(),
+		IsCommunity:    info.IsParent,
+		IsAnnouncement: info.IsDefaultSubGroup,
+	}
+	if !info.LinkedParentJID.IsEmpty() {
+		g.CommunityJID = info.LinkedParentJID.String()
+	}
+	if !info.OwnerJID.IsEmpty() {
+		g.Owner = info.OwnerJID.ToNonAD().String()
+	}
+	var mappings []store.LIDMapping
+	for _, p := range info.Participants {
+		member := store.GroupParticipant{JID: p.JID.ToNonAD().String(), IsAdmin: p.IsAdmin, IsSuperAdmin: p.IsSuperAdmin}
+		if p.JID.Server == types.HiddenUserServer && !p.PhoneNumber.IsEmpty() {
+			member.PhoneJID = p.PhoneNumber.ToNonAD().String()
+		}
+		if m, ok := lidMapping(p.LID, p.PhoneNumber); ok {
+			mappings = append(mappings, m)
+		}
+		g.Participants = append(g.Participants, member)
+	}
+	w.storeLIDMappings(mappings)
+	if err := w.store.StoreGroup(g); err != nil {
+		w.log.Warnf("Failed to store group %s: %v", g.JID, err)
+	}
+}
@@ -0,0 +1,289 @@
+package wa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waWeb"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/store"
+)
+
+// Request full history sync from WhatsApp
+func (w *Logger) requestHistorySync() error {
+	if !w.client.IsConnected() {
+		return fmt.Errorf("cannot request history sync: %w", ErrNotConnected)
+	}
+
+	if w.client.Store.ID == nil {
+		return fmt.Errorf("cannot request history sync: %w", ErrNotPaired)
+	}
+
+	// Request multiple batches to get comprehensive history
+	batchSizes := []int{10000, 5000, 2000} // Try different batch sizes
+
+	for i, batchSize := range batchSizes {
+		w.log.Infof("Requesting history sync batch %d/%d (%d messages)...", i+1, len(batchSizes), batchSize)
+
+		// Build and send a history sync request
+		historyMsg := w.client.BuildHistorySyncRequest(nil, batchSize)
+		if historyMsg == nil {
+			w.log.Errorf("Failed to build history sync request for batch %d", i+1)
+			continue
+		}
+
+		_, err := w.client.SendMessage(context.Background(), types.JID{
+			Server: "s.whatsapp.net",
+			User:   "status",
+		}, historyMsg)
+
+		if err != nil {
+			w.log.Errorf("Failed to request history sync batch %d: %v", i+1, err)
+		} else {
+			w.log.Infof("History sync batch %d requested successfully", i+1)
+		}
+
+		// Wait between requests to avoid overwhelming the server
+		if i < len(batchSizes)-1 {
+			time.Sleep(3 * time.Second)
+		}
+	}
+
+	w.log.Infof("All history sync requests sent. Messages will appear as they are processed...")
+	return nil
+}
+
+// Ask the primary device for up to count messages older than m in its chat,
+// to fill a gap ending at m. The messages arrive as a history sync event.
+func (w *Logger) RequestHistoryBefore(ctx context.Context, m store.Message, count int) error {
+	if w.client == nil || !w.client.IsLoggedIn() {
+		return fmt.Errorf("cannot request history: %w", ErrNotConnected)
+	}
+	chat, err := types.ParseJID(m.ChatJID)
+	if err != nil {
+		return fmt.Errorf("invalid chat JID %q: %w", m.ChatJID, err)
+	}
+	info := &types.MessageInfo{
+		MessageSource: types.MessageSource{Chat: chat, IsFromMe: m.IsFromMe},
+		ID:            m.ID,
+		Timestamp:     m.Timestamp,
+	}
+	own := w.client.Store.ID.ToNonAD()
+	_, err = w.client.SendMessage(ctx, own, w.client.BuildHistorySyncRequest(info, count), whatsmeow.SendRequestExtra{Peer: true})
+	if err != nil {
+		return fmt.Errorf("failed to request history for %s: %w", m.ChatJID, err)
+	}
+	return nil
+}
+
+// Whether a linked device session exists, so Connect will not ask for a QR scan
+func (w *Logger) Paired() bool {
+	return w.client != nil && w.client.Store.ID != nil
+}
+
+// Whether the connection is up and authenticated
+func (w *Logger) Connected() bool {
+	return w.client != nil && w.client.IsConnected() && w.client.IsLoggedIn()
+}
+
+// Wait until the connection is authenticated, or timeout passes
+func (w *Logger) WaitForConnection(timeout time.Duration) error {
+	if !w.Paired() {
+		return ErrNotPaired
+	}
+	if !w.client.WaitForConnection(timeout) {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// Whether m is a non-text message history sync keeps, with fields of its own
+func structured(m *waE2E.Message) bool {
+	_, _, _, isPoll := extract.Poll(m)
+	_, isLocation := extract.Location(m)
+	_, isContact := extract.Contacts(m)
+	_, _, isInteractive := extract.Interactive(m)
+	_, isInvite := extract.GroupInvite(m)
+	return isPoll || isLocation || isContact || isInteractive || isInvite
+}
+
+// Store a group change history sync carries as a stub, keeping its message
+// ID. Reports whether info was one.
+func (w *Logger) historyStub(info *waWeb.WebMessageInfo, chat types.JID) bool {
+	e, ok := extract.Stub(info)
+	if !ok {
+		return false
+	}
+	sender, isFromMe := extract.HistorySender(info.GetKey(), chat, w.ownUser())
+	if p := info.GetParticipant(); p != "" && !isFromMe {
+		sender = p
+	}
+	at := time.Unix(int64(info.GetMessageTimestamp()), 0)
+	err := w.store.StoreSystemMessage(store.Message{
+		ID:        info.GetKey().GetID(),
+		ChatJID:   chat.String(),
+		Sender:    sender,
+		Content:   extract.SystemContent(e),
+		Timestamp: at,
+		IsFromMe:  isFromMe,
+		System:    &e,
+	})
+	if err != nil {
+		w.log.Warnf("Failed to store history group change: %v", err)
+	}
+	switch e.Kind {
+	case store.SystemJoin, store.SystemLeave, store.SystemPromote, store.SystemDemote:
+		if members := e.Data["participants"]; members != "" {
+			// System and event kinds share names for membership changes
+			w.storeMembership(chat.String(), e.Kind, strings.Split(members, ","), sender, at)
+		}
+	}
+	return true
+}
+
+// Handle history sync events
+func (w *Logger) handleHistorySync(historySync *events.HistorySync) {
+	w.log.Infof("Received history sync event with %d conversations", len(historySync.Data.Conversations))
+
+	ownUser := w.ownUser()
+	w.storePushnames(historySync.Data.GetPushnames())
+	w.storeHistoryLIDs(historySync.Data)
+
+	syncedCount := 0
+	for _, conversation := range historySync.Data.Conversations {
+		// Parse JID from the conversation
+		if conversation.ID == nil {
+			continue
+		}
+
+		chatJID := *conversation.ID
+
+		// Try to parse the JID
+		jid, err := types.ParseJID(chatJID)
+		if err != nil {
+			w.log.Warnf("Failed to parse JID %s: %v", chatJID, err)
+			continue
+		}
+
+		// Get chat name (simplified version)
+		name := extract.ChatName(jid)
+
+		// Process messages
+		messages := conversation.Messages
+		if len(messages) > 0 {
+			// Update chat with latest message timestamp
+			latestMsg := messages[0]
+			if latestMsg == nil || latestMsg.Message == nil {
+				continue
+			}
+
+			// Get timestamp from message info
+			timestamp := time.Time{}
+			if ts := latestMsg.Message.GetMessageTimestamp(); ts != 0 {
+				timestamp = time.Unix(int64(ts), 0)
+			} else {
+				continue
+			}
+
+			w.store.StoreChat(chatJID, name, timestamp)
+
+			// Store messages, then the edits and deletions among them, which
+			// come before the messages they change when newest first
+			var changes []*waWeb.WebMessageInfo
+			for _, msg := range messages {
+				if msg == nil || msg.Message == nil {
+					continue
+				}
+
+				if w.historyReaction(msg.Message, jid) || w.historyPollVote(msg.Message, jid) {
+					continue
+				}
+				if _, _, _, ok := extract.Edit(msg.Message.GetMessage()); ok {
+					changes = append(changes, msg.Message)
+					continue
+				}
+				if _, ok := extract.Revoke(msg.Message.GetMessage()); ok {
+					changes = append(changes, msg.Message)
+					continue
+				}
+				if w.historyStub(msg.Message, jid) {
+					continue
+				}
+				w.historyReactions(msg.Message, jid)
+
+				// Extract text content
+				content := extract.Text(msg.Message.GetMessage())
+				if content == "" && structured(msg.Message.GetMessage()) {
+					content, _, _ = extract.Content(msg.Message.GetMessage())
+				}
+
+				// Skip empty messages for now (could add media handling later)
+				if content == "" {
+					continue
+				}
+
+				// Determine sender
+				sender, isFromMe := extract.HistorySender(msg.Message.GetKey(), jid, ownUser)
+
+				// Store message
+				msgID := msg.Message.GetKey().GetID()
+
+				// Get message timestamp
+				timestamp := time.Time{}
+				if ts := msg.Message.GetMessageTimestamp(); ts != 0 {
+					timestamp = time.Unix(int64(ts), 0)
+				} else {
+					continue
+				}
+
+				err = w.store.StoreMessage(
+					msgID,
+					chatJID,
+					sender,
+					content,
+					timestamp,
+					isFromMe,
+					"", // No media type for now
+					"", // No filename
+					"", // No URL
+				)
+				if err != nil {
+					w.log.Warnf("Failed to store history message: %v", err)
+				} else {
+					syncedCount++
+					w.storeRaw(store.MessageKey{ID: msgID, ChatJID: chatJID}, msg.Message.GetMessage())
+					w.storeLinkPreview(msg.Message.GetMessage())
+					w.storeLinks(store.MessageKey{ID: msgID, ChatJID: chatJID}, msg.Message.GetMessage())
+					w.storeReply(store.MessageKey{ID: msgID, ChatJID: chatJID}, msg.Message.GetMessage())
+					w.storeForwarded(store.MessageKey{ID: msgID, ChatJID: chatJID}, msg.Message.GetMessage())
+					w.storeMentions(store.MessageKey{ID: msgID, ChatJID: chatJID}, msg.Message.GetMessage())
+					w.storeInteractive(store.MessageKey{ID: msgID, ChatJID: chatJID}, msg.Message.GetMessage())
+					w.storePoll(store.MessageKey{ID: msgID, ChatJID: chatJID}, w.senderJID(msg.Message.GetKey(), jid), msg.Message.GetMessage(), timestamp)
+					w.storeLocation(store.MessageKey{ID: msgID, ChatJID: chatJID}, msg.Message.GetMessage())
+					w.storeSharedContacts(store.MessageKey{ID: msgID, ChatJID: chatJID}, msg.Message.GetMessage())
+					w.storeGroupInvite(store.MessageKey{ID: msgID, ChatJID: chatJID}, msg.Message.GetMessage())
+					w.historyPollVotes(msg.Message, jid)
+				}
+			}
+			for _, change := range changes {
+				at := time.Unix(int64(change.GetMessageTimestamp()), 0)
+				if !w.handleEdit(jid, change.GetMessage(), at) {
+					w.handleRevoke(jid, w.senderJID(change.GetKey(), jid), change.GetMessage(), at)
+				}
+			}
+		}
+	}
+
+	w.log.Infof("🔄 History sync batch complete. Stored %d messages from %d conversations.", syncedCount, len(historySync.Data.Conversations))
+
+	// Get total message count from database
+	totalCount, _ := w.store.MessageCount()
+	w.log.Infof("📱 Total messages in database: %d", totalCount)
+}
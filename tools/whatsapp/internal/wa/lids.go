@@ -0,0 +1,69 @@
+package wa
+
+import (
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-logger/internal/store"
+)
+
+// The mapping between two addresses of one account, when one is a LID and
+// the other a phone number, in either order
+func lidMapping(a, b types.JID) (store.LIDMapping, bool) {
+	if a.Server == types.DefaultUserServer && b.Server == types.HiddenUserServer {
+		a, b = b, a
+	}
+	if a.Server != types.HiddenUserServer || b.Server != types.DefaultUserServer {
+		return store.LIDMapping{}, false
+	}
+	return store.LIDMapping{LID: a.ToNonAD().String(), PhoneJID: b.ToNonAD().String()}, true
+}
+
+// Record the LID mappings a message's alternate addresses reveal: the
+// sender's, and in a direct chat the chat's
+func (w *Logger) storeMessageLIDs(info types.MessageInfo) {
+	var mappings []store.LIDMapping
+	if m, ok := lidMapping(info.Sender, info.SenderAlt); ok {
+		mappings = append(mappings, m)
+	}
+	if !info.IsGroup {
+		if m, ok := lidMapping(info.Chat, info.RecipientAlt); ok {
+			mappings = append(mappings, m)
+		}
+	}
+	w.storeLIDMappings(mappings)
+}
+
+// Record the LID mappings a history sync carries, both listed and as the
+// alternate JIDs of its conversations
+func (w *Logger) storeHistoryLIDs(data *waHistorySync.HistorySync) {
+	var mappings []store.LIDMapping
+	add := func(lid, pn string) {
+		l, err1 := types.ParseJID(lid)
+		p, err2 := types.ParseJID(pn)
+		if err1 != nil || err2 != nil {
+			return
+		}
+		if m, ok := lidMapping(l, p); ok {
+			mappings = append(mappings, m)
+		}
+	}
+	for _, m := range data.GetPhoneNumberToLidMappings() {
+		add(m.GetLidJID(), m.GetPnJID())
+	}
+	for _, c := range data.GetConversations() {
+		if c.GetLidJID() != "" && c.GetPnJID() != "" {
+			add(c.GetLidJID(), c.GetPnJID())
+		}
+	}
+	w.storeLIDMappings(mappings)
+}
+
+func (w *Logger) storeLIDMappings(mappings []store.LIDMapping) {
+	if len(mappings) == 0 {
+		return
+	}
+	if err := w.store.StoreLIDMappings(mappings); err != nil {
+		w.log.Warnf("Failed to store LID mappings: %v", err)
+	}
+}
@@ -0,0 +1,484 @@
+// Package wa connects to WhatsApp via whatsmeow and feeds events into the store.
+package wa
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mdp/qrterminal"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/journal"
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/ocr"
+	"whatsapp-logger/internal/store"
+)
+
+// WhatsApp message logger - minimal version for Kenny integration
+type Logger struct {
+	client    *whatsmeow.Client
+	container *sqlstore.Container
+	store     store.Store
+	log       waLog.Logger
+	journal   *journal.Writer
+	hooks     []func(store.Message)
+
+	// Own user part for offline loggers, which have no device store to ask
+	offlineUser string
+
+	// Where profile pictures are saved, and whose were fetched this run
+	mediaDir string
+	avatars  sync.Map
+
+	// Log outbound messages instead of sending them
+	dryRun bool
+	// Typing shown before sent text, and presence set on connect
+	typing   *Typing
+	presence types.Presence
+	// Contacts whose presence is logged
+	watched []types.JID
+
+	// Live attachment downloads, enabled by SetMediaDownload; downloads
+	// holds one token per download in progress
+	downloadPolicy *media.DownloadPolicy
+	downloads      chan struct{}
+	// Where attachments go when not the media directory
+	storage media.Storage
+	// Reads text from downloaded images when set
+	ocr ocr.Engine
+
+	// Names of followed channels by JID, learned on connect
+	channels sync.Map
+}
+
+// Create new WhatsApp logger
+func New(sessionDBPath, messagesDBPath string) (*Logger, error) {
+	// Initialize message store
+	st, err := store.Open(messagesDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize message store: %w", err)
+	}
+
+	return NewWithStore(sessionDBPath, st)
+}
+
+// Create new WhatsApp logger writing to an already opened store.
+// The logger takes ownership of the store and closes it on failure or Disconnect.
+func NewWithStore(sessionDBPath string, st store.Store) (*Logger, error) {
+	// Initialize whatsmeow session store with foreign keys enabled
+	dbLog := waLog.Stdout("Database", "INFO", true)
+
+	// Create session database with foreign keys enabled, in WAL mode like
+	// the archive, unless it lives in PostgreSQL
+	dialect, address := "sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000", sessionDBPath)
+	if store.IsPostgres(sessionDBPath) {
+		dialect, address = "postgres", sessionDBPath
+	}
+	container, err := sqlstore.New(context.Background(), dialect, address, dbLog)
+	if err != nil {
+		st.Close()
+		return nil, fmt.Errorf("failed to initialize session store: %w", err)
+	}
+
+	// Get device (will be nil if not registered)
+	deviceStore, err := container.GetFirstDevice(context.Background())
+	if err != nil {
+		st.Close()
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+
+	// Initialize client
+	clientLog := waLog.Stdout("Client", "INFO", true)
+	client := whatsmeow.NewClient(deviceStore, clientLog)
+
+	logger := &Logger{
+		client:    client,
+		container: container,
+		store:     st,
+		log:       clientLog,
+	}
+
+	// Register event handlers
+	client.AddEventHandler(logger.handleEvent)
+
+	return logger, nil
+}
+
+// Create a logger with no WhatsApp connection that only runs events through the
+// handler pipeline into st, as used by replay. ownUser stands in for the paired
+// account when attributing our own messages.
+func NewOffline(st store.Store, ownUser string) *Logger {
+	return &Logger{
+		store:       st,
+		log:         waLog.Stdout("Replay", "INFO", true),
+		offlineUser: ownUser,
+	}
+}
+
+// Record every handled event to j, for later replay
+func (w *Logger) SetJournal(j *journal.Writer) {
+	w.journal = j
+}
+
+// Run fn after every live message is stored. History sync messages do not
+// trigger hooks, since they are backfill rather than new activity.
+func (w *Logger) AddMessageHook(fn func(store.Message)) {
+	w.hooks = append(w.hooks, fn)
+}
+
+// Feed a recorded event through the same handlers live events use
+func (w *Logger) Replay(evt interface{}) {
+	w.handleEvent(evt)
+}
+
+// User part of the account's own JID, or "" when unknown
+func (w *Logger) ownUser() string {
+	if w.client != nil && w.client.Store.ID != nil {
+		return w.client.Store.ID.User
+	}
+	return w.offlineUser
+}
+
+// Handle WhatsApp events
+func (w *Logger) handleEvent(evt interface{}) {
+	if w.journal != nil {
+		if err := w.journal.Record(evt); err != nil {
+			w.log.Warnf("Failed to journal event: %v", err)
+		}
+	}
+
+	switch v := evt.(type) {
+	case *events.Message:
+		w.handleMessage(v)
+	case *events.HistorySync:
+		w.handleHistorySync(v)
+	case *events.GroupInfo:
+		w.handleGroupInfo(v)
+	case *events.Picture:
+		w.handlePicture(v)
+	case *events.CallOffer:
+		w.handleCallOffer(v.BasicCallMeta, offersVideo(v.Data))
+	case *events.CallOfferNotice:
+		w.handleCallOffer(v.BasicCallMeta, v.Media == "video")
+	case *events.CallAccept, *events.CallReject, *events.CallTerminate:
+		w.handleCallUpdate(v)
+	case *events.Receipt:
+		w.handleReceipt(v)
+	case *events.Mute:
+		w.handleMute(v)
+	case *events.Archive:
+		w.handleArchive(v)
+	case *events.Pin:
+		w.handleChatPin(v)
+	case *events.Contact:
+		w.handleContact(v)
+	case *events.BusinessName:
+		w.storeContact(store.Contact{JID: v.JID.ToNonAD().String(), BusinessName: v.NewBusinessName})
+	case *events.AppStateSyncComplete:
+		if v.Name == appstate.WAPatchCriticalUnblockLow {
+			go w.syncContacts()
+		}
+	case *events.Presence:
+		w.handlePresence(v)
+	case *events.ChatPresence:
+		w.handleChatUpdate(v.MessageSource.Chat.String(), "", time.Now())
+	case *events.Connected:
+		w.log.Infof("Connected to WhatsApp - requesting message history...")
+		if err := w.requestHistorySync(); err != nil {
+			w.log.Warnf("%v", err)
+		}
+		w.sendPresence()
+		w.subscribePresence()
+		go w.syncChannels()
+		go w.syncContacts()
+		go w.syncGroups()
+	case *events.LoggedOut:
+		w.log.Infof("Logged out: %v", v)
+	}
+}
+
+// Handle incoming messages
+func (w *Logger) handleMessage(msg *events.Message) {
+	// Extract basic message info
+	chatJID := msg.Info.Chat.String()
+	sender := msg.Info.Sender.String()
+	messageID := msg.Info.ID
+	timestamp := msg.Info.Timestamp
+	isFromMe := msg.Info.IsFromMe
+
+	if w.handlePin(msg.Info, msg.Message) || w.handleReaction(msg.Info, msg.Message) ||
+		w.handleEdit(msg.Info.Chat, msg.Message, timestamp) ||
+		w.handleRevoke(msg.Info.Chat, msg.Info.Sender.ToNonAD().String(), msg.Message, timestamp) ||
+		w.handlePollVote(msg) || w.handleChannelEdit(msg) {
+		return
+	}
+
+	// Extract content based on message type
+	content, mediaType, filename := extract.Content(msg.Message)
+
+	// Learn LID mappings first so the message is stored with the sender's number
+	w.storeMessageLIDs(msg.Info)
+
+	// Update chat info first so the message's foreign key is satisfied
+	chatName := chatJID // Default to JID
+	if store.IsChannel(chatJID) {
+		chatName = w.channelName(chatJID)
+	}
+	if err := w.store.StoreChat(chatJID, chatName, timestamp); err != nil {
+		w.log.Errorf("Failed to update chat: %v", err)
+	}
+
+	// Store message
+	if err := w.store.StoreMessage(messageID, chatJID, sender, content, timestamp, isFromMe, mediaType, filename, ""); err != nil {
+		w.log.Errorf("Failed to store message: %v", err)
+		return
+	}
+	if w.store.Encrypted() {
+		// Text encrypted in the archive stays out of the log too
+		w.log.Infof("Stored message %s from %s in %s", messageID, sender, chatJID)
+	} else {
+		w.log.Infof("Stored message: %s from %s in %s", content, sender, chatJID)
+	}
+	w.storeRaw(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Message)
+	w.storeLinkPreview(msg.Message)
+	w.storeLinks(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Message)
+	w.storeReply(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Message)
+	w.storeForwarded(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Message)
+	w.storeMentions(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Message)
+	w.storeInteractive(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Message)
+	w.storePoll(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Info.Sender.ToNonAD().String(), msg.Message, timestamp)
+	w.storeLocation(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Message)
+	w.storeSharedContacts(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Message)
+	w.storeGroupInvite(store.MessageKey{ID: messageID, ChatJID: chatJID}, msg.Message)
+	if !isFromMe {
+		w.storeContactName(msg.Info.Sender, msg.Info.PushName, timestamp)
+		w.refreshAvatar(msg.Info.Sender)
+	}
+	if msg.Info.IsGroup {
+		w.refreshAvatar(msg.Info.Chat)
+	}
+	attachment, hasMedia := extract.Media(msg.Message)
+	if hasMedia {
+		attachment.ViewOnce = attachment.ViewOnce || msg.IsViewOnce
+		if err := w.store.StoreMedia(store.MessageKey{ID: messageID, ChatJID: chatJID}, attachment); err != nil {
+			w.log.Warnf("Failed to store media metadata: %v", err)
+		}
+	}
+
+	replyTo, replySender := extract.ReplyTo(msg.Message)
+	stored := store.Message{
+		ID:            messageID,
+		ChatJID:       chatJID,
+		ChatName:      chatName,
+		Sender:        sender,
+		SenderName:    msg.Info.PushName,
+		Content:       content,
+		Timestamp:     timestamp,
+		IsFromMe:      isFromMe,
+		MediaType:     mediaType,
+		IsViewOnce:    attachment.ViewOnce,
+		Filename:      filename,
+		ReplyTo:       replyTo,
+		ReplyToSender: replySender,
+		MimeType:      attachment.MimeType,
+	}
+	stored.IsForwarded, stored.ForwardingScore = extract.Forwarded(msg.Message)
+	if loc, ok := extract.Location(msg.Message); ok {
+		stored.Location = &loc
+	}
+	if hasMedia {
+		w.queueDownload(stored, attachment)
+	}
+	for _, hook := range w.hooks {
+		hook(stored)
+	}
+}
+
+// Keep the proto a stored message arrived as, so `reprocess` can extract
+// from it again
+func (w *Logger) storeRaw(key store.MessageKey, m *waE2E.Message) {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		w.log.Warnf("Failed to marshal message: %v", err)
+		return
+	}
+	if err := w.store.StoreRawMessage(key, data); err != nil {
+		w.log.Warnf("Failed to store raw message: %v", err)
+	}
+}
+
+// Keep the preview of a shared link so `links` can show its title
+func (w *Logger) storeLinkPreview(m *waE2E.Message) {
+	url, title, description := extract.LinkPreview(m)
+	if url == "" {
+		return
+	}
+	if err := w.store.StoreLinkPreview(store.LinkPreview{URL: url, Title: title, Description: description}); err != nil {
+		w.log.Warnf("Failed to store link preview: %v", err)
+	}
+}
+
+// Keep the URLs a stored message shares for link queries
+func (w *Logger) storeLinks(key store.MessageKey, m *waE2E.Message) {
+	found := extract.Links(m)
+	if len(found) == 0 {
+		return
+	}
+	if err := w.store.StoreLinks(key, found); err != nil {
+		w.log.Warnf("Failed to store links: %v", err)
+	}
+}
+
+// Keep the coordinates of a stored location message
+func (w *Logger) storeLocation(key store.MessageKey, m *waE2E.Message) {
+	loc, ok := extract.Location(m)
+	if !ok {
+		return
+	}
+	if err := w.store.StoreLocation(key, loc); err != nil {
+		w.log.Warnf("Failed to store location: %v", err)
+	}
+}
+
+// Keep the contact cards a stored message shares
+func (w *Logger) storeSharedContacts(key store.MessageKey, m *waE2E.Message) {
+	contacts, ok := extract.Contacts(m)
+	if !ok {
+		return
+	}
+	if err := w.store.StoreSharedContacts(key, contacts); err != nil {
+		w.log.Warnf("Failed to store shared contacts: %v", err)
+	}
+}
+
+// Keep whether a stored message was forwarded, so analytics can set chain
+// mail apart
+func (w *Logger) storeForwarded(key store.MessageKey, m *waE2E.Message) {
+	forwarded, score := extract.Forwarded(m)
+	if !forwarded {
+		return
+	}
+	if err := w.store.StoreForwarded(key, score); err != nil {
+		w.log.Warnf("Failed to store forwarding: %v", err)
+	}
+}
+
+// Keep the structured payload of a buttons, list or template message
+func (w *Logger) storeInteractive(key store.MessageKey, m *waE2E.Message) {
+	_, payload, ok := extract.Interactive(m)
+	if !ok || payload == nil {
+		return
+	}
+	if err := w.store.StoreInteractive(key, payload); err != nil {
+		w.log.Warnf("Failed to store interactive message: %v", err)
+	}
+}
+
+// Keep the group invite a stored message carries, so it can be accepted
+// later
+func (w *Logger) storeGroupInvite(key store.MessageKey, m *waE2E.Message) {
+	inv, ok := extract.GroupInvite(m)
+	if !ok {
+		return
+	}
+	if err := w.store.StoreGroupInvite(key, inv); err != nil {
+		w.log.Warnf("Failed to store group invite: %v", err)
+	}
+}
+
+// Flag a stored message that mentions me, for the unreplied digest
+func (w *Logger) storeMentions(key store.MessageKey, m *waE2E.Message) {
+	for _, jid := range extract.Mentions(m) {
+		if !w.isOwn(jid) {
+			continue
+		}
+		if err := w.store.StoreMentionsMe(key); err != nil {
+			w.log.Warnf("Failed to store mention: %v", err)
+		}
+		return
+	}
+}
+
+// Keep the reply link of a stored message for threaded exports
+func (w *Logger) storeReply(key store.MessageKey, m *waE2E.Message) {
+	replyTo, replySender := extract.ReplyTo(m)
+	if replyTo == "" {
+		return
+	}
+	if err := w.store.StoreReply(key, replyTo, replySender); err != nil {
+		w.log.Warnf("Failed to store reply link: %v", err)
+	}
+}
+
+// Handle message updates would go here if needed
+// (MessageUpdate events are not available in this version)
+
+// Handle chat updates
+func (w *Logger) handleChatUpdate(chatJID, chatName string, lastMessage time.Time) {
+	if chatName == "" {
+		chatName = chatJID
+	}
+	if err := w.store.StoreChat(chatJID, chatName, lastMessage); err != nil {
+		w.log.Errorf("Failed to update chat: %v", err)
+	}
+}
+
+// Connect to WhatsApp
+func (w *Logger) Connect() error {
+	if w.client.Store.ID == nil {
+		// Not registered, need to scan QR code
+		qrChan, _ := w.client.GetQRChannel(context.Background())
+		err := w.client.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+
+		for evt := range qrChan {
+			if evt.Event == "code" {
+				w.log.Infof("QR code received, please scan with your phone:")
+				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
+			} else {
+				w.log.Infof("Login event: %s", evt.Event)
+				if evt.Event == "success" {
+					break
+				}
+			}
+		}
+	} else {
+		// Already registered, just connect
+		err := w.client.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		w.log.Infof("Connected with existing session")
+	}
+
+	return nil
+}
+
+// Disconnect from WhatsApp
+func (w *Logger) Disconnect() {
+	if w.client != nil {
+		w.client.Disconnect()
+	}
+	if w.store != nil {
+		w.store.Close()
+	}
+}
+
+// Query messages for Kenny integration
+func (w *Logger) QueryMessages(chatJID string, limit int) ([]store.Message, error) {
+	return w.store.QueryMessages(chatJID, limit)
+}
@@ -0,0 +1,177 @@
+package wa
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/ocr"
+	"whatsapp-logger/internal/store"
+)
+
+// Attachments downloaded at once; more wait for a free slot
+const downloadSlots = 2
+
+// Give up on a single attachment after this long
+const downloadTimeout = 5 * time.Minute
+
+// Download attachments of live messages into media storage as p allows
+func (w *Logger) SetMediaDownload(p *media.DownloadPolicy) {
+	w.downloadPolicy = p
+	w.downloads = make(chan struct{}, downloadSlots)
+}
+
+// Keep downloaded attachments in s instead of the media directory
+func (w *Logger) SetMediaStorage(s media.Storage) {
+	w.storage = s
+}
+
+// Where attachments are saved: the configured storage, else the media directory
+func (w *Logger) mediaStorage() media.Storage {
+	if w.storage != nil {
+		return w.storage
+	}
+	if w.mediaDir != "" {
+		return media.Local(w.mediaDir)
+	}
+	return nil
+}
+
+// Run downloaded images through engine, keeping the text for searches
+func (w *Logger) SetOCR(engine ocr.Engine) {
+	w.ocr = engine
+}
+
+// Whether a live attachment should be fetched under the download policy
+func (w *Logger) wantsDownload(m store.Message, a store.Media) bool {
+	if w.downloadPolicy == nil || w.mediaStorage() == nil || w.client == nil {
+		return false
+	}
+	if a.ViewOnce {
+		return w.downloadPolicy.ViewOnce()
+	}
+	return w.downloadPolicy.Automatic(m.ChatJID, m.MediaType, a.FileLength)
+}
+
+// Fetch a live message's attachment in the background
+func (w *Logger) queueDownload(m store.Message, a store.Media) {
+	if !w.wantsDownload(m, a) {
+		return
+	}
+	go func() {
+		w.downloads <- struct{}{}
+		defer func() { <-w.downloads }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+		defer cancel()
+		path, err := w.DownloadMedia(ctx, m, a)
+		if err != nil {
+			w.log.Warnf("Failed to download %s %s in %s: %v", m.MediaType, m.ID, m.ChatJID, err)
+			return
+		}
+		w.log.Infof("Saved %s %s to %s", m.MediaType, m.ID, path)
+	}()
+}
+
+// Download the attachment of m described by a into media storage and
+// record its location, returning it. Content already stored for another
+// message is reused rather than downloaded again.
+func (w *Logger) DownloadMedia(ctx context.Context, m store.Message, a store.Media) (string, error) {
+	storage := w.mediaStorage()
+	if storage == nil {
+		return "", fmt.Errorf("no media storage set")
+	}
+	if m.MimeType == "" {
+		m.MimeType = a.MimeType
+	}
+	key := store.MessageKey{ID: m.ID, ChatJID: m.ChatJID}
+
+	if len(a.FileSHA256) > 0 {
+		b, ok, err := w.store.FindMediaBlob(hex.EncodeToString(a.FileSHA256))
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			exists, err := storage.Exists(ctx, b.Location)
+			if err != nil {
+				return "", err
+			}
+			if exists {
+				// OCR text stays unset here; the ocr command fills it in
+				return b.Location, w.store.SetMediaFile(key, b)
+			}
+		}
+	}
+
+	if w.client == nil {
+		return "", ErrNotConnected
+	}
+	msg, err := downloadable(m.MediaType, a)
+	if err != nil {
+		return "", err
+	}
+	data, err := w.client.Download(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	b := store.MediaBlob{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data)), MimeType: m.MimeType}
+	if b.Location, err = storage.Put(ctx, media.BlobKey(b.SHA256, media.FileExtension(m)), data, m.MimeType); err != nil {
+		return "", err
+	}
+	if err := w.store.SetMediaFile(key, b); err != nil {
+		return "", err
+	}
+	if m.MediaType == "sticker" {
+		if sticker, ok := media.StickerInfo(data); ok {
+			if err := w.store.StoreSticker(b.SHA256, sticker); err != nil {
+				w.log.Warnf("Failed to store sticker pack: %v", err)
+			}
+		}
+	}
+	if w.ocr != nil && m.MediaType == "image" {
+		// A failed OCR leaves the text NULL for the ocr command to retry
+		if text, err := w.ocr.Recognize(ctx, data, m.MimeType); err != nil {
+			w.log.Warnf("OCR failed for %s in %s: %v", m.ID, m.ChatJID, err)
+		} else if err := w.store.StoreOCRText(key, text); err != nil {
+			w.log.Warnf("Failed to store OCR text: %v", err)
+		}
+	}
+	return b.Location, nil
+}
+
+// Rebuild the attachment message whatsmeow downloads from stored metadata
+func downloadable(mediaType string, a store.Media) (whatsmeow.DownloadableMessage, error) {
+	if a.URL == "" && a.DirectPath == "" {
+		return nil, whatsmeow.ErrNoURLPresent
+	}
+	url, directPath, mimeType := proto.String(a.URL), proto.String(a.DirectPath), proto.String(a.MimeType)
+	length := proto.Uint64(uint64(a.FileLength))
+	switch mediaType {
+	case "image":
+		return &waE2E.ImageMessage{URL: url, DirectPath: directPath, Mimetype: mimeType, MediaKey: a.MediaKey,
+			FileSHA256: a.FileSHA256, FileEncSHA256: a.FileEncSHA256, FileLength: length}, nil
+	case "video":
+		return &waE2E.VideoMessage{URL: url, DirectPath: directPath, Mimetype: mimeType, MediaKey: a.MediaKey,
+			FileSHA256: a.FileSHA256, FileEncSHA256: a.FileEncSHA256, FileLength: length}, nil
+	case "audio":
+		return &waE2E.AudioMessage{URL: url, DirectPath: directPath, Mimetype: mimeType, MediaKey: a.MediaKey,
+			FileSHA256: a.FileSHA256, FileEncSHA256: a.FileEncSHA256, FileLength: length}, nil
+	case "document":
+		return &waE2E.DocumentMessage{URL: url, DirectPath: directPath, Mimetype: mimeType, MediaKey: a.MediaKey,
+			FileSHA256: a.FileSHA256, FileEncSHA256: a.FileEncSHA256, FileLength: length}, nil
+	case "sticker":
+		return &waE2E.StickerMessage{URL: url, DirectPath: directPath, Mimetype: mimeType, MediaKey: a.MediaKey,
+			FileSHA256: a.FileSHA256, FileEncSHA256: a.FileEncSHA256, FileLength: length}, nil
+	default:
+		return nil, fmt.Errorf("cannot download media type %q", mediaType)
+	}
+}
@@ -0,0 +1,163 @@
+package wa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waWeb"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/store"
+)
+
+// Most options WhatsApp allows in a poll
+const maxPollOptions = 12
+
+// Start a poll in chatJID, storing it like any other of mine. selectable
+// is how many options each person may pick, 0 for any number.
+func (w *Logger) SendPoll(ctx context.Context, chatJID, question string, options []string, selectable int) error {
+	jid, err := types.ParseJID(chatJID)
+	if err != nil {
+		return fmt.Errorf("invalid chat JID %q: %w", chatJID, err)
+	}
+	if err := validatePoll(question, options, selectable); err != nil {
+		return err
+	}
+	if w.skipSend(chatJID, "[Poll] "+question+" / "+strings.Join(options, " / ")) {
+		return nil
+	}
+	if w.client == nil || !w.client.IsConnected() {
+		return fmt.Errorf("cannot send poll: %w", ErrNotConnected)
+	}
+	msg := w.client.BuildPollCreation(question, options, selectable)
+	resp, err := w.client.SendMessage(ctx, jid, msg)
+	if err != nil {
+		return fmt.Errorf("failed to send poll: %w", err)
+	}
+	w.storeSent(chatJID, resp, msg)
+	w.storePoll(store.MessageKey{ID: resp.ID, ChatJID: chatJID}, w.client.Store.ID.ToNonAD().String(), msg, resp.Timestamp)
+	return nil
+}
+
+// Check a poll against WhatsApp's limits
+func validatePoll(question string, options []string, selectable int) error {
+	if strings.TrimSpace(question) == "" {
+		return fmt.Errorf("poll needs a question")
+	}
+	if len(options) < 2 || len(options) > maxPollOptions {
+		return fmt.Errorf("poll needs 2 to %d options, got %d", maxPollOptions, len(options))
+	}
+	seen := map[string]bool{}
+	for _, o := range options {
+		if strings.TrimSpace(o) == "" {
+			return fmt.Errorf("poll options cannot be empty")
+		}
+		if seen[o] {
+			return fmt.Errorf("poll option %q is repeated", o)
+		}
+		seen[o] = true
+	}
+	if selectable < 0 || selectable > len(options) {
+		return fmt.Errorf("selectable options must be 0 (any) to %d", len(options))
+	}
+	return nil
+}
+
+// Keep the question and options of a stored poll message
+func (w *Logger) storePoll(key store.MessageKey, creator string, m *waE2E.Message, at time.Time) {
+	question, options, selectable, ok := extract.Poll(m)
+	if !ok {
+		return
+	}
+	err := w.store.StorePoll(store.Poll{
+		ID:              key.ID,
+		ChatJID:         key.ChatJID,
+		Creator:         creator,
+		Question:        question,
+		Options:         options,
+		SelectableCount: selectable,
+		CreatedAt:       at,
+	})
+	if err != nil {
+		w.log.Warnf("Failed to store poll: %v", err)
+	}
+}
+
+// Decrypt and record a vote against the poll it is cast in, rather than
+// storing it as a message. Reports whether msg was one.
+func (w *Logger) handlePollVote(msg *events.Message) bool {
+	update := msg.Message.GetPollUpdateMessage()
+	if update == nil {
+		return false
+	}
+	if w.client == nil {
+		w.log.Debugf("Skipping poll vote %s: decrypting needs a session", msg.Info.ID)
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	vote, err := w.client.DecryptPollVote(ctx, msg)
+	if err != nil {
+		w.log.Warnf("Failed to decrypt poll vote %s: %v", msg.Info.ID, err)
+		return true
+	}
+	at := msg.Info.Timestamp
+	if ms := update.GetSenderTimestampMS(); ms > 0 {
+		at = time.UnixMilli(ms)
+	}
+	w.storePollVote(store.PollVote{
+		PollID:       update.GetPollCreationMessageKey().GetID(),
+		ChatJID:      msg.Info.Chat.String(),
+		Voter:        msg.Info.Sender.ToNonAD().String(),
+		OptionHashes: extract.VoteHashes(vote),
+		At:           at,
+	})
+	return true
+}
+
+// Record an encrypted vote found in a history sync conversation. Reports
+// whether msg was one.
+func (w *Logger) historyPollVote(msg *waWeb.WebMessageInfo, chat types.JID) bool {
+	if msg.GetMessage().GetPollUpdateMessage() == nil {
+		return false
+	}
+	if w.client == nil {
+		return true
+	}
+	evt, err := w.client.ParseWebMessage(chat, msg)
+	if err != nil {
+		w.log.Warnf("Failed to parse history poll vote: %v", err)
+		return true
+	}
+	return w.handlePollVote(evt)
+}
+
+// Record the votes history sync attaches, already decrypted, to a poll
+func (w *Logger) historyPollVotes(msg *waWeb.WebMessageInfo, chat types.JID) {
+	for _, u := range msg.GetPollUpdates() {
+		w.storePollVote(store.PollVote{
+			PollID:       msg.GetKey().GetID(),
+			ChatJID:      chat.String(),
+			Voter:        w.senderJID(u.GetPollUpdateMessageKey(), chat),
+			OptionHashes: extract.VoteHashes(u.GetVote()),
+			At:           time.UnixMilli(u.GetSenderTimestampMS()),
+		})
+	}
+}
+
+func (w *Logger) storePollVote(v store.PollVote) {
+	if v.PollID == "" {
+		return
+	}
+	if v.At.Unix() <= 0 {
+		v.At = time.Now()
+	}
+	if err := w.store.StorePollVote(v); err != nil {
+		w.log.Warnf("Failed to store poll vote: %v", err)
+	}
+}
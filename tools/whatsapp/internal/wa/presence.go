@@ -0,0 +1,126 @@
+package wa
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-logger/internal/store"
+)
+
+// Typing makes sent text look typed: the chat shows "typing…" for about as
+// long as the text would take to type, within Min and Max
+type Typing struct {
+	CharsPerSecond float64
+	Min, Max       time.Duration
+}
+
+// Delay before sending text: its typing time, varied by up to a fifth so
+// replies of the same length do not all take the same time
+func (t Typing) delay(text string) time.Duration {
+	d := time.Duration(float64(len([]rune(text))) / t.CharsPerSecond * float64(time.Second))
+	d = time.Duration(float64(d) * (0.8 + 0.4*rand.Float64()))
+	return min(max(d, t.Min), t.Max)
+}
+
+// Show "typing…" before each text message sent; nil sends at once
+func (w *Logger) SetTyping(t *Typing) {
+	w.typing = t
+}
+
+// Set the presence sent on every connect: "available", "unavailable", or ""
+// to leave it to WhatsApp
+func (w *Logger) SetPresence(presence string) error {
+	switch types.Presence(presence) {
+	case "", types.PresenceAvailable, types.PresenceUnavailable:
+		w.presence = types.Presence(presence)
+		return nil
+	}
+	return fmt.Errorf("invalid presence %q: use available or unavailable", presence)
+}
+
+// Send the configured presence, once connected
+func (w *Logger) sendPresence() {
+	if w.presence == "" || w.dryRun {
+		return
+	}
+	if err := w.client.SendPresence(w.presence); err != nil {
+		w.log.Warnf("Failed to set presence %s: %v", w.presence, err)
+	}
+}
+
+// Show "typing…" in chat for as long as text would take to type, returning
+// early if ctx ends
+func (w *Logger) simulateTyping(ctx context.Context, chat types.JID, text string) {
+	if w.typing == nil {
+		return
+	}
+	if err := w.client.SendChatPresence(chat, types.ChatPresenceComposing, types.ChatPresenceMediaText); err != nil {
+		w.log.Warnf("Failed to send typing indicator: %v", err)
+		return
+	}
+	timer := time.NewTimer(w.typing.delay(text))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	// The message itself clears the indicator, so pausing is only needed
+	// when it will not be sent
+	if ctx.Err() != nil {
+		w.client.SendChatPresence(chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+	}
+}
+
+// Log when these contacts, given as JIDs, come online and go offline
+func (w *Logger) SetPresenceLog(contacts []string) error {
+	w.watched = nil
+	for _, c := range contacts {
+		jid, err := types.ParseJID(c)
+		if err != nil || jid.User == "" {
+			return fmt.Errorf("invalid presence_log contact %q: use a JID or phone number", c)
+		}
+		w.watched = append(w.watched, jid.ToNonAD())
+	}
+	return nil
+}
+
+// Ask for presence updates of the watched contacts, once connected
+func (w *Logger) subscribePresence() {
+	if len(w.watched) == 0 {
+		return
+	}
+	if w.presence != types.PresenceAvailable {
+		w.log.Warnf("WhatsApp may send no presence updates unless sending.presence is \"available\"")
+	}
+	for _, jid := range w.watched {
+		if err := w.client.SubscribePresence(jid); err != nil {
+			w.log.Warnf("Failed to watch presence of %s: %v", jid, err)
+		}
+	}
+}
+
+// Log a watched contact coming online or going offline
+func (w *Logger) handlePresence(v *events.Presence) {
+	p := store.PresenceChange{JID: v.From.ToNonAD().String(), Online: !v.Unavailable, At: time.Now()}
+	if v.Unavailable && !v.LastSeen.IsZero() {
+		lastSeen := v.LastSeen
+		p.LastSeen = &lastSeen
+	}
+	changed, err := w.store.LogPresence(p)
+	if err != nil {
+		w.log.Warnf("Failed to log presence: %v", err)
+		return
+	}
+	if changed {
+		state := "offline"
+		if p.Online {
+			state = "online"
+		}
+		w.log.Infof("%s is %s", p.JID, state)
+	}
+}
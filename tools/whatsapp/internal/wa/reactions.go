@@ -0,0 +1,133 @@
+package wa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waWeb"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/store"
+)
+
+// Record a reaction against the message it targets rather than as a message
+// of its own. Reports whether m was one.
+func (w *Logger) handleReaction(info types.MessageInfo, m *waE2E.Message) bool {
+	target, emoji, ok := extract.Reaction(m)
+	if !ok {
+		return m.GetReactionMessage() != nil
+	}
+	w.storeReaction(store.Reaction{
+		MessageID: target,
+		ChatJID:   info.Chat.String(),
+		Reactor:   info.Sender.ToNonAD().String(),
+		Emoji:     emoji,
+		Timestamp: info.Timestamp,
+	})
+	return true
+}
+
+// Record a reaction message found in a history sync conversation. Reports
+// whether msg was one.
+func (w *Logger) historyReaction(msg *waWeb.WebMessageInfo, chat types.JID) bool {
+	target, emoji, ok := extract.Reaction(msg.GetMessage())
+	if !ok {
+		return msg.GetMessage().GetReactionMessage() != nil
+	}
+	w.storeReaction(store.Reaction{
+		MessageID: target,
+		ChatJID:   chat.String(),
+		Reactor:   w.senderJID(msg.GetKey(), chat),
+		Emoji:     emoji,
+		Timestamp: time.Unix(int64(msg.GetMessageTimestamp()), 0),
+	})
+	return true
+}
+
+// Record the reactions history sync attaches to a message it carries
+func (w *Logger) historyReactions(msg *waWeb.WebMessageInfo, chat types.JID) {
+	for _, r := range msg.GetReactions() {
+		w.storeReaction(store.Reaction{
+			MessageID: msg.GetKey().GetID(),
+			ChatJID:   chat.String(),
+			Reactor:   w.senderJID(r.GetKey(), chat),
+			Emoji:     r.GetText(),
+			Timestamp: time.UnixMilli(r.GetSenderTimestampMS()),
+		})
+	}
+}
+
+// The JID of whoever sent the history message with the given key in chat
+func (w *Logger) senderJID(key *waCommon.MessageKey, chat types.JID) string {
+	switch {
+	case key.GetFromMe():
+		return types.NewJID(w.ownUser(), types.DefaultUserServer).String()
+	case key.GetParticipant() != "":
+		if jid, err := types.ParseJID(key.GetParticipant()); err == nil {
+			return jid.ToNonAD().String()
+		}
+		return key.GetParticipant()
+	default:
+		return chat.ToNonAD().String()
+	}
+}
+
+// React to a stored message with emoji, or take my reaction back when emoji
+// is empty, and store the reaction as mine
+func (w *Logger) SendReaction(ctx context.Context, key store.MessageKey, emoji string) error {
+	m, err := w.store.GetMessage(key)
+	if err != nil {
+		return err
+	}
+	chat, err := types.ParseJID(key.ChatJID)
+	if err != nil {
+		return fmt.Errorf("invalid chat JID %q: %w", key.ChatJID, err)
+	}
+	if w.skipSend(key.ChatJID, fmt.Sprintf("reaction %q to %s", emoji, key.ID)) {
+		return nil
+	}
+	if w.client == nil || !w.client.IsConnected() {
+		return fmt.Errorf("cannot send reaction: %w", ErrNotConnected)
+	}
+
+	sender := *w.client.Store.ID
+	if !m.IsFromMe {
+		if sender, err = userJID(m.Sender); err != nil {
+			return fmt.Errorf("invalid sender of %s: %w", key.ID, err)
+		}
+	}
+	resp, err := w.client.SendMessage(ctx, chat, w.client.BuildReaction(chat, sender, key.ID, emoji))
+	if err != nil {
+		return fmt.Errorf("failed to send reaction: %w", err)
+	}
+	w.storeReaction(store.Reaction{
+		MessageID: key.ID,
+		ChatJID:   key.ChatJID,
+		Reactor:   w.client.Store.ID.ToNonAD().String(),
+		Emoji:     emoji,
+		Timestamp: resp.Timestamp,
+	})
+	return nil
+}
+
+// Parse a stored sender, which older history rows keep as a bare user
+func userJID(sender string) (types.JID, error) {
+	if !strings.Contains(sender, "@") {
+		return types.NewJID(sender, types.DefaultUserServer), nil
+	}
+	return types.ParseJID(sender)
+}
+
+func (w *Logger) storeReaction(r store.Reaction) {
+	if r.Timestamp.Unix() <= 0 {
+		r.Timestamp = time.Now()
+	}
+	if err := w.store.StoreReaction(r); err != nil {
+		w.log.Warnf("Failed to store reaction: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package wa
+
+import (
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Record when a recipient got or read messages I sent. Receipts from my
+// other devices, and for messages others sent, are not tracked.
+func (w *Logger) handleReceipt(v *events.Receipt) {
+	if v.IsFromMe {
+		return
+	}
+	var read bool
+	switch v.Type {
+	case types.ReceiptTypeDelivered:
+	case types.ReceiptTypeRead, types.ReceiptTypePlayed:
+		read = true
+	default:
+		return
+	}
+	at := v.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	chat, recipient := v.Chat.String(), v.Sender.ToNonAD().String()
+	if err := w.store.StoreReceipt(chat, recipient, v.MessageIDs, at, read); err != nil {
+		w.log.Warnf("Failed to store receipt: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package wa
+
+import (
+	"fmt"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/store"
+)
+
+// Run extraction again over the raw payloads of stored messages in chatJID
+// and the chats linked to it, or in all chats when empty, so messages
+// logged before an extractor learned something pick it up. Returns how many
+// messages were reprocessed. Hooks don't run, as for history sync.
+func (w *Logger) Reprocess(chatJID string) (int, error) {
+	n := 0
+	err := w.store.ForEachRawMessage(chatJID, func(m store.Message, data []byte) error {
+		var msg waE2E.Message
+		if err := proto.Unmarshal(data, &msg); err != nil {
+			w.log.Warnf("Failed to decode raw message %s in %s: %v", m.ID, m.ChatJID, err)
+			return nil
+		}
+		key := store.MessageKey{ID: m.ID, ChatJID: m.ChatJID}
+		content, mediaType, filename := extract.Content(&msg)
+		if err := w.store.UpdateExtracted(key, content, mediaType, filename); err != nil {
+			return fmt.Errorf("failed to update %s in %s: %w", m.ID, m.ChatJID, err)
+		}
+		w.storeLinkPreview(&msg)
+		w.storeLinks(key, &msg)
+		w.storeReply(key, &msg)
+		w.storeForwarded(key, &msg)
+		w.storeMentions(key, &msg)
+		w.storeInteractive(key, &msg)
+		creator := m.Sender
+		if jid, err := types.ParseJID(m.Sender); err == nil {
+			creator = jid.ToNonAD().String()
+		}
+		w.storePoll(key, creator, &msg, m.Timestamp)
+		w.storeLocation(key, &msg)
+		w.storeSharedContacts(key, &msg)
+		w.storeGroupInvite(key, &msg)
+		if attachment, ok := extract.Media(&msg); ok {
+			attachment.ViewOnce = attachment.ViewOnce || m.IsViewOnce
+			if err := w.store.StoreMedia(key, attachment); err != nil {
+				w.log.Warnf("Failed to store media metadata: %v", err)
+			}
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,267 @@
+package wa
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-logger/internal/extract"
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/store"
+)
+
+// Log outbound messages instead of sending them, for developing automations
+// against a real account
+func (w *Logger) SetDryRun(on bool) {
+	w.dryRun = on
+}
+
+// In dry-run mode, log what would be sent and report true
+func (w *Logger) skipSend(chatJID, text string, attachments ...string) bool {
+	if !w.dryRun {
+		return false
+	}
+	w.log.Infof("[dry-run] Would send to %s: %q", chatJID, text)
+	for _, a := range attachments {
+		w.log.Infof("[dry-run]   with attachment %s", a)
+	}
+	return true
+}
+
+// Send a text message to chatJID and store it like any other of mine
+func (w *Logger) SendText(ctx context.Context, chatJID, text string) error {
+	jid, err := types.ParseJID(chatJID)
+	if err != nil {
+		return fmt.Errorf("invalid chat JID %q: %w", chatJID, err)
+	}
+	if w.skipSend(chatJID, text) {
+		return nil
+	}
+	if w.client == nil || !w.client.IsConnected() {
+		return fmt.Errorf("cannot send message: %w", ErrNotConnected)
+	}
+	w.simulateTyping(ctx, jid, text)
+	msg := &waE2E.Message{Conversation: proto.String(text)}
+	resp, err := w.client.SendMessage(ctx, jid, msg)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	w.storeSent(chatJID, resp, msg)
+	return nil
+}
+
+// Upload the file data named name and send it to chatJID with an optional
+// caption, storing it like any other of mine. Images, videos and audio go
+// out as such, anything else as a document; the type comes from the file
+// name, else its content.
+func (w *Logger) SendMedia(ctx context.Context, chatJID, name string, data []byte, caption string) error {
+	jid, err := types.ParseJID(chatJID)
+	if err != nil {
+		return fmt.Errorf("invalid chat JID %q: %w", chatJID, err)
+	}
+	mimeType := fileMimeType(name, data)
+	if w.skipSend(chatJID, caption, fmt.Sprintf("%s (%s, %d bytes)", name, mimeType, len(data))) {
+		return nil
+	}
+	if w.client == nil || !w.client.IsConnected() {
+		return fmt.Errorf("cannot send message: %w", ErrNotConnected)
+	}
+
+	kind := mediaKind(mimeType)
+	up, err := w.client.Upload(ctx, data, kind)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	msg := &waE2E.Message{}
+	switch kind {
+	case whatsmeow.MediaImage:
+		msg.ImageMessage = &waE2E.ImageMessage{URL: &up.URL, DirectPath: &up.DirectPath, MediaKey: up.MediaKey,
+			FileSHA256: up.FileSHA256, FileEncSHA256: up.FileEncSHA256, FileLength: &up.FileLength,
+			Mimetype: &mimeType, Caption: optional(caption)}
+	case whatsmeow.MediaVideo:
+		msg.VideoMessage = &waE2E.VideoMessage{URL: &up.URL, DirectPath: &up.DirectPath, MediaKey: up.MediaKey,
+			FileSHA256: up.FileSHA256, FileEncSHA256: up.FileEncSHA256, FileLength: &up.FileLength,
+			Mimetype: &mimeType, Caption: optional(caption)}
+	case whatsmeow.MediaAudio:
+		// Audio messages carry no caption; it follows as its own message
+		msg.AudioMessage = &waE2E.AudioMessage{URL: &up.URL, DirectPath: &up.DirectPath, MediaKey: up.MediaKey,
+			FileSHA256: up.FileSHA256, FileEncSHA256: up.FileEncSHA256, FileLength: &up.FileLength,
+			Mimetype: &mimeType}
+	default:
+		name := filepath.Base(name)
+		msg.DocumentMessage = &waE2E.DocumentMessage{URL: &up.URL, DirectPath: &up.DirectPath, MediaKey: up.MediaKey,
+			FileSHA256: up.FileSHA256, FileEncSHA256: up.FileEncSHA256, FileLength: &up.FileLength,
+			Mimetype: &mimeType, FileName: &name, Title: &name, Caption: optional(caption)}
+	}
+
+	resp, err := w.client.SendMessage(ctx, jid, msg)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	w.storeSent(chatJID, resp, msg)
+	w.keepSentFile(ctx, store.MessageKey{ID: resp.ID, ChatJID: chatJID}, data, mimeType, kind)
+
+	if kind == whatsmeow.MediaAudio && caption != "" {
+		return w.SendText(ctx, chatJID, caption)
+	}
+	return nil
+}
+
+// Received messages marked read at once by MarkRead
+const markReadLimit = 100
+
+// Send read receipts for the messages received in chatJID since I last
+// sent one there, so the chat stops showing as unread on my phone.
+// Returns how many messages were marked.
+func (w *Logger) MarkRead(chatJID string) (int, error) {
+	chat, err := types.ParseJID(chatJID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chat JID %q: %w", chatJID, err)
+	}
+	unread, err := w.store.UnreadMessages(chatJID, markReadLimit)
+	if err != nil {
+		return 0, err
+	}
+	if len(unread) == 0 {
+		return 0, nil
+	}
+	if w.dryRun {
+		w.log.Infof("[dry-run] Would mark %d messages read in %s", len(unread), chatJID)
+		return len(unread), nil
+	}
+	if w.client == nil || !w.client.IsConnected() {
+		return 0, fmt.Errorf("cannot send read receipts: %w", ErrNotConnected)
+	}
+
+	// Receipts name one sender each, which only matters in groups
+	bySender := map[string][]types.MessageID{}
+	for _, m := range unread {
+		bySender[m.Sender] = append(bySender[m.Sender], m.ID)
+	}
+	for sender, ids := range bySender {
+		var from types.JID
+		if chat.Server == types.GroupServer {
+			if from, err = userJID(sender); err != nil {
+				return 0, fmt.Errorf("invalid sender %q: %w", sender, err)
+			}
+		}
+		if err := w.client.MarkRead(ids, time.Now(), chat, from); err != nil {
+			return 0, fmt.Errorf("failed to send read receipts: %w", err)
+		}
+	}
+	return len(unread), nil
+}
+
+// Store a message I sent, with its attachment metadata. Our own sends do
+// not come back as message events.
+func (w *Logger) storeSent(chatJID string, resp whatsmeow.SendResponse, msg *waE2E.Message) {
+	if err := w.store.StoreChat(chatJID, chatJID, resp.Timestamp); err != nil {
+		w.log.Warnf("Failed to update chat: %v", err)
+	}
+	content, mediaType, filename := extract.Content(msg)
+	sender := w.client.Store.ID.ToNonAD().String()
+	if err := w.store.StoreMessage(resp.ID, chatJID, sender, content, resp.Timestamp, true, mediaType, filename, ""); err != nil {
+		w.log.Warnf("Failed to store sent message: %v", err)
+		return
+	}
+	w.storeRaw(store.MessageKey{ID: resp.ID, ChatJID: chatJID}, msg)
+	if attachment, ok := extract.Media(msg); ok {
+		if err := w.store.StoreMedia(store.MessageKey{ID: resp.ID, ChatJID: chatJID}, attachment); err != nil {
+			w.log.Warnf("Failed to store media metadata: %v", err)
+		}
+	}
+}
+
+// Save a sent file to media storage as if downloaded, when storage is set
+func (w *Logger) keepSentFile(ctx context.Context, key store.MessageKey, data []byte, mimeType string, kind whatsmeow.MediaType) {
+	storage := w.mediaStorage()
+	if storage == nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	b := store.MediaBlob{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data)), MimeType: mimeType}
+	m := store.Message{MimeType: mimeType, MediaType: mediaTypes[kind]}
+	var err error
+	if b.Location, err = storage.Put(ctx, media.BlobKey(b.SHA256, media.FileExtension(m)), data, mimeType); err == nil {
+		err = w.store.SetMediaFile(key, b)
+	}
+	if err != nil {
+		w.log.Warnf("Failed to keep sent file: %v", err)
+	}
+}
+
+// Stored media types of the kinds of upload
+var mediaTypes = map[whatsmeow.MediaType]string{
+	whatsmeow.MediaImage:    "image",
+	whatsmeow.MediaVideo:    "video",
+	whatsmeow.MediaAudio:    "audio",
+	whatsmeow.MediaDocument: "document",
+}
+
+// The MIME type of a file from its extension, else sniffed from its content
+func fileMimeType(name string, data []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
+
+// How a file of mimeType is uploaded and sent
+func mediaKind(mimeType string) whatsmeow.MediaType {
+	switch strings.SplitN(mimeType, "/", 2)[0] {
+	case "image":
+		return whatsmeow.MediaImage
+	case "video":
+		return whatsmeow.MediaVideo
+	case "audio":
+		return whatsmeow.MediaAudio
+	}
+	return whatsmeow.MediaDocument
+}
+
+// A proto string field, unset when s is empty
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// JID of the paired account's "message yourself" chat, or "" when not paired
+func (w *Logger) SelfChatJID() string {
+	if user := w.ownUser(); user != "" {
+		return user + "@" + types.DefaultUserServer
+	}
+	return ""
+}
+
+// Whether chatJID is the "message yourself" chat of the paired account
+func (w *Logger) IsSelfChat(chatJID string) bool {
+	jid, err := types.ParseJID(chatJID)
+	return err == nil && w.isOwn(jid)
+}
+
+// Whether jid is the paired account, by phone number or LID
+func (w *Logger) isOwn(jid types.JID) bool {
+	if jid.User == "" {
+		return false
+	}
+	switch jid.Server {
+	case types.DefaultUserServer:
+		return jid.User == w.ownUser()
+	case types.HiddenUserServer:
+		return w.client != nil && jid.User == w.client.Store.GetLID().User
+	}
+	return false
+}
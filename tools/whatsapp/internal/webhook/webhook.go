@@ -0,0 +1,213 @@
+// Package webhook POSTs live messages as JSON to configured HTTP endpoints,
+// so other services can react to them as they arrive.
+//
+// Each request carries the headers
+//
+//	X-Kenny-Event      "message"
+//	X-Kenny-Delivery   an ID that stays the same across retries
+//	X-Kenny-Signature  "sha256=" and the hex HMAC-SHA256 of the body, keyed
+//	                   with the endpoint's secret, when it has one
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-logger/internal/config"
+	"whatsapp-logger/internal/store"
+)
+
+const (
+	// Messages waiting per endpoint before new ones are dropped
+	queueSize = 1000
+	// How long one request may take
+	requestTimeout = 15 * time.Second
+	// Wait before the first retry, doubling up to maxBackoff
+	firstBackoff = 2 * time.Second
+	maxBackoff   = 5 * time.Minute
+)
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	Event    string        `json:"event"`
+	Delivery string        `json:"delivery"`
+	Message  store.Message `json:"message"`
+}
+
+// Dispatcher queues live messages for each endpoint whose matcher selects
+// them and delivers them in the background
+type Dispatcher struct {
+	endpoints []*endpoint
+	log       waLog.Logger
+}
+
+type endpoint struct {
+	cfg    config.Webhook
+	queue  chan Payload
+	client *http.Client
+}
+
+// Create a dispatcher for the configured endpoints
+func New(cfg config.Webhooks, log waLog.Logger) (*Dispatcher, error) {
+	d := &Dispatcher{log: log}
+	for _, wh := range cfg.Endpoints {
+		u, err := url.Parse(wh.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook %q: url must be an http or https URL", name(wh))
+		}
+		d.endpoints = append(d.endpoints, &endpoint{
+			cfg:    wh,
+			queue:  make(chan Payload, queueSize),
+			client: &http.Client{Timeout: requestTimeout},
+		})
+	}
+	return d, nil
+}
+
+func name(wh config.Webhook) string {
+	if wh.Name != "" {
+		return wh.Name
+	}
+	return wh.URL
+}
+
+// Message hook: queue msg for every endpoint that wants it, never waiting
+// on delivery
+func (d *Dispatcher) HandleMessage(msg store.Message) {
+	for _, e := range d.endpoints {
+		if !e.cfg.Match(msg) {
+			continue
+		}
+		select {
+		case e.queue <- Payload{Event: "message", Delivery: deliveryID(), Message: msg}:
+		default:
+			d.log.Warnf("Webhook %s is %d messages behind, dropping %s", name(e.cfg), queueSize, msg.ID)
+		}
+	}
+}
+
+func deliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Deliver queued messages until ctx is cancelled, one at a time per
+// endpoint so each sees them in order
+func (d *Dispatcher) Run(ctx context.Context) {
+	done := make(chan struct{})
+	for _, e := range d.endpoints {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case p := <-e.queue:
+					d.deliver(ctx, e, p)
+				}
+			}
+		}()
+	}
+	for range d.endpoints {
+		<-done
+	}
+}
+
+// POST p to e, retrying with backoff until it is accepted, a retry could
+// not help or the attempts run out
+func (d *Dispatcher) deliver(ctx context.Context, e *endpoint, p Payload) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		d.log.Errorf("Failed to encode message %s for webhook %s: %v", p.Message.ID, name(e.cfg), err)
+		return
+	}
+	backoff := firstBackoff
+	for attempt := 1; ; attempt++ {
+		wait, err := e.post(ctx, p.Delivery, body)
+		if err == nil {
+			return
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt >= e.cfg.MaxAttempts {
+			d.log.Warnf("Webhook %s failed for message %s after %d attempts: %v", name(e.cfg), p.Message.ID, attempt, err)
+			return
+		}
+		if wait == 0 {
+			wait = backoff
+			backoff = min(2*backoff, maxBackoff)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// A response retrying won't change, like 400 or 404
+type permanentError struct {
+	status int
+}
+
+func (e permanentError) Error() string {
+	return fmt.Sprintf("endpoint answered %d", e.status)
+}
+
+// Send one attempt. On failure also returns how long the endpoint asked to
+// wait with Retry-After, or 0.
+func (e *endpoint) post(ctx context.Context, delivery string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", e.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kenny-whatsapp")
+	req.Header.Set("X-Kenny-Event", "message")
+	req.Header.Set("X-Kenny-Delivery", delivery)
+	if e.cfg.Secret != "" {
+		req.Header.Set("X-Kenny-Signature", Sign(e.cfg.Secret, body))
+	}
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return min(time.Duration(seconds)*time.Second, maxBackoff), fmt.Errorf("endpoint answered %d", resp.StatusCode)
+	default:
+		return 0, permanentError{resp.StatusCode}
+	}
+}
+
+// The X-Kenny-Signature value for body: "sha256=" and the hex HMAC-SHA256
+// of body keyed with secret. Receivers compute it over the raw body and
+// compare in constant time.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,178 @@
+// Package whois gathers everything the archive knows about one contact.
+package whois
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-logger/internal/media"
+	"whatsapp-logger/internal/phone"
+	"whatsapp-logger/internal/reconcile"
+	"whatsapp-logger/internal/store"
+)
+
+// Nothing in the archive mentions the contact
+var ErrUnknown = errors.New("nothing known about contact")
+
+// What the archive knows about a contact
+type Profile struct {
+	JID string `json:"jid"`
+	// International phone number, for phone-number JIDs
+	Phone string `json:"phone,omitempty"`
+	// Name of the direct chat
+	ChatName string `json:"chat_name,omitempty"`
+	// Push names the contact has used, oldest first
+	Names []store.ContactName `json:"names"`
+	// Other JIDs of the direct chat, linked with link-chats
+	LinkedJIDs []string `json:"linked_jids,omitempty"`
+	// Messages the contact sent in any chat
+	Messages     int       `json:"messages"`
+	FirstMessage time.Time `json:"first_message"`
+	LastMessage  time.Time `json:"last_message"`
+	Direct       *Activity `json:"direct,omitempty"`
+	// Groups the contact has written in, most active first
+	Groups []Activity `json:"groups"`
+	// Saved profile picture, when there is one
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// The contact's messages in one chat
+type Activity struct {
+	JID  string `json:"jid"`
+	Name string `json:"name,omitempty"`
+	// Messages from the contact, and in a direct chat from me
+	Theirs int       `json:"theirs"`
+	Mine   int       `json:"mine,omitempty"`
+	First  time.Time `json:"first"`
+	Last   time.Time `json:"last"`
+}
+
+// Turn a JID or phone number, read in region when it has no country code,
+// into the contact's canonical JID
+func Resolve(query, region string) (types.JID, error) {
+	query = strings.TrimSpace(query)
+	if strings.Contains(query, "@") {
+		jid, err := types.ParseJID(reconcile.Canonical(query))
+		if err != nil || jid.User == "" {
+			return types.JID{}, fmt.Errorf("invalid JID %q", query)
+		}
+		return jid, nil
+	}
+	e164, err := phone.Normalize(query, region)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("%q is neither a JID nor a phone number", query)
+	}
+	return types.ParseJID(phone.JID(e164))
+}
+
+// Build the profile of the contact query names, reading phone numbers in
+// region and looking for a saved profile picture under mediaDir
+func Lookup(st store.Store, query, region, mediaDir string) (*Profile, error) {
+	jid, err := Resolve(query, region)
+	if err != nil {
+		return nil, err
+	}
+	p := &Profile{JID: jid.String(), Phone: phone.FromJID(jid.String()), Groups: []Activity{}}
+
+	if p.Names, err = st.ContactNames(p.JID); err != nil {
+		return nil, err
+	}
+	if p.Names == nil {
+		p.Names = []store.ContactName{}
+	}
+	group, err := st.ChatGroup(p.JID)
+	if err != nil {
+		return nil, err
+	}
+	direct := map[string]bool{}
+	for _, j := range group {
+		direct[j] = true
+		if j != p.JID {
+			p.LinkedJIDs = append(p.LinkedJIDs, j)
+		}
+	}
+
+	groups := map[string]*Activity{}
+	err = st.ForEachMessage(time.Time{}, time.Time{}, func(m store.Message) error {
+		theirs := !m.IsFromMe && (userOf(m.Sender) == jid.User || p.Phone != "" && m.SenderPhone == p.Phone)
+		if direct[m.ChatJID] {
+			if p.Direct == nil {
+				p.Direct = &Activity{JID: p.JID, First: m.Timestamp}
+			}
+			count(p.Direct, m, theirs)
+			if p.ChatName == "" || m.ChatJID == p.JID {
+				p.ChatName = m.ChatName
+			}
+		} else if theirs {
+			a := groups[m.ChatJID]
+			if a == nil {
+				a = &Activity{JID: m.ChatJID, Name: m.ChatName, First: m.Timestamp}
+				groups[m.ChatJID] = a
+			}
+			count(a, m, true)
+		}
+		if theirs {
+			if p.Messages == 0 {
+				p.FirstMessage = m.Timestamp
+			}
+			p.Messages++
+			p.LastMessage = m.Timestamp
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range groups {
+		p.Groups = append(p.Groups, *a)
+	}
+	sort.Slice(p.Groups, func(i, j int) bool {
+		if p.Groups[i].Theirs != p.Groups[j].Theirs {
+			return p.Groups[i].Theirs > p.Groups[j].Theirs
+		}
+		return p.Groups[i].JID < p.Groups[j].JID
+	})
+
+	if mediaDir != "" {
+		if path := media.AvatarPath(mediaDir, jid.User); fileExists(path) {
+			p.Avatar = path
+		}
+	}
+	if len(p.Names) == 0 && p.Direct == nil && p.Messages == 0 && p.Avatar == "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnknown, p.JID)
+	}
+	return p, nil
+}
+
+// Add m to a chat's activity
+func count(a *Activity, m store.Message, theirs bool) {
+	switch {
+	case theirs:
+		a.Theirs++
+	case m.IsFromMe:
+		a.Mine++
+	default:
+		return
+	}
+	a.Last = m.Timestamp
+}
+
+// User part of a JID, without device suffix; history-synced senders may
+// be stored as the bare user already
+func userOf(jid string) string {
+	user, _, _ := strings.Cut(jid, "@")
+	user, _, _ = strings.Cut(user, ":")
+	return user
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}